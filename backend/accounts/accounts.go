@@ -0,0 +1,288 @@
+// Package accounts stores the social media accounts users have linked, with their access
+// tokens encrypted at rest, and the authorizations that are still waiting for the user's approval.
+package accounts
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/secret"
+	"github.com/Tattsum/enjo/backend/store"
+)
+
+const (
+	// bucketAccounts is the store bucket holding linked accounts
+	bucketAccounts = "linked_accounts"
+	// bucketPending is the store bucket holding authorizations in progress
+	bucketPending = "pending_authorizations"
+	// PendingTTL is how long a user has to approve an authorization
+	PendingTTL = 15 * time.Minute
+)
+
+// Platform identifies a social network
+type Platform string
+
+// PlatformTwitter is Twitter / X
+const PlatformTwitter Platform = "twitter"
+
+var (
+	// ErrNotFound is returned when the user has not linked an account
+	ErrNotFound = errors.New("linked account not found")
+	// ErrPendingNotFound is returned for unknown or expired authorizations
+	ErrPendingNotFound = errors.New("authorization not found or expired")
+	// ErrStateMismatch is returned when an authorization is completed with a state other than the
+	// one it was started with, i.e. from a browser other than the one that started it
+	ErrStateMismatch = errors.New("authorization state does not match")
+	// ErrOwnerRequired is returned when linking or unlinking an account without an owner.
+	// Anonymous users share the account configured at startup and cannot replace it.
+	ErrOwnerRequired = errors.New("linked accounts require an authenticated owner")
+)
+
+// Account is a linked account with its decrypted access token
+type Account struct {
+	OwnerID           string
+	Platform          Platform
+	UserID            string // Account ID on the platform
+	ScreenName        string
+	AccessToken       string
+	AccessTokenSecret string
+	ConnectedAt       time.Time
+}
+
+// record is the stored form of an Account. The tokens are only kept encrypted.
+type record struct {
+	OwnerID     string    `json:"ownerId"`
+	Platform    Platform  `json:"platform"`
+	UserID      string    `json:"userId"`
+	ScreenName  string    `json:"screenName"`
+	Tokens      []byte    `json:"tokens"`
+	ConnectedAt time.Time `json:"connectedAt"`
+}
+
+// tokens is the plaintext sealed into record.Tokens
+type tokens struct {
+	AccessToken       string `json:"accessToken"`
+	AccessTokenSecret string `json:"accessTokenSecret"`
+}
+
+// Pending is an authorization started by a user and not yet approved on the platform
+type Pending struct {
+	RequestToken  string
+	RequestSecret string
+	State         string // Random value kept by the user's browser; required to complete the authorization
+	OwnerID       string
+	Platform      Platform
+	CreatedAt     time.Time
+}
+
+// pendingRecord is the stored form of a Pending authorization
+type pendingRecord struct {
+	OwnerID       string    `json:"ownerId"`
+	Platform      Platform  `json:"platform"`
+	RequestSecret []byte    `json:"requestSecret"` // Sealed with the request token as associated data
+	StateHash     []byte    `json:"stateHash"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// Store persists linked accounts in the embedded store
+type Store struct {
+	db  *store.DB
+	box *secret.Box
+	now func() time.Time
+}
+
+// Option is a functional option for the store
+type Option func(*Store)
+
+// WithClock overrides the clock used for timestamps and expiry (useful for testing)
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) {
+		s.now = now
+	}
+}
+
+// New creates an account store backed by db that encrypts tokens with box
+func New(db *store.DB, box *secret.Box, options ...Option) *Store {
+	s := &Store{db: db, box: box, now: time.Now}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// Save links an account to its owner, replacing any account linked before on the same platform
+func (s *Store) Save(_ context.Context, account Account) (*Account, error) {
+	if account.OwnerID == "" {
+		return nil, ErrOwnerRequired
+	}
+	plaintext, err := json.Marshal(tokens{AccessToken: account.AccessToken, AccessTokenSecret: account.AccessTokenSecret})
+	if err != nil {
+		return nil, err
+	}
+	key := accountKey(account.OwnerID, account.Platform)
+	sealed, err := s.box.Seal(plaintext, []byte(key))
+	if err != nil {
+		return nil, err
+	}
+
+	account.ConnectedAt = s.now()
+	rec := record{
+		OwnerID:     account.OwnerID,
+		Platform:    account.Platform,
+		UserID:      account.UserID,
+		ScreenName:  account.ScreenName,
+		Tokens:      sealed,
+		ConnectedAt: account.ConnectedAt,
+	}
+	if err := s.db.Put(bucketAccounts, key, &rec); err != nil {
+		return nil, fmt.Errorf("failed to store linked account: %w", err)
+	}
+	return &account, nil
+}
+
+// Get returns the account the owner linked on platform
+func (s *Store) Get(_ context.Context, ownerID string, platform Platform) (*Account, error) {
+	if ownerID == "" {
+		return nil, ErrNotFound
+	}
+	key := accountKey(ownerID, platform)
+	var rec record
+	if err := s.db.Get(bucketAccounts, key, &rec); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	plaintext, err := s.box.Open(rec.Tokens, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt linked account: %w", err)
+	}
+	var t tokens
+	if err := json.Unmarshal(plaintext, &t); err != nil {
+		return nil, fmt.Errorf("failed to decode linked account: %w", err)
+	}
+
+	return &Account{
+		OwnerID:           rec.OwnerID,
+		Platform:          rec.Platform,
+		UserID:            rec.UserID,
+		ScreenName:        rec.ScreenName,
+		AccessToken:       t.AccessToken,
+		AccessTokenSecret: t.AccessTokenSecret,
+		ConnectedAt:       rec.ConnectedAt,
+	}, nil
+}
+
+// Delete unlinks the owner's account on platform. Deleting an unlinked account is not an error.
+func (s *Store) Delete(_ context.Context, ownerID string, platform Platform) error {
+	if ownerID == "" {
+		return ErrOwnerRequired
+	}
+	return s.db.Delete(bucketAccounts, accountKey(ownerID, platform))
+}
+
+// SavePending records an authorization until the user approves it or PendingTTL passes
+func (s *Store) SavePending(_ context.Context, pending Pending) error {
+	if pending.RequestToken == "" {
+		return errors.New("request token is required")
+	}
+	if pending.OwnerID == "" {
+		return ErrOwnerRequired
+	}
+	if pending.State == "" {
+		return errors.New("state is required")
+	}
+	sealed, err := s.box.Seal([]byte(pending.RequestSecret), []byte(pending.RequestToken))
+	if err != nil {
+		return err
+	}
+	if err := s.prunePending(); err != nil {
+		return err
+	}
+
+	rec := pendingRecord{
+		OwnerID:       pending.OwnerID,
+		Platform:      pending.Platform,
+		RequestSecret: sealed,
+		StateHash:     hashState(pending.State),
+		CreatedAt:     s.now(),
+	}
+	if err := s.db.Put(bucketPending, pending.RequestToken, &rec); err != nil {
+		return fmt.Errorf("failed to store authorization: %w", err)
+	}
+	return nil
+}
+
+// prunePending removes authorizations that expired without a callback, which would otherwise
+// be kept forever when users abandon the approval page
+func (s *Store) prunePending() error {
+	now := s.now()
+	_, err := s.db.DeleteMatching(bucketPending, func(_ string, raw []byte) (bool, error) {
+		var rec pendingRecord
+		if err := json.Unmarshal(raw, &rec); err != nil {
+			return false, fmt.Errorf("failed to decode authorization: %w", err)
+		}
+		return now.Sub(rec.CreatedAt) > PendingTTL, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to prune expired authorizations: %w", err)
+	}
+	return nil
+}
+
+// TakePending returns and removes the authorization started with requestToken, so that each
+// authorization completes at most once. state must be the one the authorization was started with;
+// otherwise ErrStateMismatch is returned and the authorization is left in place.
+func (s *Store) TakePending(_ context.Context, requestToken, state string) (*Pending, error) {
+	if requestToken == "" {
+		return nil, ErrPendingNotFound
+	}
+	var rec pendingRecord
+	err := s.db.Take(bucketPending, requestToken, &rec, func() error {
+		if subtle.ConstantTimeCompare(hashState(state), rec.StateHash) != 1 {
+			return ErrStateMismatch
+		}
+		return nil
+	})
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		return nil, ErrPendingNotFound
+	case errors.Is(err, ErrStateMismatch):
+		return nil, ErrStateMismatch
+	case err != nil:
+		return nil, fmt.Errorf("failed to take authorization: %w", err)
+	}
+	if s.now().Sub(rec.CreatedAt) > PendingTTL {
+		return nil, ErrPendingNotFound
+	}
+
+	secretValue, err := s.box.Open(rec.RequestSecret, []byte(requestToken))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt authorization: %w", err)
+	}
+	return &Pending{
+		RequestToken:  requestToken,
+		RequestSecret: string(secretValue),
+		State:         state,
+		OwnerID:       rec.OwnerID,
+		Platform:      rec.Platform,
+		CreatedAt:     rec.CreatedAt,
+	}, nil
+}
+
+// hashState returns the digest stored in place of an authorization state
+func hashState(state string) []byte {
+	sum := sha256.Sum256([]byte(state))
+	return sum[:]
+}
+
+// accountKey is the store key of an owner's account on a platform
+func accountKey(ownerID string, platform Platform) string {
+	return string(platform) + "/" + ownerID
+}
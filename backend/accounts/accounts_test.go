@@ -0,0 +1,197 @@
+package accounts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/secret"
+	"github.com/Tattsum/enjo/backend/store"
+)
+
+func newTestStore(t *testing.T, now *time.Time) (*Store, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := store.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	box, err := secret.New(bytes.Repeat([]byte{1}, secret.KeySize))
+	if err != nil {
+		t.Fatalf("secret.New() error = %v", err)
+	}
+	return New(db, box, WithClock(func() time.Time { return *now })), path
+}
+
+func TestStore_Accounts(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s, path := newTestStore(t, &now)
+	ctx := context.Background()
+
+	if _, err := s.Get(ctx, "alice", PlatformTwitter); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() error = %v, want ErrNotFound", err)
+	}
+
+	saved, err := s.Save(ctx, Account{
+		OwnerID: "alice", Platform: PlatformTwitter, UserID: "42", ScreenName: "enjo",
+		AccessToken: "alice-access-token", AccessTokenSecret: "alice-access-secret",
+	})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if !saved.ConnectedAt.Equal(now) {
+		t.Errorf("ConnectedAt = %v, want %v", saved.ConnectedAt, now)
+	}
+
+	got, err := s.Get(ctx, "alice", PlatformTwitter)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.AccessToken != "alice-access-token" || got.AccessTokenSecret != "alice-access-secret" || got.ScreenName != "enjo" {
+		t.Errorf("Get() = %+v", got)
+	}
+	if _, err := s.Get(ctx, "bob", PlatformTwitter); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() for another owner error = %v, want ErrNotFound", err)
+	}
+
+	// Tokens are never written in plaintext
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read database: %v", err)
+	}
+	if bytes.Contains(data, []byte("alice-access")) {
+		t.Error("database file contains the access token in plaintext")
+	}
+
+	if err := s.Delete(ctx, "alice", PlatformTwitter); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := s.Get(ctx, "alice", PlatformTwitter); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_Pending(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s, _ := newTestStore(t, &now)
+	ctx := context.Background()
+
+	pending := Pending{RequestToken: "token", RequestSecret: "secret", State: "state", OwnerID: "alice", Platform: PlatformTwitter}
+	if err := s.SavePending(ctx, pending); err != nil {
+		t.Fatalf("SavePending() error = %v", err)
+	}
+	got, err := s.TakePending(ctx, "token", "state")
+	if err != nil {
+		t.Fatalf("TakePending() error = %v", err)
+	}
+	if got.RequestSecret != "secret" || got.OwnerID != "alice" {
+		t.Errorf("TakePending() = %+v", got)
+	}
+	if _, err := s.TakePending(ctx, "token", "state"); !errors.Is(err, ErrPendingNotFound) {
+		t.Errorf("second TakePending() error = %v, want ErrPendingNotFound", err)
+	}
+
+	// A different state, e.g. from another browser, leaves the authorization to the one that started it
+	if err := s.SavePending(ctx, pending); err != nil {
+		t.Fatalf("SavePending() error = %v", err)
+	}
+	for _, state := range []string{"", "other"} {
+		if _, err := s.TakePending(ctx, "token", state); !errors.Is(err, ErrStateMismatch) {
+			t.Errorf("TakePending(%q) error = %v, want ErrStateMismatch", state, err)
+		}
+	}
+	if _, err := s.TakePending(ctx, "token", "state"); err != nil {
+		t.Errorf("TakePending() after mismatches error = %v", err)
+	}
+
+	if err := s.SavePending(ctx, pending); err != nil {
+		t.Fatalf("SavePending() error = %v", err)
+	}
+	now = now.Add(PendingTTL + time.Second)
+	if _, err := s.TakePending(ctx, "token", "state"); !errors.Is(err, ErrPendingNotFound) {
+		t.Errorf("expired TakePending() error = %v, want ErrPendingNotFound", err)
+	}
+}
+
+func TestStore_RequiresOwner(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s, _ := newTestStore(t, &now)
+	ctx := context.Background()
+
+	if _, err := s.Save(ctx, Account{Platform: PlatformTwitter, AccessToken: "token"}); !errors.Is(err, ErrOwnerRequired) {
+		t.Errorf("Save() without owner error = %v, want ErrOwnerRequired", err)
+	}
+	if _, err := s.Get(ctx, "", PlatformTwitter); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() without owner error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "", PlatformTwitter); !errors.Is(err, ErrOwnerRequired) {
+		t.Errorf("Delete() without owner error = %v, want ErrOwnerRequired", err)
+	}
+	if err := s.SavePending(ctx, Pending{RequestToken: "token", State: "state", Platform: PlatformTwitter}); !errors.Is(err, ErrOwnerRequired) {
+		t.Errorf("SavePending() without owner error = %v, want ErrOwnerRequired", err)
+	}
+}
+
+func TestStore_TakePendingOnce(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s, _ := newTestStore(t, &now)
+	ctx := context.Background()
+
+	if err := s.SavePending(ctx, Pending{RequestToken: "token", RequestSecret: "secret", State: "state", OwnerID: "alice", Platform: PlatformTwitter}); err != nil {
+		t.Fatalf("SavePending() error = %v", err)
+	}
+
+	// Concurrent callbacks for the same authorization: exactly one of them completes it
+	const callbacks = 8
+	var wg sync.WaitGroup
+	var taken atomic.Int32
+	for range callbacks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.TakePending(ctx, "token", "state"); err == nil {
+				taken.Add(1)
+			} else if !errors.Is(err, ErrPendingNotFound) {
+				t.Errorf("TakePending() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if taken.Load() != 1 {
+		t.Errorf("TakePending() succeeded %d times, want 1", taken.Load())
+	}
+}
+
+func TestStore_SavePendingPrunesExpired(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s, _ := newTestStore(t, &now)
+	ctx := context.Background()
+
+	if err := s.SavePending(ctx, Pending{RequestToken: "abandoned", RequestSecret: "secret", State: "state", OwnerID: "alice", Platform: PlatformTwitter}); err != nil {
+		t.Fatalf("SavePending() error = %v", err)
+	}
+	now = now.Add(PendingTTL + time.Second)
+	if err := s.SavePending(ctx, Pending{RequestToken: "fresh", RequestSecret: "secret", State: "state", OwnerID: "bob", Platform: PlatformTwitter}); err != nil {
+		t.Fatalf("SavePending() error = %v", err)
+	}
+
+	var tokens []string
+	if err := s.db.ForEach(bucketPending, func(key string, _ []byte) error {
+		tokens = append(tokens, key)
+		return nil
+	}); err != nil {
+		t.Fatalf("ForEach() error = %v", err)
+	}
+	if len(tokens) != 1 || tokens[0] != "fresh" {
+		t.Errorf("pending authorizations = %v, want only the fresh one", tokens)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultShutdownTimeout bounds how long in-flight requests and jobs may take to finish on
+// shutdown. It is longer than the server's WriteTimeout so any request that can still succeed does.
+const defaultShutdownTimeout = 20 * time.Second
+
+// worker is a background task that runs until its context is cancelled
+type worker struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// closer releases a resource on shutdown
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+// App is the running service: the HTTP server, its background workers and the clients they
+// share. Run serves until the context is cancelled and then shuts down in order: the server
+// stops accepting requests and drains the in-flight ones, the workers finish their current job,
+// and the clients are closed in the reverse order they were opened.
+type App struct {
+	server          *http.Server
+	shutdownTimeout time.Duration
+	workers         []worker
+	closers         []closer
+}
+
+// NewApp creates an app serving server. shutdownTimeout bounds the graceful shutdown; zero means
+// defaultShutdownTimeout.
+func NewApp(server *http.Server, shutdownTimeout time.Duration) *App {
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	return &App{server: server, shutdownTimeout: shutdownTimeout}
+}
+
+// addWorker runs fn in the background while the app serves
+func (a *App) addWorker(name string, fn func(ctx context.Context) error) {
+	a.workers = append(a.workers, worker{name: name, run: fn})
+}
+
+// onClose registers fn to release a resource on shutdown. Resources are closed in the reverse
+// order they were registered, so register each one as soon as it is opened.
+func (a *App) onClose(name string, fn func(ctx context.Context) error) {
+	a.closers = append(a.closers, closer{name: name, close: fn})
+}
+
+// Run listens on the server's address and serves until ctx is cancelled or the server fails
+func (a *App) Run(ctx context.Context) error {
+	listener, err := net.Listen("tcp", a.server.Addr)
+	if err != nil {
+		closeErr := a.Close(context.Background())
+		return errors.Join(fmt.Errorf("failed to listen on %s: %w", a.server.Addr, err), closeErr)
+	}
+	return a.Serve(ctx, listener)
+}
+
+// Serve serves on listener until ctx is cancelled or the server fails, then shuts down gracefully.
+// It returns an error if the server failed or the shutdown did not complete in time.
+func (a *App) Serve(ctx context.Context, listener net.Listener) error {
+	// Workers outlive ctx so they stop only after the server has drained
+	workerCtx, stopWorkers := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	for _, w := range a.workers {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			if err := w.run(workerCtx); err != nil {
+				slog.Error("background worker stopped", "worker", w.name, "error", err)
+			}
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- a.server.Serve(listener)
+	}()
+	slog.Info("server is running", "address", listener.Addr().String())
+
+	var errs []error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutting down", "timeout", a.shutdownTimeout)
+	case err := <-serveErr:
+		errs = append(errs, fmt.Errorf("server failed: %w", err))
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), a.shutdownTimeout)
+	defer cancel()
+
+	if err := a.server.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to drain requests: %w", err))
+	}
+
+	stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		errs = append(errs, fmt.Errorf("background workers did not stop: %w", shutdownCtx.Err()))
+	}
+
+	// Clients get their own deadline so a slow drain still lets them flush
+	closeCtx, cancelClose := context.WithTimeout(context.WithoutCancel(ctx), a.shutdownTimeout)
+	defer cancelClose()
+	if err := a.Close(closeCtx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// Close releases every registered resource in reverse order. It is safe to call more than once.
+func (a *App) Close(ctx context.Context) error {
+	var errs []error
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		c := a.closers[i]
+		if err := c.close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close %s: %w", c.name, err))
+		}
+	}
+	a.closers = nil
+	return errors.Join(errs...)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startApp serves app on a free local port and returns its URL and the result of Serve
+func startApp(t *testing.T, ctx context.Context, app *App) (string, <-chan error) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- app.Serve(ctx, listener)
+	}()
+	return "http://" + listener.Addr().String(), done
+}
+
+// shutdownLog records the order in which workers stop and resources are closed
+type shutdownLog struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (l *shutdownLog) add(event string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+func (l *shutdownLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.events)
+}
+
+func TestAppServe_DrainsInFlightRequests(t *testing.T) {
+	// Arrange
+	started, release := make(chan struct{}), make(chan struct{})
+	var log shutdownLog
+	app := NewApp(&http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		log.add("request done")
+		_, _ = io.WriteString(w, "generated")
+	})}, time.Second)
+	app.addWorker("test", func(ctx context.Context) error {
+		<-ctx.Done()
+		log.add("worker stopped")
+		return nil
+	})
+	app.onClose("gemini", func(context.Context) error { log.add("gemini closed"); return nil })
+	app.onClose("database", func(context.Context) error { log.add("database closed"); return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	url, done := startApp(t, ctx, app)
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{body: string(body), err: err}
+	}()
+
+	// Act: shut down while the request is in flight
+	<-started
+	cancel()
+	select {
+	case err := <-done:
+		t.Fatalf("Serve() returned %v before the in-flight request finished", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+
+	// Assert
+	if err := <-done; err != nil {
+		t.Errorf("Serve() error = %v", err)
+	}
+	got := <-responses
+	if got.err != nil || got.body != "generated" {
+		t.Errorf("Expected the in-flight request to complete, got body %q, error %v", got.body, got.err)
+	}
+	want := []string{"request done", "worker stopped", "database closed", "gemini closed"}
+	if events := log.get(); !slices.Equal(events, want) {
+		t.Errorf("Expected shutdown order %v, got %v", want, events)
+	}
+}
+
+func TestAppServe_ShutdownTimeout(t *testing.T) {
+	// Arrange
+	started, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	closed := false
+	app := NewApp(&http.Server{Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		close(started)
+		<-release
+	})}, 50*time.Millisecond)
+	app.onClose("database", func(context.Context) error { closed = true; return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	url, done := startApp(t, ctx, app)
+	go func() {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	// Act
+	<-started
+	cancel()
+	err := <-done
+
+	// Assert
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a drain timeout, got %v", err)
+	}
+	if !closed {
+		t.Error("Expected resources to be closed after a drain timeout")
+	}
+}
+
+func TestAppClose(t *testing.T) {
+	// Arrange
+	var closed []string
+	app := NewApp(&http.Server{}, 0)
+	app.onClose("tracing", func(context.Context) error { closed = append(closed, "tracing"); return nil })
+	app.onClose("imagen", func(context.Context) error { return errors.New("connection reset") })
+	app.onClose("database", func(context.Context) error { closed = append(closed, "database"); return nil })
+
+	// Act
+	err := app.Close(context.Background())
+	again := app.Close(context.Background())
+
+	// Assert
+	if err == nil || !strings.Contains(err.Error(), "failed to close imagen: connection reset") {
+		t.Errorf("Expected the imagen close error, got %v", err)
+	}
+	if want := []string{"database", "tracing"}; !slices.Equal(closed, want) {
+		t.Errorf("Expected every resource to be closed in reverse order %v, got %v", want, closed)
+	}
+	if again != nil || len(closed) != 2 {
+		t.Errorf("Expected a second Close to do nothing, got %v", again)
+	}
+}
+
+func TestAppRun_ListenError(t *testing.T) {
+	// Arrange
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+	closed := false
+	app := NewApp(&http.Server{Addr: listener.Addr().String()}, 0)
+	app.onClose("database", func(context.Context) error { closed = true; return nil })
+
+	// Act
+	err = app.Run(context.Background())
+
+	// Assert
+	if err == nil {
+		t.Fatal("Expected an error when the address is in use")
+	}
+	if !closed {
+		t.Error("Expected resources to be closed when the server cannot start")
+	}
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// minAPIKeyLength rejects keys short enough to guess
+const minAPIKeyLength = 16
+
+// APIKeys maps API keys to the users they authenticate. Only SHA-256 digests of the keys are kept.
+type APIKeys struct {
+	users map[[sha256.Size]byte]string
+}
+
+// NewAPIKeys creates a key set from a map of user IDs to API keys
+func NewAPIKeys(keys map[string]string) (*APIKeys, error) {
+	k := &APIKeys{users: make(map[[sha256.Size]byte]string, len(keys))}
+	for userID, key := range keys {
+		if userID == "" {
+			return nil, errors.New("API key user ID is required")
+		}
+		if len(key) < minAPIKeyLength {
+			return nil, fmt.Errorf("API key for %s must be at least %d characters", userID, minAPIKeyLength)
+		}
+		digest := sha256.Sum256([]byte(key))
+		if _, ok := k.users[digest]; ok {
+			return nil, fmt.Errorf("API key for %s is already assigned to another user", userID)
+		}
+		k.users[digest] = userID
+	}
+	return k, nil
+}
+
+// ParseAPIKeys reads comma-separated userID:key pairs, as set in AUTH_API_KEYS
+func ParseAPIKeys(value string) (*APIKeys, error) {
+	keys := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		userID, key, ok := strings.Cut(pair, ":")
+		if !ok {
+			return nil, errors.New("API key entries must be userID:key")
+		}
+		if _, ok := keys[userID]; ok {
+			return nil, fmt.Errorf("duplicate API key user %s", userID)
+		}
+		keys[userID] = key
+	}
+	return NewAPIKeys(keys)
+}
+
+// Lookup returns the user the key belongs to
+func (k *APIKeys) Lookup(key string) (*User, error) {
+	digest := sha256.Sum256([]byte(key))
+	// Compare against every digest so that the time taken does not depend on which key matched
+	var userID string
+	for candidate, id := range k.users {
+		if subtle.ConstantTimeCompare(candidate[:], digest[:]) == 1 {
+			userID = id
+		}
+	}
+	if userID == "" {
+		return nil, ErrInvalidCredentials
+	}
+	return &User{ID: userID, Name: userID, Method: MethodAPIKey}, nil
+}
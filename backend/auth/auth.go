@@ -0,0 +1,192 @@
+// Package auth authenticates API requests with API keys or OIDC/JWT bearer tokens
+// and makes the authenticated user available to handlers through the request context.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// Method is how a user authenticated
+type Method string
+
+const (
+	// MethodAPIKey means the user sent a configured API key
+	MethodAPIKey Method = "API_KEY"
+	// MethodJWT means the user sent a bearer token signed by a key in the JWKS
+	MethodJWT Method = "JWT"
+)
+
+var (
+	// ErrMissingCredentials is returned when a request carries no credentials but authentication is required
+	ErrMissingCredentials = errors.New("authentication required")
+	// ErrInvalidCredentials is returned for unknown API keys and bearer tokens that fail verification
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// APIKeyHeader is the header API keys are sent in
+const APIKeyHeader = "X-API-Key"
+
+// User is an authenticated caller
+type User struct {
+	ID     string // The API key's user ID or the token's subject
+	Name   string
+	Email  string
+	Method Method
+	Issuer string // The token's issuer, for MethodJWT
+}
+
+// OwnerID identifies the user as the owner of stored records. IDs are only unique per
+// authentication method and issuer, so an API key user and a token subject with the same ID,
+// or the same subject from two issuers, own separate records.
+func (u *User) OwnerID() string {
+	switch u.Method {
+	case MethodAPIKey:
+		return "apikey:" + u.ID
+	case MethodJWT:
+		return "jwt:" + u.Issuer + "|" + u.ID
+	default:
+		return u.ID
+	}
+}
+
+type userKey struct{}
+
+// WithUser returns a context carrying user
+func WithUser(ctx context.Context, user *User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// UserFrom returns the user authenticated for the request, if any
+func UserFrom(ctx context.Context) (*User, bool) {
+	user, ok := ctx.Value(userKey{}).(*User)
+	return user, ok && user != nil
+}
+
+// Authenticator checks the credentials of incoming requests
+type Authenticator struct {
+	apiKeys  *APIKeys
+	verifier *Verifier
+	optional bool
+}
+
+// Option is a functional option for the authenticator
+type Option func(*Authenticator)
+
+// WithAPIKeys accepts the given API keys
+func WithAPIKeys(keys *APIKeys) Option {
+	return func(a *Authenticator) {
+		a.apiKeys = keys
+	}
+}
+
+// WithJWT accepts bearer tokens that the verifier accepts
+func WithJWT(verifier *Verifier) Option {
+	return func(a *Authenticator) {
+		a.verifier = verifier
+	}
+}
+
+// WithOptional lets requests without credentials through as anonymous.
+// Requests with invalid credentials are still rejected.
+func WithOptional() Option {
+	return func(a *Authenticator) {
+		a.optional = true
+	}
+}
+
+// New creates an authenticator. At least one of WithAPIKeys or WithJWT is required.
+func New(options ...Option) (*Authenticator, error) {
+	a := &Authenticator{}
+	for _, opt := range options {
+		opt(a)
+	}
+	if a.apiKeys == nil && a.verifier == nil {
+		return nil, errors.New("at least one authentication method is required")
+	}
+	return a, nil
+}
+
+// Authenticate returns the user identified by the request's credentials.
+// It returns nil without an error for anonymous requests when authentication is optional.
+func (a *Authenticator) Authenticate(r *http.Request) (*User, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		if a.apiKeys == nil {
+			return nil, ErrInvalidCredentials
+		}
+		return a.apiKeys.Lookup(key)
+	}
+
+	if token, ok := bearerToken(r); ok {
+		if a.verifier == nil {
+			return nil, ErrInvalidCredentials
+		}
+		claims, err := a.verifier.Verify(r.Context(), token)
+		if err != nil {
+			return nil, err
+		}
+		return claims.User(), nil
+	}
+
+	if a.optional {
+		return nil, nil
+	}
+	return nil, ErrMissingCredentials
+}
+
+// Middleware rejects requests with missing or invalid credentials with 401 Unauthorized
+// and attaches the authenticated user to the context of the others
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Let CORS preflight requests through; they never carry credentials
+		if r.Method == http.MethodOptions {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := a.Authenticate(r)
+		if err != nil {
+			slog.WarnContext(r.Context(), "rejected unauthenticated request", "path", r.URL.Path, "error", err)
+			writeUnauthorized(w, err)
+			return
+		}
+		if user != nil {
+			r = r.WithContext(WithUser(r.Context(), user))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// writeUnauthorized writes a GraphQL-shaped 401 response. Verification details stay in the log.
+func writeUnauthorized(w http.ResponseWriter, err error) {
+	message := ErrInvalidCredentials.Error()
+	if errors.Is(err, ErrMissingCredentials) {
+		message = ErrMissingCredentials.Error()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="enjo"`)
+	w.WriteHeader(http.StatusUnauthorized)
+	body := map[string]any{
+		"errors": []map[string]any{{
+			"message":    message,
+			"extensions": map[string]any{"code": "UNAUTHENTICATED"},
+		}},
+	}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("failed to encode unauthorized response", "error", err)
+	}
+}
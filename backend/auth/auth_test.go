@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testAPIKey = "0123456789abcdef-key"
+
+func TestParseAPIKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "pairs", value: "alice:" + testAPIKey + ", bob:fedcba9876543210-key"},
+		{name: "missing separator", value: testAPIKey, wantErr: true},
+		{name: "short key", value: "alice:short", wantErr: true},
+		{name: "empty user", value: ":" + testAPIKey, wantErr: true},
+		{name: "duplicate user", value: "alice:" + testAPIKey + ",alice:fedcba9876543210-key", wantErr: true},
+		{name: "shared key", value: "alice:" + testAPIKey + ",bob:" + testAPIKey, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseAPIKeys(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseAPIKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	keys := newTestKeys(t)
+	apiKeys, err := ParseAPIKeys("alice:" + testAPIKey)
+	if err != nil {
+		t.Fatalf("ParseAPIKeys() error = %v", err)
+	}
+	required, err := New(WithAPIKeys(apiKeys), WithJWT(newTestVerifier(t, keys)))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	optional, _ := New(WithAPIKeys(apiKeys), WithOptional())
+
+	tests := []struct {
+		name       string
+		auth       *Authenticator
+		method     string
+		header     map[string]string
+		wantStatus int
+		wantUser   string
+	}{
+		{name: "API key", auth: required, header: map[string]string{APIKeyHeader: testAPIKey}, wantStatus: http.StatusOK, wantUser: "alice"},
+		{name: "bearer token", auth: required, header: map[string]string{"Authorization": "Bearer " + keys.sign(t, "RS256", "rsa", validClaims())}, wantStatus: http.StatusOK, wantUser: "user-1"},
+		{name: "unknown API key", auth: required, header: map[string]string{APIKeyHeader: "unknown-key-0123456789"}, wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", auth: required, header: map[string]string{"Authorization": "Bearer invalid"}, wantStatus: http.StatusUnauthorized},
+		{name: "missing credentials", auth: required, wantStatus: http.StatusUnauthorized},
+		{name: "preflight", auth: required, method: http.MethodOptions, wantStatus: http.StatusOK},
+		{name: "optional anonymous", auth: optional, wantStatus: http.StatusOK},
+		{name: "optional with invalid key", auth: optional, header: map[string]string{APIKeyHeader: "unknown-key-0123456789"}, wantStatus: http.StatusUnauthorized},
+		{name: "token without JWT configured", auth: optional, header: map[string]string{"Authorization": "Bearer token"}, wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotUser string
+			handler := tt.auth.Middleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				if user, ok := UserFrom(r.Context()); ok {
+					gotUser = user.ID
+				}
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, "/graphql", http.NoBody)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if gotUser != tt.wantUser {
+				t.Errorf("user = %q, want %q", gotUser, tt.wantUser)
+			}
+			if w.Code == http.StatusUnauthorized {
+				var body struct {
+					Errors []struct {
+						Extensions map[string]any `json:"extensions"`
+					} `json:"errors"`
+				}
+				if err := json.NewDecoder(w.Body).Decode(&body); err != nil || len(body.Errors) != 1 ||
+					body.Errors[0].Extensions["code"] != "UNAUTHENTICATED" {
+					t.Errorf("body = %+v (%v), want an UNAUTHENTICATED error", body, err)
+				}
+			}
+		})
+	}
+}
+
+func TestUser_OwnerID(t *testing.T) {
+	tests := []struct {
+		name string
+		user User
+		want string
+	}{
+		{name: "API key", user: User{ID: "alice", Method: MethodAPIKey}, want: "apikey:alice"},
+		{name: "JWT", user: User{ID: "alice", Method: MethodJWT, Issuer: "https://issuer.example.com"}, want: "jwt:https://issuer.example.com|alice"},
+		{name: "JWT from another issuer", user: User{ID: "alice", Method: MethodJWT, Issuer: "https://other.example.com"}, want: "jwt:https://other.example.com|alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.user.OwnerID(); got != tt.want {
+				t.Errorf("OwnerID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_RequiresMethod(t *testing.T) {
+	if _, err := New(WithOptional()); err == nil {
+		t.Error("New() error = nil, want error")
+	}
+	if _, err := New(WithAPIKeys(&APIKeys{})); err != nil {
+		t.Errorf("New() error = %v", err)
+	}
+}
@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// defaultLeeway tolerates clock skew between this server and the identity provider
+const defaultLeeway = time.Minute
+
+// Verifier checks the signature and registered claims of JWT bearer tokens
+type Verifier struct {
+	keys     KeySet
+	issuer   string
+	audience string
+	leeway   time.Duration
+	now      func() time.Time
+}
+
+// VerifierOption is a functional option for the verifier
+type VerifierOption func(*Verifier)
+
+// WithLeeway sets the clock skew tolerated when checking exp, nbf and iat
+func WithLeeway(leeway time.Duration) VerifierOption {
+	return func(v *Verifier) {
+		v.leeway = leeway
+	}
+}
+
+// withClock replaces the verifier's clock, for tests
+func withClock(now func() time.Time) VerifierOption {
+	return func(v *Verifier) {
+		v.now = now
+	}
+}
+
+// NewVerifier creates a verifier for tokens signed by keys. Tokens must name issuer in iss and,
+// when audience is set, include it in aud.
+func NewVerifier(keys KeySet, issuer, audience string, options ...VerifierOption) (*Verifier, error) {
+	if keys == nil {
+		return nil, errors.New("JWKS is required")
+	}
+	if issuer == "" {
+		return nil, errors.New("token issuer is required")
+	}
+	v := &Verifier{keys: keys, issuer: issuer, audience: audience, leeway: defaultLeeway, now: time.Now}
+	for _, opt := range options {
+		opt(v)
+	}
+	return v, nil
+}
+
+// Claims are the token claims this package uses
+type Claims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	ExpiresAt         *int64   `json:"exp"`
+	NotBefore         *int64   `json:"nbf"`
+	IssuedAt          *int64   `json:"iat"`
+	Name              string   `json:"name"`
+	Email             string   `json:"email"`
+	PreferredUsername string   `json:"preferred_username"`
+}
+
+// User returns the user the token was issued to
+func (c *Claims) User() *User {
+	name := c.Name
+	if name == "" {
+		name = c.PreferredUsername
+	}
+	return &User{ID: c.Subject, Name: name, Email: c.Email, Method: MethodJWT, Issuer: c.Issuer}
+}
+
+// audience is the aud claim, which may be a single string or an array
+type audience []string
+
+// UnmarshalJSON accepts both forms of the aud claim
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("aud must be a string or an array of strings")
+	}
+	*a = list
+	return nil
+}
+
+// contains reports whether aud lists value
+func (a audience) contains(value string) bool {
+	for _, v := range a {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// jwtHeader is the JOSE header of a token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Verify checks token and returns its claims. Failures wrap ErrInvalidCredentials.
+func (v *Verifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	claims, err := v.verify(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, err)
+	}
+	return claims, nil
+}
+
+func (v *Verifier) verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a signed JWT")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	if err := v.validate(&claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// validate checks the registered claims
+func (v *Verifier) validate(c *Claims) error {
+	if c.Issuer != v.issuer {
+		return fmt.Errorf("unexpected issuer %q", c.Issuer)
+	}
+	if v.audience != "" && !c.Audience.contains(v.audience) {
+		return errors.New("token is not intended for this audience")
+	}
+	if c.Subject == "" {
+		return errors.New("token has no subject")
+	}
+
+	now := v.now()
+	if c.ExpiresAt == nil {
+		return errors.New("token has no expiry")
+	}
+	if now.After(time.Unix(*c.ExpiresAt, 0).Add(v.leeway)) {
+		return errors.New("token has expired")
+	}
+	if c.NotBefore != nil && now.Before(time.Unix(*c.NotBefore, 0).Add(-v.leeway)) {
+		return errors.New("token is not valid yet")
+	}
+	if c.IssuedAt != nil && now.Before(time.Unix(*c.IssuedAt, 0).Add(-v.leeway)) {
+		return errors.New("token was issued in the future")
+	}
+	return nil
+}
+
+// verifySignature checks signature over signed with key for alg.
+// The algorithm must match the key type, so a token cannot pick a weaker scheme; none and HMAC are never accepted.
+func verifySignature(alg string, key crypto.PublicKey, signed, signature []byte) error {
+	switch alg {
+	case "RS256", "RS384", "RS512":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an RSA key", alg)
+		}
+		h, hashID := hashFor(alg)
+		h.Write(signed)
+		if err := rsa.VerifyPKCS1v15(pub, hashID, h.Sum(nil), signature); err != nil {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case "ES256", "ES384", "ES512":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%s requires an EC key", alg)
+		}
+		if want := curveBits(alg); pub.Curve.Params().BitSize != want {
+			return fmt.Errorf("%s requires a P-%d key", alg, want)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.New("invalid signature")
+		}
+		h, _ := hashFor(alg)
+		h.Write(signed)
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, h.Sum(nil), r, s) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	case "EdDSA":
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("EdDSA requires an Ed25519 key")
+		}
+		if !ed25519.Verify(pub, signed, signature) {
+			return errors.New("invalid signature")
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+}
+
+// hashFor returns the digest used by an RS or ES algorithm
+func hashFor(alg string) (hash.Hash, crypto.Hash) {
+	switch alg[2:] {
+	case "384":
+		return sha512.New384(), crypto.SHA384
+	case "512":
+		return sha512.New(), crypto.SHA512
+	default:
+		return sha256.New(), crypto.SHA256
+	}
+}
+
+// curveBits returns the curve size an ES algorithm is defined for
+func curveBits(alg string) int {
+	switch alg {
+	case "ES384":
+		return 384
+	case "ES512":
+		return 521
+	default:
+		return 256
+	}
+}
+
+// decodeSegment decodes a base64url-encoded JSON token segment into v
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://issuer.example.com"
+	testAudience = "enjo-api"
+)
+
+var testNow = time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// testKeys are signing keys with their JWKS entries
+type testKeys struct {
+	rsa     *rsa.PrivateKey
+	ec      *ecdsa.PrivateKey
+	ed      ed25519.PrivateKey
+	jwksDoc []byte
+}
+
+func newTestKeys(t *testing.T) *testKeys {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() error = %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey() error = %v", err)
+	}
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey() error = %v", err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	ecPoint, err := ecKey.PublicKey.Bytes()
+	if err != nil {
+		t.Fatalf("PublicKey.Bytes() error = %v", err)
+	}
+	doc, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecPoint[1:33]), "y": b64(ecPoint[33:])},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPub)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"},
+	}})
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	return &testKeys{rsa: rsaKey, ec: ecKey, ed: edKey, jwksDoc: doc}
+}
+
+// sign creates a token signed with the key for alg
+func (k *testKeys) sign(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	b64 := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	var err error
+	switch alg {
+	case "RS256":
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k.rsa, crypto.SHA256, digest[:])
+	case "ES256":
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k.ec, digest[:])
+		if err == nil {
+			signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+		}
+	case "EdDSA":
+		signature = ed25519.Sign(k.ed, []byte(signed))
+	case "none":
+	default:
+		t.Fatalf("unsupported test algorithm %s", alg)
+	}
+	if err != nil {
+		t.Fatalf("signing error = %v", err)
+	}
+	return signed + "." + b64(signature)
+}
+
+func validClaims() map[string]any {
+	return map[string]any{
+		"iss":   testIssuer,
+		"sub":   "user-1",
+		"aud":   []string{testAudience, "other"},
+		"exp":   testNow.Add(time.Hour).Unix(),
+		"iat":   testNow.Unix(),
+		"name":  "炎上 太郎",
+		"email": "taro@example.com",
+	}
+}
+
+func newTestVerifier(t *testing.T, keys *testKeys) *Verifier {
+	t.Helper()
+	set, err := NewStaticKeySet(keys.jwksDoc)
+	if err != nil {
+		t.Fatalf("NewStaticKeySet() error = %v", err)
+	}
+	verifier, err := NewVerifier(set, testIssuer, testAudience, withClock(func() time.Time { return testNow }))
+	if err != nil {
+		t.Fatalf("NewVerifier() error = %v", err)
+	}
+	return verifier
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	keys := newTestKeys(t)
+	verifier := newTestVerifier(t, keys)
+
+	with := func(key string, value any) map[string]any {
+		claims := validClaims()
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "RS256", token: keys.sign(t, "RS256", "rsa", validClaims())},
+		{name: "ES256", token: keys.sign(t, "ES256", "ec", validClaims())},
+		{name: "EdDSA", token: keys.sign(t, "EdDSA", "ed", validClaims())},
+		{name: "single audience string", token: keys.sign(t, "RS256", "rsa", with("aud", testAudience))},
+		{name: "expired within leeway", token: keys.sign(t, "RS256", "rsa", with("exp", testNow.Add(-30*time.Second).Unix()))},
+		{name: "expired", token: keys.sign(t, "RS256", "rsa", with("exp", testNow.Add(-time.Hour).Unix())), wantErr: true},
+		{name: "no expiry", token: keys.sign(t, "RS256", "rsa", with("exp", nil)), wantErr: true},
+		{name: "not yet valid", token: keys.sign(t, "RS256", "rsa", with("nbf", testNow.Add(time.Hour).Unix())), wantErr: true},
+		{name: "issued in the future", token: keys.sign(t, "RS256", "rsa", with("iat", testNow.Add(time.Hour).Unix())), wantErr: true},
+		{name: "wrong issuer", token: keys.sign(t, "RS256", "rsa", with("iss", "https://evil.example.com")), wantErr: true},
+		{name: "wrong audience", token: keys.sign(t, "RS256", "rsa", with("aud", "other")), wantErr: true},
+		{name: "no subject", token: keys.sign(t, "RS256", "rsa", with("sub", nil)), wantErr: true},
+		{name: "unknown key", token: keys.sign(t, "RS256", "missing", validClaims()), wantErr: true},
+		{name: "algorithm does not match key", token: keys.sign(t, "ES256", "rsa", validClaims()), wantErr: true},
+		{name: "unsigned", token: keys.sign(t, "none", "rsa", validClaims()), wantErr: true},
+		{name: "symmetric key is ignored", token: keys.sign(t, "RS256", "secret", validClaims()), wantErr: true},
+		{name: "malformed", token: "not-a-token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidCredentials) {
+					t.Errorf("Verify() error = %v, want ErrInvalidCredentials", err)
+				}
+				return
+			}
+			if user := claims.User(); user.ID != "user-1" || user.Email != "taro@example.com" || user.Method != MethodJWT || user.Issuer != testIssuer {
+				t.Errorf("User() = %+v", user)
+			}
+		})
+	}
+
+	t.Run("tampered payload", func(t *testing.T) {
+		token := keys.sign(t, "RS256", "rsa", validClaims())
+		parts := strings.Split(token, ".")
+		forged := keys.sign(t, "RS256", "rsa", with("sub", "admin"))
+		parts[1] = strings.Split(forged, ".")[1]
+		if _, err := verifier.Verify(context.Background(), strings.Join(parts, ".")); err == nil {
+			t.Error("Verify() accepted a token with a swapped payload")
+		}
+	})
+}
+
+func TestNewStaticKeySet(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{name: "invalid JSON", doc: "{"},
+		{name: "no keys", doc: `{"keys":[]}`},
+		{name: "only unsupported keys", doc: `{"keys":[{"kty":"oct","k":"c2VjcmV0"}]}`},
+		{name: "short RSA key", doc: `{"keys":[{"kty":"RSA","n":"AQAB","e":"AQAB"}]}`},
+		{name: "point not on curve", doc: `{"keys":[{"kty":"EC","crv":"P-256","x":"` + strings.Repeat("A", 43) + `","y":"` + strings.Repeat("A", 43) + `"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewStaticKeySet([]byte(tt.doc)); err == nil {
+				t.Error("NewStaticKeySet() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	keys := newTestKeys(t)
+	var fetches int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches++
+		_, _ = w.Write(keys.jwksDoc)
+	}))
+	defer server.Close()
+
+	set, err := NewRemoteKeySet(server.URL, server.Client())
+	if err != nil {
+		t.Fatalf("NewRemoteKeySet() error = %v", err)
+	}
+	now := testNow
+	set.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	if _, err := set.Key(ctx, "rsa"); err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+	if _, err := set.Key(ctx, "ec"); err != nil || fetches != 1 {
+		t.Fatalf("Key() error = %v after %d fetches, want a cached key", err, fetches)
+	}
+
+	// Unknown key IDs are refetched at most once per minJWKSRefresh
+	if _, err := set.Key(ctx, "rotated"); !errors.Is(err, ErrUnknownKey) || fetches != 1 {
+		t.Errorf("Key(rotated) error = %v after %d fetches, want ErrUnknownKey without a fetch", err, fetches)
+	}
+	now = now.Add(2 * minJWKSRefresh)
+	if _, err := set.Key(ctx, "rotated"); !errors.Is(err, ErrUnknownKey) || fetches != 2 {
+		t.Errorf("Key(rotated) error = %v after %d fetches, want a refetch", err, fetches)
+	}
+
+	// Stale key sets are refetched
+	now = now.Add(defaultJWKSMaxAge)
+	if _, err := set.Key(ctx, "rsa"); err != nil || fetches != 3 {
+		t.Errorf("Key() error = %v after %d fetches, want a refetch", err, fetches)
+	}
+}
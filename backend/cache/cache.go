@@ -0,0 +1,100 @@
+// Package cache stores the results of expensive model calls under content-addressed keys,
+// in memory or on disk, and tracks per-request cache hits and misses.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Store is a cache backend
+type Store interface {
+	// Get returns the value stored under key; expired and missing entries report false
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key; a ttl of 0 keeps the entry until it is evicted
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Key hashes parts into a cache key. Each part is length-prefixed, so moving text
+// from one part to the next always produces a different key.
+func Key(parts ...string) string {
+	h := sha256.New()
+	var size [8]byte
+	for _, part := range parts {
+		binary.BigEndian.PutUint64(size[:], uint64(len(part)))
+		h.Write(size[:])
+		h.Write([]byte(part))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Report counts the cache lookups made while serving one request
+type Report struct {
+	mu       sync.Mutex
+	hits     int
+	misses   int
+	bypassed bool
+}
+
+// Hit records a lookup answered from the cache. Recording on a nil report does nothing.
+func (r *Report) Hit() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hits++
+}
+
+// Miss records a lookup that had to call the model
+func (r *Report) Miss() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.misses++
+}
+
+// Counts returns the number of hits and misses recorded so far
+func (r *Report) Counts() (hits, misses int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hits, r.misses
+}
+
+// Bypassed reports whether the request skipped cached results
+func (r *Report) Bypassed() bool {
+	return r.bypassed
+}
+
+type reportKey struct{}
+
+type bypassKey struct{}
+
+// WithReport returns a context whose cache lookups are recorded in the returned report
+func WithReport(ctx context.Context, bypass bool) (context.Context, *Report) {
+	report := &Report{bypassed: bypass}
+	ctx = context.WithValue(ctx, reportKey{}, report)
+	if bypass {
+		ctx = context.WithValue(ctx, bypassKey{}, true)
+	}
+	return ctx, report
+}
+
+// ReportFrom returns the report attached to ctx, or nil
+func ReportFrom(ctx context.Context) *Report {
+	report, _ := ctx.Value(reportKey{}).(*Report)
+	return report
+}
+
+// Bypass reports whether lookups in ctx should skip cached results.
+// Fresh results are still stored, so a bypass also refreshes the cache.
+func Bypass(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey{}).(bool)
+	return bypass
+}
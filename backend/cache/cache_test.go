@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	if Key("a", "b") != Key("a", "b") {
+		t.Error("Key() is not deterministic")
+	}
+	if Key("ab", "c") == Key("a", "bc") {
+		t.Error("Key() collides when text moves between parts")
+	}
+	if Key("a") == Key("a", "") {
+		t.Error("Key() ignores empty parts")
+	}
+	if got := len(Key("a")); got != 64 {
+		t.Errorf("len(Key()) = %d, want 64", got)
+	}
+}
+
+func TestReport(t *testing.T) {
+	ctx := context.Background()
+	if ReportFrom(ctx) != nil || Bypass(ctx) {
+		t.Fatal("plain context has a report or bypass")
+	}
+
+	// Recording without a report is a no-op
+	var missing *Report
+	missing.Hit()
+	missing.Miss()
+
+	ctx, report := WithReport(ctx, false)
+	if ReportFrom(ctx) != report || Bypass(ctx) {
+		t.Fatal("WithReport(false) did not attach the report without bypass")
+	}
+	report.Hit()
+	report.Miss()
+	report.Miss()
+	if hits, misses := report.Counts(); hits != 1 || misses != 2 {
+		t.Errorf("Counts() = %d, %d, want 1, 2", hits, misses)
+	}
+
+	ctx, report = WithReport(context.Background(), true)
+	if !Bypass(ctx) || !report.Bypassed() {
+		t.Error("WithReport(true) did not set bypass")
+	}
+}
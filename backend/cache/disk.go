@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+const (
+	// dirPerm is the permission for the cache directories
+	dirPerm = 0o750
+	// filePerm is the permission for cache entry files
+	filePerm = 0o640
+	// headerSize is the length of the expiry header at the start of each entry file
+	headerSize = 8
+)
+
+// ErrInvalidKey is returned for keys that were not produced by Key
+var ErrInvalidKey = errors.New("invalid cache key")
+
+// keyPattern matches the hex digests returned by Key, which are safe to use as file names
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Disk is a store that keeps each entry in its own file, so cached results survive restarts.
+// Each file starts with the expiry time in Unix nanoseconds (0 for none) followed by the value.
+type Disk struct {
+	dir string
+	now func() time.Time
+}
+
+// NewDisk creates a store in dir, creating the directory if needed
+func NewDisk(dir string) (*Disk, error) {
+	if dir == "" {
+		return nil, errors.New("cache directory is required")
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	return &Disk{dir: dir, now: time.Now}, nil
+}
+
+// Get returns the value stored under key. Expired entries are removed.
+func (d *Disk) Get(_ context.Context, key string) ([]byte, bool, error) {
+	if !keyPattern.MatchString(key) {
+		return nil, false, ErrInvalidKey
+	}
+
+	data, err := os.ReadFile(d.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read cache entry: %w", err)
+	}
+	if len(data) < headerSize {
+		return nil, false, d.remove(key)
+	}
+
+	if expiresAt := int64(binary.BigEndian.Uint64(data[:headerSize])); expiresAt != 0 && d.now().UnixNano() >= expiresAt {
+		return nil, false, d.remove(key)
+	}
+	return data[headerSize:], true, nil
+}
+
+// Set writes value to the entry file for key. The file is written under a temporary name
+// and renamed so readers never see a partially written entry.
+func (d *Disk) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	if !keyPattern.MatchString(key) {
+		return ErrInvalidKey
+	}
+
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = d.now().Add(ttl).UnixNano()
+	}
+	data := make([]byte, headerSize+len(value))
+	binary.BigEndian.PutUint64(data, uint64(expiresAt))
+	copy(data[headerSize:], value)
+
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry-*")
+	if err != nil {
+		return fmt.Errorf("failed to create cache entry: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write cache entry: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), filePerm); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to set cache entry permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to store cache entry: %w", err)
+	}
+	return nil
+}
+
+// remove deletes the entry file for key
+func (d *Disk) remove(key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove cache entry: %w", err)
+	}
+	return nil
+}
+
+// path returns the entry file for key, sharded by the first two characters to keep directories small
+func (d *Disk) path(key string) string {
+	return filepath.Join(d.dir, key[:2], key)
+}
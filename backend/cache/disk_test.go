@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDisk(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	d, err := NewDisk(dir)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	d.now = func() time.Time { return now }
+
+	key := Key("prompt")
+	if _, ok, err := d.Get(ctx, key); ok || err != nil {
+		t.Fatalf("Get() before Set = %v, %v, want miss", ok, err)
+	}
+	if err := d.Set(ctx, key, []byte("result"), time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+
+	// Entries survive reopening the store
+	reopened, err := NewDisk(dir)
+	if err != nil {
+		t.Fatalf("NewDisk() error = %v", err)
+	}
+	reopened.now = d.now
+	value, ok, err := reopened.Get(ctx, key)
+	if err != nil || !ok || string(value) != "result" {
+		t.Fatalf("Get() = %q, %v, %v, want result", value, ok, err)
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok, err := d.Get(ctx, key); ok || err != nil {
+		t.Fatalf("Get() after TTL = %v, %v, want miss", ok, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, key[:2], key)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expired entry was not removed: %v", err)
+	}
+
+	if err := d.Set(ctx, "../escape", []byte("x"), 0); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Set() with a path key error = %v, want ErrInvalidKey", err)
+	}
+	if _, _, err := d.Get(ctx, "../escape"); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Get() with a path key error = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestNewDisk_RequiresDir(t *testing.T) {
+	if _, err := NewDisk(""); err == nil {
+		t.Error("NewDisk(\"\") succeeded, want error")
+	}
+}
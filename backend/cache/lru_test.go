@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	c := NewLRU(2)
+	c.now = func() time.Time { return now }
+
+	mustSet := func(key, value string, ttl time.Duration) {
+		t.Helper()
+		if err := c.Set(ctx, key, []byte(value), ttl); err != nil {
+			t.Fatalf("Set(%s) error = %v", key, err)
+		}
+	}
+	get := func(key string) (string, bool) {
+		t.Helper()
+		value, ok, err := c.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", key, err)
+		}
+		return string(value), ok
+	}
+
+	mustSet("a", "1", 0)
+	mustSet("b", "2", time.Minute)
+	if value, ok := get("a"); !ok || value != "1" {
+		t.Errorf("Get(a) = %q, %v, want 1, true", value, ok)
+	}
+
+	// a was used more recently than b, so b is evicted
+	mustSet("c", "3", 0)
+	if _, ok := get("b"); ok {
+		t.Error("Get(b) hit after eviction")
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+
+	// Overwriting keeps one entry per key
+	mustSet("c", "4", time.Minute)
+	if value, ok := get("c"); !ok || value != "4" {
+		t.Errorf("Get(c) = %q, %v, want 4, true", value, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := get("c"); ok {
+		t.Error("Get(c) hit after its TTL")
+	}
+	if value, ok := get("a"); !ok || value != "1" {
+		t.Errorf("Get(a) without TTL = %q, %v, want 1, true", value, ok)
+	}
+}
@@ -0,0 +1,271 @@
+// Package config loads the backend's configuration into typed settings. Values come from, in
+// increasing order of precedence: built-in defaults, an optional YAML or TOML file, .env and the
+// process environment. Every setting is validated at startup and all problems are reported
+// together, and the effective configuration can be printed with secrets redacted.
+package config
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/gemini"
+	"github.com/Tattsum/enjo/backend/gqlserver"
+	"github.com/Tattsum/enjo/backend/health"
+	"github.com/Tattsum/enjo/backend/image"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+	"github.com/Tattsum/enjo/backend/tracing"
+)
+
+// Image store kinds accepted by ImageStore.Kind
+const (
+	ImageStoreLocal = "local"
+	ImageStoreS3    = "s3"
+	ImageStoreNone  = "none"
+)
+
+// Generation cache kinds accepted by Cache.Kind
+const (
+	CacheNone   = "none"
+	CacheMemory = "memory"
+	CacheDisk   = "disk"
+)
+
+// Config is the configuration of the backend
+type Config struct {
+	GCP        GCP
+	Server     Server
+	Gemini     Gemini
+	Imagen     Imagen
+	Twitter    Twitter
+	Database   Database
+	ImageStore ImageStore
+	Cache      Cache
+	Auth       Auth
+	GraphQL    GraphQL
+	RateLimit  RateLimit
+	Posting    Posting
+	Features   Features
+	Logging    Logging
+	Tracing    Tracing
+	Health     Health
+	Render     Render
+
+	// sources records where each setting, by file key, was last set from
+	sources map[string]string
+}
+
+// GCP selects the Google Cloud project used for Vertex AI
+type GCP struct {
+	ProjectID string
+	Location  string
+}
+
+// Server configures the HTTP server
+type Server struct {
+	Port int
+	// CORSOrigins are the browser origins allowed to call the API; "*" allows any
+	CORSOrigins     []string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+	// PublicBaseURL is where clients reach the server; empty means http://localhost:Port
+	PublicBaseURL string
+}
+
+// BaseURL returns the public base URL of the server
+func (s Server) BaseURL() string {
+	if s.PublicBaseURL != "" {
+		return s.PublicBaseURL
+	}
+	return "http://localhost:" + strconv.Itoa(s.Port)
+}
+
+// Gemini configures text generation and moderation
+type Gemini struct {
+	Model string
+}
+
+// Imagen configures image generation. Empty settings use the image package defaults.
+type Imagen struct {
+	Model     string
+	ImageSize string
+	// NegativePrompt replaces the default negative prompt when set; set to "" to send none
+	NegativePrompt   *string
+	PersonGeneration string
+	SafetySetting    string
+}
+
+// Twitter holds the app credentials, the shared account used for posting and the settings for
+// linking users' own accounts
+type Twitter struct {
+	APIKey            string
+	APISecret         string
+	AccessToken       string
+	AccessTokenSecret string
+	// TokenEncryptionKey encrypts linked accounts' tokens; linking is disabled without it
+	TokenEncryptionKey string
+	// OAuthCallbackURL is registered with Twitter; empty means the server's own callback route
+	OAuthCallbackURL string
+	// OAuthRedirectURL is where users return after linking
+	OAuthRedirectURL string
+}
+
+// PostingEnabled reports whether the shared account credentials are set
+func (t Twitter) PostingEnabled() bool {
+	return t.APIKey != "" && t.APISecret != "" && t.AccessToken != "" && t.AccessTokenSecret != ""
+}
+
+// LinkingEnabled reports whether users can link their own accounts
+func (t Twitter) LinkingEnabled() bool {
+	return t.APIKey != "" && t.APISecret != "" && t.TokenEncryptionKey != ""
+}
+
+// Database configures the embedded store
+type Database struct {
+	Path string
+}
+
+// ImageStore selects where generated images are kept and how their URLs are signed
+type ImageStore struct {
+	// Kind is ImageStoreLocal, ImageStoreS3 or ImageStoreNone (images stay inline as data URLs)
+	Kind string
+	Dir  string
+	S3   S3
+	// URLSecret signs image URLs; empty means a random key per process
+	URLSecret string
+	// URLTTL is how long signed URLs stay valid; zero means the image store default
+	URLTTL time.Duration
+}
+
+// S3 configures an S3-compatible image bucket
+type S3 struct {
+	Endpoint  string
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+}
+
+// Cache configures the cache of Gemini and Imagen results
+type Cache struct {
+	// Kind is CacheNone, CacheMemory or CacheDisk
+	Kind string
+	Dir  string
+	// Size is the number of entries kept in memory
+	Size int
+	TTL  time.Duration
+}
+
+// Auth configures authentication of GraphQL requests. It is off unless API keys or a JWKS are set.
+type Auth struct {
+	// APIKeys are comma-separated userID:key pairs
+	APIKeys  string
+	JWKSURL  string
+	JWKSFile string
+	Issuer   string
+	Audience string
+	// Required rejects unauthenticated requests; otherwise they are served anonymously
+	Required bool
+}
+
+// GraphQL sets the limits applied to GraphQL requests
+type GraphQL struct {
+	MaxComplexity int
+	MaxDepth      int
+	Introspection bool
+	// PersistedQueries is the path of a persisted query manifest; when set only its queries run
+	PersistedQueries string
+}
+
+// RateLimit sets the per-client rates and daily cost quota, applied when Features.RateLimit is on
+type RateLimit struct {
+	ratelimit.Config
+}
+
+// Posting configures the posting policy. Zero levels keep the policy defaults.
+type Posting struct {
+	ForceDisclaimerLevel int
+	ForceHashtagLevel    int
+	ConfirmationLevel    int
+	DefaultLevel         int
+	// ConfirmationSecret signs confirmation tokens; empty means a random key per process
+	ConfirmationSecret string
+	// BlockOnModerationError blocks posts when moderation fails instead of letting them through
+	BlockOnModerationError bool
+}
+
+// Features switches optional behavior on and off
+type Features struct {
+	// Metrics records metrics and serves /metrics
+	Metrics bool
+	// RateLimit enforces RateLimit
+	RateLimit bool
+	// PostingModeration checks posts with Gemini before publishing
+	PostingModeration bool
+	// ImageModeration classifies generated and posted images with Gemini
+	ImageModeration bool
+}
+
+// Logging configures the structured logger
+type Logging struct {
+	// Level is debug, info, warn or error; empty means info, or debug with Debug
+	Level string
+	// Format is json or text
+	Format string
+	// Debug logs at debug level without redaction. Never enable it in production.
+	Debug bool
+}
+
+// Tracing selects the OpenTelemetry exporter
+type Tracing struct {
+	// Exporter is otlp, console, stdout or none
+	Exporter string
+}
+
+// Health configures the dependency health checks
+type Health struct {
+	// CheckTTL is how long probe results are reused
+	CheckTTL time.Duration
+}
+
+// Render configures the overlay and screenshot renderers
+type Render struct {
+	// FontPath replaces the embedded font when set
+	FontPath string
+}
+
+// Default returns the configuration used when nothing is set. It is not valid on its own:
+// GCP.ProjectID is required.
+func Default() *Config {
+	return &Config{
+		GCP: GCP{Location: "us-central1"},
+		Server: Server{
+			Port:            8080,
+			CORSOrigins:     []string{"http://localhost:3000", "http://localhost:8080"},
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 20 * time.Second,
+		},
+		Gemini:     Gemini{Model: gemini.DefaultModel},
+		Imagen:     Imagen{Model: image.DefaultModel},
+		Database:   Database{Path: "data/enjo.db"},
+		ImageStore: ImageStore{Kind: ImageStoreLocal, Dir: "data/images"},
+		Cache:      Cache{Kind: CacheNone, Dir: "data/cache", Size: 512, TTL: 24 * time.Hour},
+		Auth:       Auth{Required: true},
+		Posting:    Posting{BlockOnModerationError: true},
+		GraphQL: GraphQL{
+			MaxComplexity: gqlserver.DefaultMaxComplexity,
+			MaxDepth:      gqlserver.DefaultMaxDepth,
+			Introspection: true,
+		},
+		RateLimit: RateLimit{ratelimit.DefaultConfig()},
+		Features:  Features{Metrics: true, RateLimit: true, PostingModeration: true},
+		Logging:   Logging{Format: "json"},
+		Tracing:   Tracing{Exporter: tracing.ExporterNone},
+		Health:    Health{CheckTTL: health.DefaultTTL},
+		sources:   map[string]string{},
+	}
+}
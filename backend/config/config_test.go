@@ -0,0 +1,301 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/logging"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+)
+
+// env returns a LookupFunc over vars
+func env(vars map[string]string) LookupFunc {
+	return func(key string) (string, bool) {
+		value, ok := vars[key]
+		return value, ok
+	}
+}
+
+// writeFile writes content to name in a temporary directory and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	c, err := Load("", env(map[string]string{"GCP_PROJECT_ID": "enjo"}))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Server.Port != 8080 || c.Server.ReadTimeout != 15*time.Second {
+		t.Errorf("server = %+v, want the defaults", c.Server)
+	}
+	if !slices.Equal(c.Server.CORSOrigins, []string{"http://localhost:3000", "http://localhost:8080"}) {
+		t.Errorf("CORSOrigins = %v, want the local frontend", c.Server.CORSOrigins)
+	}
+	if c.Server.BaseURL() != "http://localhost:8080" {
+		t.Errorf("BaseURL() = %q, want http://localhost:8080", c.Server.BaseURL())
+	}
+	if c.Imagen.NegativePrompt != nil {
+		t.Errorf("NegativePrompt = %q, want unset", *c.Imagen.NegativePrompt)
+	}
+	if !c.Posting.BlockOnModerationError {
+		t.Error("BlockOnModerationError = false, want posts blocked when moderation fails")
+	}
+
+	c, err = Load("", env(map[string]string{"GCP_PROJECT_ID": "enjo", "POSTING_BLOCK_ON_MODERATION_ERROR": "false"}))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Posting.BlockOnModerationError {
+		t.Error("BlockOnModerationError = true, want the environment to turn it off")
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	yamlFile := writeFile(t, "config.yaml", `
+gcp:
+  project_id: from-file
+server:
+  port: 9000
+  cors_origins: [https://enjo.example.com, https://admin.example.com]
+  read_timeout: 5s
+gemini:
+  model: gemini-file
+features:
+  metrics: off
+rate_limit:
+  rates:
+    image: 5/1h
+`)
+	tomlFile := writeFile(t, "config.toml", `
+# same settings as the YAML file
+[gcp]
+project_id = "from-file"
+
+[server]
+port = 9000
+cors_origins = [
+  "https://enjo.example.com",
+  "https://admin.example.com",
+]
+read_timeout = "5s"
+
+[gemini]
+model = 'gemini-file'
+
+[features]
+metrics = false
+
+[rate_limit.rates]
+image = "5/1h"
+`)
+
+	for _, path := range []string{yamlFile, tomlFile} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			c, err := Load(path, env(map[string]string{
+				"PORT":          "9100",
+				"GEMINI_MODEL":  "gemini-env",
+				"DATABASE_PATH": "",
+			}))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			if c.GCP.ProjectID != "from-file" {
+				t.Errorf("ProjectID = %q, want the file value", c.GCP.ProjectID)
+			}
+			if c.Server.Port != 9100 {
+				t.Errorf("Port = %d, want the environment to override the file", c.Server.Port)
+			}
+			if !slices.Equal(c.Server.CORSOrigins, []string{"https://enjo.example.com", "https://admin.example.com"}) {
+				t.Errorf("CORSOrigins = %v", c.Server.CORSOrigins)
+			}
+			if c.Server.ReadTimeout != 5*time.Second || c.Server.WriteTimeout != 15*time.Second {
+				t.Errorf("timeouts = %v/%v, want 5s from the file and the 15s default", c.Server.ReadTimeout, c.Server.WriteTimeout)
+			}
+			if c.Gemini.Model != "gemini-env" {
+				t.Errorf("Gemini.Model = %q, want the environment value", c.Gemini.Model)
+			}
+			if c.Features.Metrics {
+				t.Error("Features.Metrics = true, want false from the file")
+			}
+			if got := c.RateLimit.Rates[ratelimit.OpImage]; got != (ratelimit.Rate{Limit: 5, Period: time.Hour}) {
+				t.Errorf("image rate = %+v, want 5/1h", got)
+			}
+			if c.Database.Path != "data/enjo.db" {
+				t.Errorf("Database.Path = %q, want an empty variable to leave the default", c.Database.Path)
+			}
+
+			wantSources := map[string]string{"gcp.project_id": SourceFile, "server.port": SourceEnv, "database.path": ""}
+			for key, want := range wantSources {
+				if got := c.sources[key]; got != want {
+					t.Errorf("source of %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_EmptyNegativePrompt(t *testing.T) {
+	c, err := Load("", env(map[string]string{"GCP_PROJECT_ID": "enjo", "IMAGEN_NEGATIVE_PROMPT": ""}))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if c.Imagen.NegativePrompt == nil || *c.Imagen.NegativePrompt != "" {
+		t.Errorf("NegativePrompt = %v, want set to empty", c.Imagen.NegativePrompt)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		want []string
+	}{
+		{
+			name: "missing project",
+			want: []string{"GCP_PROJECT_ID is required"},
+		},
+		{
+			name: "every problem is reported",
+			env: map[string]string{
+				"GCP_PROJECT_ID":      "enjo",
+				"PORT":                "eighty",
+				"CORS_ORIGINS":        "https://ok.example.com,example.com/path",
+				"SERVER_READ_TIMEOUT": "0s",
+				"GENERATION_CACHE":    "redis",
+				"METRICS":             "maybe",
+			},
+			want: []string{
+				"PORT: must be an integer",
+				`CORS_ORIGINS: "example.com/path"`,
+				"SERVER_READ_TIMEOUT must be a positive duration",
+				`unknown GENERATION_CACHE "redis"`,
+				"METRICS: must be a boolean",
+			},
+		},
+		{
+			name: "unknown file key",
+			file: "gcp:\n  project_id: enjo\nserver:\n  prot: 80\n",
+			want: []string{`unknown setting "server.prot"`},
+		},
+		{
+			name: "twitter credentials",
+			env: map[string]string{
+				"GCP_PROJECT_ID":       "enjo",
+				"TWITTER_ACCESS_TOKEN": "token",
+			},
+			want: []string{
+				"TWITTER_ACCESS_TOKEN and TWITTER_ACCESS_TOKEN_SECRET must be set together",
+				"TWITTER_ACCESS_TOKEN needs TWITTER_API_KEY and TWITTER_API_SECRET",
+			},
+		},
+		{
+			name: "posting level",
+			env:  map[string]string{"GCP_PROJECT_ID": "enjo", "POSTING_DEFAULT_LEVEL": "6"},
+			want: []string{"POSTING_DEFAULT_LEVEL must be between 1 and 5"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := ""
+			if tt.file != "" {
+				path = writeFile(t, "config.yml", tt.file)
+			}
+
+			c, err := Load(path, env(tt.env))
+			if c == nil {
+				t.Fatal("Load() returned no config")
+			}
+			if err == nil {
+				t.Fatal("Load() error = nil, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() error = %q, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoad_FileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"unsupported extension", "config.json", `{}`},
+		{"invalid YAML", "config.yaml", "server: [port"},
+		{"invalid TOML", "config.toml", "[server\nport = 80"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(writeFile(t, tt.file, tt.content), env(nil)); err == nil {
+				t.Error("Load() error = nil, want an error")
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml"), env(nil)); err == nil {
+		t.Error("Load() error = nil for a missing file, want an error")
+	}
+}
+
+func TestPrint(t *testing.T) {
+	c, err := Load("", env(map[string]string{
+		"GCP_PROJECT_ID":     "enjo",
+		"TWITTER_API_KEY":    "consumer-key",
+		"TWITTER_API_SECRET": "consumer-secret",
+		"CORS_ORIGINS":       "https://enjo.example.com",
+	}))
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := c.Print(&buf); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	out := buf.String()
+
+	for _, secret := range []string{"consumer-key", "consumer-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Print() output contains secret %q", secret)
+		}
+	}
+	for _, want := range []string{
+		"api_key: '" + logging.Redacted + "' # TWITTER_API_KEY (env)",
+		"access_token: \"\" # TWITTER_ACCESS_TOKEN (default)",
+		"cors_origins: ['https://enjo.example.com'] # CORS_ORIGINS (env)",
+		"port: 8080 # PORT (default)",
+		"negative_prompt: null # IMAGEN_NEGATIVE_PROMPT (default)",
+		"text: 30/1m # RATE_LIMIT_TEXT (default)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Print() output missing %q:\n%s", want, out)
+		}
+	}
+
+	// The printed configuration is a valid config file that loads to the same values
+	printed := writeFile(t, "printed.yaml", strings.ReplaceAll(out, logging.Redacted, "redacted"))
+	reloaded, err := Load(printed, env(nil))
+	if err != nil {
+		t.Fatalf("Load(printed) error = %v", err)
+	}
+	if reloaded.Server.Port != c.Server.Port || !slices.Equal(reloaded.Server.CORSOrigins, c.Server.CORSOrigins) ||
+		reloaded.Cache.TTL != c.Cache.TTL || reloaded.RateLimit.Rates[ratelimit.OpText] != c.RateLimit.Rates[ratelimit.OpText] {
+		t.Errorf("reloaded config differs:\n%+v\nwant\n%+v", reloaded, c)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sources of a setting, shown by Print
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+)
+
+// LookupFunc looks up an environment variable, like os.LookupEnv
+type LookupFunc func(key string) (string, bool)
+
+// Load reads the configuration file at path, if path is not empty, and then the environment
+// through lookup, which takes precedence. Load .env into the environment first to give it the
+// same precedence. The file is YAML or TOML, chosen by its extension.
+//
+// Load always returns a configuration, so that an invalid one can still be printed. The error
+// lists every value that could not be parsed and every validation failure.
+func Load(path string, lookup LookupFunc) (*Config, error) {
+	c := Default()
+	settings := c.settings()
+
+	var errs []error
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return c, err
+		}
+		errs = append(errs, c.apply(settings, values, path)...)
+	}
+
+	for _, s := range settings {
+		value, ok := lookup(s.env)
+		if !ok {
+			continue
+		}
+		// .env templates leave unused settings empty, so empty means unset unless the setting
+		// tells empty apart
+		if _, acceptsEmpty := s.value.(interface{ acceptsEmpty() bool }); value == "" && !acceptsEmpty {
+			continue
+		}
+		if err := s.value.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			continue
+		}
+		c.sources[s.key] = SourceEnv
+	}
+
+	errs = append(errs, c.Validate())
+	return c, errors.Join(errs...)
+}
+
+// apply sets the settings found in values, read from file. Unknown keys are errors so that typos
+// do not go unnoticed.
+func (c *Config) apply(settings []setting, values map[string]string, file string) []error {
+	byKey := make(map[string]setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", file, key))
+			continue
+		}
+		if err := s.value.Set(values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", file, key, err))
+			continue
+		}
+		c.sources[key] = SourceFile
+	}
+	return errs
+}
+
+// readFile parses a YAML or TOML config file into values by dotted key
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		tree, err = parseTOML(data)
+	default:
+		return nil, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flatten("", tree, values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flatten stores the leaves of tree in values under their dotted keys. Lists become
+// comma-separated values.
+func flatten(prefix string, tree map[string]any, values map[string]string) error {
+	for name, node := range tree {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		switch node := node.(type) {
+		case nil:
+			// An empty YAML section or value leaves the setting alone
+		case map[string]any:
+			if err := flatten(key, node, values); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, len(node))
+			for i, item := range node {
+				switch item.(type) {
+				case map[string]any, []any, nil:
+					return fmt.Errorf("%s: list items must be plain values", key)
+				}
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			values[key] = fmt.Sprint(node)
+		}
+	}
+	return nil
+}
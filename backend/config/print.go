@@ -0,0 +1,75 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Tattsum/enjo/backend/logging"
+)
+
+// Print writes the configuration to w as YAML that can be used as a config file, with secrets
+// redacted. Each setting is annotated with its environment variable and where its value came from.
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	for _, s := range c.settings() {
+		parent := root
+		path := strings.Split(s.key, ".")
+		for _, name := range path[:len(path)-1] {
+			parent = child(parent, name)
+		}
+
+		source := c.sources[s.key]
+		if source == "" {
+			source = SourceDefault
+		}
+		key := &yaml.Node{Kind: yaml.ScalarNode, Value: path[len(path)-1]}
+		node := valueNode(s)
+		node.LineComment = fmt.Sprintf("%s (%s)", s.env, source)
+		parent.Content = append(parent.Content, key, node)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// child returns the mapping named name in parent, adding it if needed
+func child(parent *yaml.Node, name string) *yaml.Node {
+	for i := 0; i+1 < len(parent.Content); i += 2 {
+		if parent.Content[i].Value == name {
+			return parent.Content[i+1]
+		}
+	}
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name}, node)
+	return node
+}
+
+// valueNode renders the value of s, redacting secrets that are set
+func valueNode(s setting) *yaml.Node {
+	text := s.value.String()
+	switch v := s.value.(type) {
+	case *listValue:
+		list := &yaml.Node{Kind: yaml.SequenceNode, Style: yaml.FlowStyle}
+		for _, item := range *v {
+			list.Content = append(list.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+		return list
+	case optionalStringValue:
+		if !v.isSet() {
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}
+		}
+	case *intValue, *floatValue, costValue, *boolValue:
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: text}
+	}
+	if s.secret && text != "" {
+		text = logging.Redacted
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: text}
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/ratelimit"
+)
+
+// setting binds a configuration value to its key in config files and its environment variable
+type setting struct {
+	// key is the dotted path in config files, e.g. server.port
+	key string
+	// env is the environment variable, e.g. PORT
+	env   string
+	value value
+	// secret values are redacted when printed
+	secret bool
+}
+
+// value parses and formats a setting, like flag.Value
+type value interface {
+	String() string
+	Set(string) error
+}
+
+// settings lists every setting of c in the order they are printed
+func (c *Config) settings() []setting {
+	settings := []setting{
+		{key: "gcp.project_id", env: "GCP_PROJECT_ID", value: (*stringValue)(&c.GCP.ProjectID)},
+		{key: "gcp.location", env: "GCP_LOCATION", value: (*stringValue)(&c.GCP.Location)},
+
+		{key: "server.port", env: "PORT", value: (*intValue)(&c.Server.Port)},
+		{key: "server.cors_origins", env: "CORS_ORIGINS", value: (*listValue)(&c.Server.CORSOrigins)},
+		{key: "server.read_timeout", env: "SERVER_READ_TIMEOUT", value: (*durationValue)(&c.Server.ReadTimeout)},
+		{key: "server.write_timeout", env: "SERVER_WRITE_TIMEOUT", value: (*durationValue)(&c.Server.WriteTimeout)},
+		{key: "server.idle_timeout", env: "SERVER_IDLE_TIMEOUT", value: (*durationValue)(&c.Server.IdleTimeout)},
+		{key: "server.shutdown_timeout", env: "SHUTDOWN_TIMEOUT", value: (*durationValue)(&c.Server.ShutdownTimeout)},
+		{key: "server.public_base_url", env: "PUBLIC_BASE_URL", value: (*stringValue)(&c.Server.PublicBaseURL)},
+
+		{key: "gemini.model", env: "GEMINI_MODEL", value: (*stringValue)(&c.Gemini.Model)},
+
+		{key: "imagen.model", env: "IMAGEN_MODEL", value: (*stringValue)(&c.Imagen.Model)},
+		{key: "imagen.image_size", env: "IMAGEN_IMAGE_SIZE", value: (*stringValue)(&c.Imagen.ImageSize)},
+		{key: "imagen.negative_prompt", env: "IMAGEN_NEGATIVE_PROMPT", value: optionalStringValue{&c.Imagen.NegativePrompt}},
+		{key: "imagen.person_generation", env: "IMAGEN_PERSON_GENERATION", value: (*stringValue)(&c.Imagen.PersonGeneration)},
+		{key: "imagen.safety_setting", env: "IMAGEN_SAFETY_SETTING", value: (*stringValue)(&c.Imagen.SafetySetting)},
+
+		{key: "twitter.api_key", env: "TWITTER_API_KEY", value: (*stringValue)(&c.Twitter.APIKey), secret: true},
+		{key: "twitter.api_secret", env: "TWITTER_API_SECRET", value: (*stringValue)(&c.Twitter.APISecret), secret: true},
+		{key: "twitter.access_token", env: "TWITTER_ACCESS_TOKEN", value: (*stringValue)(&c.Twitter.AccessToken), secret: true},
+		{key: "twitter.access_token_secret", env: "TWITTER_ACCESS_TOKEN_SECRET", value: (*stringValue)(&c.Twitter.AccessTokenSecret), secret: true},
+		{key: "twitter.token_encryption_key", env: "TWITTER_TOKEN_ENCRYPTION_KEY", value: (*stringValue)(&c.Twitter.TokenEncryptionKey), secret: true},
+		{key: "twitter.oauth_callback_url", env: "TWITTER_OAUTH_CALLBACK_URL", value: (*stringValue)(&c.Twitter.OAuthCallbackURL)},
+		{key: "twitter.oauth_redirect_url", env: "TWITTER_OAUTH_REDIRECT_URL", value: (*stringValue)(&c.Twitter.OAuthRedirectURL)},
+
+		{key: "database.path", env: "DATABASE_PATH", value: (*stringValue)(&c.Database.Path)},
+
+		{key: "image_store.kind", env: "IMAGE_STORE", value: (*stringValue)(&c.ImageStore.Kind)},
+		{key: "image_store.dir", env: "IMAGE_STORE_DIR", value: (*stringValue)(&c.ImageStore.Dir)},
+		{key: "image_store.s3.endpoint", env: "IMAGE_STORE_S3_ENDPOINT", value: (*stringValue)(&c.ImageStore.S3.Endpoint)},
+		{key: "image_store.s3.bucket", env: "IMAGE_STORE_S3_BUCKET", value: (*stringValue)(&c.ImageStore.S3.Bucket)},
+		{key: "image_store.s3.region", env: "IMAGE_STORE_S3_REGION", value: (*stringValue)(&c.ImageStore.S3.Region)},
+		{key: "image_store.s3.access_key", env: "IMAGE_STORE_S3_ACCESS_KEY", value: (*stringValue)(&c.ImageStore.S3.AccessKey), secret: true},
+		{key: "image_store.s3.secret_key", env: "IMAGE_STORE_S3_SECRET_KEY", value: (*stringValue)(&c.ImageStore.S3.SecretKey), secret: true},
+		{key: "image_store.s3.prefix", env: "IMAGE_STORE_S3_PREFIX", value: (*stringValue)(&c.ImageStore.S3.Prefix)},
+		{key: "image_store.url_secret", env: "IMAGE_URL_SECRET", value: (*stringValue)(&c.ImageStore.URLSecret), secret: true},
+		{key: "image_store.url_ttl", env: "IMAGE_URL_TTL", value: (*durationValue)(&c.ImageStore.URLTTL)},
+
+		{key: "cache.kind", env: "GENERATION_CACHE", value: (*stringValue)(&c.Cache.Kind)},
+		{key: "cache.dir", env: "GENERATION_CACHE_DIR", value: (*stringValue)(&c.Cache.Dir)},
+		{key: "cache.size", env: "GENERATION_CACHE_SIZE", value: (*intValue)(&c.Cache.Size)},
+		{key: "cache.ttl", env: "GENERATION_CACHE_TTL", value: (*durationValue)(&c.Cache.TTL)},
+
+		{key: "auth.api_keys", env: "AUTH_API_KEYS", value: (*stringValue)(&c.Auth.APIKeys), secret: true},
+		{key: "auth.jwks_url", env: "AUTH_JWKS_URL", value: (*stringValue)(&c.Auth.JWKSURL)},
+		{key: "auth.jwks_file", env: "AUTH_JWKS_FILE", value: (*stringValue)(&c.Auth.JWKSFile)},
+		{key: "auth.issuer", env: "AUTH_ISSUER", value: (*stringValue)(&c.Auth.Issuer)},
+		{key: "auth.audience", env: "AUTH_AUDIENCE", value: (*stringValue)(&c.Auth.Audience)},
+		{key: "auth.required", env: "AUTH_REQUIRED", value: (*boolValue)(&c.Auth.Required)},
+
+		{key: "graphql.max_complexity", env: "GRAPHQL_MAX_COMPLEXITY", value: (*intValue)(&c.GraphQL.MaxComplexity)},
+		{key: "graphql.max_depth", env: "GRAPHQL_MAX_DEPTH", value: (*intValue)(&c.GraphQL.MaxDepth)},
+		{key: "graphql.introspection", env: "GRAPHQL_INTROSPECTION", value: (*boolValue)(&c.GraphQL.Introspection)},
+		{key: "graphql.persisted_queries", env: "GRAPHQL_PERSISTED_QUERIES", value: (*stringValue)(&c.GraphQL.PersistedQueries)},
+	}
+
+	for _, op := range ratelimit.Operations {
+		name := strings.ToLower(string(op))
+		settings = append(settings,
+			setting{key: "rate_limit.rates." + name, env: "RATE_LIMIT_" + string(op), value: rateValue{rates: c.RateLimit.Rates, op: op}},
+			setting{key: "rate_limit.costs." + name, env: "COST_PER_" + string(op), value: costValue{costs: c.RateLimit.Costs, op: op}},
+		)
+	}
+
+	return append(settings, []setting{
+		{key: "rate_limit.daily_budget", env: "DAILY_COST_BUDGET", value: (*floatValue)(&c.RateLimit.DailyBudget)},
+
+		{key: "posting.force_disclaimer_level", env: "POSTING_FORCE_DISCLAIMER_LEVEL", value: (*intValue)(&c.Posting.ForceDisclaimerLevel)},
+		{key: "posting.force_hashtag_level", env: "POSTING_FORCE_HASHTAG_LEVEL", value: (*intValue)(&c.Posting.ForceHashtagLevel)},
+		{key: "posting.confirmation_level", env: "POSTING_CONFIRMATION_LEVEL", value: (*intValue)(&c.Posting.ConfirmationLevel)},
+		{key: "posting.default_level", env: "POSTING_DEFAULT_LEVEL", value: (*intValue)(&c.Posting.DefaultLevel)},
+		{key: "posting.confirmation_secret", env: "POSTING_CONFIRMATION_SECRET", value: (*stringValue)(&c.Posting.ConfirmationSecret), secret: true},
+		{key: "posting.block_on_moderation_error", env: "POSTING_BLOCK_ON_MODERATION_ERROR", value: (*boolValue)(&c.Posting.BlockOnModerationError)},
+
+		{key: "features.metrics", env: "METRICS", value: (*boolValue)(&c.Features.Metrics)},
+		{key: "features.rate_limit", env: "RATE_LIMIT", value: (*boolValue)(&c.Features.RateLimit)},
+		{key: "features.posting_moderation", env: "POSTING_MODERATION", value: (*boolValue)(&c.Features.PostingModeration)},
+		{key: "features.image_moderation", env: "IMAGE_MODERATION", value: (*boolValue)(&c.Features.ImageModeration)},
+
+		{key: "logging.level", env: "LOG_LEVEL", value: (*stringValue)(&c.Logging.Level)},
+		{key: "logging.format", env: "LOG_FORMAT", value: (*stringValue)(&c.Logging.Format)},
+		{key: "logging.debug", env: "DEBUG", value: (*boolValue)(&c.Logging.Debug)},
+
+		{key: "tracing.exporter", env: "OTEL_TRACES_EXPORTER", value: (*stringValue)(&c.Tracing.Exporter)},
+
+		{key: "health.check_ttl", env: "HEALTH_CHECK_TTL", value: (*durationValue)(&c.Health.CheckTTL)},
+
+		{key: "render.font_path", env: "RENDER_FONT_PATH", value: (*stringValue)(&c.Render.FontPath)},
+	}...)
+}
+
+type stringValue string
+
+func (v *stringValue) String() string { return string(*v) }
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+// optionalStringValue distinguishes an empty value from an unset one, so an empty environment
+// variable sets it rather than being ignored
+type optionalStringValue struct {
+	target **string
+}
+
+func (v optionalStringValue) String() string {
+	if *v.target == nil {
+		return ""
+	}
+	return **v.target
+}
+
+func (v optionalStringValue) Set(s string) error {
+	*v.target = &s
+	return nil
+}
+
+func (v optionalStringValue) isSet() bool { return *v.target != nil }
+
+func (optionalStringValue) acceptsEmpty() bool { return true }
+
+type intValue int
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("must be an integer, got %q", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+type floatValue float64
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return fmt.Errorf("must be a number, got %q", s)
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+// boolValue accepts on and off as well as the forms strconv.ParseBool does
+type boolValue bool
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+func (v *boolValue) Set(s string) error {
+	switch s = strings.ToLower(strings.TrimSpace(s)); s {
+	case "on":
+		*v = true
+	case "off":
+		*v = false
+	default:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("must be a boolean (true/false or on/off), got %q", s)
+		}
+		*v = boolValue(b)
+	}
+	return nil
+}
+
+type durationValue time.Duration
+
+func (v *durationValue) String() string { return formatDuration(time.Duration(*v)) }
+
+func (v *durationValue) Set(s string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("must be a duration such as 30s or 5m, got %q", s)
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+// formatDuration formats d without trailing zero units, e.g. 1m rather than 1m0s
+func formatDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// listValue is a comma-separated list
+type listValue []string
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+func (v *listValue) Set(s string) error {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v = items
+	return nil
+}
+
+// rateValue is an operation's rate limit, written as limit/period or off
+type rateValue struct {
+	rates map[ratelimit.Operation]ratelimit.Rate
+	op    ratelimit.Operation
+}
+
+func (v rateValue) String() string {
+	rate := v.rates[v.op]
+	if rate.Limit == 0 {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", rate.Limit, formatDuration(rate.Period))
+}
+
+func (v rateValue) Set(s string) error {
+	rate, err := ratelimit.ParseRate(s)
+	if err != nil {
+		return err
+	}
+	v.rates[v.op] = rate
+	return nil
+}
+
+// costValue is the estimated cost of one unit of an operation in USD
+type costValue struct {
+	costs map[ratelimit.Operation]float64
+	op    ratelimit.Operation
+}
+
+func (v costValue) String() string {
+	return strconv.FormatFloat(v.costs[v.op], 'g', -1, 64)
+}
+
+func (v costValue) Set(s string) error {
+	cost, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return fmt.Errorf("must be a number, got %q", s)
+	}
+	v.costs[v.op] = cost
+	return nil
+}
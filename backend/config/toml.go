@@ -0,0 +1,246 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseTOML parses the subset of TOML config files need: [tables], dotted and quoted keys, and
+// string, integer, float, boolean and array values. Inline tables, arrays of tables, multi-line
+// strings and dates are not supported.
+func parseTOML(data []byte) (map[string]any, error) {
+	root := map[string]any{}
+	table := root
+
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: arrays of tables are not supported", lineNo)
+			}
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated table header", lineNo)
+			}
+			path, err := parseKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			if table, err = descend(root, path); err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+			continue
+		}
+
+		keyPart, valuePart, ok := cutOutsideQuotes(line, '=')
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		path, err := parseKey(keyPart)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		// Arrays may span lines until their closing bracket
+		valuePart = strings.TrimSpace(valuePart)
+		for strings.HasPrefix(valuePart, "[") && !balanced(valuePart) && i+1 < len(lines) {
+			i++
+			valuePart += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+		value, err := parseValue(valuePart)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		parent, err := descend(table, path[:len(path)-1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		name := path[len(path)-1]
+		if _, exists := parent[name]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key %q", lineNo, strings.Join(path, "."))
+		}
+		parent[name] = value
+	}
+	return root, nil
+}
+
+// descend returns the table at path below table, creating missing tables
+func descend(table map[string]any, path []string) (map[string]any, error) {
+	for _, name := range path {
+		next, exists := table[name]
+		if !exists {
+			child := map[string]any{}
+			table[name] = child
+			table = child
+			continue
+		}
+		child, ok := next.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("key %q is a value, not a table", name)
+		}
+		table = child
+	}
+	return table, nil
+}
+
+// parseKey splits a bare, quoted or dotted key into its parts
+func parseKey(s string) ([]string, error) {
+	var parts []string
+	for s = strings.TrimSpace(s); ; {
+		var part string
+		if strings.HasPrefix(s, `"`) || strings.HasPrefix(s, "'") {
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quoted key %s", s)
+			}
+			part, s = s[1:end+1], strings.TrimSpace(s[end+2:])
+		} else {
+			end := strings.IndexByte(s, '.')
+			if end < 0 {
+				end = len(s)
+			}
+			part, s = strings.TrimSpace(s[:end]), strings.TrimSpace(s[end:])
+			if part == "" || strings.ContainsAny(part, " \t\"'") {
+				return nil, fmt.Errorf("invalid key %q", part)
+			}
+		}
+		parts = append(parts, part)
+
+		if s == "" {
+			return parts, nil
+		}
+		if s[0] != '.' {
+			return nil, fmt.Errorf("invalid key near %q", s)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+}
+
+// parseValue parses a string, number, boolean or array
+func parseValue(s string) (any, error) {
+	switch {
+	case s == "":
+		return nil, fmt.Errorf("missing value")
+	case strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''"):
+		return nil, fmt.Errorf("multi-line strings are not supported")
+	case s[0] == '"':
+		value, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid string %s", s)
+		}
+		return value, nil
+	case s[0] == '\'':
+		if len(s) < 2 || !strings.HasSuffix(s, "'") || strings.Contains(s[1:len(s)-1], "'") {
+			return nil, fmt.Errorf("invalid literal string %s", s)
+		}
+		return s[1 : len(s)-1], nil
+	case s[0] == '[':
+		return parseArray(s)
+	case s[0] == '{':
+		return nil, fmt.Errorf("inline tables are not supported")
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	}
+
+	number := strings.ReplaceAll(s, "_", "")
+	if n, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("invalid value %s (quote strings)", s)
+}
+
+// parseArray parses a single-line array of plain values
+func parseArray(s string) ([]any, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated array %s", s)
+	}
+	body := strings.TrimSpace(s[1 : len(s)-1])
+
+	items := []any{}
+	for body != "" {
+		item, rest, _ := cutOutsideQuotes(body, ',')
+		item = strings.TrimSpace(item)
+		if item == "" {
+			if strings.TrimSpace(rest) == "" {
+				break // trailing comma
+			}
+			return nil, fmt.Errorf("empty array item in %s", s)
+		}
+		if strings.HasPrefix(item, "[") {
+			return nil, fmt.Errorf("nested arrays are not supported")
+		}
+		value, err := parseValue(item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+		body = strings.TrimSpace(rest)
+	}
+	return items, nil
+}
+
+// stripComment removes a # comment that is not inside a string
+func stripComment(line string) string {
+	before, _, _ := cutOutsideQuotes(line, '#')
+	return before
+}
+
+// cutOutsideQuotes cuts s around the first sep that is not inside a quoted string
+func cutOutsideQuotes(s string, sep byte) (before, after string, found bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == sep:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// balanced reports whether every bracket opened in s outside strings is closed
+func balanced(s string) bool {
+	unquoted := stripQuoted(s)
+	return strings.Count(unquoted, "[") == strings.Count(unquoted, "]")
+}
+
+// stripQuoted removes quoted strings from s so brackets inside them are not counted
+func stripQuoted(s string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	got, err := parseTOML([]byte(`
+title = "enjo # not a comment" # a comment
+"quoted key" = 'C:\path'
+server.port = 8_080
+ratio = 0.5
+enabled = true
+
+[rate_limit.rates]
+text = "10/1m"
+
+[server]
+origins = ["https://a.example.com", 'https://b.example.com',]
+`))
+	if err != nil {
+		t.Fatalf("parseTOML() error = %v", err)
+	}
+
+	want := map[string]any{
+		"title":      "enjo # not a comment",
+		"quoted key": `C:\path`,
+		"ratio":      0.5,
+		"enabled":    true,
+		"server": map[string]any{
+			"port":    int64(8080),
+			"origins": []any{"https://a.example.com", "https://b.example.com"},
+		},
+		"rate_limit": map[string]any{"rates": map[string]any{"text": "10/1m"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTOML() = %#v, want %#v", got, want)
+	}
+}
+
+func TestParseTOML_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"duplicate key", "a = 1\na = 2"},
+		{"value used as table", "a = 1\n[a]"},
+		{"missing value", "a ="},
+		{"unquoted string", "a = hello"},
+		{"unterminated header", "[server"},
+		{"array of tables", "[[servers]]"},
+		{"inline table", "a = { b = 1 }"},
+		{"nested array", "a = [[1]]"},
+		{"unterminated array", "a = [1, 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseTOML([]byte(tt.input)); err == nil {
+				t.Error("parseTOML() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/logging"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+	"github.com/Tattsum/enjo/backend/secret"
+	"github.com/Tattsum/enjo/backend/tracing"
+)
+
+// maxPostingLevel is the highest inflammatory level a posting policy threshold can name
+const maxPostingLevel = 5
+
+// Validate checks every setting and returns all problems found, joined, or nil
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	v.check(c.GCP.ProjectID != "", "GCP_PROJECT_ID is required")
+
+	v.check(c.Server.Port > 0 && c.Server.Port <= 65535, "PORT must be between 1 and 65535, got %d", c.Server.Port)
+	for _, origin := range c.Server.CORSOrigins {
+		v.check(origin == "*" || isOrigin(origin), "CORS_ORIGINS: %q must be * or an origin such as https://example.com", origin)
+	}
+	v.positive("SERVER_READ_TIMEOUT", c.Server.ReadTimeout)
+	v.positive("SERVER_WRITE_TIMEOUT", c.Server.WriteTimeout)
+	v.positive("SERVER_IDLE_TIMEOUT", c.Server.IdleTimeout)
+	v.positive("SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout)
+	v.url("PUBLIC_BASE_URL", c.Server.PublicBaseURL)
+
+	v.check(c.Gemini.Model != "", "GEMINI_MODEL must not be empty")
+
+	t := c.Twitter
+	v.check((t.AccessToken == "") == (t.AccessTokenSecret == ""),
+		"TWITTER_ACCESS_TOKEN and TWITTER_ACCESS_TOKEN_SECRET must be set together")
+	v.check(t.AccessToken == "" || (t.APIKey != "" && t.APISecret != ""),
+		"TWITTER_ACCESS_TOKEN needs TWITTER_API_KEY and TWITTER_API_SECRET")
+	if t.TokenEncryptionKey != "" {
+		v.check(t.APIKey != "" && t.APISecret != "",
+			"TWITTER_TOKEN_ENCRYPTION_KEY needs TWITTER_API_KEY and TWITTER_API_SECRET")
+		if _, err := secret.ParseKey(t.TokenEncryptionKey); err != nil {
+			v.add("invalid TWITTER_TOKEN_ENCRYPTION_KEY: %v", err)
+		}
+	}
+	v.url("TWITTER_OAUTH_CALLBACK_URL", t.OAuthCallbackURL)
+	v.url("TWITTER_OAUTH_REDIRECT_URL", t.OAuthRedirectURL)
+
+	v.check(c.Database.Path != "", "DATABASE_PATH must not be empty")
+
+	switch c.ImageStore.Kind {
+	case ImageStoreLocal:
+		v.check(c.ImageStore.Dir != "", "IMAGE_STORE_DIR must not be empty")
+	case ImageStoreS3:
+		v.check(c.ImageStore.S3.Endpoint != "" && c.ImageStore.S3.Bucket != "",
+			"IMAGE_STORE=s3 needs IMAGE_STORE_S3_ENDPOINT and IMAGE_STORE_S3_BUCKET")
+		v.url("IMAGE_STORE_S3_ENDPOINT", c.ImageStore.S3.Endpoint)
+	case ImageStoreNone:
+	default:
+		v.add("unknown IMAGE_STORE %q (want local, s3 or none)", c.ImageStore.Kind)
+	}
+	v.check(c.ImageStore.URLTTL >= 0, "IMAGE_URL_TTL must not be negative")
+
+	switch c.Cache.Kind {
+	case CacheNone:
+	case CacheMemory:
+		v.check(c.Cache.Size >= 1, "GENERATION_CACHE_SIZE must be a positive integer, got %d", c.Cache.Size)
+	case CacheDisk:
+		v.check(c.Cache.Dir != "", "GENERATION_CACHE_DIR must not be empty")
+	default:
+		v.add("unknown GENERATION_CACHE %q (want memory, disk or none)", c.Cache.Kind)
+	}
+	v.positive("GENERATION_CACHE_TTL", c.Cache.TTL)
+
+	if c.Auth.APIKeys != "" {
+		if _, err := auth.ParseAPIKeys(c.Auth.APIKeys); err != nil {
+			v.add("invalid AUTH_API_KEYS: %v", err)
+		}
+	}
+	v.check(c.Auth.JWKSURL == "" || c.Auth.JWKSFile == "", "set only one of AUTH_JWKS_URL and AUTH_JWKS_FILE")
+	v.url("AUTH_JWKS_URL", c.Auth.JWKSURL)
+
+	v.check(c.GraphQL.MaxComplexity >= 0, "GRAPHQL_MAX_COMPLEXITY must not be negative")
+	v.check(c.GraphQL.MaxDepth >= 0, "GRAPHQL_MAX_DEPTH must not be negative")
+
+	for _, op := range ratelimit.Operations {
+		v.check(c.RateLimit.Costs[op] >= 0, "COST_PER_%s must not be negative", op)
+	}
+	v.check(c.RateLimit.DailyBudget >= 0, "DAILY_COST_BUDGET must not be negative")
+
+	levels := []struct {
+		name  string
+		level int
+	}{
+		{"POSTING_FORCE_DISCLAIMER_LEVEL", c.Posting.ForceDisclaimerLevel},
+		{"POSTING_FORCE_HASHTAG_LEVEL", c.Posting.ForceHashtagLevel},
+		{"POSTING_CONFIRMATION_LEVEL", c.Posting.ConfirmationLevel},
+		{"POSTING_DEFAULT_LEVEL", c.Posting.DefaultLevel},
+	}
+	for _, l := range levels {
+		v.check(l.level >= 0 && l.level <= maxPostingLevel, "%s must be between 1 and %d, or 0 for the policy default, got %d", l.name, maxPostingLevel, l.level)
+	}
+
+	if c.Logging.Level != "" {
+		if _, err := logging.ParseLevel(c.Logging.Level); err != nil {
+			v.add("invalid LOG_LEVEL: %v", err)
+		}
+	}
+	v.check(slices.Contains([]string{"", logging.FormatJSON, logging.FormatText}, strings.ToLower(c.Logging.Format)),
+		"LOG_FORMAT must be %s or %s, got %q", logging.FormatJSON, logging.FormatText, c.Logging.Format)
+
+	v.check(slices.Contains([]string{"", tracing.ExporterNone, tracing.ExporterOTLP, tracing.ExporterConsole, tracing.ExporterStdout}, c.Tracing.Exporter),
+		"OTEL_TRACES_EXPORTER must be otlp, console, stdout or none, got %q", c.Tracing.Exporter)
+
+	v.positive("HEALTH_CHECK_TTL", c.Health.CheckTTL)
+
+	return errors.Join(v.errs...)
+}
+
+// validator collects validation failures
+type validator struct {
+	errs []error
+}
+
+func (v *validator) add(format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) check(ok bool, format string, args ...any) {
+	if !ok {
+		v.add(format, args...)
+	}
+}
+
+func (v *validator) positive(name string, d time.Duration) {
+	v.check(d > 0, "%s must be a positive duration, got %s", name, d)
+}
+
+// url checks that value, if set, is an absolute http or https URL
+func (v *validator) url(name, value string) {
+	if value == "" {
+		return
+	}
+	u, err := url.Parse(value)
+	v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+		"%s must be an http or https URL, got %q", name, value)
+}
+
+// isOrigin reports whether s is a scheme and host without a path, as browsers send in Origin
+func isOrigin(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" &&
+		u.Path == "" && u.RawQuery == "" && u.Fragment == "" && u.User == nil
+}
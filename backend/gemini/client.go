@@ -4,10 +4,18 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"cloud.google.com/go/vertexai/genai"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/Tattsum/enjo/backend/metrics"
+	"github.com/Tattsum/enjo/backend/tracing"
 )
 
 const (
@@ -16,19 +24,43 @@ const (
 	defaultTopK           = 40
 	defaultTopP           = 0.95
 	defaultMaxOutputToken = 2048 // Increased from 1024 to avoid FinishReasonMaxTokens
-	defaultModel          = "gemini-2.5-flash"
+)
+
+// DefaultModel is the Gemini model used unless WithModel selects another
+const DefaultModel = "gemini-2.5-flash"
+
+// Task types, used to label requests in metrics
+const (
+	TaskInflammatory = "inflammatory"
+	TaskExplanation  = "explanation"
+	TaskReply        = "reply"
+	TaskContent      = "content"
+	TaskVision       = "vision"
 )
 
 // Client is a Vertex AI client for generating inflammatory text and replies
 type Client struct {
 	client    *genai.Client
 	model     *genai.GenerativeModel
+	modelName string
 	projectID string
 	location  string
 }
 
+// ClientOption is a functional option for NewClient
+type ClientOption func(*Client)
+
+// WithModel selects the Gemini model, e.g. "gemini-2.5-pro"
+func WithModel(name string) ClientOption {
+	return func(c *Client) {
+		if name != "" {
+			c.modelName = name
+		}
+	}
+}
+
 // NewClient creates a new Vertex AI client using Application Default Credentials
-func NewClient(ctx context.Context, projectID, location string) (*Client, error) {
+func NewClient(ctx context.Context, projectID, location string, options ...ClientOption) (*Client, error) {
 	if projectID == "" {
 		return nil, errors.New("GCP project ID is required")
 	}
@@ -41,20 +73,25 @@ func NewClient(ctx context.Context, projectID, location string) (*Client, error)
 		return nil, fmt.Errorf("failed to create Vertex AI client: %w", err)
 	}
 
-	model := client.GenerativeModel(defaultModel)
+	c := &Client{
+		client:    client,
+		modelName: DefaultModel,
+		projectID: projectID,
+		location:  location,
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	model := client.GenerativeModel(c.modelName)
 
 	// Configure the model for consistent output
 	model.SetTemperature(defaultTemperature)
 	model.SetTopK(int32(defaultTopK))
 	model.SetTopP(defaultTopP)
 	model.SetMaxOutputTokens(int32(defaultMaxOutputToken))
+	c.model = model
 
-	return &Client{
-		client:    client,
-		model:     model,
-		projectID: projectID,
-		location:  location,
-	}, nil
+	return c, nil
 }
 
 // Close closes the Vertex AI client
@@ -76,7 +113,7 @@ func (c *Client) GenerateInflammatoryText(ctx context.Context, original string,
 	prompt := buildInflammatoryPrompt(original, level)
 
 	// Generate content
-	return c.generate(ctx, prompt, "no content generated")
+	return c.generate(ctx, TaskInflammatory, prompt, "no content generated", []attribute.KeyValue{attribute.Int("enjo.level", level)})
 }
 
 // GenerateExplanation generates an explanation of why the text is inflammatory
@@ -93,7 +130,7 @@ func (c *Client) GenerateExplanation(ctx context.Context, original, inflammatory
 	prompt := buildExplanationPrompt(original, inflammatory)
 
 	// Generate content
-	return c.generate(ctx, prompt, "no explanation generated")
+	return c.generate(ctx, TaskExplanation, prompt, "no explanation generated", nil)
 }
 
 // GenerateReply generates a reply based on the reply type
@@ -110,42 +147,163 @@ func (c *Client) GenerateReply(ctx context.Context, text, replyType string) (str
 	prompt := buildReplyPrompt(text, replyType)
 
 	// Generate content
-	return c.generate(ctx, prompt, "no reply generated")
+	return c.generate(ctx, TaskReply, prompt, "no reply generated", []attribute.KeyValue{attribute.String("enjo.reply_type", replyType)})
 }
 
 // GenerateContent generates content from a given prompt (public method for general use)
 func (c *Client) GenerateContent(ctx context.Context, prompt string) (string, error) {
-	return c.generate(ctx, prompt, "no content generated")
+	return c.generate(ctx, TaskContent, prompt, "no content generated", nil)
+}
+
+// Ping checks that Vertex AI accepts the credentials by counting the tokens of a short text,
+// which generates nothing and is not billed
+func (c *Client) Ping(ctx context.Context) error {
+	if _, err := c.model.CountTokens(ctx, genai.Text("ping")); err != nil {
+		return fmt.Errorf("failed to reach Vertex AI: %w", err)
+	}
+	return nil
+}
+
+// InflammatoryPrompt returns the prompt sent by GenerateInflammatoryText
+func (*Client) InflammatoryPrompt(original string, level int) string {
+	return buildInflammatoryPrompt(original, level)
+}
+
+// ExplanationPrompt returns the prompt sent by GenerateExplanation
+func (*Client) ExplanationPrompt(original, inflammatory string) string {
+	return buildExplanationPrompt(original, inflammatory)
+}
+
+// ReplyPrompt returns the prompt sent by GenerateReply
+func (*Client) ReplyPrompt(text, replyType string) string {
+	return buildReplyPrompt(text, replyType)
 }
 
-// generate is a helper function to generate content from Vertex AI
-func (c *Client) generate(ctx context.Context, prompt, emptyResultMsg string) (string, error) {
-	resp, err := c.model.GenerateContent(ctx, genai.Text(prompt))
+// ModelParameters describes the model and generation settings every request is sent with
+func (c *Client) ModelParameters() string {
+	return fmt.Sprintf("model=%s temperature=%v topK=%d topP=%v maxOutputTokens=%d",
+		c.modelName, defaultTemperature, defaultTopK, defaultTopP, defaultMaxOutputToken)
+}
+
+// GenerateContentWithImage generates content from a prompt about a PNG, JPEG or WebP image
+func (c *Client) GenerateContentWithImage(ctx context.Context, prompt string, image []byte) (string, error) {
+	format, err := imageFormat(image)
 	if err != nil {
+		return "", err
+	}
+	return c.generate(ctx, TaskVision, prompt, "no content generated",
+		[]attribute.KeyValue{attribute.Int("enjo.image.bytes", len(image))}, genai.ImageData(format, image))
+}
+
+// imageFormat returns the image MIME subtype Gemini expects for the image data
+func imageFormat(data []byte) (string, error) {
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return "png", nil
+	case "image/jpeg":
+		return "jpeg", nil
+	case "image/webp":
+		return "webp", nil
+	default:
+		return "", errors.New("image must be PNG, JPEG or WebP")
+	}
+}
+
+// generate is a helper function to generate content from Vertex AI.
+// Extra parts such as images are sent after the prompt. task labels the request in metrics and
+// names its span, which also carries attrs.
+func (c *Client) generate(ctx context.Context, task, prompt, emptyResultMsg string, attrs []attribute.KeyValue, extra ...genai.Part) (result string, err error) {
+	ctx, span := tracing.Start(ctx, "gemini."+task, append([]attribute.KeyValue{
+		attribute.String("gen_ai.system", "vertex_ai"),
+		attribute.String("gen_ai.request.model", c.modelName),
+		attribute.Int("enjo.prompt.length", utf8.RuneCountInString(prompt)),
+	}, attrs...)...)
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
+	resp, err := c.model.GenerateContent(ctx, append([]genai.Part{genai.Text(prompt)}, extra...)...)
+	observe(task, start, resp, err)
+	annotate(span, resp, err)
+	if err != nil {
+		slog.WarnContext(ctx, "gemini request failed", "task", task, "duration", time.Since(start), "error", err)
 		return "", fmt.Errorf("failed to generate content: %w", err)
 	}
+	slog.DebugContext(ctx, "gemini request", "task", task, "duration", time.Since(start), "candidates", len(resp.Candidates), "prompt", prompt)
 
-	// Debug: Check for safety ratings
-	const finishReasonSafety = 3
-	if len(resp.Candidates) > 0 && resp.Candidates[0].FinishReason != 0 {
-		log.Printf("WARNING: Content generation finished with reason: %v (prompt length: %d chars)",
-			resp.Candidates[0].FinishReason, len(prompt))
-		if resp.Candidates[0].FinishReason == finishReasonSafety {
-			log.Print("Content blocked by safety filter")
+	if len(resp.Candidates) > 0 {
+		if finishReason := resp.Candidates[0].FinishReason; finishReason != genai.FinishReasonStop && finishReason != genai.FinishReasonUnspecified {
+			slog.WarnContext(ctx, "gemini generation finished early", "task", task,
+				"finish_reason", finishReason.String(), "safety_blocked", finishReason == genai.FinishReasonSafety, "prompt", prompt)
 		}
 	}
 
-	result := extractTextFromResponse(resp)
+	result = extractTextFromResponse(resp)
 	if result == "" {
-		// Debug: Log more details about the empty response
-		log.Printf("WARNING: Empty response from Gemini API. Candidates: %d, Prompt preview: %.100s...",
-			len(resp.Candidates), prompt)
+		slog.WarnContext(ctx, "gemini returned an empty response", "candidates", len(resp.Candidates), "prompt", prompt)
 		return "", errors.New(emptyResultMsg)
 	}
 
 	return result, nil
 }
 
+// observe records a generation request in metrics: its duration and outcome, the finish reason
+// of each candidate, safety blocks and the tokens used
+func observe(task string, start time.Time, resp *genai.GenerateContentResponse, err error) {
+	var blocked *genai.BlockedError
+	switch {
+	case errors.As(err, &blocked):
+		metrics.ObserveUpstream(metrics.ServiceGemini, task, metrics.OutcomeBlocked, start)
+		metrics.SafetyBlocks.WithLabelValues(metrics.ServiceGemini, task).Inc()
+		if blocked.Candidate != nil {
+			metrics.FinishReasons.WithLabelValues(task, finishReasonLabel(blocked.Candidate.FinishReason)).Inc()
+		}
+		return
+	case err != nil:
+		metrics.ObserveUpstream(metrics.ServiceGemini, task, metrics.OutcomeError, start)
+		return
+	}
+
+	metrics.ObserveUpstream(metrics.ServiceGemini, task, metrics.OutcomeOK, start)
+	for _, candidate := range resp.Candidates {
+		metrics.FinishReasons.WithLabelValues(task, finishReasonLabel(candidate.FinishReason)).Inc()
+		if candidate.FinishReason == genai.FinishReasonSafety {
+			metrics.SafetyBlocks.WithLabelValues(metrics.ServiceGemini, task).Inc()
+		}
+	}
+	if usage := resp.UsageMetadata; usage != nil {
+		metrics.Tokens.WithLabelValues(task, "prompt").Add(float64(usage.PromptTokenCount))
+		metrics.Tokens.WithLabelValues(task, "candidates").Add(float64(usage.CandidatesTokenCount))
+		metrics.Tokens.WithLabelValues(task, "thoughts").Add(float64(usage.ThoughtsTokenCount))
+	}
+}
+
+// annotate adds the finish reasons and token counts of a response to span
+func annotate(span trace.Span, resp *genai.GenerateContentResponse, err error) {
+	var blocked *genai.BlockedError
+	if errors.As(err, &blocked) && blocked.Candidate != nil {
+		span.SetAttributes(attribute.StringSlice("gen_ai.response.finish_reasons", []string{finishReasonLabel(blocked.Candidate.FinishReason)}))
+	}
+	if resp == nil {
+		return
+	}
+	reasons := make([]string, 0, len(resp.Candidates))
+	for _, candidate := range resp.Candidates {
+		reasons = append(reasons, finishReasonLabel(candidate.FinishReason))
+	}
+	span.SetAttributes(attribute.StringSlice("gen_ai.response.finish_reasons", reasons))
+	if usage := resp.UsageMetadata; usage != nil {
+		span.SetAttributes(
+			attribute.Int("gen_ai.usage.input_tokens", int(usage.PromptTokenCount)),
+			attribute.Int("gen_ai.usage.output_tokens", int(usage.CandidatesTokenCount)),
+		)
+	}
+}
+
+// finishReasonLabel returns a finish reason without its type prefix, e.g. "Stop" or "MaxTokens"
+func finishReasonLabel(reason genai.FinishReason) string {
+	return strings.TrimPrefix(reason.String(), "FinishReason")
+}
+
 // buildInflammatoryPrompt builds a prompt for generating inflammatory text
 func buildInflammatoryPrompt(original string, level int) string {
 	levelDesc := map[int]string{
@@ -0,0 +1,43 @@
+package gemini
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/Tattsum/enjo/backend/metrics"
+)
+
+func TestObserve(t *testing.T) {
+	const task = "observe-test"
+	blocks := metrics.SafetyBlocks.WithLabelValues(metrics.ServiceGemini, task)
+
+	observe(task, time.Now(), &genai.GenerateContentResponse{
+		Candidates:    []*genai.Candidate{{FinishReason: genai.FinishReasonMaxTokens}},
+		UsageMetadata: &genai.UsageMetadata{PromptTokenCount: 120, CandidatesTokenCount: 30, ThoughtsTokenCount: 7},
+	}, nil)
+	if got := testutil.ToFloat64(metrics.FinishReasons.WithLabelValues(task, "MaxTokens")); got != 1 {
+		t.Errorf("MaxTokens finish reasons = %v, want 1", got)
+	}
+	for kind, want := range map[string]float64{"prompt": 120, "candidates": 30, "thoughts": 7} {
+		if got := testutil.ToFloat64(metrics.Tokens.WithLabelValues(task, kind)); got != want {
+			t.Errorf("%s tokens = %v, want %v", kind, got, want)
+		}
+	}
+	if got := testutil.ToFloat64(blocks); got != 0 {
+		t.Errorf("safety blocks = %v, want 0", got)
+	}
+
+	// The SDK reports responses stopped by the safety filters as errors
+	observe(task, time.Now(), nil, &genai.BlockedError{Candidate: &genai.Candidate{FinishReason: genai.FinishReasonSafety}})
+	observe(task, time.Now(), nil, errors.New("deadline exceeded"))
+	if got := testutil.ToFloat64(blocks); got != 1 {
+		t.Errorf("safety blocks = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(metrics.FinishReasons.WithLabelValues(task, "Safety")); got != 1 {
+		t.Errorf("Safety finish reasons = %v, want 1", got)
+	}
+}
@@ -0,0 +1,121 @@
+package gqlserver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// errQueryNotAllowed is the error code of queries missing from the allowlist
+const errQueryNotAllowed = "PERSISTED_QUERY_NOT_ALLOWED"
+
+// Allowlist is the set of queries a production deployment executes, keyed by the SHA-256 of
+// their text as in automatic persisted queries. It doubles as the read-only query cache of
+// the persisted query extension, so clients can send just the hash of an allowed query.
+type Allowlist struct {
+	queries map[string]string
+}
+
+var _ interface {
+	graphql.Cache[string]
+	graphql.OperationParameterMutator
+	graphql.HandlerExtension
+} = (*Allowlist)(nil)
+
+// NewAllowlist allows exactly the given queries
+func NewAllowlist(queries ...string) *Allowlist {
+	a := &Allowlist{queries: make(map[string]string, len(queries))}
+	for _, query := range queries {
+		a.queries[queryHash(query)] = query
+	}
+	return a
+}
+
+// ParseAllowlist reads a persisted query manifest. Both Apollo's manifest format
+// ({"format": "apollo-persisted-query-manifest", "operations": [{"id", "body"}]}) and a plain
+// object mapping hashes to queries are accepted. Every hash must match its query.
+func ParseAllowlist(data []byte) (*Allowlist, error) {
+	var manifest struct {
+		Format     string `json:"format"`
+		Operations []struct {
+			ID   string `json:"id"`
+			Body string `json:"body"`
+		} `json:"operations"`
+	}
+	if err := json.Unmarshal(data, &manifest); err == nil && manifest.Format != "" {
+		if manifest.Format != "apollo-persisted-query-manifest" {
+			return nil, fmt.Errorf("unsupported persisted query manifest format %q", manifest.Format)
+		}
+		queries := make(map[string]string, len(manifest.Operations))
+		for _, op := range manifest.Operations {
+			queries[op.ID] = op.Body
+		}
+		return newVerifiedAllowlist(queries)
+	}
+
+	var queries map[string]string
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return nil, fmt.Errorf("invalid persisted query manifest: %w", err)
+	}
+	return newVerifiedAllowlist(queries)
+}
+
+func newVerifiedAllowlist(queries map[string]string) (*Allowlist, error) {
+	if len(queries) == 0 {
+		return nil, errors.New("persisted query manifest contains no queries")
+	}
+	for hash, query := range queries {
+		if queryHash(query) != hash {
+			return nil, fmt.Errorf("persisted query %s does not match its SHA-256 hash", hash)
+		}
+	}
+	return &Allowlist{queries: queries}, nil
+}
+
+// Len returns the number of allowed queries
+func (a *Allowlist) Len() int {
+	return len(a.queries)
+}
+
+// Get implements graphql.Cache by looking up an allowed query by its hash
+func (a *Allowlist) Get(_ context.Context, hash string) (string, bool) {
+	query, ok := a.queries[hash]
+	return query, ok
+}
+
+// Add implements graphql.Cache. Queries can only be allowed through the manifest, so it does nothing.
+func (a *Allowlist) Add(context.Context, string, string) {}
+
+// ExtensionName implements graphql.HandlerExtension
+func (a *Allowlist) ExtensionName() string {
+	return "PersistedQueryAllowlist"
+}
+
+// Validate implements graphql.HandlerExtension
+func (a *Allowlist) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// MutateOperationParameters implements graphql.OperationParameterMutator. It runs after the
+// persisted query extension has resolved hashes, so it sees the text of every query.
+func (a *Allowlist) MutateOperationParameters(_ context.Context, rawParams *graphql.RawParams) *gqlerror.Error {
+	if _, ok := a.queries[queryHash(rawParams.Query)]; ok {
+		return nil
+	}
+	err := gqlerror.Errorf("query is not in the persisted query allowlist")
+	errcode.Set(err, errQueryNotAllowed)
+	return err
+}
+
+// queryHash returns the hex SHA-256 of a query, as used by automatic persisted queries
+func queryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
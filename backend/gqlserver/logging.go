@@ -0,0 +1,79 @@
+package gqlserver
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/Tattsum/enjo/backend/logging"
+)
+
+// logOperation names the operation in the context, so that everything logged while it runs
+// carries the name, and logs its outcome. Variables hold user content and are redacted
+// unless debug logging is on.
+func logOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	opCtx := graphql.GetOperationContext(ctx)
+	ctx = logging.WithOperation(ctx, operationName(opCtx))
+	start := time.Now()
+	handler := next(ctx)
+
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+		if resp == nil {
+			return nil
+		}
+
+		attrs := []slog.Attr{
+			slog.String("operation_type", operationType(opCtx)),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if len(opCtx.Variables) > 0 {
+			attrs = append(attrs, slog.Any("variables", opCtx.Variables))
+		}
+		level := slog.LevelInfo
+		if len(resp.Errors) > 0 {
+			level = slog.LevelWarn
+			codes := make([]string, 0, len(resp.Errors))
+			for _, err := range resp.Errors {
+				if code, ok := err.Extensions["code"].(string); ok {
+					codes = append(codes, code)
+				}
+			}
+			attrs = append(attrs, slog.Int("errors", len(resp.Errors)), slog.Any("error_codes", codes))
+		}
+		slog.LogAttrs(ctx, level, "graphql operation", attrs...)
+		return resp
+	}
+}
+
+// operationName returns the name the client gave the operation, or its root fields for
+// anonymous operations such as "mutation { generateImage(...) }"
+func operationName(opCtx *graphql.OperationContext) string {
+	if opCtx.OperationName != "" {
+		return opCtx.OperationName
+	}
+	if opCtx.Operation == nil {
+		return ""
+	}
+	if opCtx.Operation.Name != "" {
+		return opCtx.Operation.Name
+	}
+	fields := make([]string, 0, len(opCtx.Operation.SelectionSet))
+	for _, selection := range opCtx.Operation.SelectionSet {
+		if field, ok := selection.(*ast.Field); ok {
+			fields = append(fields, field.Name)
+		}
+	}
+	return strings.Join(fields, ",")
+}
+
+func operationType(opCtx *graphql.OperationContext) string {
+	if opCtx.Operation == nil {
+		return ""
+	}
+	return string(opCtx.Operation.Operation)
+}
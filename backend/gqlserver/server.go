@@ -0,0 +1,96 @@
+// Package gqlserver builds the GraphQL HTTP handler with the limits a public endpoint needs:
+// query complexity and depth caps, persisted queries with an optional allowlist, and
+// introspection that can be switched off in production. Every operation is logged by name and
+// traced, and, when enabled, measured.
+package gqlserver
+
+import (
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/vektah/gqlparser/v2/ast"
+
+	"github.com/Tattsum/enjo/backend/metrics"
+	"github.com/Tattsum/enjo/backend/tracing"
+)
+
+// Defaults used by DefaultConfig
+const (
+	// DefaultMaxComplexity admits one four-image generation or a handful of text generations per request
+	DefaultMaxComplexity = 500
+	// DefaultMaxDepth is well above the deepest selection the schema needs
+	DefaultMaxDepth = 10
+	// defaultAPQCacheSize is the number of automatically persisted queries remembered
+	defaultAPQCacheSize = 100
+	// defaultQueryCacheSize is the number of parsed query documents cached
+	defaultQueryCacheSize = 1000
+)
+
+// Config controls the protections applied to GraphQL requests
+type Config struct {
+	// MaxComplexity caps the summed field costs of an operation; zero disables the check
+	MaxComplexity int
+	// MaxDepth caps how deeply selections may nest; zero disables the check
+	MaxDepth int
+	// Introspection allows __schema and __type queries. The playground needs it.
+	Introspection bool
+	// Allowlist restricts execution to known queries when set. Clients may still send
+	// only the hash of an allowed query, but cannot register new ones.
+	Allowlist *Allowlist
+	// Metrics records operation and resolver timings for the /metrics endpoint
+	Metrics bool
+}
+
+// DefaultConfig returns the limits for development: introspection on and any query accepted
+func DefaultConfig() Config {
+	return Config{
+		MaxComplexity: DefaultMaxComplexity,
+		MaxDepth:      DefaultMaxDepth,
+		Introspection: true,
+		Metrics:       true,
+	}
+}
+
+// New creates a GraphQL server for schema with the transports the frontend uses and the
+// protections in config
+func New(schema graphql.ExecutableSchema, config Config) *handler.Server {
+	srv := handler.New(schema)
+
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](defaultQueryCacheSize))
+	srv.AroundOperations(logOperation)
+
+	srv.Use(tracing.Extension{})
+	if config.Metrics {
+		srv.Use(metrics.Extension{})
+	}
+	if config.Introspection {
+		srv.Use(extension.Introspection{})
+	}
+	if config.Allowlist != nil {
+		// The allowlist serves hashed queries and rejects everything it does not contain
+		srv.Use(extension.AutomaticPersistedQuery{Cache: config.Allowlist})
+		srv.Use(config.Allowlist)
+	} else {
+		srv.Use(extension.AutomaticPersistedQuery{Cache: lru.New[string](defaultAPQCacheSize)})
+	}
+	if config.MaxDepth > 0 {
+		srv.Use(DepthLimit{Limit: config.MaxDepth})
+	}
+	if config.MaxComplexity > 0 {
+		srv.Use(extension.FixedComplexityLimit(config.MaxComplexity))
+	}
+
+	return srv
+}
@@ -0,0 +1,314 @@
+package gqlserver_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql/introspection"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/Tattsum/enjo/backend/gqlserver"
+	"github.com/Tattsum/enjo/backend/graph"
+	"github.com/Tattsum/enjo/backend/graph/generated"
+	"github.com/Tattsum/enjo/backend/logging"
+	"github.com/Tattsum/enjo/backend/metrics"
+	"github.com/Tattsum/enjo/backend/tracing"
+)
+
+// response is the subset of a GraphQL response the tests inspect
+type response struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func (r response) code() string {
+	if len(r.Errors) == 0 {
+		return ""
+	}
+	code, _ := r.Errors[0].Extensions["code"].(string)
+	return code
+}
+
+func newServer(t *testing.T, config gqlserver.Config) http.Handler {
+	t.Helper()
+	return gqlserver.New(generated.NewExecutableSchema(generated.Config{
+		Resolvers:  graph.NewResolver(nil, nil, nil),
+		Complexity: graph.NewComplexityRoot(),
+	}), config)
+}
+
+func execute(t *testing.T, handler http.Handler, body map[string]any) response {
+	t.Helper()
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("failed to encode request: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(data)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	var resp response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response %q: %v", w.Body.String(), err)
+	}
+	return resp
+}
+
+func hash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestIntrospection(t *testing.T) {
+	// The full query tools like the playground send must also fit the default limits
+	query := introspection.Query
+
+	tests := []struct {
+		name    string
+		enabled bool
+		wantErr bool
+	}{
+		{name: "enabled", enabled: true},
+		{name: "disabled", enabled: false, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := gqlserver.DefaultConfig()
+			config.Introspection = tt.enabled
+			resp := execute(t, newServer(t, config), map[string]any{"query": query})
+			if gotErr := len(resp.Errors) > 0; gotErr != tt.wantErr {
+				t.Errorf("errors = %+v, wantErr %v", resp.Errors, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	handler := newServer(t, gqlserver.Config{MaxComplexity: gqlserver.DefaultMaxComplexity, MaxDepth: 3})
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode string
+	}{
+		{
+			name:  "shallow query",
+			query: `{ simulation(id: "x") { replies { content } } }`,
+		},
+		{
+			name:     "too deep",
+			query:    `{ replyComparison(tweetId: "x") { report { replies { closestSimulatedReply { content } } } } }`,
+			wantCode: "DEPTH_LIMIT_EXCEEDED",
+		},
+		{
+			name: "too deep through a fragment",
+			query: `{ replyComparison(tweetId: "x") { report { ...observed } } }
+				fragment observed on ReplyComparison { replies { closestSimulatedReply { content } } }`,
+			wantCode: "DEPTH_LIMIT_EXCEEDED",
+		},
+		{
+			name:  "one large image generation",
+			query: `mutation { generateImage(input: {text: "x", sampleCount: 4}) { imageUrl } }`,
+		},
+		{
+			name: "several large image generations",
+			query: `mutation {
+				a: generateImage(input: {text: "x", sampleCount: 4}) { imageUrl }
+				b: generateImage(input: {text: "x", sampleCount: 4}) { imageUrl }
+			}`,
+			wantCode: "COMPLEXITY_LIMIT_EXCEEDED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := execute(t, handler, map[string]any{"query": tt.query})
+			// Queries within the limits reach the resolvers, which fail without clients configured
+			if tt.wantCode == "" {
+				if code := resp.code(); code == "DEPTH_LIMIT_EXCEEDED" || code == "COMPLEXITY_LIMIT_EXCEEDED" {
+					t.Errorf("query rejected with %s: %+v", code, resp.Errors)
+				}
+				return
+			}
+			if code := resp.code(); code != tt.wantCode {
+				t.Errorf("code = %q, want %q (errors %+v)", code, tt.wantCode, resp.Errors)
+			}
+		})
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	const allowed = `{ health }`
+	config := gqlserver.DefaultConfig()
+	config.Allowlist = gqlserver.NewAllowlist(allowed)
+	handler := newServer(t, config)
+
+	persisted := func(sha string) map[string]any {
+		return map[string]any{"persistedQuery": map[string]any{"version": 1, "sha256Hash": sha}}
+	}
+
+	tests := []struct {
+		name     string
+		body     map[string]any
+		wantCode string
+	}{
+		{name: "allowed query", body: map[string]any{"query": allowed}},
+		{name: "allowed hash", body: map[string]any{"extensions": persisted(hash(allowed))}},
+		{name: "other query", body: map[string]any{"query": `{ me { id } }`}, wantCode: "PERSISTED_QUERY_NOT_ALLOWED"},
+		{name: "unknown hash", body: map[string]any{"extensions": persisted(hash(`{ me { id } }`))}, wantCode: "PERSISTED_QUERY_NOT_FOUND"},
+		{
+			// Registering a query through automatic persisted queries does not allow it
+			name:     "registering a query",
+			body:     map[string]any{"query": `{ me { id } }`, "extensions": persisted(hash(`{ me { id } }`))},
+			wantCode: "PERSISTED_QUERY_NOT_ALLOWED",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := execute(t, handler, tt.body)
+			if code := resp.code(); code != tt.wantCode {
+				t.Errorf("code = %q, want %q (errors %+v)", code, tt.wantCode, resp.Errors)
+			}
+			if tt.wantCode == "" && resp.Data["health"] == nil {
+				t.Errorf("data = %+v, want health", resp.Data)
+			}
+		})
+	}
+}
+
+func TestParseAllowlist(t *testing.T) {
+	const query = `{ health }`
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{
+			name: "apollo manifest",
+			data: `{"format": "apollo-persisted-query-manifest", "version": 1, "operations": [{"id": "` + hash(query) + `", "name": "Health", "type": "query", "body": "{ health }"}]}`,
+		},
+		{name: "hash map", data: `{"` + hash(query) + `": "{ health }"}`},
+		{name: "hash mismatch", data: `{"` + hash("other") + `": "{ health }"}`, wantErr: true},
+		{name: "unknown format", data: `{"format": "relay", "operations": []}`, wantErr: true},
+		{name: "empty", data: `{}`, wantErr: true},
+		{name: "invalid JSON", data: `[`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowlist, err := gqlserver.ParseAllowlist([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseAllowlist() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && allowlist.Len() != 1 {
+				t.Errorf("Len() = %d, want 1", allowlist.Len())
+			}
+		})
+	}
+}
+
+func TestOperationLogging(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Config{})
+	if err != nil {
+		t.Fatalf("logging.New() error = %v", err)
+	}
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logger)
+
+	handler := newServer(t, gqlserver.DefaultConfig())
+	tests := []struct {
+		name string
+		body map[string]any
+		want string
+	}{
+		{
+			name: "named",
+			body: map[string]any{"query": "query CurrentQuota($name: String!) { quota { spent } __type(name: $name) { name } }", "variables": map[string]any{"name": "炎上"}},
+			want: "CurrentQuota",
+		},
+		{name: "anonymous", body: map[string]any{"query": "{ quota { spent } __typename }"}, want: "quota,__typename"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			if resp := execute(t, handler, tt.body); len(resp.Errors) > 0 {
+				t.Fatalf("errors = %+v", resp.Errors)
+			}
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("log output %q is not a single JSON record: %v", buf.String(), err)
+			}
+			if entry["msg"] != "graphql operation" || entry["graphql_operation"] != tt.want || entry["operation_type"] != "query" {
+				t.Errorf("entry = %v, want operation %q", entry, tt.want)
+			}
+			if strings.Contains(buf.String(), "炎上") {
+				t.Errorf("log output %q contains variables", buf.String())
+			}
+		})
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	handler := newServer(t, gqlserver.DefaultConfig())
+	if resp := execute(t, handler, map[string]any{"query": "query Named { quota { spent } }"}); len(resp.Errors) > 0 {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	for _, want := range []string{
+		// Operations are labelled by their root fields rather than the name the client chose
+		`enjo_graphql_operation_duration_seconds_count{operation="quota",outcome="ok",type="query"}`,
+		`enjo_graphql_field_duration_seconds_count{field="quota",object="Query",outcome="ok"}`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("metrics output is missing %s", want)
+		}
+	}
+}
+
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+	if _, err := tracing.Setup(context.Background(), tracing.Config{}); err != nil {
+		t.Fatalf("tracing.Setup() error = %v", err)
+	}
+
+	handler := tracing.Middleware(newServer(t, gqlserver.DefaultConfig()))
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "query Named { quota { spent } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	operation, ok := spans["query Named"]
+	if !ok {
+		t.Fatalf("recorded spans %v, want query Named", spans)
+	}
+	if operation.SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("operation trace ID = %s, want the caller's", operation.SpanContext().TraceID())
+	}
+	field, ok := spans["Query.quota"]
+	if !ok || field.Parent().SpanID() != operation.SpanContext().SpanID() {
+		t.Errorf("Query.quota span = %v, want a child of the operation span", field)
+	}
+}
@@ -0,0 +1,177 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/cache"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/image"
+)
+
+// PromptRenderer is implemented by Gemini clients that expose the exact prompts and model settings
+// they send. Cached results are keyed on them, so template and model changes invalidate the cache.
+type PromptRenderer interface {
+	InflammatoryPrompt(original string, level int) string
+	ExplanationPrompt(original, inflammatory string) string
+	ReplyPrompt(text, replyType string) string
+	ModelParameters() string
+}
+
+// ImageRequestRenderer is implemented by image clients that expose the exact request sent for a prompt,
+// including their deployment defaults
+type ImageRequestRenderer interface {
+	RenderRequest(prompt string, options ...image.Option) (string, error)
+}
+
+// CachedGeminiClient is a GeminiClient that answers repeated requests from a cache
+type CachedGeminiClient struct {
+	client GeminiClient
+	store  cache.Store
+	ttl    time.Duration
+}
+
+// NewCachedGeminiClient wraps client so that identical requests are served from store for ttl
+func NewCachedGeminiClient(client GeminiClient, store cache.Store, ttl time.Duration) *CachedGeminiClient {
+	return &CachedGeminiClient{client: client, store: store, ttl: ttl}
+}
+
+// GenerateInflammatoryText returns the cached conversion or generates it
+func (c *CachedGeminiClient) GenerateInflammatoryText(ctx context.Context, original string, level int) (string, error) {
+	key := c.key(func(r PromptRenderer) string { return r.InflammatoryPrompt(original, level) },
+		"inflammatory", original, strconv.Itoa(level))
+	return cached(ctx, c.store, c.ttl, key, func() (string, error) {
+		return c.client.GenerateInflammatoryText(ctx, original, level)
+	})
+}
+
+// GenerateExplanation returns the cached explanation or generates it
+func (c *CachedGeminiClient) GenerateExplanation(ctx context.Context, original, inflammatory string) (string, error) {
+	key := c.key(func(r PromptRenderer) string { return r.ExplanationPrompt(original, inflammatory) },
+		"explanation", original, inflammatory)
+	return cached(ctx, c.store, c.ttl, key, func() (string, error) {
+		return c.client.GenerateExplanation(ctx, original, inflammatory)
+	})
+}
+
+// GenerateReply returns the cached reply or generates it
+func (c *CachedGeminiClient) GenerateReply(ctx context.Context, text, replyType string) (string, error) {
+	key := c.key(func(r PromptRenderer) string { return r.ReplyPrompt(text, replyType) },
+		"reply", text, replyType)
+	return cached(ctx, c.store, c.ttl, key, func() (string, error) {
+		return c.client.GenerateReply(ctx, text, replyType)
+	})
+}
+
+// GenerateContent returns the cached answer to prompt or generates it
+func (c *CachedGeminiClient) GenerateContent(ctx context.Context, prompt string) (string, error) {
+	key := c.key(func(PromptRenderer) string { return prompt }, "content", prompt)
+	return cached(ctx, c.store, c.ttl, key, func() (string, error) {
+		return c.client.GenerateContent(ctx, prompt)
+	})
+}
+
+// key returns the cache key for a request: the rendered prompt and model settings when the client
+// can render them, otherwise the method and its arguments
+func (c *CachedGeminiClient) key(render func(PromptRenderer) string, method string, args ...string) string {
+	if renderer, ok := c.client.(PromptRenderer); ok {
+		return cache.Key("gemini", renderer.ModelParameters(), render(renderer))
+	}
+	return cache.Key(append([]string{"gemini", method}, args...)...)
+}
+
+// CachedImageClient is an ImageClient that answers repeated requests from a cache
+type CachedImageClient struct {
+	client ImageClient
+	store  cache.Store
+	ttl    time.Duration
+}
+
+// NewCachedImageClient wraps client so that identical requests are served from store for ttl
+func NewCachedImageClient(client ImageClient, store cache.Store, ttl time.Duration) *CachedImageClient {
+	return &CachedImageClient{client: client, store: store, ttl: ttl}
+}
+
+// GenerateImages returns the cached images for the request or generates them.
+// Requests that cannot be rendered, such as ones with invalid options, are passed through uncached.
+func (c *CachedImageClient) GenerateImages(ctx context.Context, prompt string, options ...image.Option) ([][]byte, error) {
+	var request string
+	var err error
+	if renderer, ok := c.client.(ImageRequestRenderer); ok {
+		request, err = renderer.RenderRequest(prompt, options...)
+	} else {
+		request, err = image.RenderRequest(prompt, options...)
+	}
+	if err != nil {
+		return c.client.GenerateImages(ctx, prompt, options...)
+	}
+
+	return cached(ctx, c.store, c.ttl, cache.Key("imagen", request), func() ([][]byte, error) {
+		return c.client.GenerateImages(ctx, prompt, options...)
+	})
+}
+
+// cached returns the value stored under key, or calls generate and stores its result.
+// Lookups are recorded in the request's cache report; cache failures are logged and fall
+// through to generate, since the cache only saves cost.
+func cached[T any](ctx context.Context, store cache.Store, ttl time.Duration, key string, generate func() (T, error)) (T, error) {
+	report := cache.ReportFrom(ctx)
+	if !cache.Bypass(ctx) {
+		data, ok, err := store.Get(ctx, key)
+		if err != nil {
+			slog.WarnContext(ctx, "cache lookup failed", "error", err)
+		}
+		if ok {
+			var value T
+			if err := json.Unmarshal(data, &value); err == nil {
+				report.Hit()
+				return value, nil
+			}
+			slog.WarnContext(ctx, "discarding unreadable cache entry", "entry", key)
+		}
+	}
+
+	report.Miss()
+	value, err := generate()
+	if err != nil {
+		return value, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to encode cache entry", "error", err)
+		return value, nil
+	}
+	if err := store.Set(ctx, key, data, ttl); err != nil {
+		slog.WarnContext(ctx, "failed to store cache entry", "error", err)
+	}
+	return value, nil
+}
+
+// withCacheReport starts recording the cache lookups made for one result
+func withCacheReport(ctx context.Context, bypass *bool) (context.Context, *cache.Report) {
+	return cache.WithReport(ctx, boolValue(bypass))
+}
+
+// toCacheReportModel converts a cache report into its GraphQL representation.
+// It returns nil when no lookups were made, i.e. when no cache is configured.
+func toCacheReportModel(report *cache.Report) *model.CacheReport {
+	hits, misses := report.Counts()
+	if hits+misses == 0 {
+		return nil
+	}
+	return &model.CacheReport{Hits: hits, Misses: misses, Bypassed: report.Bypassed()}
+}
+
+// replyCached reports whether the lookups in report were all answered from the cache,
+// or nil when no lookups were made
+func replyCached(report *cache.Report) *bool {
+	hits, misses := report.Counts()
+	if hits+misses == 0 {
+		return nil
+	}
+	cachedReply := misses == 0
+	return &cachedReply
+}
@@ -0,0 +1,222 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/cache"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/image"
+)
+
+// renderingGeminiClient is a MockGeminiClient that renders prompts from a template version
+type renderingGeminiClient struct {
+	MockGeminiClient
+	template string
+}
+
+func (c *renderingGeminiClient) InflammatoryPrompt(original string, level int) string {
+	return fmt.Sprintf("%s: %s (%d)", c.template, original, level)
+}
+
+func (c *renderingGeminiClient) ExplanationPrompt(original, inflammatory string) string {
+	return c.template + ": " + original + " / " + inflammatory
+}
+
+func (c *renderingGeminiClient) ReplyPrompt(text, replyType string) string {
+	return c.template + ": " + text + " / " + replyType
+}
+
+func (*renderingGeminiClient) ModelParameters() string {
+	return "model=test"
+}
+
+func TestCachedGeminiClient(t *testing.T) {
+	var calls int
+	mock := MockGeminiClient{
+		GenerateInflammatoryTextFunc: func(_ context.Context, original string, level int) (string, error) {
+			calls++
+			if level == 5 {
+				return "", errors.New("generation failed")
+			}
+			return fmt.Sprintf("%s #%d", original, calls), nil
+		},
+	}
+	store := cache.NewLRU(10)
+	client := NewCachedGeminiClient(&mock, store, time.Hour)
+
+	generate := func(original string, level int, bypass bool) (string, *cache.Report, error) {
+		ctx, report := cache.WithReport(context.Background(), bypass)
+		text, err := client.GenerateInflammatoryText(ctx, original, level)
+		return text, report, err
+	}
+	assertCounts := func(report *cache.Report, wantHits, wantMisses int) {
+		t.Helper()
+		if hits, misses := report.Counts(); hits != wantHits || misses != wantMisses {
+			t.Errorf("Counts() = %d, %d, want %d, %d", hits, misses, wantHits, wantMisses)
+		}
+	}
+
+	first, report, err := generate("original", 3, false)
+	if err != nil {
+		t.Fatalf("GenerateInflammatoryText() error = %v", err)
+	}
+	assertCounts(report, 0, 1)
+
+	second, report, _ := generate("original", 3, false)
+	if second != first || calls != 1 {
+		t.Errorf("second call = %q after %d calls, want cached %q", second, calls, first)
+	}
+	assertCounts(report, 1, 0)
+
+	if other, _, _ := generate("original", 4, false); other == first {
+		t.Error("different level was served from the cache")
+	}
+
+	bypassed, report, _ := generate("original", 3, true)
+	if bypassed == first {
+		t.Error("bypassCache returned the cached result")
+	}
+	assertCounts(report, 0, 1)
+	if refreshed, _, _ := generate("original", 3, false); refreshed != bypassed {
+		t.Errorf("after bypass = %q, want the refreshed %q", refreshed, bypassed)
+	}
+
+	// Errors are not cached
+	for range 2 {
+		if _, _, err := generate("original", 5, false); err == nil {
+			t.Fatal("GenerateInflammatoryText() error = nil, want the model error")
+		}
+	}
+	if calls != 5 {
+		t.Errorf("model called %d times, want 5", calls)
+	}
+}
+
+func TestCachedGeminiClient_KeysOnRenderedPrompt(t *testing.T) {
+	store := cache.NewLRU(10)
+	var calls int
+	mock := MockGeminiClient{
+		GenerateReplyFunc: func(context.Context, string, string) (string, error) {
+			calls++
+			return fmt.Sprintf("reply %d", calls), nil
+		},
+	}
+
+	v1 := NewCachedGeminiClient(&renderingGeminiClient{MockGeminiClient: mock, template: "v1"}, store, time.Hour)
+	v1Again := NewCachedGeminiClient(&renderingGeminiClient{MockGeminiClient: mock, template: "v1"}, store, time.Hour)
+	v2 := NewCachedGeminiClient(&renderingGeminiClient{MockGeminiClient: mock, template: "v2"}, store, time.Hour)
+
+	ctx := context.Background()
+	first, _ := v1.GenerateReply(ctx, "post", "critic")
+	if again, _ := v1Again.GenerateReply(ctx, "post", "critic"); again != first {
+		t.Errorf("same template = %q, want cached %q", again, first)
+	}
+	if changed, _ := v2.GenerateReply(ctx, "post", "critic"); changed == first {
+		t.Error("changed template was served from the cache")
+	}
+	if calls != 2 {
+		t.Errorf("model called %d times, want 2", calls)
+	}
+}
+
+func TestCachedImageClient(t *testing.T) {
+	var calls int
+	mock := &MockImageClient{
+		GenerateImagesFunc: func(context.Context, string, ...image.Option) ([][]byte, error) {
+			calls++
+			return [][]byte{[]byte(fmt.Sprintf("image %d", calls))}, nil
+		},
+	}
+	client := NewCachedImageClient(mock, cache.NewLRU(10), time.Hour)
+	ctx := context.Background()
+
+	first, err := client.GenerateImages(ctx, "a flame", image.WithStyle("MEME"), image.WithSeed(1))
+	if err != nil {
+		t.Fatalf("GenerateImages() error = %v", err)
+	}
+	again, _ := client.GenerateImages(ctx, "a flame", image.WithStyle("MEME"), image.WithSeed(1))
+	if string(again[0]) != string(first[0]) || calls != 1 {
+		t.Errorf("identical request = %q after %d calls, want cached %q", again[0], calls, first[0])
+	}
+
+	for _, options := range [][]image.Option{
+		{image.WithStyle("MEME"), image.WithSeed(2)},
+		{image.WithStyle("DRAMATIC"), image.WithSeed(1)},
+		{image.WithStyle("MEME"), image.WithSeed(1), image.WithModel("imagen-4.0-generate-001")},
+	} {
+		if other, _ := client.GenerateImages(ctx, "a flame", options...); string(other[0]) == string(first[0]) {
+			t.Error("request with different options was served from the cache")
+		}
+	}
+
+	// Invalid options are passed through for the client to reject
+	if _, err := client.GenerateImages(ctx, "a flame", image.WithSafetySetting("invalid")); err != nil {
+		t.Fatalf("GenerateImages() error = %v", err)
+	}
+	if calls != 5 {
+		t.Errorf("model called %d times, want 5", calls)
+	}
+}
+
+func TestMutationResolver_GenerateInflammatoryText_ReportsCache(t *testing.T) {
+	mock := &MockGeminiClient{
+		GenerateInflammatoryTextFunc: func(context.Context, string, int) (string, error) { return "炎上文章", nil },
+		GenerateExplanationFunc:      func(context.Context, string, string) (string, error) { return "解説", nil },
+	}
+	input := model.GenerateInput{OriginalText: "元の文章", Level: 2}
+
+	uncached := &mutationResolver{NewResolver(mock, nil, nil)}
+	result, err := uncached.GenerateInflammatoryText(context.Background(), input)
+	if err != nil {
+		t.Fatalf("GenerateInflammatoryText() error = %v", err)
+	}
+	if result.Cache != nil {
+		t.Errorf("Cache = %+v without a cache, want nil", result.Cache)
+	}
+
+	cached := &mutationResolver{NewResolver(NewCachedGeminiClient(mock, cache.NewLRU(10), time.Hour), nil, nil)}
+	tests := []struct {
+		name   string
+		bypass *bool
+		want   model.CacheReport
+	}{
+		{name: "first request misses", want: model.CacheReport{Hits: 0, Misses: 2}},
+		{name: "identical request hits", want: model.CacheReport{Hits: 2, Misses: 0}},
+		{name: "bypass misses", bypass: boolPtr(true), want: model.CacheReport{Hits: 0, Misses: 2, Bypassed: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input.BypassCache = tt.bypass
+			result, err := cached.GenerateInflammatoryText(context.Background(), input)
+			if err != nil {
+				t.Fatalf("GenerateInflammatoryText() error = %v", err)
+			}
+			if result.Cache == nil || *result.Cache != tt.want {
+				t.Errorf("Cache = %+v, want %+v", result.Cache, tt.want)
+			}
+		})
+	}
+}
+
+func TestMutationResolver_GenerateReplies_ReportsCache(t *testing.T) {
+	mock := &MockGeminiClient{
+		GenerateReplyFunc: func(_ context.Context, _, replyType string) (string, error) { return replyType, nil },
+	}
+	resolver := &mutationResolver{NewResolver(NewCachedGeminiClient(mock, cache.NewLRU(10), time.Hour), nil, nil)}
+
+	for _, want := range []bool{false, true} {
+		replies, err := resolver.GenerateReplies(context.Background(), "炎上文章", nil, nil, nil)
+		if err != nil {
+			t.Fatalf("GenerateReplies() error = %v", err)
+		}
+		for _, reply := range replies {
+			if reply.Cached == nil || *reply.Cached != want {
+				t.Errorf("reply %s Cached = %v, want %v", reply.ID, reply.Cached, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,362 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/simulation"
+	"github.com/Tattsum/enjo/backend/twitter"
+)
+
+// maxClassifiedReplies caps how many real replies are classified in a single comparison
+const maxClassifiedReplies = 100
+
+var (
+	// errTweetWithoutSimulation is returned when comparing replies of a tweet not linked to a simulation
+	errTweetWithoutSimulation = errors.New("tweet is not linked to a simulation")
+	// errNoSimulatedReplies is returned when the simulation has no predicted replies to compare against
+	errNoSimulatedReplies = errors.New("simulation has no replies; run generateReplies with its simulationId first")
+	// errComparisonQueueDisabled is returned when no job queue is configured to run comparisons on
+	errComparisonQueueDisabled = errors.New("reply comparison is not configured")
+)
+
+// comparisonSource returns the posted tweet of ownerID to compare and the simulation it was posted from
+func (r *Resolver) comparisonSource(ctx context.Context, ownerID, tweetID string) (*simulation.Tweet, *simulation.Simulation, error) {
+	tweet, err := r.simulations.GetTweet(ctx, tweetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tweet.OwnerID != ownerID {
+		return nil, nil, simulation.ErrTweetNotFound
+	}
+	if tweet.SimulationID == "" {
+		return nil, nil, errTweetWithoutSimulation
+	}
+
+	sim, err := r.simulations.Get(ctx, tweet.SimulationID)
+	if err == nil && sim.OwnerID != ownerID {
+		err = simulation.ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get simulation: %w", err)
+	}
+	if len(sim.Replies) == 0 {
+		return nil, nil, errNoSimulatedReplies
+	}
+	return tweet, sim, nil
+}
+
+// compareReplies fetches the real replies of a tweet of ownerID, classifies them into the simulator's
+// personas and stores the comparison with the simulated replies
+func (r *Resolver) compareReplies(ctx context.Context, ownerID, tweetID string) (*simulation.Comparison, error) {
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+	tweet, sim, err := r.comparisonSource(ctx, ownerID, tweetID)
+	if err != nil {
+		return nil, err
+	}
+	twitterClient, err := r.twitterForOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	realReplies, err := twitterClient.SearchReplies(ctx, tweetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch replies: %w", err)
+	}
+	if len(realReplies) > maxClassifiedReplies {
+		realReplies = realReplies[:maxClassifiedReplies]
+	}
+
+	texts := make([]string, 0, len(realReplies))
+	for _, reply := range realReplies {
+		texts = append(texts, reply.Text)
+	}
+	personas, err := NewGeminiReplyClassifier(r.geminiClient).Classify(ctx, tweet.Text, texts)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.simulations.SaveComparison(ctx, buildReplyComparison(tweetID, sim, realReplies, personas))
+}
+
+// latestComparisonJob returns the most recent reply comparison job of the current user for a tweet,
+// or nil when the tweet was never compared
+func (r *Resolver) latestComparisonJob(ctx context.Context, tweetID string) (*queue.Job, error) {
+	jobs, err := r.postQueue.List(ctx, queue.KindCompareReplies, nil)
+	if err != nil {
+		return nil, err
+	}
+	owner := currentOwner(ctx)
+	var latest *queue.Job
+	for _, job := range jobs {
+		if job.TweetID != tweetID || job.OwnerID != owner {
+			continue
+		}
+		if latest == nil || job.CreatedAt.After(latest.CreatedAt) {
+			latest = job
+		}
+	}
+	return latest, nil
+}
+
+// GeminiReplyClassifier classifies real replies into the simulator's reply personas using Gemini
+type GeminiReplyClassifier struct {
+	client GeminiClient
+}
+
+// NewGeminiReplyClassifier creates a reply classifier backed by the Gemini client
+func NewGeminiReplyClassifier(client GeminiClient) *GeminiReplyClassifier {
+	return &GeminiReplyClassifier{client: client}
+}
+
+// classificationResponse is the JSON document Gemini is asked to return
+type classificationResponse struct {
+	Results []struct {
+		Index int    `json:"index"`
+		Type  string `json:"type"`
+	} `json:"results"`
+}
+
+// Classify returns the persona of each reply in order; replies that fit no persona get an empty type
+func (c *GeminiReplyClassifier) Classify(ctx context.Context, postText string, replies []string) ([]model.ReplyType, error) {
+	personas := make([]model.ReplyType, len(replies))
+	if len(replies) == 0 {
+		return personas, nil
+	}
+
+	raw, err := c.client.GenerateContent(ctx, buildReplyClassificationPrompt(postText, replies))
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify replies: %w", err)
+	}
+
+	var resp classificationResponse
+	if err := json.Unmarshal([]byte(extractJSONObject(raw)), &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse reply classification: %w", err)
+	}
+
+	for _, result := range resp.Results {
+		// Indexes in the prompt are 1-based
+		i := result.Index - 1
+		if i < 0 || i >= len(personas) {
+			continue
+		}
+		replyType := model.ReplyType(result.Type)
+		if replyType.IsValid() {
+			personas[i] = replyType
+		}
+	}
+	return personas, nil
+}
+
+// buildReplyClassificationPrompt builds the prompt for classifying real replies into personas
+func buildReplyClassificationPrompt(postText string, replies []string) string {
+	var personas strings.Builder
+	for _, p := range replyPersonas {
+		fmt.Fprintf(&personas, "- %s: %s\n", p.Type, p.Description)
+	}
+
+	var numbered strings.Builder
+	for i, reply := range replies {
+		fmt.Fprintf(&numbered, "%d. %s\n", i+1, strings.ReplaceAll(reply, "\n", " "))
+	}
+
+	return fmt.Sprintf(`あなたはSNSのリプライを分類するアナリストです。以下の投稿に付いたリプライを、それぞれ最も近いタイプに分類してください。
+
+【投稿】
+%s
+
+【タイプ】
+%s- OTHER: どのタイプにも当てはまらない（無関係な内容、スパムなど）
+
+【リプライ】
+%s
+次のJSONのみを出力してください。説明は不要です。
+{"results": [{"index": リプライ番号, "type": "タイプ名"}]}`, postText, personas.String(), numbered.String())
+}
+
+// buildReplyComparison compares classified real replies against the simulated replies of a simulation
+func buildReplyComparison(tweetID string, sim *simulation.Simulation, real []twitter.Reply, personas []model.ReplyType) simulation.Comparison {
+	counts := make(map[string]*simulation.PersonaCount, len(replyPersonas))
+	distribution := make([]simulation.PersonaCount, len(replyPersonas))
+	for i, p := range replyPersonas {
+		distribution[i].Type = string(p.Type)
+		counts[string(p.Type)] = &distribution[i]
+	}
+	for _, reply := range sim.Replies {
+		if c, ok := counts[reply.Type]; ok {
+			c.Predicted++
+		}
+	}
+
+	comparison := simulation.Comparison{
+		TweetID:      tweetID,
+		SimulationID: sim.ID,
+		Replies:      make([]simulation.ObservedReply, 0, len(real)),
+	}
+	for i, reply := range real {
+		observed := simulation.ObservedReply{
+			ID:       reply.ID,
+			Text:     reply.Text,
+			AuthorID: reply.AuthorID,
+			PostedAt: reply.CreatedAt,
+		}
+		if i < len(personas) && personas[i] != "" {
+			observed.Persona = string(personas[i])
+			counts[observed.Persona].Observed++
+		} else {
+			comparison.Unclassified++
+		}
+		observed.ClosestReply, observed.Similarity = closestSimulatedReply(reply.Text, sim.Replies)
+		comparison.Replies = append(comparison.Replies, observed)
+	}
+	comparison.Distribution = distribution
+
+	return comparison
+}
+
+// closestSimulatedReply returns the simulated reply most similar to text and its similarity
+func closestSimulatedReply(text string, simulated []simulation.Reply) (*simulation.Reply, float64) {
+	var closest *simulation.Reply
+	best := -1.0
+	for i := range simulated {
+		score := textSimilarity(text, simulated[i].Content)
+		if score > best {
+			best = score
+			closest = &simulated[i]
+		}
+	}
+	if closest == nil {
+		return nil, 0
+	}
+	return closest, best
+}
+
+// textSimilarity returns the Dice coefficient of the character bigrams of a and b.
+// Character bigrams work for Japanese text, which has no word boundaries.
+func textSimilarity(a, b string) float64 {
+	bigramsA, bigramsB := charBigrams(a), charBigrams(b)
+	if len(bigramsA) == 0 || len(bigramsB) == 0 {
+		return 0
+	}
+
+	remaining := make(map[string]int, len(bigramsB))
+	for _, bg := range bigramsB {
+		remaining[bg]++
+	}
+	shared := 0
+	for _, bg := range bigramsA {
+		if remaining[bg] > 0 {
+			remaining[bg]--
+			shared++
+		}
+	}
+	return 2 * float64(shared) / float64(len(bigramsA)+len(bigramsB))
+}
+
+// charBigrams returns the bigrams of s ignoring case, whitespace and punctuation
+func charBigrams(s string) []string {
+	runes := make([]rune, 0, len(s))
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			runes = append(runes, r)
+		}
+	}
+	if len(runes) < 2 {
+		return nil
+	}
+
+	bigrams := make([]string, 0, len(runes)-1)
+	for i := 0; i < len(runes)-1; i++ {
+		bigrams = append(bigrams, string(runes[i:i+2]))
+	}
+	return bigrams
+}
+
+// toReplyComparisonModel converts a comparison into its GraphQL representation
+func toReplyComparisonModel(comparison *simulation.Comparison) *model.ReplyComparison {
+	predictedTotal := 0
+	for _, c := range comparison.Distribution {
+		predictedTotal += c.Predicted
+	}
+	observedTotal := len(comparison.Replies)
+
+	distribution := make([]*model.PersonaDistribution, 0, len(comparison.Distribution))
+	for _, c := range comparison.Distribution {
+		distribution = append(distribution, &model.PersonaDistribution{
+			Type:           model.ReplyType(c.Type),
+			Predicted:      c.Predicted,
+			Observed:       c.Observed,
+			PredictedShare: share(c.Predicted, predictedTotal),
+			ObservedShare:  share(c.Observed, observedTotal),
+		})
+	}
+
+	replies := make([]*model.ObservedReply, 0, len(comparison.Replies))
+	for _, r := range comparison.Replies {
+		observed := &model.ObservedReply{
+			ID:         r.ID,
+			Text:       r.Text,
+			AuthorID:   optionalString(r.AuthorID),
+			PostedAt:   r.PostedAt.Format(time.RFC3339),
+			Similarity: r.Similarity,
+		}
+		if r.Persona != "" {
+			persona := model.ReplyType(r.Persona)
+			observed.Persona = &persona
+		}
+		if r.ClosestReply != nil {
+			observed.ClosestSimulatedReply = &model.Reply{
+				ID:      r.ClosestReply.ID,
+				Type:    model.ReplyType(r.ClosestReply.Type),
+				Content: r.ClosestReply.Content,
+			}
+		}
+		replies = append(replies, observed)
+	}
+
+	return &model.ReplyComparison{
+		TweetID:           comparison.TweetID,
+		SimulationID:      comparison.SimulationID,
+		RealReplyCount:    observedTotal,
+		UnclassifiedCount: comparison.Unclassified,
+		Distribution:      distribution,
+		Replies:           replies,
+		GeneratedAt:       comparison.GeneratedAt.Format(time.RFC3339),
+	}
+}
+
+// toReplyComparisonJobModel converts a comparison job and, once it has succeeded, its report into
+// their GraphQL representation
+func toReplyComparisonJobModel(job *queue.Job, report *simulation.Comparison) *model.ReplyComparisonJob {
+	converted := &model.ReplyComparisonJob{
+		ID:          job.ID,
+		TweetID:     job.TweetID,
+		Status:      model.ReplyComparisonStatus(job.Status),
+		Attempts:    job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+		LastError:   optionalString(job.LastError),
+		CreatedAt:   job.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   job.UpdatedAt.Format(time.RFC3339),
+	}
+	if report != nil {
+		converted.Report = toReplyComparisonModel(report)
+	}
+	return converted
+}
+
+// share returns n as a fraction of total, or 0 when total is 0
+func share(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
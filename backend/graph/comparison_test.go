@@ -0,0 +1,207 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/simulation"
+	"github.com/Tattsum/enjo/backend/store"
+	"github.com/Tattsum/enjo/backend/twitter"
+)
+
+func TestTextSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want float64
+	}{
+		{name: "identical texts", a: "それは違うと思います", b: "それは違うと思います", want: 1},
+		{name: "ignores punctuation and spaces", a: "それは 違う！", b: "それは違う", want: 1},
+		{name: "unrelated texts", a: "天気がいい", b: "猫が好き", want: 0},
+		{name: "empty text", a: "", b: "猫", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := textSimilarity(tt.a, tt.b); got != tt.want {
+				t.Errorf("textSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeminiReplyClassifier_Classify(t *testing.T) {
+	classifier := NewGeminiReplyClassifier(&MockGeminiClient{
+		GenerateContentFunc: func(_ context.Context, _ string) (string, error) {
+			return "```json\n" + `{"results": [{"index": 1, "type": "NITPICKING"}, {"index": 2, "type": "OTHER"}, {"index": 9, "type": "OFF_TARGET"}]}` + "\n```", nil
+		},
+	})
+
+	got, err := classifier.Classify(context.Background(), "投稿", []string{"揚げ足", "宣伝です"})
+	if err != nil {
+		t.Fatalf("Classify() error = %v", err)
+	}
+	want := []model.ReplyType{model.ReplyTypeNitpicking, ""}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Classify() = %v, want %v", got, want)
+	}
+}
+
+func TestMutationResolver_CompareReplies(t *testing.T) {
+	ctx := context.Background()
+	simulations := newTestSimulationStore(t)
+
+	sim, err := simulations.Create(ctx, simulation.Simulation{InflammatoryText: "炎上投稿", Level: 3})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if _, err := simulations.SetReplies(ctx, sim.ID, []simulation.Reply{
+		{ID: "1", Type: string(model.ReplyTypeLogicalCriticism), Content: "データを見ればそれは間違いです"},
+		{ID: "2", Type: string(model.ReplyTypeNitpicking), Content: "誤字がありますよ"},
+		{ID: "3", Type: string(model.ReplyTypeOffTarget), Content: "それより猫の話をしよう"},
+		{ID: "4", Type: string(model.ReplyTypeExcessiveDefense), Content: "何も悪くない、最高です"},
+	}); err != nil {
+		t.Fatalf("SetReplies() error = %v", err)
+	}
+	if _, err := simulations.RecordTweet(ctx, simulation.Tweet{TweetID: "100", SimulationID: sim.ID, Text: "炎上投稿"}); err != nil {
+		t.Fatalf("RecordTweet() error = %v", err)
+	}
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	jobs := queue.New(db)
+
+	geminiClient := &MockGeminiClient{
+		GenerateContentFunc: func(_ context.Context, _ string) (string, error) {
+			return `{"results": [{"index": 1, "type": "NITPICKING"}, {"index": 2, "type": "NITPICKING"}, {"index": 3, "type": "OTHER"}]}`, nil
+		},
+	}
+	searches := 0
+	twitterClient := &MockTwitterClient{
+		SearchRepliesFunc: func(_ context.Context, _ string) ([]twitter.Reply, error) {
+			searches++
+			return []twitter.Reply{
+				{ID: "r1", Text: "誤字がありますね"},
+				{ID: "r2", Text: "句読点がおかしい"},
+				{ID: "r3", Text: "フォローお願いします"},
+			}, nil
+		},
+	}
+	r := &Resolver{geminiClient: geminiClient, twitterClient: twitterClient, simulations: simulations, postQueue: jobs}
+	mutation, query := &mutationResolver{r}, &queryResolver{r}
+
+	// The mutation only queues the comparison
+	queued, err := mutation.CompareReplies(ctx, "100")
+	if err != nil {
+		t.Fatalf("CompareReplies() error = %v", err)
+	}
+	if queued.ID == "" || queued.Status != model.ReplyComparisonStatusPending || queued.Report != nil {
+		t.Errorf("CompareReplies() = %+v, want a pending job without a report", queued)
+	}
+	if searches != 0 {
+		t.Errorf("SearchReplies called %d times before the job ran, want 0", searches)
+	}
+
+	again, err := mutation.CompareReplies(ctx, "100")
+	if err != nil {
+		t.Fatalf("CompareReplies() error = %v", err)
+	}
+	if again.ID != queued.ID {
+		t.Errorf("CompareReplies() queued job %s, want the pending job %s", again.ID, queued.ID)
+	}
+
+	pending, err := query.ReplyComparison(ctx, "100")
+	if err != nil || pending == nil || pending.Status != model.ReplyComparisonStatusPending {
+		t.Fatalf("ReplyComparison() = %+v, %v, want the pending job", pending, err)
+	}
+
+	// Run the job through the worker, stopping it once the job is done
+	workerCtx, stop := context.WithCancel(ctx)
+	handler := NewJobHandler(geminiClient, twitterClient, WithSimulationStore(simulations))
+	worker := queue.NewWorker(jobs, func(ctx context.Context, job *queue.Job) (*queue.Result, error) {
+		defer stop()
+		return handler(ctx, job)
+	}, time.Hour)
+	if err := worker.Run(workerCtx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	done, err := query.ReplyComparison(ctx, "100")
+	if err != nil {
+		t.Fatalf("ReplyComparison() error = %v", err)
+	}
+	if done == nil || done.Status != model.ReplyComparisonStatusSucceeded || done.Report == nil {
+		t.Fatalf("ReplyComparison() = %+v, want a succeeded job with its report", done)
+	}
+	got := done.Report
+	if got.RealReplyCount != 3 || got.UnclassifiedCount != 1 {
+		t.Errorf("counts = %d real, %d unclassified, want 3 and 1", got.RealReplyCount, got.UnclassifiedCount)
+	}
+
+	for _, d := range got.Distribution {
+		if d.Predicted != 1 || d.PredictedShare != 0.25 {
+			t.Errorf("%s predicted = %d (%v), want 1 (0.25)", d.Type, d.Predicted, d.PredictedShare)
+		}
+		if d.Type == model.ReplyTypeNitpicking && d.Observed != 2 {
+			t.Errorf("NITPICKING observed = %d, want 2", d.Observed)
+		}
+	}
+
+	first := got.Replies[0]
+	if first.ClosestSimulatedReply == nil || first.ClosestSimulatedReply.ID != "2" || first.Similarity <= 0 {
+		t.Errorf("closest reply = %+v (similarity %v), want simulated reply 2", first.ClosestSimulatedReply, first.Similarity)
+	}
+	if got.Replies[2].Persona != nil {
+		t.Errorf("reply r3 persona = %v, want nil", *got.Replies[2].Persona)
+	}
+}
+
+func TestMutationResolver_CompareReplies_Errors(t *testing.T) {
+	ctx := context.Background()
+	simulations := newTestSimulationStore(t)
+
+	sim, err := simulations.Create(ctx, simulation.Simulation{InflammatoryText: "炎上投稿"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	for _, tweet := range []simulation.Tweet{
+		{TweetID: "unlinked", Text: "投稿"},
+		{TweetID: "no-replies", SimulationID: sim.ID, Text: "投稿"},
+	} {
+		if _, err := simulations.RecordTweet(ctx, tweet); err != nil {
+			t.Fatalf("RecordTweet() error = %v", err)
+		}
+	}
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	r := &Resolver{twitterClient: &MockTwitterClient{}, simulations: simulations, postQueue: queue.New(db)}
+	mutation := &mutationResolver{r}
+
+	if _, err := mutation.CompareReplies(ctx, "unlinked"); !errors.Is(err, errTweetWithoutSimulation) {
+		t.Errorf("CompareReplies(unlinked) error = %v, want errTweetWithoutSimulation", err)
+	}
+	if _, err := mutation.CompareReplies(ctx, "no-replies"); !errors.Is(err, errNoSimulatedReplies) {
+		t.Errorf("CompareReplies(no-replies) error = %v, want errNoSimulatedReplies", err)
+	}
+	if _, err := mutation.CompareReplies(ctx, "missing"); !errors.Is(err, simulation.ErrTweetNotFound) {
+		t.Errorf("CompareReplies(missing) error = %v, want ErrTweetNotFound", err)
+	}
+
+	// Nothing is queued for requests that would fail
+	if job, err := (&queryResolver{r}).ReplyComparison(ctx, "no-replies"); err != nil || job != nil {
+		t.Errorf("ReplyComparison(no-replies) = %+v, %v, want no job", job, err)
+	}
+}
@@ -0,0 +1,86 @@
+package graph
+
+import (
+	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/Tattsum/enjo/backend/graph/generated"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/image"
+)
+
+// Field costs for the query complexity limit. Every other field costs one. Fields that call
+// Gemini, Imagen or Twitter are priced so that a single request cannot fan out into many of them.
+const (
+	costGeminiCall  = 50
+	costImage       = 100 // Per generated image
+	costTwitterCall = 20
+	costRender      = 20
+)
+
+// NewComplexityRoot returns the field costs to pass as generated.Config.Complexity
+func NewComplexityRoot() generated.ComplexityRoot {
+	var c generated.ComplexityRoot
+
+	// Conversion and explanation are two Gemini calls, plus one to describe an attached image
+	c.Mutation.GenerateInflammatoryText = func(childComplexity int, input model.GenerateInput) int {
+		return childComplexity + 2*costGeminiCall + imageDescriptionCost(input.ImageID)
+	}
+	c.Mutation.GenerateReplies = func(childComplexity int, _ string, _, imageID *string, _ *bool) int {
+		return childComplexity + len(replyPersonas)*costGeminiCall + imageDescriptionCost(imageID)
+	}
+	// The image prompt is written by Gemini before the images are generated
+	c.Mutation.GenerateImage = func(childComplexity int, input model.GenerateImageInput) int {
+		return childComplexity + costGeminiCall + imageCost(input.SampleCount)
+	}
+	c.Mutation.VaryImage = func(childComplexity int, input model.VaryImageInput) int {
+		return childComplexity + imageCost(input.SampleCount)
+	}
+	c.Mutation.CompareReplies = func(childComplexity int, _ string) int {
+		return childComplexity + costTwitterCall + costGeminiCall
+	}
+	c.Mutation.UploadImage = func(childComplexity int, _ graphql.Upload, _ *string) int {
+		return childComplexity + costGeminiCall
+	}
+	c.Mutation.PostToTwitter = func(childComplexity int, _ model.TwitterPostInput) int {
+		return childComplexity + costTwitterCall
+	}
+	c.Mutation.SchedulePost = func(childComplexity int, _ model.SchedulePostInput) int {
+		return childComplexity + costTwitterCall
+	}
+	c.Mutation.DeleteTweet = func(childComplexity int, _ string) int {
+		return childComplexity + costTwitterCall
+	}
+	c.Mutation.RefreshTweetMetrics = func(childComplexity int, _ string) int {
+		return childComplexity + costTwitterCall
+	}
+	c.Mutation.OverlayImage = func(childComplexity int, _ model.ImageOverlayInput) int {
+		return childComplexity + costRender
+	}
+	c.Mutation.RenderScreenshot = func(childComplexity int, _ string, _ *model.ScreenshotTheme, _ *string) int {
+		return childComplexity + costRender
+	}
+
+	return c
+}
+
+// imageDescriptionCost prices describing a referenced image, which takes a Gemini call unless
+// the image was described when it was uploaded
+func imageDescriptionCost(imageID *string) int {
+	if imageID == nil || *imageID == "" {
+		return 0
+	}
+	return costGeminiCall
+}
+
+// imageCost prices an image generation by the number of images requested.
+// Out of range counts are priced at the maximum; the resolver rejects them anyway.
+func imageCost(sampleCount *int) int {
+	n := 1
+	if sampleCount != nil {
+		n = *sampleCount
+		if n < 1 || n > image.MaxSampleCount {
+			n = image.MaxSampleCount
+		}
+	}
+	return n * costImage
+}
@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// errCookiesUnavailable is returned when a resolver sets a cookie on a request that did not pass
+// through ResponseCookies
+var errCookiesUnavailable = errors.New("response cookies are not available for this request")
+
+// cookiesKey is the context key of the cookies collected for the current response
+type cookiesKey struct{}
+
+// responseCookies collects the cookies resolvers set while a request is executed
+type responseCookies struct {
+	secure  bool // The request arrived over HTTPS, directly or through a proxy
+	mu      sync.Mutex
+	cookies []*http.Cookie
+}
+
+// withResponseCookies returns a context in which resolvers can set cookies on the response to req
+func withResponseCookies(req *http.Request) (context.Context, *responseCookies) {
+	c := &responseCookies{secure: isHTTPS(req)}
+	return context.WithValue(req.Context(), cookiesKey{}, c), c
+}
+
+// isHTTPS reports whether req arrived over HTTPS. Behind a TLS-terminating proxy the scheme is
+// only known from X-Forwarded-Proto.
+func isHTTPS(req *http.Request) bool {
+	return req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+// setCookie adds a cookie to the response of the current request. Cookies set on requests that
+// arrived over HTTPS are marked Secure.
+func setCookie(ctx context.Context, cookie *http.Cookie) error {
+	c, ok := ctx.Value(cookiesKey{}).(*responseCookies)
+	if !ok {
+		return errCookiesUnavailable
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cookie.Secure = cookie.Secure || c.secure
+	c.cookies = append(c.cookies, cookie)
+	return nil
+}
+
+// ResponseCookies lets resolvers set cookies on the response to a GraphQL request. The cookies
+// are written before the response, which gqlgen only starts once the operation has executed.
+func ResponseCookies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, c := withResponseCookies(r)
+		next.ServeHTTP(&cookieWriter{ResponseWriter: w, cookies: c}, r.WithContext(ctx))
+	})
+}
+
+// cookieWriter adds the collected cookies before the response is written
+type cookieWriter struct {
+	http.ResponseWriter
+	cookies *responseCookies
+	written bool
+}
+
+func (w *cookieWriter) WriteHeader(status int) {
+	w.writeCookies()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *cookieWriter) Write(b []byte) (int, error) {
+	w.writeCookies()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *cookieWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *cookieWriter) writeCookies() {
+	if w.written {
+		return
+	}
+	w.written = true
+	w.cookies.mu.Lock()
+	defer w.cookies.mu.Unlock()
+	for _, cookie := range w.cookies.cookies {
+		http.SetCookie(w.ResponseWriter, cookie)
+	}
+}
@@ -0,0 +1,49 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseCookies(t *testing.T) {
+	tests := []struct {
+		name       string
+		proto      string
+		wantSecure bool
+	}{
+		{name: "http", wantSecure: false},
+		{name: "https behind a proxy", proto: "https", wantSecure: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := ResponseCookies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := setCookie(r.Context(), &http.Cookie{Name: "state", Value: "value"}); err != nil {
+					t.Errorf("setCookie() error = %v", err)
+				}
+				_, _ = w.Write([]byte(`{"data":{}}`))
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/graphql", http.NoBody)
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Value != "value" {
+				t.Fatalf("cookies = %+v, want the state cookie", cookies)
+			}
+			if cookies[0].Secure != tt.wantSecure {
+				t.Errorf("Secure = %v, want %v", cookies[0].Secure, tt.wantSecure)
+			}
+		})
+	}
+
+	if err := setCookie(context.Background(), &http.Cookie{Name: "state"}); !errors.Is(err, errCookiesUnavailable) {
+		t.Errorf("setCookie() without the middleware error = %v, want errCookiesUnavailable", err)
+	}
+}
@@ -0,0 +1,62 @@
+package graph
+
+import (
+	"context"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/health"
+)
+
+// healthStatus summarizes the service for the health field: OK when every configured dependency
+// is healthy or no health checks are configured, otherwise DEGRADED or DOWN
+func (r *Resolver) healthStatus(ctx context.Context) string {
+	if r.healthChecker == nil {
+		return "OK"
+	}
+	report := r.healthChecker.Check(ctx)
+	if report.Status == health.StatusHealthy {
+		return "OK"
+	}
+	return string(report.Status)
+}
+
+// healthReport returns the status of each dependency, or nil when health checks are not configured.
+// Probe errors can reveal internal hosts and configuration, so only authenticated callers see them.
+func (r *Resolver) healthReport(ctx context.Context) *model.HealthReport {
+	if r.healthChecker == nil {
+		return nil
+	}
+	report := r.healthChecker.Check(ctx)
+	_, showErrors := auth.UserFrom(ctx)
+
+	result := &model.HealthReport{
+		Status:       model.HealthStatus(report.Status),
+		CheckedAt:    report.CheckedAt.Format(time.RFC3339),
+		Dependencies: make([]*model.DependencyHealth, 0, len(report.Dependencies)),
+	}
+	for _, dep := range report.Dependencies {
+		dependency := &model.DependencyHealth{
+			Name:       dep.Name,
+			Configured: dep.Configured(),
+			Critical:   dep.Critical,
+			Status:     model.HealthStatus(dep.Status),
+		}
+		if !dep.CheckedAt.IsZero() {
+			latency, checkedAt := int(dep.Latency.Milliseconds()), dep.CheckedAt.Format(time.RFC3339)
+			dependency.LatencyMs = &latency
+			dependency.CheckedAt = &checkedAt
+		}
+		if showErrors && dep.LastError != "" {
+			lastError := dep.LastError
+			dependency.LastError = &lastError
+		}
+		if !dep.LastSuccessAt.IsZero() {
+			lastSuccessAt := dep.LastSuccessAt.Format(time.RFC3339)
+			dependency.LastSuccessAt = &lastSuccessAt
+		}
+		result.Dependencies = append(result.Dependencies, dependency)
+	}
+	return result
+}
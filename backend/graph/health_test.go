@@ -0,0 +1,72 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/health"
+)
+
+func TestQueryResolver_HealthReport(t *testing.T) {
+	checker := health.New([]health.Dependency{
+		{Name: "gemini", Probe: func(context.Context) error { return nil }, Critical: true},
+		{Name: "storage", Probe: func(context.Context) error { return errors.New("bucket not found") }},
+		{Name: "twitter"},
+	})
+	resolver := &queryResolver{NewResolver(nil, nil, nil, WithHealthChecker(checker))}
+
+	status, err := resolver.Health(context.Background())
+	if err != nil {
+		t.Fatalf("Health() error = %v", err)
+	}
+	if status != string(health.StatusDegraded) {
+		t.Errorf("Health() = %q, want %q", status, health.StatusDegraded)
+	}
+
+	signedIn := auth.WithUser(context.Background(), &auth.User{ID: "alice", Method: auth.MethodAPIKey})
+	report, err := resolver.HealthReport(signedIn)
+	if err != nil {
+		t.Fatalf("HealthReport() error = %v", err)
+	}
+	if report.Status != model.HealthStatusDegraded {
+		t.Errorf("Status = %s, want %s", report.Status, model.HealthStatusDegraded)
+	}
+	if len(report.Dependencies) != 3 {
+		t.Fatalf("got %d dependencies, want 3", len(report.Dependencies))
+	}
+
+	gemini, storage, twitter := report.Dependencies[0], report.Dependencies[1], report.Dependencies[2]
+	if !gemini.Configured || !gemini.Critical || gemini.Status != model.HealthStatusHealthy || gemini.LatencyMs == nil || gemini.LastSuccessAt == nil {
+		t.Errorf("gemini = %+v", gemini)
+	}
+	if storage.Status != model.HealthStatusDown || storage.LastError == nil || *storage.LastError != "bucket not found" || storage.LastSuccessAt != nil {
+		t.Errorf("storage = %+v", storage)
+	}
+	if twitter.Configured || twitter.Status != model.HealthStatusUnconfigured || twitter.CheckedAt != nil || twitter.LatencyMs != nil {
+		t.Errorf("twitter = %+v", twitter)
+	}
+
+	// Anonymous callers see the status without the probe errors
+	anonymous, err := resolver.HealthReport(context.Background())
+	if err != nil {
+		t.Fatalf("HealthReport() error = %v", err)
+	}
+	if storage := anonymous.Dependencies[1]; storage.Status != model.HealthStatusDown || storage.LastError != nil {
+		t.Errorf("anonymous storage = %+v, want DOWN without the error", storage)
+	}
+}
+
+func TestQueryResolver_HealthReport_NotConfigured(t *testing.T) {
+	resolver := &queryResolver{&Resolver{}}
+
+	report, err := resolver.HealthReport(context.Background())
+	if err != nil {
+		t.Fatalf("HealthReport() error = %v", err)
+	}
+	if report != nil {
+		t.Errorf("HealthReport() = %+v, want nil", report)
+	}
+}
@@ -1,36 +1,85 @@
 package graph
 
 import (
-	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+)
+
+const (
+	// maxPostLength is the maximum length of a post in characters
+	maxPostLength = 280
+	// maxPostImages is the maximum number of images attached to a post
+	maxPostImages = 4
+	// maxAltTextLength is the maximum length of image alt text in characters
+	maxAltTextLength = 1000
 )
 
+// replyPersona is a kind of reply the simulator predicts
+type replyPersona struct {
+	Type        model.ReplyType
+	Description string
+	// DisplayName and Handle are the fake account shown on rendered screenshots
+	DisplayName string
+	Handle      string
+}
+
+// replyPersonas are the reply types generated for every post, in display order
+var replyPersonas = []replyPersona{
+	{model.ReplyTypeLogicalCriticism, "正論で批判するタイプ", "正論パンチ", "seiron_punch"},
+	{model.ReplyTypeNitpicking, "揚げ足を取るタイプ", "揚げ足取り名人", "ageashi_meijin"},
+	{model.ReplyTypeOffTarget, "的外れな批判", "通りすがりの論客", "toorisugari_ronkyaku"},
+	{model.ReplyTypeExcessiveDefense, "過剰に擁護するタイプ", "全肯定botくん", "zenkoutei_bot"},
+}
+
+// postImage is an image attached to a post before it is decoded
+type postImage struct {
+	URL     string
+	AltText string
+}
+
 // stringPtr returns a pointer to a string
 func stringPtr(s string) *string {
 	return &s
 }
 
-// generateImagePromptFromText generates an image prompt from the given text using Gemini
-func generateImagePromptFromText(ctx context.Context, client GeminiClient, text string) (string, error) {
-	promptTemplate := fmt.Sprintf(`以下の炎上投稿に合わせた、視覚的にインパクトのある画像のプロンプトを生成してください。
+// optionalString returns a pointer to s, or nil if s is empty
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
 
-【投稿】
-%s
+// stringValue dereferences an optional string, treating nil as empty
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
 
-【要件】
-- 投稿の雰囲気を視覚的に表現
-- 炎のモチーフを含める
-- SNS映えする構図
-- ミーム的な要素
-- 日本のネット文化に馴染む表現
+// boolValue dereferences an optional boolean, treating nil as false
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
 
-画像生成プロンプト（英語）のみを出力してください。説明は不要です。`, text)
+// validatePostText checks that the post text is non-empty and within the character limit
+func validatePostText(text string) error {
+	if text == "" {
+		return errors.New("投稿内容が空です")
+	}
+
+	// Check character limit (considering runes for proper Unicode counting)
+	if len([]rune(text)) > maxPostLength {
+		return errors.New("投稿内容が280文字を超えています")
+	}
 
-	return client.GenerateContent(ctx, promptTemplate)
+	return nil
 }
 
 // createImageDataURL creates a data URL from image bytes
@@ -73,3 +122,44 @@ func extractImageDataFromURL(dataURL string) ([]byte, error) {
 
 	return imageData, nil
 }
+
+// collectPostImages merges the legacy single imageUrl with the images list and validates the result
+func collectPostImages(imageURL *string, images []*model.PostImageInput) ([]postImage, error) {
+	var collected []postImage
+	if imageURL != nil && *imageURL != "" {
+		collected = append(collected, postImage{URL: *imageURL})
+	}
+	for _, img := range images {
+		if img == nil {
+			return nil, errors.New("画像URLが空です")
+		}
+		ref := stringValue(img.URL)
+		if ref == "" {
+			ref = stringValue(img.ImageID)
+		}
+		if ref == "" {
+			return nil, errors.New("画像URLが空です")
+		}
+		collected = append(collected, postImage{URL: ref, AltText: stringValue(img.AltText)})
+	}
+
+	if len(collected) > maxPostImages {
+		return nil, fmt.Errorf("画像は最大%d枚までです", maxPostImages)
+	}
+	for i, img := range collected {
+		if len([]rune(img.AltText)) > maxAltTextLength {
+			return nil, fmt.Errorf("画像%dの代替テキストが%d文字を超えています", i+1, maxAltTextLength)
+		}
+	}
+
+	return collected, nil
+}
+
+// altTextFromPrompt derives suggested alt text for a generated image from its prompt
+func altTextFromPrompt(prompt string) string {
+	altText := []rune(strings.TrimSpace(prompt))
+	if len(altText) > maxAltTextLength {
+		altText = altText[:maxAltTextLength]
+	}
+	return string(altText)
+}
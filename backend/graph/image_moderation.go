@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+)
+
+// Image moderation categories reported by GeminiImageModerator
+const (
+	ImageCategoryRealPerson    = "real_person"
+	ImageCategoryLogo          = "logo"
+	ImageCategoryHatefulSymbol = "hateful_symbol"
+	ImageCategoryEmbeddedText  = "embedded_text"
+)
+
+// ImageModerator checks whether an image is acceptable to publish
+type ImageModerator interface {
+	ModerateImage(ctx context.Context, image []byte) (*ModerationResult, error)
+}
+
+// VisionClient generates text from a prompt about an image
+type VisionClient interface {
+	GenerateContentWithImage(ctx context.Context, prompt string, image []byte) (string, error)
+}
+
+// GeminiImageModerator is an ImageModerator that asks a multimodal Gemini model to classify images
+type GeminiImageModerator struct {
+	client VisionClient
+}
+
+// NewGeminiImageModerator creates an image moderator backed by the multimodal client
+func NewGeminiImageModerator(client VisionClient) *GeminiImageModerator {
+	return &GeminiImageModerator{client: client}
+}
+
+// ModerateImage classifies an image for real people, logos, hateful symbols and embedded text
+func (m *GeminiImageModerator) ModerateImage(ctx context.Context, image []byte) (*ModerationResult, error) {
+	if len(image) == 0 {
+		return nil, errors.New("image is required")
+	}
+
+	raw, err := m.client.GenerateContentWithImage(ctx, imageModerationPrompt, image)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run image moderation check: %w", err)
+	}
+
+	var resp moderationResponse
+	if err := json.Unmarshal([]byte(extractJSONObject(raw)), &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse image moderation result: %w", err)
+	}
+
+	return &ModerationResult{
+		Flagged:    resp.Flagged,
+		Categories: resp.Categories,
+		Reason:     resp.Reason,
+	}, nil
+}
+
+// imageModerationPrompt asks Gemini to classify the attached image before it is shown or posted
+const imageModerationPrompt = `あなたはSNSに投稿される画像のモデレーターです。添付の画像を実際のSNSに公開してよいか判定してください。
+
+【公開してはいけない内容】
+- 実在の人物（有名人・政治家など）と判別できる顔や姿 (real_person)
+- 実在する企業・ブランドのロゴや商標 (logo)
+- ヘイトシンボル、差別的な記号・ジェスチャー (hateful_symbol)
+- 画像内に埋め込まれた読める文字・文章 (embedded_text)
+
+架空の人物、一般的な炎やスマートフォンなどのモチーフは公開可とします。
+
+次のJSONのみを出力してください。説明は不要です。
+{"flagged": true または false, "categories": ["該当カテゴリ"], "reason": "理由（日本語で1文）"}`
+
+// imageModerationCalls returns the number of Gemini calls moderating n images takes, which is
+// none when image moderation is not configured
+func (r *Resolver) imageModerationCalls(n int) int {
+	if r.imageModerator == nil {
+		return 0
+	}
+	return n
+}
+
+// moderateImage runs the optional image moderation step on a generated or uploaded image.
+// Failures are logged and leave the image without a verdict.
+func (r *Resolver) moderateImage(ctx context.Context, image []byte) *model.ModerationVerdict {
+	if r.imageModerator == nil {
+		return nil
+	}
+
+	result, err := r.imageModerator.ModerateImage(ctx, image)
+	if err != nil {
+		slog.WarnContext(ctx, "image moderation failed", "error", err)
+		return nil
+	}
+	return toModerationVerdict(result)
+}
+
+// toModerationVerdict converts a moderation result into its GraphQL representation
+func toModerationVerdict(result *ModerationResult) *model.ModerationVerdict {
+	if result == nil {
+		return nil
+	}
+	categories := result.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	return &model.ModerationVerdict{
+		Flagged:    result.Flagged,
+		Categories: categories,
+		Reason:     optionalString(result.Reason),
+	}
+}
+
+// moderationReason describes a flagged moderation result for policy reasons
+func moderationReason(result *ModerationResult) string {
+	reason := "flagged by moderation"
+	if len(result.Categories) > 0 {
+		reason += " (" + strings.Join(result.Categories, ", ") + ")"
+	}
+	if result.Reason != "" {
+		reason += ": " + result.Reason
+	}
+	return reason
+}
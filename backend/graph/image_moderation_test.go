@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/image"
+	"github.com/Tattsum/enjo/backend/twitter"
+)
+
+type mockVisionClient struct {
+	GenerateContentWithImageFunc func(ctx context.Context, prompt string, image []byte) (string, error)
+}
+
+func (m *mockVisionClient) GenerateContentWithImage(ctx context.Context, prompt string, image []byte) (string, error) {
+	return m.GenerateContentWithImageFunc(ctx, prompt, image)
+}
+
+func TestGeminiImageModerator_ModerateImage(t *testing.T) {
+	tests := []struct {
+		name           string
+		response       string
+		err            error
+		wantFlagged    bool
+		wantCategories []string
+		wantErr        bool
+	}{
+		{
+			name:           "parses flagged result wrapped in a code fence",
+			response:       "```json\n{\"flagged\": true, \"categories\": [\"real_person\", \"embedded_text\"], \"reason\": \"実在の政治家\"}\n```",
+			wantFlagged:    true,
+			wantCategories: []string{ImageCategoryRealPerson, ImageCategoryEmbeddedText},
+		},
+		{
+			name:     "parses clean result",
+			response: `{"flagged": false, "categories": [], "reason": ""}`,
+		},
+		{
+			name:     "returns error for non JSON response",
+			response: "判定できません",
+			wantErr:  true,
+		},
+		{
+			name:    "returns error when the model fails",
+			err:     errors.New("unavailable"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moderator := NewGeminiImageModerator(&mockVisionClient{
+				GenerateContentWithImageFunc: func(_ context.Context, prompt string, img []byte) (string, error) {
+					if string(img) != "png-bytes" {
+						t.Errorf("image = %q, want the moderated image", img)
+					}
+					for _, category := range []string{ImageCategoryRealPerson, ImageCategoryLogo, ImageCategoryHatefulSymbol, ImageCategoryEmbeddedText} {
+						if !strings.Contains(prompt, category) {
+							t.Errorf("prompt does not mention %s", category)
+						}
+					}
+					return tt.response, tt.err
+				},
+			})
+
+			got, err := moderator.ModerateImage(context.Background(), []byte("png-bytes"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ModerateImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Flagged != tt.wantFlagged || len(got.Categories) != len(tt.wantCategories) {
+				t.Errorf("ModerateImage() = %+v, want flagged %v with %q", got, tt.wantFlagged, tt.wantCategories)
+			}
+		})
+	}
+}
+
+func TestGenerateImage_AttachesModerationVerdicts(t *testing.T) {
+	moderator := &mockImageModerator{flagged: map[string]bool{"png-2": true}}
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateContentFunc: func(_ context.Context, _ string) (string, error) {
+				return "a burning phone", nil
+			},
+		},
+		nil,
+		&MockImageClient{
+			GenerateImagesFunc: func(_ context.Context, _ string, _ ...image.Option) ([][]byte, error) {
+				return [][]byte{[]byte("png-1"), []byte("png-2")}, nil
+			},
+		},
+		WithImageModerator(moderator),
+	)
+
+	result, err := (&mutationResolver{r}).GenerateImage(context.Background(), model.GenerateImageInput{Text: "炎上投稿", SampleCount: intPtr(2)})
+	if err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+	if result.Moderation == nil || result.Moderation.Flagged {
+		t.Errorf("Moderation = %+v, want the clean verdict of the first variant", result.Moderation)
+	}
+	second := result.Variants[1].Moderation
+	if second == nil || !second.Flagged || second.Categories[0] != ImageCategoryLogo {
+		t.Errorf("second variant Moderation = %+v, want flagged for a logo", second)
+	}
+
+	// Failed checks leave the image without a verdict
+	r.imageModerator = &mockImageModerator{err: errors.New("unavailable")}
+	result, err = (&mutationResolver{r}).GenerateImage(context.Background(), model.GenerateImageInput{Text: "炎上投稿"})
+	if err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+	if result.Moderation != nil {
+		t.Errorf("Moderation = %+v, want nil when the check fails", result.Moderation)
+	}
+}
+
+func TestPostToTwitter_BlocksFlaggedImages(t *testing.T) {
+	policy := DefaultPostingPolicy()
+	policy.ImageModerator = &mockImageModerator{flagged: map[string]bool{"logo.png": true}}
+	var posted int
+	r := NewResolver(nil, &MockTwitterClient{
+		PostTweetWithImagesFunc: func(_ context.Context, _ string, _ []twitter.Image) (*twitter.TweetResult, error) {
+			posted++
+			return &twitter.TweetResult{ID: "1", URL: "https://twitter.com/user/status/1"}, nil
+		},
+	}, nil, WithPolicyEngine(newTestPolicyEngine(t, policy)))
+
+	result, err := (&mutationResolver{r}).PostToTwitter(context.Background(), model.TwitterPostInput{
+		Text:     "炎上投稿",
+		Level:    intPtr(1),
+		ImageURL: stringPtr(createImageDataURL([]byte("logo.png"))),
+	})
+	if err != nil {
+		t.Fatalf("PostToTwitter() error = %v", err)
+	}
+	if result.Success || posted != 0 {
+		t.Fatalf("PostToTwitter() = %+v, want the flagged image to be refused", result)
+	}
+	if result.Policy == nil || result.Policy.Action != model.PolicyActionBlock || len(result.Policy.ImageModeration) != 1 {
+		t.Errorf("Policy = %+v, want BLOCK with the image verdict", result.Policy)
+	}
+}
@@ -0,0 +1,394 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/image"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/twitter"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+var (
+	// errUnsupportedImageReference is returned for image references that are neither data URLs nor stored images
+	errUnsupportedImageReference = errors.New("image must be a data URL, a signed image URL or a stored image ID")
+	// errImageVariationsDisabled is returned by varyImage when images are not stored with their metadata
+	errImageVariationsDisabled = errors.New("image variations require an image store")
+	// errImagePromptsDisabled is returned by generateImage when no Gemini client is configured to write prompts
+	errImagePromptsDisabled = errors.New("image prompts require a Gemini client")
+	// errImageNotGenerated is returned by varyImage for images that were uploaded rather than generated
+	errImageNotGenerated = errors.New("only generated images can be varied")
+	// errNoImagesGenerated is returned when the image client succeeds without returning any image
+	errNoImagesGenerated = errors.New("no images were generated")
+)
+
+// maxImageSeed is the largest seed accepted by Imagen
+const maxImageSeed = 1<<31 - 1
+
+// imageGeneration describes one request to the image client and the metadata recorded for its variants
+type imageGeneration struct {
+	prompt         string
+	negativePrompt string
+	style          string // Style name understood by the image package, e.g. "MEME"
+	aspectRatio    string // Aspect ratio such as "16:9"
+	seed           *int64
+	sampleCount    int
+	parentID       string
+	// model, imageSize, personGeneration and safetySetting override the deployment defaults when non-empty
+	model            string
+	imageSize        string
+	personGeneration string // Value understood by the image package, e.g. "allow_adult"
+	safetySetting    string // Value understood by the image package, e.g. "block_only_high"
+}
+
+// options converts the generation into image client options
+func (g imageGeneration) options() []image.Option {
+	options := []image.Option{image.WithSampleCount(g.sampleCount)}
+	if g.style != "" {
+		options = append(options, image.WithStyle(g.style))
+	}
+	if g.aspectRatio != "" {
+		options = append(options, image.WithAspectRatio(g.aspectRatio))
+	}
+	if g.negativePrompt != "" {
+		options = append(options, image.WithNegativePrompt(g.negativePrompt))
+	}
+	if g.seed != nil {
+		options = append(options, image.WithSeed(*g.seed))
+	}
+	if g.model != "" {
+		options = append(options, image.WithModel(g.model))
+	}
+	if g.imageSize != "" {
+		options = append(options, image.WithImageSize(g.imageSize))
+	}
+	if g.personGeneration != "" {
+		options = append(options, image.WithPersonGeneration(g.personGeneration))
+	}
+	if g.safetySetting != "" {
+		options = append(options, image.WithSafetySetting(g.safetySetting))
+	}
+	return options
+}
+
+// writeImagePrompt writes the Imagen prompt for a post. Sanitization is on unless explicitly disabled.
+func (r *Resolver) writeImagePrompt(ctx context.Context, post, style, aspectRatio string, sanitize *bool) (*image.PromptResult, error) {
+	pipeline := r.imagePrompts
+	if pipeline == nil {
+		if r.geminiClient == nil {
+			return nil, errImagePromptsDisabled
+		}
+		pipeline = image.NewPromptPipeline(r.geminiClient)
+	}
+	return pipeline.Generate(ctx, image.PromptRequest{
+		Post:        post,
+		Style:       style,
+		AspectRatio: aspectRatio,
+		Sanitize:    sanitize == nil || *sanitize,
+	})
+}
+
+// generateImageVariants generates the requested variants, stores each one and records
+// its generation metadata so that it can be varied later
+func (r *Resolver) generateImageVariants(ctx context.Context, gen imageGeneration) (*model.GenerateImageResult, error) {
+	images, err := r.imageClient.GenerateImages(ctx, gen.prompt, gen.options()...)
+	if err != nil {
+		return nil, imageGenerationError(err)
+	}
+	if len(images) == 0 {
+		return nil, errNoImagesGenerated
+	}
+
+	variants := make([]*model.GeneratedImage, 0, len(images))
+	for _, data := range images {
+		id, url, err := r.storeImage(ctx, data, gen.record())
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, &model.GeneratedImage{
+			ImageID:        optionalString(id),
+			ImageURL:       url,
+			Seed:           seedValue(gen.seed),
+			NegativePrompt: optionalString(gen.negativePrompt),
+			ParentImageID:  optionalString(gen.parentID),
+			Moderation:     r.moderateImage(ctx, data),
+		})
+	}
+
+	return &model.GenerateImageResult{
+		ImageID:     variants[0].ImageID,
+		ImageURL:    variants[0].ImageURL,
+		Prompt:      gen.prompt,
+		AltText:     altTextFromPrompt(gen.prompt),
+		GeneratedAt: getCurrentTimestamp(),
+		Variants:    variants,
+		Moderation:  variants[0].Moderation,
+	}, nil
+}
+
+// record returns the generation metadata recorded for the variants
+func (g imageGeneration) record() imagestore.Record {
+	return imagestore.Record{
+		Prompt:           g.prompt,
+		NegativePrompt:   g.negativePrompt,
+		Style:            g.style,
+		AspectRatio:      g.aspectRatio,
+		Seed:             g.seed,
+		ParentID:         g.parentID,
+		Model:            g.model,
+		ImageSize:        g.imageSize,
+		PersonGeneration: g.personGeneration,
+		SafetySetting:    g.safetySetting,
+	}
+}
+
+// imageGenerationError converts image client errors into GraphQL errors. Safety filter
+// rejections carry Imagen's reasons in the extensions so clients can tell them apart.
+func imageGenerationError(err error) error {
+	var filtered *image.FilteredError
+	if errors.As(err, &filtered) {
+		return &gqlerror.Error{
+			Message: "画像が安全フィルタによりブロックされました。プロンプトを変えて再度お試しください",
+			Extensions: map[string]any{
+				"code":    "IMAGE_FILTERED",
+				"reasons": filtered.Reasons,
+			},
+		}
+	}
+	var apiErr *image.APIError
+	if errors.As(err, &apiErr) {
+		return &gqlerror.Error{
+			Message: fmt.Sprintf("failed to generate image: %s", apiErr.Message),
+			Extensions: map[string]any{
+				"code":       "IMAGE_API_ERROR",
+				"status":     apiErr.Status,
+				"statusCode": apiErr.StatusCode,
+			},
+		}
+	}
+	return fmt.Errorf("failed to generate image: %w", err)
+}
+
+// imageVariation builds the generation for a variation of a stored image.
+// The stored prompt, style and aspect ratio are reused; the seed defaults to a new random one.
+func imageVariation(record *imagestore.Record, input model.VaryImageInput) (imageGeneration, error) {
+	if record.Prompt == "" {
+		return imageGeneration{}, errImageNotGenerated
+	}
+	gen := imageGeneration{
+		prompt:           record.Prompt,
+		negativePrompt:   record.NegativePrompt,
+		style:            record.Style,
+		aspectRatio:      record.AspectRatio,
+		parentID:         record.ID,
+		model:            record.Model,
+		imageSize:        record.ImageSize,
+		personGeneration: record.PersonGeneration,
+		safetySetting:    record.SafetySetting,
+	}
+	if input.NegativePrompt != nil {
+		gen.negativePrompt = *input.NegativePrompt
+	}
+
+	var err error
+	if gen.sampleCount, err = parseSampleCount(input.SampleCount); err != nil {
+		return imageGeneration{}, err
+	}
+	if gen.seed, err = parseImageSeed(input.Seed); err != nil {
+		return imageGeneration{}, err
+	}
+	if gen.seed == nil {
+		seed := rand.Int64N(maxImageSeed) + 1
+		gen.seed = &seed
+	}
+	return gen, nil
+}
+
+// parseSampleCount validates the requested number of variants, defaulting to one
+func parseSampleCount(n *int) (int, error) {
+	if n == nil {
+		return 1, nil
+	}
+	if *n < 1 || *n > image.MaxSampleCount {
+		return 0, fmt.Errorf("sampleCount must be between 1 and %d, got %d", image.MaxSampleCount, *n)
+	}
+	return *n, nil
+}
+
+// parseImageSeed validates an optional seed
+func parseImageSeed(seed *int) (*int64, error) {
+	if seed == nil {
+		return nil, nil
+	}
+	if *seed < 1 || *seed > maxImageSeed {
+		return nil, fmt.Errorf("seed must be between 1 and %d, got %d", maxImageSeed, *seed)
+	}
+	v := int64(*seed)
+	return &v, nil
+}
+
+// seedValue converts a stored seed for the GraphQL schema
+func seedValue(seed *int64) *int {
+	if seed == nil {
+		return nil
+	}
+	v := int(*seed)
+	return &v
+}
+
+// imageStyleName maps the GraphQL image style to the style name understood by the image package
+func imageStyleName(style *model.ImageStyle) string {
+	if style == nil {
+		return ""
+	}
+	return string(*style)
+}
+
+// personGenerationValue maps the GraphQL person generation setting to the value understood by the image package
+func personGenerationValue(setting *model.PersonGeneration) string {
+	if setting == nil {
+		return ""
+	}
+	return strings.ToLower(string(*setting))
+}
+
+// safetySettingValue maps the GraphQL safety setting to the value understood by the image package
+func safetySettingValue(setting *model.SafetySetting) string {
+	if setting == nil {
+		return ""
+	}
+	return strings.ToLower(string(*setting))
+}
+
+// imageAspectRatio maps the GraphQL aspect ratio to Imagen's ratio notation
+func imageAspectRatio(ratio *model.AspectRatio) string {
+	if ratio == nil {
+		return ""
+	}
+	switch *ratio {
+	case model.AspectRatioLandscape:
+		return "16:9"
+	case model.AspectRatioPortrait:
+		return "9:16"
+	default:
+		return "1:1"
+	}
+}
+
+// loadImage returns the bytes of an image reference: a data URL, a signed /images URL or a stored
+// image ID. Stored images must belong to the current user.
+func (r *Resolver) loadImage(ctx context.Context, ref string) ([]byte, error) {
+	return r.loadImageForOwner(ctx, currentOwner(ctx), ref)
+}
+
+// loadImageForOwner returns the bytes of an image reference, which must belong to ownerID when it is stored
+func (r *Resolver) loadImageForOwner(ctx context.Context, ownerID, ref string) ([]byte, error) {
+	if strings.HasPrefix(ref, "data:") || r.imageStore == nil {
+		return extractImageDataFromURL(ref)
+	}
+
+	id := imageIDFromReference(ref)
+	if !imagestore.ValidID(id) {
+		return nil, errUnsupportedImageReference
+	}
+	if err := r.checkImageOwner(ctx, ownerID, id); err != nil {
+		return nil, fmt.Errorf("failed to load image %s: %w", id, err)
+	}
+
+	data, err := r.imageStore.Get(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image %s: %w", id, err)
+	}
+	return data, nil
+}
+
+// imageIDFromReference returns the stored image ID of a signed /images URL, or ref itself
+func imageIDFromReference(ref string) string {
+	if id, ok := imagestore.IDFromURL(ref); ok {
+		return id
+	}
+	return ref
+}
+
+// loadPostImages loads the bytes of each image of a post by ownerID
+func (r *Resolver) loadPostImages(ctx context.Context, ownerID string, images []postImage) ([]twitter.Image, error) {
+	loaded := make([]twitter.Image, 0, len(images))
+	for i, img := range images {
+		data, err := r.loadImageForOwner(ctx, ownerID, img.URL)
+		if err != nil {
+			return nil, fmt.Errorf("image %d: %w", i+1, err)
+		}
+		loaded = append(loaded, twitter.Image{Data: data, AltText: img.AltText})
+	}
+	return loaded, nil
+}
+
+// imageBytes returns the data of each loaded image
+func imageBytes(images []twitter.Image) [][]byte {
+	data := make([][]byte, 0, len(images))
+	for _, img := range images {
+		data = append(data, img.Data)
+	}
+	return data
+}
+
+// storeImage saves image bytes and returns the stored ID and a URL for clients. record is kept in
+// the image catalog with the current user as owner, so that only they can use the image later.
+// Without an image store the image is returned inline as a data URL and the ID is empty.
+func (r *Resolver) storeImage(ctx context.Context, data []byte, record imagestore.Record) (id, url string, err error) {
+	if r.imageStore == nil {
+		return "", createImageDataURL(data), nil
+	}
+
+	id, err = r.imageStore.Put(ctx, data)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to store image: %w", err)
+	}
+	if r.imageCatalog != nil {
+		record.ID = id
+		record.OwnerID = currentOwner(ctx)
+		if _, err := r.imageCatalog.Save(ctx, record); err != nil {
+			return "", "", err
+		}
+	}
+	return id, r.imageURLs.URL(id), nil
+}
+
+// persistInlineImages moves data URL images into the image store so that only IDs are kept
+// in long-lived records such as scheduled posts
+func (r *Resolver) persistInlineImages(ctx context.Context, images []postImage) ([]postImage, error) {
+	if r.imageStore == nil {
+		return images, nil
+	}
+
+	persisted := make([]postImage, 0, len(images))
+	for i, img := range images {
+		if strings.HasPrefix(img.URL, "data:") {
+			data, err := extractImageDataFromURL(img.URL)
+			if err != nil {
+				return nil, fmt.Errorf("image %d: %w", i+1, err)
+			}
+			id, _, err := r.storeImage(ctx, data, imagestore.Record{})
+			if err != nil {
+				return nil, err
+			}
+			img.URL = id
+		}
+		persisted = append(persisted, img)
+	}
+	return persisted, nil
+}
+
+// ImageHandler returns the HTTP handler serving stored images, or nil when no image store is configured
+func (r *Resolver) ImageHandler() http.Handler {
+	if r.imageStore == nil {
+		return nil
+	}
+	return imagestore.NewHandler(r.imageStore, r.imageURLs)
+}
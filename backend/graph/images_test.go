@@ -0,0 +1,553 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	goimage "image"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/image"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/store"
+	"github.com/Tattsum/enjo/backend/twitter"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func newTestImageStoreOption(t *testing.T) (ResolverOption, imagestore.Store) {
+	t.Helper()
+
+	images, err := imagestore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalStore() error = %v", err)
+	}
+	signer, err := imagestore.NewURLSigner([]byte("secret"), time.Hour, "http://localhost:8080")
+	if err != nil {
+		t.Fatalf("NewURLSigner() error = %v", err)
+	}
+	return WithImageStore(images, signer), images
+}
+
+func TestImageStore_GenerateAndPost(t *testing.T) {
+	ctx := context.Background()
+	withImages, _ := newTestImageStoreOption(t)
+
+	var posted []twitter.Image
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateContentFunc: func(_ context.Context, _ string) (string, error) {
+				return "a burning phone", nil
+			},
+		},
+		&MockTwitterClient{
+			PostTweetWithImagesFunc: func(_ context.Context, _ string, images []twitter.Image) (*twitter.TweetResult, error) {
+				posted = images
+				return &twitter.TweetResult{ID: "1"}, nil
+			},
+		},
+		&MockImageClient{
+			GenerateImageFunc: func(_ context.Context, _ string) ([]byte, error) {
+				return []byte("png-bytes"), nil
+			},
+		},
+		withImages,
+		withLenientPolicy(t),
+	)
+	mutation := &mutationResolver{r}
+
+	generated, err := mutation.GenerateImage(ctx, model.GenerateImageInput{Text: "炎上投稿"})
+	if err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+	if generated.ImageID == nil {
+		t.Fatal("GenerateImage() did not return an image ID")
+	}
+	if !strings.HasPrefix(generated.ImageURL, "http://localhost:8080/images/"+*generated.ImageID+"?") {
+		t.Errorf("ImageURL = %q, want signed image URL", generated.ImageURL)
+	}
+
+	// Images can be referenced by ID or by the signed URL
+	result, err := mutation.PostToTwitter(ctx, model.TwitterPostInput{
+		Text:     "画像付き投稿",
+		Level:    intPtr(1),
+		ImageURL: &generated.ImageURL,
+		Images:   []*model.PostImageInput{{ImageID: generated.ImageID, AltText: &generated.AltText}},
+	})
+	if err != nil || !result.Success {
+		t.Fatalf("PostToTwitter() = %+v, %v, want success", result, err)
+	}
+	if len(posted) != 2 || string(posted[0].Data) != "png-bytes" || string(posted[1].Data) != "png-bytes" {
+		t.Errorf("posted images = %+v, want the stored image twice", posted)
+	}
+
+	missing, err := mutation.PostToTwitter(ctx, model.TwitterPostInput{
+		Text:   "画像付き投稿",
+		Level:  intPtr(1),
+		Images: []*model.PostImageInput{{ImageID: stringPtr("00000000-0000-0000-0000-000000000000")}},
+	})
+	if err != nil || missing.Success {
+		t.Errorf("PostToTwitter() with unknown image = %+v, %v, want failure", missing, err)
+	}
+}
+
+func TestSchedulePost_PersistsInlineImages(t *testing.T) {
+	ctx := context.Background()
+	withImages, images := newTestImageStoreOption(t)
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	postQueue := queue.New(db)
+
+	r := NewResolver(nil, nil, nil, withImages, WithPostQueue(postQueue), withLenientPolicy(t))
+	scheduled, err := (&mutationResolver{r}).SchedulePost(ctx, model.SchedulePostInput{
+		Text:        "予約投稿",
+		Level:       intPtr(1),
+		ImageURL:    stringPtr(createImageDataURL([]byte("png-bytes"))),
+		ScheduledAt: time.Now().Add(time.Hour).Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("SchedulePost() error = %v", err)
+	}
+
+	job, err := postQueue.Get(ctx, scheduled.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(job.Images) != 1 || strings.HasPrefix(job.Images[0].URL, "data:") {
+		t.Fatalf("job images = %+v, want a stored image ID", job.Images)
+	}
+	data, err := images.Get(ctx, job.Images[0].URL)
+	if err != nil || string(data) != "png-bytes" {
+		t.Errorf("stored image = %q, %v, want png-bytes", data, err)
+	}
+}
+
+func TestGenerateImage_VariantsAndVaryImage(t *testing.T) {
+	ctx := context.Background()
+	withImages, _ := newTestImageStoreOption(t)
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	catalog := imagestore.NewCatalog(db)
+
+	var prompts []string
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateContentFunc: func(_ context.Context, _ string) (string, error) {
+				return "a burning phone", nil
+			},
+		},
+		nil,
+		&MockImageClient{
+			GenerateImagesFunc: func(_ context.Context, prompt string, _ ...image.Option) ([][]byte, error) {
+				prompts = append(prompts, prompt)
+				return [][]byte{[]byte("png-1"), []byte("png-2"), []byte("png-3")}, nil
+			},
+		},
+		withImages,
+		WithImageCatalog(catalog),
+	)
+	mutation := &mutationResolver{r}
+
+	generated, err := mutation.GenerateImage(ctx, model.GenerateImageInput{
+		Text:           "炎上投稿",
+		SampleCount:    intPtr(3),
+		NegativePrompt: stringPtr("people"),
+	})
+	if err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+	if len(generated.Variants) != 3 {
+		t.Fatalf("GenerateImage() returned %d variants, want 3", len(generated.Variants))
+	}
+	ids := map[string]bool{}
+	for _, v := range generated.Variants {
+		if v.ImageID == nil {
+			t.Fatal("variant has no image ID")
+		}
+		ids[*v.ImageID] = true
+	}
+	if len(ids) != 3 || *generated.ImageID != *generated.Variants[0].ImageID {
+		t.Errorf("variants = %+v, want three distinct IDs with the first one on the result", generated.Variants)
+	}
+
+	varied, err := mutation.VaryImage(ctx, model.VaryImageInput{ImageID: generated.Variants[1].ImageURL, Seed: intPtr(42)})
+	if err != nil {
+		t.Fatalf("VaryImage() error = %v", err)
+	}
+	if len(prompts) != 2 || prompts[1] != prompts[0] {
+		t.Errorf("prompts = %q, want the stored prompt reused", prompts)
+	}
+	first := varied.Variants[0]
+	if first.Seed == nil || *first.Seed != 42 {
+		t.Errorf("Seed = %v, want 42", first.Seed)
+	}
+	if first.ParentImageID == nil || *first.ParentImageID != *generated.Variants[1].ImageID {
+		t.Errorf("ParentImageID = %v, want %s", first.ParentImageID, *generated.Variants[1].ImageID)
+	}
+	if first.NegativePrompt == nil || *first.NegativePrompt != "people" {
+		t.Errorf("NegativePrompt = %v, want the original negative prompt", first.NegativePrompt)
+	}
+
+	tweaked, err := mutation.VaryImage(ctx, model.VaryImageInput{ImageID: *first.ImageID, NegativePrompt: stringPtr("text, logos")})
+	if err != nil {
+		t.Fatalf("VaryImage() error = %v", err)
+	}
+	if got := tweaked.Variants[0]; got.Seed == nil || got.NegativePrompt == nil || *got.NegativePrompt != "text, logos" {
+		t.Errorf("variant = %+v, want a random seed and the tweaked negative prompt", got)
+	}
+
+	if _, err := mutation.GenerateImage(ctx, model.GenerateImageInput{Text: "炎上投稿", SampleCount: intPtr(5)}); err == nil {
+		t.Error("GenerateImage() with sampleCount 5 succeeded, want error")
+	}
+	if _, err := mutation.VaryImage(ctx, model.VaryImageInput{ImageID: "missing"}); !errors.Is(err, imagestore.ErrRecordNotFound) {
+		t.Errorf("VaryImage(missing) error = %v, want ErrRecordNotFound", err)
+	}
+}
+
+func TestGenerateImage_PromptPipeline(t *testing.T) {
+	var geminiPrompt, imagenPrompt string
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateContentFunc: func(_ context.Context, prompt string) (string, error) {
+				geminiPrompt = prompt
+				return `{"prompt": "a cartoon phone on fire, blood red sky", "reasoning": "炎上をスマホで表現"}`, nil
+			},
+		},
+		nil,
+		&MockImageClient{
+			GenerateImagesFunc: func(_ context.Context, prompt string, _ ...image.Option) ([][]byte, error) {
+				imagenPrompt = prompt
+				return [][]byte{[]byte("png")}, nil
+			},
+		},
+	)
+	mutation := &mutationResolver{r}
+	style := model.ImageStyleDramatic
+	ratio := model.AspectRatioPortrait
+
+	result, err := mutation.GenerateImage(context.Background(), model.GenerateImageInput{Text: "炎上投稿", Style: &style, AspectRatio: &ratio})
+	if err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+	if !strings.Contains(geminiPrompt, "ドラマチック") || !strings.Contains(geminiPrompt, "縦長") {
+		t.Errorf("Gemini prompt does not use the dramatic portrait template:\n%s", geminiPrompt)
+	}
+	if imagenPrompt != "a cartoon phone on fire, red sky" || result.Prompt != imagenPrompt {
+		t.Errorf("prompt = %q, want the sanitized prompt", imagenPrompt)
+	}
+	if result.PromptReasoning == nil || *result.PromptReasoning != "炎上をスマホで表現" {
+		t.Errorf("PromptReasoning = %v, want Gemini's reasoning", result.PromptReasoning)
+	}
+
+	if _, err := mutation.GenerateImage(context.Background(), model.GenerateImageInput{Text: "炎上投稿", SanitizePrompt: boolPtr(false)}); err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+	if !strings.Contains(imagenPrompt, "blood") {
+		t.Errorf("prompt = %q, want it unsanitized", imagenPrompt)
+	}
+}
+
+func TestGenerateImage_ImagenErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode string
+	}{
+		{
+			name:     "safety filter rejections carry the reasons",
+			err:      &image.FilteredError{Reasons: []string{"filtered for sensitive content"}},
+			wantCode: "IMAGE_FILTERED",
+		},
+		{
+			name:     "API errors carry the status",
+			err:      &image.APIError{StatusCode: 400, Status: "INVALID_ARGUMENT", Message: "bad prompt"},
+			wantCode: "IMAGE_API_ERROR",
+		},
+		{
+			name: "other errors are wrapped",
+			err:  errors.New("connection reset"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResolver(
+				&MockGeminiClient{
+					GenerateContentFunc: func(_ context.Context, _ string) (string, error) {
+						return "a burning phone", nil
+					},
+				},
+				nil,
+				&MockImageClient{
+					GenerateImagesFunc: func(_ context.Context, _ string, _ ...image.Option) ([][]byte, error) {
+						return nil, tt.err
+					},
+				},
+			)
+
+			_, err := (&mutationResolver{r}).GenerateImage(context.Background(), model.GenerateImageInput{Text: "炎上投稿"})
+			if !errors.Is(err, tt.err) && tt.wantCode == "" {
+				t.Fatalf("GenerateImage() error = %v, want it to wrap %v", err, tt.err)
+			}
+			var gqlErr *gqlerror.Error
+			if got := errors.As(err, &gqlErr); got != (tt.wantCode != "") {
+				t.Fatalf("GenerateImage() error = %#v, want a GraphQL error: %v", err, tt.wantCode != "")
+			}
+			if tt.wantCode != "" && gqlErr.Extensions["code"] != tt.wantCode {
+				t.Errorf("code = %v, want %s", gqlErr.Extensions["code"], tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestImageVariation_KeepsImagenSettings(t *testing.T) {
+	record := &imagestore.Record{
+		ID:               "img-1",
+		Prompt:           "a burning phone",
+		Model:            "imagen-4.0-generate-001",
+		ImageSize:        "2K",
+		PersonGeneration: image.PersonGenerationDontAllow,
+		SafetySetting:    image.SafetyBlockOnlyHigh,
+	}
+
+	gen, err := imageVariation(record, model.VaryImageInput{ImageID: "img-1"})
+	if err != nil {
+		t.Fatalf("imageVariation() error = %v", err)
+	}
+	if gen.model != record.Model || gen.imageSize != record.ImageSize ||
+		gen.personGeneration != record.PersonGeneration || gen.safetySetting != record.SafetySetting {
+		t.Errorf("imageVariation() = %+v, want the Imagen settings of %+v", gen, record)
+	}
+	if got := len(gen.options()); got != 6 {
+		t.Errorf("options() returned %d options, want sample count, seed and the four Imagen settings", got)
+	}
+}
+
+func TestImagenSettingValues(t *testing.T) {
+	safety := model.SafetySettingBlockOnlyHigh
+	if got := safetySettingValue(&safety); got != image.SafetyBlockOnlyHigh {
+		t.Errorf("safetySettingValue() = %q, want %q", got, image.SafetyBlockOnlyHigh)
+	}
+	person := model.PersonGenerationAllowAdult
+	if got := personGenerationValue(&person); got != image.PersonGenerationAllowAdult {
+		t.Errorf("personGenerationValue() = %q, want %q", got, image.PersonGenerationAllowAdult)
+	}
+	if safetySettingValue(nil) != "" || personGenerationValue(nil) != "" {
+		t.Error("unset settings should keep the deployment defaults")
+	}
+}
+
+func TestVaryImage_DisabledWithoutCatalog(t *testing.T) {
+	withImages, _ := newTestImageStoreOption(t)
+	r := NewResolver(nil, nil, nil, withImages)
+
+	_, err := (&mutationResolver{r}).VaryImage(context.Background(), model.VaryImageInput{ImageID: "img-1"})
+	if !errors.Is(err, errImageVariationsDisabled) {
+		t.Errorf("VaryImage() error = %v, want errImageVariationsDisabled", err)
+	}
+}
+
+func TestOverlayImage(t *testing.T) {
+	ctx := context.Background()
+	withImages, _ := newTestImageStoreOption(t)
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	src := testPNG(t)
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateContentFunc: func(_ context.Context, _ string) (string, error) {
+				return "a burning phone", nil
+			},
+		},
+		nil,
+		&MockImageClient{
+			GenerateImageFunc: func(_ context.Context, _ string) ([]byte, error) {
+				return src, nil
+			},
+		},
+		withImages,
+		WithImageCatalog(imagestore.NewCatalog(db)),
+	)
+	mutation := &mutationResolver{r}
+
+	generated, err := mutation.GenerateImage(ctx, model.GenerateImageInput{Text: "炎上投稿"})
+	if err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+
+	layout := model.OverlayLayoutCaptionBar
+	overlaid, err := mutation.OverlayImage(ctx, model.ImageOverlayInput{
+		ImageID:    generated.ImageURL,
+		Layout:     &layout,
+		Text:       stringPtr("会議が長すぎる"),
+		Engagement: &model.EngagementInput{Replies: 1, Reposts: 2, Likes: 3},
+	})
+	if err != nil {
+		t.Fatalf("OverlayImage() error = %v", err)
+	}
+	if overlaid.ImageID == nil || *overlaid.ImageID == *generated.ImageID {
+		t.Fatalf("OverlayImage() ImageID = %v, want a new image", overlaid.ImageID)
+	}
+	if got := overlaid.Variants[0].ParentImageID; got == nil || *got != *generated.ImageID {
+		t.Errorf("ParentImageID = %v, want %s", got, *generated.ImageID)
+	}
+	if overlaid.Prompt != generated.Prompt || !strings.HasPrefix(overlaid.AltText, "「会議が長すぎる」") {
+		t.Errorf("Prompt = %q, AltText = %q, want the source prompt and the caption", overlaid.Prompt, overlaid.AltText)
+	}
+
+	// The overlay inherits the source's prompt, so it can be varied as well
+	if _, err := mutation.VaryImage(ctx, model.VaryImageInput{ImageID: *overlaid.ImageID}); err != nil {
+		t.Errorf("VaryImage() of overlay error = %v", err)
+	}
+
+	// Without an image store data URLs are accepted and returned
+	inline, err := (&mutationResolver{NewResolver(nil, nil, nil)}).OverlayImage(ctx, model.ImageOverlayInput{
+		ImageID: createImageDataURL(src),
+	})
+	if err != nil {
+		t.Fatalf("OverlayImage() with data URL error = %v", err)
+	}
+	if !strings.HasPrefix(inline.ImageURL, "data:image/png;base64,") || inline.ImageID != nil {
+		t.Errorf("OverlayImage() = %+v, want an inline PNG", inline)
+	}
+
+	noWatermark := false
+	if _, err := mutation.OverlayImage(ctx, model.ImageOverlayInput{ImageID: *generated.ImageID, Watermark: &noWatermark}); err == nil {
+		t.Error("OverlayImage() with nothing to draw succeeded, want error")
+	}
+	if _, err := (&mutationResolver{&Resolver{}}).OverlayImage(ctx, model.ImageOverlayInput{ImageID: "img"}); !errors.Is(err, errOverlayDisabled) {
+		t.Errorf("OverlayImage() error = %v, want errOverlayDisabled", err)
+	}
+}
+
+func TestImageOwnership(t *testing.T) {
+	withImages, images := newTestImageStoreOption(t)
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	catalog := imagestore.NewCatalog(db)
+
+	src := testPNG(t)
+	twitterClient := &MockTwitterClient{
+		PostTweetWithImagesFunc: func(context.Context, string, []twitter.Image) (*twitter.TweetResult, error) {
+			return &twitter.TweetResult{ID: "1"}, nil
+		},
+	}
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateContentFunc: func(_ context.Context, _ string) (string, error) {
+				return "a burning phone", nil
+			},
+		},
+		twitterClient,
+		&MockImageClient{
+			GenerateImageFunc: func(_ context.Context, _ string) ([]byte, error) {
+				return src, nil
+			},
+		},
+		withImages,
+		WithImageCatalog(catalog),
+		withLenientPolicy(t),
+	)
+	mutation := &mutationResolver{r}
+
+	alice := auth.WithUser(context.Background(), &auth.User{ID: "alice", Method: auth.MethodAPIKey})
+	bob := auth.WithUser(context.Background(), &auth.User{ID: "bob", Method: auth.MethodJWT})
+
+	generated, err := mutation.GenerateImage(alice, model.GenerateImageInput{Text: "炎上投稿"})
+	if err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+	record, err := catalog.Get(alice, *generated.ImageID)
+	if err != nil || record.OwnerID != currentOwner(alice) {
+		t.Fatalf("catalog record = %+v, %v, want it owned by alice", record, err)
+	}
+	post := func(ctx context.Context) *model.TwitterPostResult {
+		t.Helper()
+		result, err := mutation.PostToTwitter(ctx, model.TwitterPostInput{
+			Text:   "画像付き投稿",
+			Level:  intPtr(1),
+			Images: []*model.PostImageInput{{ImageID: generated.ImageID}},
+		})
+		if err != nil {
+			t.Fatalf("PostToTwitter() error = %v", err)
+		}
+		return result
+	}
+
+	t.Run("owner can use the image", func(t *testing.T) {
+		if _, err := mutation.VaryImage(alice, model.VaryImageInput{ImageID: *generated.ImageID}); err != nil {
+			t.Errorf("VaryImage() error = %v", err)
+		}
+		if _, err := mutation.OverlayImage(alice, model.ImageOverlayInput{ImageID: generated.ImageURL}); err != nil {
+			t.Errorf("OverlayImage() error = %v", err)
+		}
+		if result := post(alice); !result.Success {
+			t.Errorf("PostToTwitter() = %+v, want success", result)
+		}
+	})
+
+	t.Run("other users cannot use the image", func(t *testing.T) {
+		if _, err := mutation.VaryImage(bob, model.VaryImageInput{ImageID: *generated.ImageID}); !errors.Is(err, imagestore.ErrRecordNotFound) {
+			t.Errorf("VaryImage() error = %v, want ErrRecordNotFound", err)
+		}
+		if _, err := mutation.OverlayImage(bob, model.ImageOverlayInput{ImageID: generated.ImageURL}); !errors.Is(err, imagestore.ErrNotFound) {
+			t.Errorf("OverlayImage() error = %v, want ErrNotFound", err)
+		}
+		if result := post(bob); result.Success {
+			t.Error("PostToTwitter() with another user's image succeeded, want failure")
+		}
+
+		handler := NewScheduledPostHandler(twitterClient, withImages, WithImageCatalog(catalog))
+		job := &queue.Job{OwnerID: "bob", Text: "予約投稿", Images: []queue.Image{{URL: *generated.ImageID}}}
+		if _, err := handler(context.Background(), job); !errors.Is(err, imagestore.ErrNotFound) {
+			t.Errorf("scheduled post handler error = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("images without a record belong to anonymous users", func(t *testing.T) {
+		id, err := images.Put(context.Background(), src)
+		if err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+		if _, err := r.loadImage(context.Background(), id); err != nil {
+			t.Errorf("loadImage() anonymous error = %v", err)
+		}
+		if _, err := r.loadImage(alice, id); !errors.Is(err, imagestore.ErrNotFound) {
+			t.Errorf("loadImage() as alice error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+// testPNG returns a small valid PNG
+func testPNG(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, goimage.NewRGBA(goimage.Rect(0, 0, 64, 48))); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+	return buf.Bytes()
+}
@@ -9,53 +9,324 @@ import (
 	"strconv"
 )
 
+type CacheReport struct {
+	Hits     int  `json:"hits"`
+	Misses   int  `json:"misses"`
+	Bypassed bool `json:"bypassed"`
+}
+
+type DependencyHealth struct {
+	Name          string       `json:"name"`
+	Configured    bool         `json:"configured"`
+	Critical      bool         `json:"critical"`
+	Status        HealthStatus `json:"status"`
+	LatencyMs     *int         `json:"latencyMs,omitempty"`
+	LastError     *string      `json:"lastError,omitempty"`
+	CheckedAt     *string      `json:"checkedAt,omitempty"`
+	LastSuccessAt *string      `json:"lastSuccessAt,omitempty"`
+}
+
+type EngagementInput struct {
+	Replies int `json:"replies"`
+	Reposts int `json:"reposts"`
+	Likes   int `json:"likes"`
+}
+
 type GenerateImageInput struct {
-	Text         string       `json:"text"`
-	OriginalText *string      `json:"originalText,omitempty"`
-	Style        *ImageStyle  `json:"style,omitempty"`
-	AspectRatio  *AspectRatio `json:"aspectRatio,omitempty"`
+	Text             string            `json:"text"`
+	OriginalText     *string           `json:"originalText,omitempty"`
+	Style            *ImageStyle       `json:"style,omitempty"`
+	AspectRatio      *AspectRatio      `json:"aspectRatio,omitempty"`
+	SampleCount      *int              `json:"sampleCount,omitempty"`
+	NegativePrompt   *string           `json:"negativePrompt,omitempty"`
+	Seed             *int              `json:"seed,omitempty"`
+	Model            *string           `json:"model,omitempty"`
+	ImageSize        *string           `json:"imageSize,omitempty"`
+	PersonGeneration *PersonGeneration `json:"personGeneration,omitempty"`
+	SafetySetting    *SafetySetting    `json:"safetySetting,omitempty"`
+	SanitizePrompt   *bool             `json:"sanitizePrompt,omitempty"`
+	BypassCache      *bool             `json:"bypassCache,omitempty"`
 }
 
 type GenerateImageResult struct {
-	ImageURL    string `json:"imageUrl"`
-	Prompt      string `json:"prompt"`
-	GeneratedAt string `json:"generatedAt"`
+	ImageID         *string            `json:"imageId,omitempty"`
+	ImageURL        string             `json:"imageUrl"`
+	Prompt          string             `json:"prompt"`
+	PromptReasoning *string            `json:"promptReasoning,omitempty"`
+	AltText         string             `json:"altText"`
+	GeneratedAt     string             `json:"generatedAt"`
+	Variants        []*GeneratedImage  `json:"variants"`
+	Moderation      *ModerationVerdict `json:"moderation,omitempty"`
+	Cache           *CacheReport       `json:"cache,omitempty"`
 }
 
 type GenerateInput struct {
-	OriginalText string `json:"originalText"`
-	Level        int    `json:"level"`
+	OriginalText string  `json:"originalText"`
+	Level        int     `json:"level"`
+	ImageID      *string `json:"imageId,omitempty"`
+	BypassCache  *bool   `json:"bypassCache,omitempty"`
 }
 
 type GenerateResult struct {
-	InflammatoryText string  `json:"inflammatoryText"`
-	Explanation      *string `json:"explanation,omitempty"`
+	InflammatoryText string       `json:"inflammatoryText"`
+	Explanation      *string      `json:"explanation,omitempty"`
+	SimulationID     *string      `json:"simulationId,omitempty"`
+	Cache            *CacheReport `json:"cache,omitempty"`
+}
+
+type GeneratedImage struct {
+	ImageID        *string            `json:"imageId,omitempty"`
+	ImageURL       string             `json:"imageUrl"`
+	Seed           *int               `json:"seed,omitempty"`
+	NegativePrompt *string            `json:"negativePrompt,omitempty"`
+	ParentImageID  *string            `json:"parentImageId,omitempty"`
+	Moderation     *ModerationVerdict `json:"moderation,omitempty"`
+}
+
+type HealthReport struct {
+	Status       HealthStatus        `json:"status"`
+	CheckedAt    string              `json:"checkedAt"`
+	Dependencies []*DependencyHealth `json:"dependencies"`
+}
+
+type ImageOverlayInput struct {
+	ImageID    string           `json:"imageId"`
+	Layout     *OverlayLayout   `json:"layout,omitempty"`
+	Text       *string          `json:"text,omitempty"`
+	Engagement *EngagementInput `json:"engagement,omitempty"`
+	Watermark  *bool            `json:"watermark,omitempty"`
+}
+
+type ModerationVerdict struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories"`
+	Reason     *string  `json:"reason,omitempty"`
 }
 
 type Mutation struct {
 }
 
+type ObservedReply struct {
+	ID                    string     `json:"id"`
+	Text                  string     `json:"text"`
+	AuthorID              *string    `json:"authorId,omitempty"`
+	PostedAt              string     `json:"postedAt"`
+	Persona               *ReplyType `json:"persona,omitempty"`
+	ClosestSimulatedReply *Reply     `json:"closestSimulatedReply,omitempty"`
+	Similarity            float64    `json:"similarity"`
+}
+
+type PersonaDistribution struct {
+	Type           ReplyType `json:"type"`
+	Predicted      int       `json:"predicted"`
+	Observed       int       `json:"observed"`
+	PredictedShare float64   `json:"predictedShare"`
+	ObservedShare  float64   `json:"observedShare"`
+}
+
+type PolicyDecision struct {
+	Action           PolicyAction         `json:"action"`
+	Level            int                  `json:"level"`
+	Reasons          []string             `json:"reasons"`
+	ForcedHashtag    bool                 `json:"forcedHashtag"`
+	ForcedDisclaimer bool                 `json:"forcedDisclaimer"`
+	Moderation       *ModerationVerdict   `json:"moderation,omitempty"`
+	ImageModeration  []*ModerationVerdict `json:"imageModeration,omitempty"`
+}
+
+type PostImageInput struct {
+	URL     *string `json:"url,omitempty"`
+	ImageID *string `json:"imageId,omitempty"`
+	AltText *string `json:"altText,omitempty"`
+}
+
+type PostedTweet struct {
+	TweetID         string        `json:"tweetId"`
+	TweetURL        string        `json:"tweetUrl"`
+	SimulationID    *string       `json:"simulationId,omitempty"`
+	ScheduledPostID *string       `json:"scheduledPostId,omitempty"`
+	Text            string        `json:"text"`
+	Level           int           `json:"level"`
+	Status          TweetStatus   `json:"status"`
+	Metrics         *TweetMetrics `json:"metrics,omitempty"`
+	PostedAt        string        `json:"postedAt"`
+	DeletedAt       *string       `json:"deletedAt,omitempty"`
+}
+
 type Query struct {
 }
 
+type Quota struct {
+	DailyBudget *float64     `json:"dailyBudget,omitempty"`
+	Spent       float64      `json:"spent"`
+	Remaining   *float64     `json:"remaining,omitempty"`
+	ResetsAt    string       `json:"resetsAt"`
+	Limits      []*RateLimit `json:"limits"`
+}
+
+type RateLimit struct {
+	Operation     RateLimitedOperation `json:"operation"`
+	Limit         *int                 `json:"limit,omitempty"`
+	Remaining     *int                 `json:"remaining,omitempty"`
+	WindowSeconds *int                 `json:"windowSeconds,omitempty"`
+	ResetSeconds  int                  `json:"resetSeconds"`
+}
+
 type Reply struct {
 	ID      string    `json:"id"`
 	Type    ReplyType `json:"type"`
 	Content string    `json:"content"`
+	Cached  *bool     `json:"cached,omitempty"`
+}
+
+type ReplyComparison struct {
+	TweetID           string                 `json:"tweetId"`
+	SimulationID      string                 `json:"simulationId"`
+	RealReplyCount    int                    `json:"realReplyCount"`
+	UnclassifiedCount int                    `json:"unclassifiedCount"`
+	Distribution      []*PersonaDistribution `json:"distribution"`
+	Replies           []*ObservedReply       `json:"replies"`
+	GeneratedAt       string                 `json:"generatedAt"`
+}
+
+type ReplyComparisonJob struct {
+	ID          string                `json:"id"`
+	TweetID     string                `json:"tweetId"`
+	Status      ReplyComparisonStatus `json:"status"`
+	Attempts    int                   `json:"attempts"`
+	MaxAttempts int                   `json:"maxAttempts"`
+	LastError   *string               `json:"lastError,omitempty"`
+	Report      *ReplyComparison      `json:"report,omitempty"`
+	CreatedAt   string                `json:"createdAt"`
+	UpdatedAt   string                `json:"updatedAt"`
+}
+
+type SchedulePostInput struct {
+	Text              string            `json:"text"`
+	ImageURL          *string           `json:"imageUrl,omitempty"`
+	Images            []*PostImageInput `json:"images,omitempty"`
+	AddHashtag        *bool             `json:"addHashtag,omitempty"`
+	AddDisclaimer     *bool             `json:"addDisclaimer,omitempty"`
+	Level             *int              `json:"level,omitempty"`
+	ConfirmationToken *string           `json:"confirmationToken,omitempty"`
+	SimulationID      *string           `json:"simulationId,omitempty"`
+	ScheduledAt       string            `json:"scheduledAt"`
+}
+
+type ScheduledPost struct {
+	ID            string                     `json:"id"`
+	Text          string                     `json:"text"`
+	HasImage      bool                       `json:"hasImage"`
+	ImageCount    int                        `json:"imageCount"`
+	Level         int                        `json:"level"`
+	SimulationID  *string                    `json:"simulationId,omitempty"`
+	AddHashtag    bool                       `json:"addHashtag"`
+	AddDisclaimer bool                       `json:"addDisclaimer"`
+	ScheduledAt   string                     `json:"scheduledAt"`
+	Status        ScheduledPostStatus        `json:"status"`
+	Attempts      int                        `json:"attempts"`
+	MaxAttempts   int                        `json:"maxAttempts"`
+	LastError     *string                    `json:"lastError,omitempty"`
+	TweetID       *string                    `json:"tweetId,omitempty"`
+	TweetURL      *string                    `json:"tweetUrl,omitempty"`
+	CreatedAt     string                     `json:"createdAt"`
+	UpdatedAt     string                     `json:"updatedAt"`
+	History       []*ScheduledPostTransition `json:"history"`
+}
+
+type ScheduledPostTransition struct {
+	Status  ScheduledPostStatus `json:"status"`
+	At      string              `json:"at"`
+	Message *string             `json:"message,omitempty"`
+}
+
+type Screenshot struct {
+	SimulationID string          `json:"simulationId"`
+	ImageID      *string         `json:"imageId,omitempty"`
+	ImageURL     string          `json:"imageUrl"`
+	Theme        ScreenshotTheme `json:"theme"`
+	GeneratedAt  string          `json:"generatedAt"`
+}
+
+type Simulation struct {
+	ID               string         `json:"id"`
+	OriginalText     string         `json:"originalText"`
+	InflammatoryText string         `json:"inflammatoryText"`
+	Explanation      *string        `json:"explanation,omitempty"`
+	Level            int            `json:"level"`
+	Replies          []*Reply       `json:"replies"`
+	Tweets           []*PostedTweet `json:"tweets"`
+	ImageID          *string        `json:"imageId,omitempty"`
+	ImageDescription *string        `json:"imageDescription,omitempty"`
+	CreatedAt        string         `json:"createdAt"`
+}
+
+type TweetMetrics struct {
+	LikeCount       int    `json:"likeCount"`
+	RepostCount     int    `json:"repostCount"`
+	ReplyCount      int    `json:"replyCount"`
+	QuoteCount      int    `json:"quoteCount"`
+	ImpressionCount int    `json:"impressionCount"`
+	FetchedAt       string `json:"fetchedAt"`
+}
+
+type TwitterAccount struct {
+	UserID      string `json:"userId"`
+	ScreenName  string `json:"screenName"`
+	ConnectedAt string `json:"connectedAt"`
+}
+
+type TwitterAuthorization struct {
+	AuthorizeURL string `json:"authorizeUrl"`
+	ExpiresAt    string `json:"expiresAt"`
 }
 
 type TwitterPostInput struct {
-	Text          string  `json:"text"`
-	ImageURL      *string `json:"imageUrl,omitempty"`
-	AddHashtag    *bool   `json:"addHashtag,omitempty"`
-	AddDisclaimer *bool   `json:"addDisclaimer,omitempty"`
+	Text              string            `json:"text"`
+	ImageURL          *string           `json:"imageUrl,omitempty"`
+	Images            []*PostImageInput `json:"images,omitempty"`
+	AddHashtag        *bool             `json:"addHashtag,omitempty"`
+	AddDisclaimer     *bool             `json:"addDisclaimer,omitempty"`
+	Level             *int              `json:"level,omitempty"`
+	ConfirmationToken *string           `json:"confirmationToken,omitempty"`
+	SimulationID      *string           `json:"simulationId,omitempty"`
 }
 
 type TwitterPostResult struct {
-	Success      bool    `json:"success"`
-	TweetID      *string `json:"tweetId,omitempty"`
-	TweetURL     *string `json:"tweetUrl,omitempty"`
-	ErrorMessage *string `json:"errorMessage,omitempty"`
+	Success              bool            `json:"success"`
+	TweetID              *string         `json:"tweetId,omitempty"`
+	TweetURL             *string         `json:"tweetUrl,omitempty"`
+	ErrorMessage         *string         `json:"errorMessage,omitempty"`
+	ConfirmationRequired bool            `json:"confirmationRequired"`
+	ConfirmationToken    *string         `json:"confirmationToken,omitempty"`
+	Policy               *PolicyDecision `json:"policy,omitempty"`
+}
+
+type UploadedImage struct {
+	ImageID      *string            `json:"imageId,omitempty"`
+	ImageURL     string             `json:"imageUrl"`
+	ContentType  string             `json:"contentType"`
+	Width        int                `json:"width"`
+	Height       int                `json:"height"`
+	Size         int                `json:"size"`
+	Description  *string            `json:"description,omitempty"`
+	SimulationID *string            `json:"simulationId,omitempty"`
+	Moderation   *ModerationVerdict `json:"moderation,omitempty"`
+}
+
+type User struct {
+	ID         string     `json:"id"`
+	Name       *string    `json:"name,omitempty"`
+	Email      *string    `json:"email,omitempty"`
+	AuthMethod AuthMethod `json:"authMethod"`
+}
+
+type VaryImageInput struct {
+	ImageID        string  `json:"imageId"`
+	Seed           *int    `json:"seed,omitempty"`
+	NegativePrompt *string `json:"negativePrompt,omitempty"`
+	SampleCount    *int    `json:"sampleCount,omitempty"`
+	BypassCache    *bool   `json:"bypassCache,omitempty"`
 }
 
 type AspectRatio string
@@ -115,6 +386,120 @@ func (e AspectRatio) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+type AuthMethod string
+
+const (
+	AuthMethodAPIKey AuthMethod = "API_KEY"
+	AuthMethodJwt    AuthMethod = "JWT"
+)
+
+var AllAuthMethod = []AuthMethod{
+	AuthMethodAPIKey,
+	AuthMethodJwt,
+}
+
+func (e AuthMethod) IsValid() bool {
+	switch e {
+	case AuthMethodAPIKey, AuthMethodJwt:
+		return true
+	}
+	return false
+}
+
+func (e AuthMethod) String() string {
+	return string(e)
+}
+
+func (e *AuthMethod) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AuthMethod(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AuthMethod", str)
+	}
+	return nil
+}
+
+func (e AuthMethod) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *AuthMethod) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e AuthMethod) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type HealthStatus string
+
+const (
+	HealthStatusHealthy      HealthStatus = "HEALTHY"
+	HealthStatusDegraded     HealthStatus = "DEGRADED"
+	HealthStatusDown         HealthStatus = "DOWN"
+	HealthStatusUnconfigured HealthStatus = "UNCONFIGURED"
+)
+
+var AllHealthStatus = []HealthStatus{
+	HealthStatusHealthy,
+	HealthStatusDegraded,
+	HealthStatusDown,
+	HealthStatusUnconfigured,
+}
+
+func (e HealthStatus) IsValid() bool {
+	switch e {
+	case HealthStatusHealthy, HealthStatusDegraded, HealthStatusDown, HealthStatusUnconfigured:
+		return true
+	}
+	return false
+}
+
+func (e HealthStatus) String() string {
+	return string(e)
+}
+
+func (e *HealthStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = HealthStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid HealthStatus", str)
+	}
+	return nil
+}
+
+func (e HealthStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *HealthStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e HealthStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
 type ImageStyle string
 
 const (
@@ -174,6 +559,295 @@ func (e ImageStyle) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+type OverlayLayout string
+
+const (
+	OverlayLayoutTop        OverlayLayout = "TOP"
+	OverlayLayoutBottom     OverlayLayout = "BOTTOM"
+	OverlayLayoutTopBottom  OverlayLayout = "TOP_BOTTOM"
+	OverlayLayoutCaptionBar OverlayLayout = "CAPTION_BAR"
+)
+
+var AllOverlayLayout = []OverlayLayout{
+	OverlayLayoutTop,
+	OverlayLayoutBottom,
+	OverlayLayoutTopBottom,
+	OverlayLayoutCaptionBar,
+}
+
+func (e OverlayLayout) IsValid() bool {
+	switch e {
+	case OverlayLayoutTop, OverlayLayoutBottom, OverlayLayoutTopBottom, OverlayLayoutCaptionBar:
+		return true
+	}
+	return false
+}
+
+func (e OverlayLayout) String() string {
+	return string(e)
+}
+
+func (e *OverlayLayout) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OverlayLayout(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid OverlayLayout", str)
+	}
+	return nil
+}
+
+func (e OverlayLayout) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *OverlayLayout) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e OverlayLayout) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PersonGeneration string
+
+const (
+	PersonGenerationDontAllow  PersonGeneration = "DONT_ALLOW"
+	PersonGenerationAllowAdult PersonGeneration = "ALLOW_ADULT"
+	PersonGenerationAllowAll   PersonGeneration = "ALLOW_ALL"
+)
+
+var AllPersonGeneration = []PersonGeneration{
+	PersonGenerationDontAllow,
+	PersonGenerationAllowAdult,
+	PersonGenerationAllowAll,
+}
+
+func (e PersonGeneration) IsValid() bool {
+	switch e {
+	case PersonGenerationDontAllow, PersonGenerationAllowAdult, PersonGenerationAllowAll:
+		return true
+	}
+	return false
+}
+
+func (e PersonGeneration) String() string {
+	return string(e)
+}
+
+func (e *PersonGeneration) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PersonGeneration(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PersonGeneration", str)
+	}
+	return nil
+}
+
+func (e PersonGeneration) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PersonGeneration) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PersonGeneration) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type PolicyAction string
+
+const (
+	PolicyActionAllow                PolicyAction = "ALLOW"
+	PolicyActionBlock                PolicyAction = "BLOCK"
+	PolicyActionConfirmationRequired PolicyAction = "CONFIRMATION_REQUIRED"
+)
+
+var AllPolicyAction = []PolicyAction{
+	PolicyActionAllow,
+	PolicyActionBlock,
+	PolicyActionConfirmationRequired,
+}
+
+func (e PolicyAction) IsValid() bool {
+	switch e {
+	case PolicyActionAllow, PolicyActionBlock, PolicyActionConfirmationRequired:
+		return true
+	}
+	return false
+}
+
+func (e PolicyAction) String() string {
+	return string(e)
+}
+
+func (e *PolicyAction) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = PolicyAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PolicyAction", str)
+	}
+	return nil
+}
+
+func (e PolicyAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *PolicyAction) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e PolicyAction) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type RateLimitedOperation string
+
+const (
+	RateLimitedOperationText  RateLimitedOperation = "TEXT"
+	RateLimitedOperationImage RateLimitedOperation = "IMAGE"
+	RateLimitedOperationPost  RateLimitedOperation = "POST"
+)
+
+var AllRateLimitedOperation = []RateLimitedOperation{
+	RateLimitedOperationText,
+	RateLimitedOperationImage,
+	RateLimitedOperationPost,
+}
+
+func (e RateLimitedOperation) IsValid() bool {
+	switch e {
+	case RateLimitedOperationText, RateLimitedOperationImage, RateLimitedOperationPost:
+		return true
+	}
+	return false
+}
+
+func (e RateLimitedOperation) String() string {
+	return string(e)
+}
+
+func (e *RateLimitedOperation) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RateLimitedOperation(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid RateLimitedOperation", str)
+	}
+	return nil
+}
+
+func (e RateLimitedOperation) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *RateLimitedOperation) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e RateLimitedOperation) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ReplyComparisonStatus string
+
+const (
+	ReplyComparisonStatusPending   ReplyComparisonStatus = "PENDING"
+	ReplyComparisonStatusRunning   ReplyComparisonStatus = "RUNNING"
+	ReplyComparisonStatusSucceeded ReplyComparisonStatus = "SUCCEEDED"
+	ReplyComparisonStatusFailed    ReplyComparisonStatus = "FAILED"
+)
+
+var AllReplyComparisonStatus = []ReplyComparisonStatus{
+	ReplyComparisonStatusPending,
+	ReplyComparisonStatusRunning,
+	ReplyComparisonStatusSucceeded,
+	ReplyComparisonStatusFailed,
+}
+
+func (e ReplyComparisonStatus) IsValid() bool {
+	switch e {
+	case ReplyComparisonStatusPending, ReplyComparisonStatusRunning, ReplyComparisonStatusSucceeded, ReplyComparisonStatusFailed:
+		return true
+	}
+	return false
+}
+
+func (e ReplyComparisonStatus) String() string {
+	return string(e)
+}
+
+func (e *ReplyComparisonStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ReplyComparisonStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ReplyComparisonStatus", str)
+	}
+	return nil
+}
+
+func (e ReplyComparisonStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ReplyComparisonStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ReplyComparisonStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
 type ReplyType string
 
 const (
@@ -232,3 +906,235 @@ func (e ReplyType) MarshalJSON() ([]byte, error) {
 	e.MarshalGQL(&buf)
 	return buf.Bytes(), nil
 }
+
+type SafetySetting string
+
+const (
+	SafetySettingBlockLowAndAbove    SafetySetting = "BLOCK_LOW_AND_ABOVE"
+	SafetySettingBlockMediumAndAbove SafetySetting = "BLOCK_MEDIUM_AND_ABOVE"
+	SafetySettingBlockOnlyHigh       SafetySetting = "BLOCK_ONLY_HIGH"
+	SafetySettingBlockNone           SafetySetting = "BLOCK_NONE"
+)
+
+var AllSafetySetting = []SafetySetting{
+	SafetySettingBlockLowAndAbove,
+	SafetySettingBlockMediumAndAbove,
+	SafetySettingBlockOnlyHigh,
+	SafetySettingBlockNone,
+}
+
+func (e SafetySetting) IsValid() bool {
+	switch e {
+	case SafetySettingBlockLowAndAbove, SafetySettingBlockMediumAndAbove, SafetySettingBlockOnlyHigh, SafetySettingBlockNone:
+		return true
+	}
+	return false
+}
+
+func (e SafetySetting) String() string {
+	return string(e)
+}
+
+func (e *SafetySetting) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SafetySetting(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SafetySetting", str)
+	}
+	return nil
+}
+
+func (e SafetySetting) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *SafetySetting) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e SafetySetting) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ScheduledPostStatus string
+
+const (
+	ScheduledPostStatusPending   ScheduledPostStatus = "PENDING"
+	ScheduledPostStatusRunning   ScheduledPostStatus = "RUNNING"
+	ScheduledPostStatusSucceeded ScheduledPostStatus = "SUCCEEDED"
+	ScheduledPostStatusFailed    ScheduledPostStatus = "FAILED"
+	ScheduledPostStatusCancelled ScheduledPostStatus = "CANCELLED"
+)
+
+var AllScheduledPostStatus = []ScheduledPostStatus{
+	ScheduledPostStatusPending,
+	ScheduledPostStatusRunning,
+	ScheduledPostStatusSucceeded,
+	ScheduledPostStatusFailed,
+	ScheduledPostStatusCancelled,
+}
+
+func (e ScheduledPostStatus) IsValid() bool {
+	switch e {
+	case ScheduledPostStatusPending, ScheduledPostStatusRunning, ScheduledPostStatusSucceeded, ScheduledPostStatusFailed, ScheduledPostStatusCancelled:
+		return true
+	}
+	return false
+}
+
+func (e ScheduledPostStatus) String() string {
+	return string(e)
+}
+
+func (e *ScheduledPostStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ScheduledPostStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ScheduledPostStatus", str)
+	}
+	return nil
+}
+
+func (e ScheduledPostStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ScheduledPostStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ScheduledPostStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type ScreenshotTheme string
+
+const (
+	ScreenshotThemeLight ScreenshotTheme = "LIGHT"
+	ScreenshotThemeDark  ScreenshotTheme = "DARK"
+)
+
+var AllScreenshotTheme = []ScreenshotTheme{
+	ScreenshotThemeLight,
+	ScreenshotThemeDark,
+}
+
+func (e ScreenshotTheme) IsValid() bool {
+	switch e {
+	case ScreenshotThemeLight, ScreenshotThemeDark:
+		return true
+	}
+	return false
+}
+
+func (e ScreenshotTheme) String() string {
+	return string(e)
+}
+
+func (e *ScreenshotTheme) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ScreenshotTheme(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ScreenshotTheme", str)
+	}
+	return nil
+}
+
+func (e ScreenshotTheme) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *ScreenshotTheme) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e ScreenshotTheme) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
+
+type TweetStatus string
+
+const (
+	TweetStatusLive        TweetStatus = "LIVE"
+	TweetStatusDeleted     TweetStatus = "DELETED"
+	TweetStatusUnavailable TweetStatus = "UNAVAILABLE"
+)
+
+var AllTweetStatus = []TweetStatus{
+	TweetStatusLive,
+	TweetStatusDeleted,
+	TweetStatusUnavailable,
+}
+
+func (e TweetStatus) IsValid() bool {
+	switch e {
+	case TweetStatusLive, TweetStatusDeleted, TweetStatusUnavailable:
+		return true
+	}
+	return false
+}
+
+func (e TweetStatus) String() string {
+	return string(e)
+}
+
+func (e *TweetStatus) UnmarshalGQL(v any) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = TweetStatus(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid TweetStatus", str)
+	}
+	return nil
+}
+
+func (e TweetStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+func (e *TweetStatus) UnmarshalJSON(b []byte) error {
+	s, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+	return e.UnmarshalGQL(s)
+}
+
+func (e TweetStatus) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	e.MarshalGQL(&buf)
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// GeminiModerator is a Moderator that asks Gemini to classify text before it is posted
+type GeminiModerator struct {
+	client GeminiClient
+}
+
+// NewGeminiModerator creates a moderator backed by the Gemini client
+func NewGeminiModerator(client GeminiClient) *GeminiModerator {
+	return &GeminiModerator{client: client}
+}
+
+// moderationResponse is the JSON document Gemini is asked to return
+type moderationResponse struct {
+	Flagged    bool     `json:"flagged"`
+	Categories []string `json:"categories"`
+	Reason     string   `json:"reason"`
+}
+
+// Moderate classifies text and reports whether it must not be published
+func (m *GeminiModerator) Moderate(ctx context.Context, text string) (*ModerationResult, error) {
+	if text == "" {
+		return nil, errors.New("text is required")
+	}
+
+	raw, err := m.client.GenerateContent(ctx, buildModerationPrompt(text))
+	if err != nil {
+		return nil, fmt.Errorf("failed to run moderation check: %w", err)
+	}
+
+	var resp moderationResponse
+	if err := json.Unmarshal([]byte(extractJSONObject(raw)), &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse moderation result: %w", err)
+	}
+
+	return &ModerationResult{
+		Flagged:    resp.Flagged,
+		Categories: resp.Categories,
+		Reason:     resp.Reason,
+	}, nil
+}
+
+// buildModerationPrompt builds the prompt for classifying a post before publication
+func buildModerationPrompt(text string) string {
+	return fmt.Sprintf(`あなたはSNS投稿のモデレーターです。以下の投稿を実際のSNSに公開してよいか判定してください。
+
+【投稿】
+%s
+
+【公開してはいけない内容】
+- 実在の個人・団体への攻撃、誹謗中傷、嫌がらせ (harassment)
+- 人種・性別・宗教・障害などに基づく差別やヘイト (hate)
+- 暴力の扇動や脅迫 (violence)
+- 個人情報の暴露 (privacy)
+- 性的な内容 (sexual)
+- 自傷行為の助長 (self_harm)
+
+単に皮肉っぽい・配慮に欠けるだけの表現は公開可とします。
+
+次のJSONのみを出力してください。説明は不要です。
+{"flagged": true または false, "categories": ["該当カテゴリ"], "reason": "理由（日本語で1文）"}`, text)
+}
+
+// extractJSONObject returns the outermost JSON object in s, tolerating markdown code fences around it
+func extractJSONObject(s string) string {
+	start := strings.Index(s, "{")
+	end := strings.LastIndex(s, "}")
+	if start < 0 || end < start {
+		return s
+	}
+	return s[start : end+1]
+}
@@ -0,0 +1,62 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/overlay"
+)
+
+// errOverlayDisabled is returned by overlayImage when no overlay renderer is configured
+var errOverlayDisabled = errors.New("image overlays are not configured")
+
+// toOverlaySpec converts the GraphQL overlay input; the watermark is on unless disabled
+func toOverlaySpec(input model.ImageOverlayInput) overlay.Spec {
+	spec := overlay.Spec{
+		Layout:    overlay.LayoutTopBottom,
+		Text:      stringValue(input.Text),
+		Watermark: input.Watermark == nil || *input.Watermark,
+	}
+	if input.Layout != nil {
+		spec.Layout = overlay.Layout(*input.Layout)
+	}
+	if e := input.Engagement; e != nil {
+		spec.Engagement = &overlay.Engagement{Replies: e.Replies, Reposts: e.Reposts, Likes: e.Likes}
+	}
+	return spec
+}
+
+// overlaySource loads the image to draw on and, when it is a stored image, its generation record
+func (r *Resolver) overlaySource(ctx context.Context, ref string) ([]byte, *imagestore.Record, error) {
+	data, err := r.loadImage(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parent := &imagestore.Record{}
+	if r.imageStore != nil && !strings.HasPrefix(ref, "data:") {
+		parent.ID = imageIDFromReference(ref)
+		if r.imageCatalog != nil {
+			if record, err := r.imageCatalog.Get(ctx, parent.ID); err == nil {
+				parent = record
+			}
+		}
+	}
+	return data, parent, nil
+}
+
+// overlayAltText describes an overlaid image: the caption followed by the source image's alt text
+func overlayAltText(spec overlay.Spec, prompt string) string {
+	parts := []string{}
+	if text := strings.TrimSpace(spec.Text); text != "" {
+		parts = append(parts, fmt.Sprintf("「%s」", text))
+	}
+	if prompt != "" {
+		parts = append(parts, prompt)
+	}
+	return altTextFromPrompt(strings.Join(parts, " "))
+}
@@ -0,0 +1,140 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/simulation"
+)
+
+// currentOwner returns the owner ID of the user making the request, or "" for anonymous requests.
+// Records are owned by the user that created them; with authentication disabled every
+// record is created and read with the empty owner, so nothing changes.
+func currentOwner(ctx context.Context) string {
+	if user, ok := auth.UserFrom(ctx); ok {
+		return user.OwnerID()
+	}
+	return ""
+}
+
+// getSimulation returns a simulation owned by the current user.
+// Simulations of other users are reported as not found so that their IDs are not revealed.
+func (r *Resolver) getSimulation(ctx context.Context, id string) (*simulation.Simulation, error) {
+	sim, err := r.simulations.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sim.OwnerID != currentOwner(ctx) {
+		return nil, simulation.ErrNotFound
+	}
+	return sim, nil
+}
+
+// getPostedTweet returns a posted tweet owned by the current user
+func (r *Resolver) getPostedTweet(ctx context.Context, tweetID string) (*simulation.Tweet, error) {
+	tweet, err := r.simulations.GetTweet(ctx, tweetID)
+	if err != nil {
+		return nil, err
+	}
+	if tweet.OwnerID != currentOwner(ctx) {
+		return nil, simulation.ErrTweetNotFound
+	}
+	return tweet, nil
+}
+
+// listPostedTweets returns the current user's posted tweets, optionally for one simulation
+func (r *Resolver) listPostedTweets(ctx context.Context, simulationID string) ([]*simulation.Tweet, error) {
+	tweets, err := r.simulations.ListTweets(ctx, simulationID)
+	if err != nil {
+		return nil, err
+	}
+	owner := currentOwner(ctx)
+	owned := tweets[:0]
+	for _, tweet := range tweets {
+		if tweet.OwnerID == owner {
+			owned = append(owned, tweet)
+		}
+	}
+	return owned, nil
+}
+
+// getScheduledJob returns a scheduled post owned by the current user. Other kinds of jobs are not
+// scheduled posts and are reported as not found.
+func (r *Resolver) getScheduledJob(ctx context.Context, id string) (*queue.Job, error) {
+	job, err := r.postQueue.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job.Kind != queue.KindPost || job.OwnerID != currentOwner(ctx) {
+		return nil, queue.ErrNotFound
+	}
+	return job, nil
+}
+
+// listScheduledJobs returns the current user's scheduled posts, optionally filtered by status
+func (r *Resolver) listScheduledJobs(ctx context.Context, status *queue.Status) ([]*queue.Job, error) {
+	jobs, err := r.postQueue.List(ctx, queue.KindPost, status)
+	if err != nil {
+		return nil, err
+	}
+	owner := currentOwner(ctx)
+	owned := jobs[:0]
+	for _, job := range jobs {
+		if job.OwnerID == owner {
+			owned = append(owned, job)
+		}
+	}
+	return owned, nil
+}
+
+// getImageRecord returns the catalog record of a stored image owned by the current user
+func (r *Resolver) getImageRecord(ctx context.Context, id string) (*imagestore.Record, error) {
+	record, err := r.imageCatalog.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if record.OwnerID != currentOwner(ctx) {
+		return nil, imagestore.ErrRecordNotFound
+	}
+	return record, nil
+}
+
+// checkImageOwner returns imagestore.ErrNotFound unless the stored image id belongs to ownerID.
+// Images without a record were stored before records had owners and belong to anonymous users.
+// Ownership is not tracked without an image catalog.
+func (r *Resolver) checkImageOwner(ctx context.Context, ownerID, id string) error {
+	if r.imageCatalog == nil {
+		return nil
+	}
+	owner := ""
+	record, err := r.imageCatalog.Get(ctx, id)
+	switch {
+	case err == nil:
+		owner = record.OwnerID
+	case !errors.Is(err, imagestore.ErrRecordNotFound):
+		return fmt.Errorf("failed to load image record: %w", err)
+	}
+	if owner != ownerID {
+		return imagestore.ErrNotFound
+	}
+	return nil
+}
+
+// currentUser returns the GraphQL representation of the user making the request, or nil when anonymous
+func currentUser(ctx context.Context) *model.User {
+	user, ok := auth.UserFrom(ctx)
+	if !ok {
+		return nil
+	}
+	return &model.User{
+		ID:         user.ID,
+		Name:       optionalString(user.Name),
+		Email:      optionalString(user.Email),
+		AuthMethod: model.AuthMethod(user.Method),
+	}
+}
@@ -0,0 +1,125 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/simulation"
+)
+
+func TestOwnership(t *testing.T) {
+	simulations := newTestSimulationStore(t)
+	r := newSchedulingResolver(t)
+	WithSimulationStore(simulations)(r)
+	r.geminiClient = &MockGeminiClient{
+		GenerateInflammatoryTextFunc: func(context.Context, string, int) (string, error) { return "炎上文章", nil },
+		GenerateExplanationFunc:      func(context.Context, string, string) (string, error) { return "解説", nil },
+	}
+	mutation, query := &mutationResolver{r}, &queryResolver{r}
+
+	alice := auth.WithUser(context.Background(), &auth.User{ID: "alice", Method: auth.MethodAPIKey})
+	bob := auth.WithUser(context.Background(), &auth.User{ID: "bob", Method: auth.MethodJWT})
+
+	generated, err := mutation.GenerateInflammatoryText(alice, model.GenerateInput{OriginalText: "元の文章", Level: 2})
+	if err != nil {
+		t.Fatalf("GenerateInflammatoryText() error = %v", err)
+	}
+	scheduled, err := mutation.SchedulePost(alice, model.SchedulePostInput{
+		Text:        "予約投稿",
+		Level:       intPtr(1),
+		ScheduledAt: time.Now().Add(time.Hour).Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("SchedulePost() error = %v", err)
+	}
+	if _, err := simulations.RecordTweet(alice, simulation.Tweet{
+		TweetID: "tweet-1", OwnerID: currentOwner(alice), SimulationID: *generated.SimulationID, Text: "炎上文章",
+	}); err != nil {
+		t.Fatalf("RecordTweet() error = %v", err)
+	}
+
+	t.Run("owner sees their records", func(t *testing.T) {
+		sim, err := query.Simulation(alice, *generated.SimulationID)
+		if err != nil || sim == nil || len(sim.Tweets) != 1 {
+			t.Fatalf("Simulation() = %+v, %v, want the simulation with its tweet", sim, err)
+		}
+		if posts, _ := query.ScheduledPosts(alice, nil); len(posts) != 1 {
+			t.Errorf("ScheduledPosts() returned %d posts, want 1", len(posts))
+		}
+		if tweet, _ := query.PostedTweet(alice, "tweet-1"); tweet == nil {
+			t.Error("PostedTweet() = nil, want the tweet")
+		}
+	})
+
+	t.Run("other users see nothing", func(t *testing.T) {
+		if sim, err := query.Simulation(bob, *generated.SimulationID); sim != nil || err != nil {
+			t.Errorf("Simulation() = %+v, %v, want nil", sim, err)
+		}
+		if posts, _ := query.ScheduledPosts(bob, nil); len(posts) != 0 {
+			t.Errorf("ScheduledPosts() returned %d posts, want 0", len(posts))
+		}
+		if post, err := query.ScheduledPost(bob, scheduled.ID); post != nil || err != nil {
+			t.Errorf("ScheduledPost() = %+v, %v, want nil", post, err)
+		}
+		if tweets, _ := query.PostedTweets(bob, nil); len(tweets) != 0 {
+			t.Errorf("PostedTweets() returned %d tweets, want 0", len(tweets))
+		}
+		if tweet, err := query.PostedTweet(bob, "tweet-1"); tweet != nil || err != nil {
+			t.Errorf("PostedTweet() = %+v, %v, want nil", tweet, err)
+		}
+	})
+
+	t.Run("the same ID through another method or issuer sees nothing", func(t *testing.T) {
+		others := map[string]*auth.User{
+			"JWT subject":                    {ID: "alice", Method: auth.MethodJWT, Issuer: "https://issuer.example.com"},
+			"other issuer":                   {ID: "alice", Method: auth.MethodJWT, Issuer: "https://other.example.com"},
+			"API key named like an owner ID": {ID: "jwt:https://issuer.example.com|alice", Method: auth.MethodAPIKey},
+		}
+		for name, user := range others {
+			ctx := auth.WithUser(context.Background(), user)
+			if sim, err := query.Simulation(ctx, *generated.SimulationID); sim != nil || err != nil {
+				t.Errorf("%s: Simulation() = %+v, %v, want nil", name, sim, err)
+			}
+			if posts, _ := query.ScheduledPosts(ctx, nil); len(posts) != 0 {
+				t.Errorf("%s: ScheduledPosts() returned %d posts, want 0", name, len(posts))
+			}
+			if tweet, err := query.PostedTweet(ctx, "tweet-1"); tweet != nil || err != nil {
+				t.Errorf("%s: PostedTweet() = %+v, %v, want nil", name, tweet, err)
+			}
+		}
+	})
+
+	t.Run("other users cannot modify records", func(t *testing.T) {
+		if _, err := mutation.CancelScheduledPost(bob, scheduled.ID); !errors.Is(err, queue.ErrNotFound) {
+			t.Errorf("CancelScheduledPost() error = %v, want queue.ErrNotFound", err)
+		}
+		if _, err := mutation.GenerateReplies(bob, "炎上文章", generated.SimulationID, nil, nil); !errors.Is(err, simulation.ErrNotFound) {
+			t.Errorf("GenerateReplies() error = %v, want simulation.ErrNotFound", err)
+		}
+		if post, err := query.ScheduledPost(alice, scheduled.ID); err != nil || post.Status != model.ScheduledPostStatusPending {
+			t.Errorf("ScheduledPost() = %+v, %v, want the post still pending", post, err)
+		}
+	})
+}
+
+func TestQueryResolver_Me(t *testing.T) {
+	query := &queryResolver{NewResolver(nil, nil, nil)}
+
+	if user, err := query.Me(context.Background()); user != nil || err != nil {
+		t.Errorf("Me() = %+v, %v for an anonymous request, want nil", user, err)
+	}
+
+	ctx := auth.WithUser(context.Background(), &auth.User{ID: "user-1", Name: "炎上 太郎", Method: auth.MethodJWT})
+	user, err := query.Me(ctx)
+	if err != nil {
+		t.Fatalf("Me() error = %v", err)
+	}
+	if user.ID != "user-1" || stringValue(user.Name) != "炎上 太郎" || user.Email != nil || user.AuthMethod != model.AuthMethodJwt {
+		t.Errorf("Me() = %+v", user)
+	}
+}
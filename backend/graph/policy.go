@@ -0,0 +1,456 @@
+package graph
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/gqlerror"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+)
+
+const (
+	// minLevel and maxLevel bound the flame level of generated content
+	minLevel = 1
+	maxLevel = 5
+	// defaultConfirmationTTL is how long a confirmation token stays valid
+	defaultConfirmationTTL = 10 * time.Minute
+	// confirmationSecretSize is the size of the randomly generated signing secret
+	confirmationSecretSize = 32
+)
+
+// PolicyAction is the outcome of evaluating the posting policy
+type PolicyAction string
+
+const (
+	// PolicyActionAllow means the post may be published (possibly with forced options)
+	PolicyActionAllow PolicyAction = "ALLOW"
+	// PolicyActionBlock means the post must not be published
+	PolicyActionBlock PolicyAction = "BLOCK"
+	// PolicyActionConfirmationRequired means the post needs a second confirmation before publishing
+	PolicyActionConfirmationRequired PolicyAction = "CONFIRMATION_REQUIRED"
+)
+
+// errConfirmationRequired is returned by scheduling when the policy requires a confirmation token
+var errConfirmationRequired = errors.New("この炎上度レベルの投稿には確認が必要です")
+
+// ModerationResult is the verdict of a content moderation check
+type ModerationResult struct {
+	Flagged    bool
+	Categories []string
+	Reason     string
+}
+
+// Moderator checks whether text is acceptable to publish
+type Moderator interface {
+	Moderate(ctx context.Context, text string) (*ModerationResult, error)
+}
+
+// PostingPolicy configures the guardrails applied before anything is published.
+// A threshold of 0 disables the corresponding rule.
+type PostingPolicy struct {
+	// ForceDisclaimerFromLevel forces the disclaimer for content at or above this level
+	ForceDisclaimerFromLevel int
+	// ForceHashtagFromLevel forces the hashtag for content at or above this level
+	ForceHashtagFromLevel int
+	// ConfirmationFromLevel requires a confirmation token for content at or above this level
+	ConfirmationFromLevel int
+	// DefaultLevel is the lowest level assumed for text the server has no record of, such as a post
+	// without a simulation. A level claimed by the client can raise it but never lower it.
+	DefaultLevel int
+	// ConfirmationTTL is how long an issued confirmation token stays valid
+	ConfirmationTTL time.Duration
+	// ConfirmationSecret signs confirmation tokens; a random secret is generated when empty
+	ConfirmationSecret []byte
+	// Moderator optionally blocks texts it flags
+	Moderator Moderator
+	// ImageModerator optionally blocks posts with images it flags
+	ImageModerator ImageModerator
+	// BlockOnModerationError blocks posting when the moderation check itself fails, so that an
+	// outage of the moderation model does not let unchecked posts through
+	BlockOnModerationError bool
+}
+
+// DefaultPostingPolicy returns the policy used when none is configured: disclaimer and hashtag
+// from level 3, confirmation for levels 4-5, unknown levels treated as 5, and posts blocked when
+// moderation fails.
+func DefaultPostingPolicy() PostingPolicy {
+	return PostingPolicy{
+		ForceDisclaimerFromLevel: 3,
+		ForceHashtagFromLevel:    3,
+		ConfirmationFromLevel:    4,
+		DefaultLevel:             maxLevel,
+		ConfirmationTTL:          defaultConfirmationTTL,
+		BlockOnModerationError:   true,
+	}
+}
+
+// PostRequest is the content being evaluated by the posting policy
+type PostRequest struct {
+	Text string
+	// Level is the level claimed by the client. It is ignored when KnownLevel is set and cannot
+	// lower the level below the policy's default otherwise.
+	Level *int
+	// KnownLevel is the level the server recorded for the text, e.g. in the simulation it was
+	// generated by, or 0 when there is no record
+	KnownLevel        int
+	AddHashtag        bool
+	AddDisclaimer     bool
+	ConfirmationToken string
+	Images            [][]byte // Attached image data, checked by the image moderator
+}
+
+// PolicyDecision is the result of evaluating a PostRequest
+type PolicyDecision struct {
+	Action            PolicyAction
+	Level             int
+	Reasons           []string
+	AddHashtag        bool
+	AddDisclaimer     bool
+	ForcedHashtag     bool
+	ForcedDisclaimer  bool
+	ConfirmationToken string
+	Moderation        *ModerationResult
+	ImageModeration   []*ModerationResult // One verdict per attached image when images are moderated
+}
+
+// PolicyEngine evaluates posts against a PostingPolicy
+type PolicyEngine struct {
+	policy PostingPolicy
+	now    func() time.Time
+}
+
+// NewPolicyEngine creates a policy engine for the given policy
+func NewPolicyEngine(policy PostingPolicy) (*PolicyEngine, error) {
+	if policy.DefaultLevel < minLevel || policy.DefaultLevel > maxLevel {
+		return nil, fmt.Errorf("default level must be between %d and %d, got %d", minLevel, maxLevel, policy.DefaultLevel)
+	}
+	if policy.ConfirmationTTL <= 0 {
+		policy.ConfirmationTTL = defaultConfirmationTTL
+	}
+	if len(policy.ConfirmationSecret) == 0 {
+		secret := make([]byte, confirmationSecretSize)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate confirmation secret: %w", err)
+		}
+		policy.ConfirmationSecret = secret
+	}
+
+	return &PolicyEngine{policy: policy, now: time.Now}, nil
+}
+
+// Evaluate applies the posting policy to req and logs the decision
+func (e *PolicyEngine) Evaluate(ctx context.Context, req PostRequest) (*PolicyDecision, error) {
+	if req.Level != nil && (*req.Level < minLevel || *req.Level > maxLevel) {
+		return nil, fmt.Errorf("level must be between %d and %d, got %d", minLevel, maxLevel, *req.Level)
+	}
+
+	// Anyone can claim a low level, so the level on record wins and a claim only counts when it is
+	// stricter than the default
+	level := e.policy.DefaultLevel
+	var reason string
+	switch {
+	case req.KnownLevel > 0:
+		level = req.KnownLevel
+		if req.Level != nil && *req.Level != level {
+			reason = fmt.Sprintf("claimed level %d ignored, using the simulation's level %d", *req.Level, level)
+		}
+	case req.Level == nil:
+		reason = fmt.Sprintf("level not provided, assuming level %d", level)
+	case *req.Level < level:
+		reason = fmt.Sprintf("claimed level %d is below the default, assuming level %d", *req.Level, level)
+	default:
+		level = *req.Level
+	}
+
+	decision := &PolicyDecision{
+		Action:        PolicyActionAllow,
+		Level:         level,
+		AddHashtag:    req.AddHashtag,
+		AddDisclaimer: req.AddDisclaimer,
+	}
+	if reason != "" {
+		decision.Reasons = append(decision.Reasons, reason)
+	}
+
+	e.applyForcedOptions(decision)
+
+	if e.policy.Moderator != nil {
+		e.moderate(ctx, req.Text, decision)
+	}
+	if e.policy.ImageModerator != nil {
+		e.moderateImages(ctx, req.Images, decision)
+	}
+
+	if decision.Action == PolicyActionAllow && atOrAbove(level, e.policy.ConfirmationFromLevel) {
+		e.checkConfirmation(req, decision)
+	}
+
+	logPolicyDecision(ctx, req, decision)
+	return decision, nil
+}
+
+// applyForcedOptions forces the disclaimer and hashtag for high levels
+func (e *PolicyEngine) applyForcedOptions(decision *PolicyDecision) {
+	if atOrAbove(decision.Level, e.policy.ForceDisclaimerFromLevel) && !decision.AddDisclaimer {
+		decision.AddDisclaimer = true
+		decision.ForcedDisclaimer = true
+		decision.Reasons = append(decision.Reasons, fmt.Sprintf("disclaimer forced for level %d", decision.Level))
+	}
+	if atOrAbove(decision.Level, e.policy.ForceHashtagFromLevel) && !decision.AddHashtag {
+		decision.AddHashtag = true
+		decision.ForcedHashtag = true
+		decision.Reasons = append(decision.Reasons, fmt.Sprintf("hashtag forced for level %d", decision.Level))
+	}
+}
+
+// moderate runs the moderation check and blocks flagged texts
+func (e *PolicyEngine) moderate(ctx context.Context, text string, decision *PolicyDecision) {
+	result, err := e.policy.Moderator.Moderate(ctx, text)
+	if err != nil {
+		if e.policy.BlockOnModerationError {
+			decision.Action = PolicyActionBlock
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("moderation check failed: %v", err))
+		} else {
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("moderation check failed, allowing: %v", err))
+		}
+		return
+	}
+
+	decision.Moderation = result
+	if result.Flagged {
+		decision.Action = PolicyActionBlock
+		decision.Reasons = append(decision.Reasons, moderationReason(result))
+	}
+}
+
+// moderateImages runs the image moderation check on every attached image and blocks flagged ones
+func (e *PolicyEngine) moderateImages(ctx context.Context, images [][]byte, decision *PolicyDecision) {
+	for i, image := range images {
+		result, err := e.policy.ImageModerator.ModerateImage(ctx, image)
+		if err != nil {
+			if e.policy.BlockOnModerationError {
+				decision.Action = PolicyActionBlock
+				decision.Reasons = append(decision.Reasons, fmt.Sprintf("image %d moderation check failed: %v", i+1, err))
+			} else {
+				decision.Reasons = append(decision.Reasons, fmt.Sprintf("image %d moderation check failed, allowing: %v", i+1, err))
+			}
+			decision.ImageModeration = append(decision.ImageModeration, nil)
+			continue
+		}
+
+		decision.ImageModeration = append(decision.ImageModeration, result)
+		if result.Flagged {
+			decision.Action = PolicyActionBlock
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("image %d %s", i+1, moderationReason(result)))
+		}
+	}
+}
+
+// checkConfirmation validates the confirmation token or issues a new one
+func (e *PolicyEngine) checkConfirmation(req PostRequest, decision *PolicyDecision) {
+	if req.ConfirmationToken != "" {
+		err := e.verifyConfirmationToken(req.ConfirmationToken, req, decision)
+		if err == nil {
+			decision.Reasons = append(decision.Reasons, fmt.Sprintf("confirmed for level %d", decision.Level))
+			return
+		}
+		decision.Reasons = append(decision.Reasons, fmt.Sprintf("confirmation token rejected: %v", err))
+	}
+
+	decision.Action = PolicyActionConfirmationRequired
+	decision.ConfirmationToken = e.issueConfirmationToken(req, decision)
+	decision.Reasons = append(decision.Reasons, fmt.Sprintf("confirmation required for level %d", decision.Level))
+}
+
+// issueConfirmationToken returns a token binding the post as decided (text, level, hashtag and
+// disclaimer, and attached images) until the TTL expires, so that nothing can be swapped after
+// the user confirmed it. The token format is "<expiry unix seconds>.<base64url HMAC>".
+func (e *PolicyEngine) issueConfirmationToken(req PostRequest, decision *PolicyDecision) string {
+	expiresAt := e.now().Add(e.policy.ConfirmationTTL).Unix()
+	return strconv.FormatInt(expiresAt, 10) + "." + e.signConfirmation(req, decision, expiresAt)
+}
+
+// verifyConfirmationToken checks a token issued by issueConfirmationToken
+func (e *PolicyEngine) verifyConfirmationToken(token string, req PostRequest, decision *PolicyDecision) error {
+	expiry, signature, found := strings.Cut(token, ".")
+	if !found {
+		return errors.New("malformed token")
+	}
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if e.now().Unix() > expiresAt {
+		return errors.New("token expired")
+	}
+
+	expected := e.signConfirmation(req, decision, expiresAt)
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(expected)) != 1 {
+		return errors.New("token does not match this post")
+	}
+	return nil
+}
+
+// signConfirmation computes the HMAC over the text, level, options, images and expiry
+func (e *PolicyEngine) signConfirmation(req PostRequest, decision *PolicyDecision, expiresAt int64) string {
+	mac := hmac.New(sha256.New, e.policy.ConfirmationSecret)
+	textHash := sha256.Sum256([]byte(req.Text))
+	mac.Write(textHash[:])
+
+	var buf [26]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(decision.Level))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(expiresAt))
+	if decision.AddHashtag {
+		buf[16] = 1
+	}
+	if decision.AddDisclaimer {
+		buf[17] = 1
+	}
+	binary.BigEndian.PutUint64(buf[18:26], uint64(len(req.Images)))
+	mac.Write(buf[:])
+	for _, image := range req.Images {
+		imageHash := sha256.Sum256(image)
+		mac.Write(imageHash[:])
+	}
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// atOrAbove reports whether level reaches threshold; a threshold of 0 disables the rule
+func atOrAbove(level, threshold int) bool {
+	return threshold > 0 && level >= threshold
+}
+
+// logPolicyDecision logs every policy decision. The post text is identified by a hash only,
+// so that decisions can be matched to posts without logging the text even in debug mode.
+func logPolicyDecision(ctx context.Context, req PostRequest, decision *PolicyDecision) {
+	textHash := sha256.Sum256([]byte(req.Text))
+	slog.InfoContext(ctx, "posting policy decision",
+		"action", decision.Action,
+		"level", decision.Level,
+		"text_sha256", hex.EncodeToString(textHash[:8]),
+		"text_length", len([]rune(req.Text)),
+		"hashtag", decision.AddHashtag,
+		"disclaimer", decision.AddDisclaimer,
+		"reasons", decision.Reasons,
+	)
+}
+
+// moderationCalls returns the number of Gemini calls Evaluate makes to moderate req
+func (e *PolicyEngine) moderationCalls(req PostRequest) int {
+	calls := 0
+	if e.policy.Moderator != nil {
+		calls++
+	}
+	if e.policy.ImageModerator != nil {
+		calls += len(req.Images)
+	}
+	return calls
+}
+
+// allowModeration charges the moderation calls the posting policy makes for req. Resolvers call it
+// before evaluatePostingPolicy so that rejected posts are charged for their moderation too.
+func (r *Resolver) allowModeration(ctx context.Context, req PostRequest) error {
+	if r.policyEngine == nil {
+		return nil
+	}
+	calls := r.policyEngine.moderationCalls(req)
+	if calls == 0 {
+		return nil
+	}
+	return r.allow(ctx, ratelimit.OpText, calls)
+}
+
+// evaluatePostingPolicy runs the posting policy. Without an engine the request is allowed unchanged.
+func (r *Resolver) evaluatePostingPolicy(ctx context.Context, req PostRequest) (*PolicyDecision, error) {
+	if r.policyEngine != nil {
+		return r.policyEngine.Evaluate(ctx, req)
+	}
+
+	decision := &PolicyDecision{
+		Action:        PolicyActionAllow,
+		AddHashtag:    req.AddHashtag,
+		AddDisclaimer: req.AddDisclaimer,
+	}
+	decision.Level = req.KnownLevel
+	if decision.Level == 0 && req.Level != nil {
+		decision.Level = *req.Level
+	}
+	return decision, nil
+}
+
+// policyRejectedResult builds the postToTwitter result for a post the policy did not allow
+func policyRejectedResult(decision *PolicyDecision) *model.TwitterPostResult {
+	result := &model.TwitterPostResult{
+		Success: false,
+		Policy:  toPolicyDecisionModel(decision),
+	}
+
+	if decision.Action == PolicyActionConfirmationRequired {
+		result.ConfirmationRequired = true
+		result.ConfirmationToken = stringPtr(decision.ConfirmationToken)
+		result.ErrorMessage = stringPtr(errConfirmationRequired.Error() + "。内容を確認のうえ、確認トークンを付けて再度投稿してください。")
+		return result
+	}
+
+	result.ErrorMessage = stringPtr("投稿ポリシーにより投稿できません: " + strings.Join(decision.Reasons, "; "))
+	return result
+}
+
+// policyRejectedError builds the GraphQL error for a scheduled post the policy did not allow.
+// The confirmation token is exposed through the error extensions.
+func policyRejectedError(decision *PolicyDecision) error {
+	if decision.Action == PolicyActionConfirmationRequired {
+		return &gqlerror.Error{
+			Message: errConfirmationRequired.Error(),
+			Extensions: map[string]any{
+				"code":              string(PolicyActionConfirmationRequired),
+				"confirmationToken": decision.ConfirmationToken,
+				"level":             decision.Level,
+			},
+		}
+	}
+
+	return &gqlerror.Error{
+		Message: "投稿ポリシーにより投稿できません: " + strings.Join(decision.Reasons, "; "),
+		Extensions: map[string]any{
+			"code":    string(PolicyActionBlock),
+			"reasons": decision.Reasons,
+		},
+	}
+}
+
+// toPolicyDecisionModel converts a policy decision into its GraphQL representation
+func toPolicyDecisionModel(decision *PolicyDecision) *model.PolicyDecision {
+	reasons := decision.Reasons
+	if reasons == nil {
+		reasons = []string{}
+	}
+
+	result := &model.PolicyDecision{
+		Action:           model.PolicyAction(decision.Action),
+		Level:            decision.Level,
+		Reasons:          reasons,
+		ForcedHashtag:    decision.ForcedHashtag,
+		ForcedDisclaimer: decision.ForcedDisclaimer,
+	}
+	result.Moderation = toModerationVerdict(decision.Moderation)
+	for _, verdict := range decision.ImageModeration {
+		result.ImageModeration = append(result.ImageModeration, toModerationVerdict(verdict))
+	}
+	return result
+}
@@ -0,0 +1,438 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/twitter"
+)
+
+// mockModerator is a Moderator returning a fixed result
+type mockModerator struct {
+	result *ModerationResult
+	err    error
+}
+
+func (m *mockModerator) Moderate(_ context.Context, _ string) (*ModerationResult, error) {
+	return m.result, m.err
+}
+
+type mockImageModerator struct {
+	flagged map[string]bool // Image data that is flagged
+	err     error
+}
+
+func (m *mockImageModerator) ModerateImage(_ context.Context, image []byte) (*ModerationResult, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if m.flagged[string(image)] {
+		return &ModerationResult{Flagged: true, Categories: []string{ImageCategoryLogo}, Reason: "企業ロゴ"}, nil
+	}
+	return &ModerationResult{}, nil
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func newTestPolicyEngine(t *testing.T, policy PostingPolicy) *PolicyEngine {
+	t.Helper()
+
+	engine, err := NewPolicyEngine(policy)
+	if err != nil {
+		t.Fatalf("NewPolicyEngine() error = %v", err)
+	}
+	return engine
+}
+
+// lenientPostingPolicy trusts claimed levels down to the minimum, for tests that post low-level text
+func lenientPostingPolicy() PostingPolicy {
+	policy := DefaultPostingPolicy()
+	policy.DefaultLevel = minLevel
+	return policy
+}
+
+// withLenientPolicy is a resolver option applying lenientPostingPolicy
+func withLenientPolicy(t *testing.T) ResolverOption {
+	t.Helper()
+	return WithPolicyEngine(newTestPolicyEngine(t, lenientPostingPolicy()))
+}
+
+func TestPolicyEngine_Evaluate(t *testing.T) {
+	tests := []struct {
+		name               string
+		policy             PostingPolicy
+		req                PostRequest
+		wantAction         PolicyAction
+		wantLevel          int
+		wantHashtag        bool
+		wantDisclaimer     bool
+		wantForcedHashtag  bool
+		wantForcedDisclaim bool
+		wantErr            bool
+	}{
+		{
+			name:       "allows low level posts unchanged",
+			policy:     lenientPostingPolicy(),
+			req:        PostRequest{Text: "投稿", Level: intPtr(1)},
+			wantAction: PolicyActionAllow,
+			wantLevel:  1,
+		},
+		{
+			name:               "forces disclaimer and hashtag from level 3",
+			policy:             lenientPostingPolicy(),
+			req:                PostRequest{Text: "投稿", Level: intPtr(3)},
+			wantAction:         PolicyActionAllow,
+			wantLevel:          3,
+			wantHashtag:        true,
+			wantDisclaimer:     true,
+			wantForcedHashtag:  true,
+			wantForcedDisclaim: true,
+		},
+		{
+			name:           "does not mark options as forced when already requested",
+			policy:         lenientPostingPolicy(),
+			req:            PostRequest{Text: "投稿", Level: intPtr(3), AddHashtag: true, AddDisclaimer: true},
+			wantAction:     PolicyActionAllow,
+			wantLevel:      3,
+			wantHashtag:    true,
+			wantDisclaimer: true,
+		},
+		{
+			name:               "requires confirmation when level is missing",
+			policy:             DefaultPostingPolicy(),
+			req:                PostRequest{Text: "投稿"},
+			wantAction:         PolicyActionConfirmationRequired,
+			wantLevel:          maxLevel,
+			wantHashtag:        true,
+			wantDisclaimer:     true,
+			wantForcedHashtag:  true,
+			wantForcedDisclaim: true,
+		},
+		{
+			name:               "does not trust a claimed level below the default",
+			policy:             DefaultPostingPolicy(),
+			req:                PostRequest{Text: "投稿", Level: intPtr(1)},
+			wantAction:         PolicyActionConfirmationRequired,
+			wantLevel:          maxLevel,
+			wantHashtag:        true,
+			wantDisclaimer:     true,
+			wantForcedHashtag:  true,
+			wantForcedDisclaim: true,
+		},
+		{
+			name:       "uses the known level over a stricter claim",
+			policy:     DefaultPostingPolicy(),
+			req:        PostRequest{Text: "投稿", Level: intPtr(5), KnownLevel: 2},
+			wantAction: PolicyActionAllow,
+			wantLevel:  2,
+		},
+		{
+			name:               "uses the known level over a lower claim",
+			policy:             DefaultPostingPolicy(),
+			req:                PostRequest{Text: "投稿", Level: intPtr(1), KnownLevel: 4},
+			wantAction:         PolicyActionConfirmationRequired,
+			wantLevel:          4,
+			wantHashtag:        true,
+			wantDisclaimer:     true,
+			wantForcedHashtag:  true,
+			wantForcedDisclaim: true,
+		},
+		{
+			name:    "rejects out of range levels",
+			policy:  DefaultPostingPolicy(),
+			req:     PostRequest{Text: "投稿", Level: intPtr(6)},
+			wantErr: true,
+		},
+		{
+			name: "blocks text flagged by moderation",
+			policy: PostingPolicy{
+				DefaultLevel: 1,
+				Moderator:    &mockModerator{result: &ModerationResult{Flagged: true, Categories: []string{"harassment"}}},
+			},
+			req:        PostRequest{Text: "投稿", Level: intPtr(1)},
+			wantAction: PolicyActionBlock,
+			wantLevel:  1,
+		},
+		{
+			name: "allows when moderation fails and blocking is disabled",
+			policy: PostingPolicy{
+				DefaultLevel: 1,
+				Moderator:    &mockModerator{err: errors.New("unavailable")},
+			},
+			req:        PostRequest{Text: "投稿", Level: intPtr(1)},
+			wantAction: PolicyActionAllow,
+			wantLevel:  1,
+		},
+		{
+			name: "blocks when moderation fails and blocking is enabled",
+			policy: PostingPolicy{
+				DefaultLevel:           1,
+				Moderator:              &mockModerator{err: errors.New("unavailable")},
+				BlockOnModerationError: true,
+			},
+			req:        PostRequest{Text: "投稿", Level: intPtr(1)},
+			wantAction: PolicyActionBlock,
+			wantLevel:  1,
+		},
+		{
+			name: "blocks posts with an image flagged by image moderation",
+			policy: PostingPolicy{
+				DefaultLevel:   1,
+				ImageModerator: &mockImageModerator{flagged: map[string]bool{"logo.png": true}},
+			},
+			req:        PostRequest{Text: "投稿", Level: intPtr(1), Images: [][]byte{[]byte("ok.png"), []byte("logo.png")}},
+			wantAction: PolicyActionBlock,
+			wantLevel:  1,
+		},
+		{
+			name: "allows posts whose images pass image moderation",
+			policy: PostingPolicy{
+				DefaultLevel:   1,
+				ImageModerator: &mockImageModerator{flagged: map[string]bool{"logo.png": true}},
+			},
+			req:        PostRequest{Text: "投稿", Level: intPtr(1), Images: [][]byte{[]byte("ok.png")}},
+			wantAction: PolicyActionAllow,
+			wantLevel:  1,
+		},
+		{
+			name: "blocks when image moderation fails and blocking is enabled",
+			policy: PostingPolicy{
+				DefaultLevel:           1,
+				ImageModerator:         &mockImageModerator{err: errors.New("unavailable")},
+				BlockOnModerationError: true,
+			},
+			req:        PostRequest{Text: "投稿", Level: intPtr(1), Images: [][]byte{[]byte("ok.png")}},
+			wantAction: PolicyActionBlock,
+			wantLevel:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newTestPolicyEngine(t, tt.policy)
+
+			got, err := engine.Evaluate(context.Background(), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.Action != tt.wantAction {
+				t.Errorf("Action = %v, want %v (reasons %q)", got.Action, tt.wantAction, got.Reasons)
+			}
+			if got.Level != tt.wantLevel {
+				t.Errorf("Level = %v, want %v", got.Level, tt.wantLevel)
+			}
+			if got.AddHashtag != tt.wantHashtag || got.ForcedHashtag != tt.wantForcedHashtag {
+				t.Errorf("hashtag = %v (forced %v), want %v (forced %v)", got.AddHashtag, got.ForcedHashtag, tt.wantHashtag, tt.wantForcedHashtag)
+			}
+			if got.AddDisclaimer != tt.wantDisclaimer || got.ForcedDisclaimer != tt.wantForcedDisclaim {
+				t.Errorf("disclaimer = %v (forced %v), want %v (forced %v)", got.AddDisclaimer, got.ForcedDisclaimer, tt.wantDisclaimer, tt.wantForcedDisclaim)
+			}
+			if (got.ConfirmationToken != "") != (tt.wantAction == PolicyActionConfirmationRequired) {
+				t.Errorf("ConfirmationToken = %q, want token only when confirmation is required", got.ConfirmationToken)
+			}
+		})
+	}
+}
+
+func TestPolicyEngine_Confirmation(t *testing.T) {
+	ctx := context.Background()
+	engine := newTestPolicyEngine(t, lenientPostingPolicy())
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+
+	first, err := engine.Evaluate(ctx, PostRequest{Text: "炎上投稿", Level: intPtr(4)})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	if first.Action != PolicyActionConfirmationRequired || first.ConfirmationToken == "" {
+		t.Fatalf("first Evaluate() = %+v, want confirmation required with a token", first)
+	}
+	token := first.ConfirmationToken
+
+	confirmed, _ := engine.Evaluate(ctx, PostRequest{Text: "炎上投稿", Level: intPtr(4), ConfirmationToken: token})
+	if confirmed.Action != PolicyActionAllow {
+		t.Errorf("Evaluate() with token = %v, want ALLOW", confirmed.Action)
+	}
+
+	edited, _ := engine.Evaluate(ctx, PostRequest{Text: "別の投稿", Level: intPtr(4), ConfirmationToken: token})
+	if edited.Action != PolicyActionConfirmationRequired {
+		t.Errorf("Evaluate() with token for other text = %v, want CONFIRMATION_REQUIRED", edited.Action)
+	}
+
+	otherLevel, _ := engine.Evaluate(ctx, PostRequest{Text: "炎上投稿", Level: intPtr(5), ConfirmationToken: token})
+	if otherLevel.Action != PolicyActionConfirmationRequired {
+		t.Errorf("Evaluate() with token for other level = %v, want CONFIRMATION_REQUIRED", otherLevel.Action)
+	}
+
+	engine.now = func() time.Time { return now.Add(defaultConfirmationTTL + time.Second) }
+	expired, _ := engine.Evaluate(ctx, PostRequest{Text: "炎上投稿", Level: intPtr(4), ConfirmationToken: token})
+	if expired.Action != PolicyActionConfirmationRequired {
+		t.Errorf("Evaluate() with expired token = %v, want CONFIRMATION_REQUIRED", expired.Action)
+	}
+}
+
+func TestPolicyEngine_ConfirmationBindsImagesAndOptions(t *testing.T) {
+	ctx := context.Background()
+	policy := lenientPostingPolicy()
+	policy.ForceDisclaimerFromLevel = 0
+	policy.ForceHashtagFromLevel = 0
+	engine := newTestPolicyEngine(t, policy)
+
+	confirmed := PostRequest{Text: "炎上投稿", Level: intPtr(4), Images: [][]byte{[]byte("image")}}
+	first, err := engine.Evaluate(ctx, confirmed)
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+	confirmed.ConfirmationToken = first.ConfirmationToken
+	if got, _ := engine.Evaluate(ctx, confirmed); got.Action != PolicyActionAllow {
+		t.Fatalf("Evaluate() with token = %v, want ALLOW", got.Action)
+	}
+
+	tests := []struct {
+		name   string
+		change func(req *PostRequest)
+	}{
+		{"other image", func(req *PostRequest) { req.Images = [][]byte{[]byte("other")} }},
+		{"added image", func(req *PostRequest) { req.Images = append(req.Images, []byte("other")) }},
+		{"no image", func(req *PostRequest) { req.Images = nil }},
+		{"hashtag", func(req *PostRequest) { req.AddHashtag = true }},
+		{"disclaimer", func(req *PostRequest) { req.AddDisclaimer = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := confirmed
+			req.Images = slices.Clone(confirmed.Images)
+			tt.change(&req)
+			got, err := engine.Evaluate(ctx, req)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+			if got.Action != PolicyActionConfirmationRequired {
+				t.Errorf("Evaluate() = %v, want CONFIRMATION_REQUIRED", got.Action)
+			}
+		})
+	}
+}
+
+func TestMutationResolver_PostToTwitter_Policy(t *testing.T) {
+	ctx := context.Background()
+	var posted int
+	r := NewResolver(nil, &MockTwitterClient{
+		PostTweetFunc: func(_ context.Context, _ string) (*twitter.TweetResult, error) {
+			posted++
+			return &twitter.TweetResult{ID: "1", URL: "https://twitter.com/user/status/1"}, nil
+		},
+	}, nil)
+	resolver := &mutationResolver{r}
+
+	first, err := resolver.PostToTwitter(ctx, model.TwitterPostInput{Text: "炎上投稿", Level: intPtr(5)})
+	if err != nil {
+		t.Fatalf("PostToTwitter() error = %v", err)
+	}
+	if first.Success || !first.ConfirmationRequired || first.ConfirmationToken == nil {
+		t.Fatalf("PostToTwitter() = %+v, want confirmation required", first)
+	}
+	if posted != 0 {
+		t.Fatalf("tweet was posted before confirmation")
+	}
+	if first.Policy == nil || first.Policy.Action != model.PolicyActionConfirmationRequired {
+		t.Errorf("Policy = %+v, want CONFIRMATION_REQUIRED", first.Policy)
+	}
+
+	second, err := resolver.PostToTwitter(ctx, model.TwitterPostInput{
+		Text:              "炎上投稿",
+		Level:             intPtr(5),
+		ConfirmationToken: first.ConfirmationToken,
+	})
+	if err != nil {
+		t.Fatalf("PostToTwitter() error = %v", err)
+	}
+	if !second.Success || posted != 1 {
+		t.Fatalf("PostToTwitter() with token = %+v, want success", second)
+	}
+	if !second.Policy.ForcedDisclaimer || !second.Policy.ForcedHashtag {
+		t.Errorf("Policy = %+v, want forced disclaimer and hashtag", second.Policy)
+	}
+}
+
+func TestMutationResolver_PostToTwitter_ClaimedLevel(t *testing.T) {
+	var posted int
+	r := NewResolver(nil, &MockTwitterClient{
+		PostTweetFunc: func(_ context.Context, _ string) (*twitter.TweetResult, error) {
+			posted++
+			return &twitter.TweetResult{ID: "1"}, nil
+		},
+	}, nil)
+
+	// Claiming a low level must not skip the guardrails of the default level
+	result, err := (&mutationResolver{r}).PostToTwitter(context.Background(), model.TwitterPostInput{Text: "炎上投稿", Level: intPtr(1)})
+	if err != nil {
+		t.Fatalf("PostToTwitter() error = %v", err)
+	}
+	if result.Success || !result.ConfirmationRequired || posted != 0 {
+		t.Fatalf("PostToTwitter() = %+v, want confirmation required", result)
+	}
+	if result.Policy.Level != maxLevel || !result.Policy.ForcedDisclaimer || !result.Policy.ForcedHashtag {
+		t.Errorf("Policy = %+v, want level %d with forced disclaimer and hashtag", result.Policy, maxLevel)
+	}
+}
+
+func TestGeminiModerator_Moderate(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    string
+		wantFlagged bool
+		wantErr     bool
+	}{
+		{
+			name:        "parses flagged result wrapped in a code fence",
+			response:    "```json\n{\"flagged\": true, \"categories\": [\"hate\"], \"reason\": \"差別的\"}\n```",
+			wantFlagged: true,
+		},
+		{
+			name:     "parses clean result",
+			response: `{"flagged": false, "categories": [], "reason": ""}`,
+		},
+		{
+			name:     "returns error for non JSON response",
+			response: "判定できません",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			moderator := NewGeminiModerator(&MockGeminiClient{
+				GenerateContentFunc: func(_ context.Context, prompt string) (string, error) {
+					if !strings.Contains(prompt, "投稿") {
+						t.Errorf("prompt does not include the post: %q", prompt)
+					}
+					return tt.response, nil
+				},
+			})
+
+			got, err := moderator.Moderate(context.Background(), "投稿")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Moderate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Flagged != tt.wantFlagged {
+				t.Errorf("Flagged = %v, want %v", got.Flagged, tt.wantFlagged)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/metrics"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// allow charges units of op to the client making the request, reports the decision in the
+// response headers and adds the estimated cost to metrics. Mutations call it before doing any
+// billable work.
+func (r *Resolver) allow(ctx context.Context, op ratelimit.Operation, units int) error {
+	if r.rateLimiter == nil {
+		return nil
+	}
+	decision, err := r.rateLimiter.Allow(ratelimit.ClientFrom(ctx), op, units)
+	ratelimit.Record(ctx, decision)
+	if err != nil {
+		return rateLimitError(decision, err)
+	}
+	metrics.EstimatedCost.WithLabelValues(string(op)).Add(decision.Cost)
+	return nil
+}
+
+// rateLimitError converts a denied decision into a GraphQL error. The extensions tell clients
+// which limit was hit and when to retry.
+func rateLimitError(decision ratelimit.Decision, err error) error {
+	retryAfter := int(decision.RetryAfter / time.Second)
+	extensions := map[string]any{
+		"operation":  string(decision.Operation),
+		"retryAfter": retryAfter,
+	}
+
+	if errors.Is(err, ratelimit.ErrQuotaExceeded) {
+		extensions["code"] = "QUOTA_EXCEEDED"
+		return &gqlerror.Error{
+			Message:    "本日の利用上限に達しました。日付が変わってから（UTC）再度お試しください",
+			Extensions: extensions,
+		}
+	}
+	if errors.Is(err, ratelimit.ErrTooManyUnits) {
+		extensions["code"] = "RATE_LIMIT_EXCEEDED_BY_REQUEST"
+		return &gqlerror.Error{
+			Message:    "一度に処理できる上限を超えています。件数を減らして再度お試しください",
+			Extensions: extensions,
+		}
+	}
+	extensions["code"] = "RATE_LIMITED"
+	return &gqlerror.Error{
+		Message:    fmt.Sprintf("リクエストが多すぎます。%d 秒後に再度お試しください", retryAfter),
+		Extensions: extensions,
+	}
+}
+
+// quota returns the current client's standing, or nil when rate limiting is disabled
+func (r *Resolver) quota(ctx context.Context) *model.Quota {
+	if r.rateLimiter == nil {
+		return nil
+	}
+	status := r.rateLimiter.Status(ratelimit.ClientFrom(ctx))
+
+	quota := &model.Quota{
+		Spent:    roundCost(status.Spent),
+		ResetsAt: status.ResetsAt.Format(time.RFC3339),
+		Limits:   make([]*model.RateLimit, 0, len(status.Limits)),
+	}
+	if status.DailyBudget > 0 {
+		budget, remaining := status.DailyBudget, roundCost(status.Remaining())
+		quota.DailyBudget = &budget
+		quota.Remaining = &remaining
+	}
+	for _, usage := range status.Limits {
+		limit := &model.RateLimit{
+			Operation:    model.RateLimitedOperation(usage.Operation),
+			ResetSeconds: int(usage.Reset / time.Second),
+		}
+		if usage.Limit > 0 {
+			allowed, remaining, window := usage.Limit, usage.Remaining, int(usage.Period/time.Second)
+			limit.Limit = &allowed
+			limit.Remaining = &remaining
+			limit.WindowSeconds = &window
+		}
+		quota.Limits = append(quota.Limits, limit)
+	}
+	return quota
+}
+
+// roundCost hides floating point noise from summed costs
+func roundCost(usd float64) float64 {
+	return math.Round(usd*1e6) / 1e6
+}
@@ -0,0 +1,290 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/image"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+	"github.com/Tattsum/enjo/backend/twitter"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestRateLimiting(t *testing.T) {
+	var generated int
+	limiter := ratelimit.New(ratelimit.Config{
+		Rates:       map[ratelimit.Operation]ratelimit.Rate{ratelimit.OpText: ratelimit.DefaultConfig().Rates[ratelimit.OpText]},
+		DailyBudget: 0.05,
+		Costs:       map[ratelimit.Operation]float64{ratelimit.OpImage: 0.02},
+	})
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateContentFunc: func(context.Context, string) (string, error) {
+				return "a burning phone", nil
+			},
+		},
+		nil,
+		&MockImageClient{
+			GenerateImagesFunc: func(context.Context, string, ...image.Option) ([][]byte, error) {
+				generated++
+				return [][]byte{[]byte("png-1"), []byte("png-2")}, nil
+			},
+		},
+		WithRateLimiter(limiter),
+	)
+	mutation, query := &mutationResolver{r}, &queryResolver{r}
+	ctx := ratelimit.WithClient(context.Background(), "user:alice")
+
+	// Each requested image is charged, and requests over the budget never reach Imagen
+	_, err := mutation.GenerateImage(ctx, model.GenerateImageInput{Text: "炎上投稿", SampleCount: intPtr(4)})
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) || gqlErr.Extensions["code"] != "QUOTA_EXCEEDED" {
+		t.Fatalf("GenerateImage() over budget error = %v, want QUOTA_EXCEEDED", err)
+	}
+	if generated != 0 {
+		t.Errorf("image client called %d times for a denied request", generated)
+	}
+	if _, err := mutation.GenerateImage(ctx, model.GenerateImageInput{Text: "炎上投稿", SampleCount: intPtr(2)}); err != nil {
+		t.Fatalf("GenerateImage() within budget error = %v", err)
+	}
+
+	quota, err := query.Quota(ctx)
+	if err != nil || quota == nil {
+		t.Fatalf("Quota() = %+v, %v", quota, err)
+	}
+	if quota.Spent != 0.04 || quota.Remaining == nil || *quota.Remaining != 0.01 {
+		t.Errorf("Quota() spent %v with %v remaining, want 0.04 and 0.01", quota.Spent, quota.Remaining)
+	}
+	for _, limit := range quota.Limits {
+		switch limit.Operation {
+		case model.RateLimitedOperationText:
+			if limit.Limit == nil || *limit.Limit != 30 || limit.WindowSeconds == nil || *limit.WindowSeconds != 60 {
+				t.Errorf("TEXT limit = %+v, want 30 per 60 seconds", limit)
+			}
+		default:
+			if limit.Limit != nil {
+				t.Errorf("%s limit = %d, want unlimited", limit.Operation, *limit.Limit)
+			}
+		}
+	}
+
+	// Other clients have their own budget
+	other, err := query.Quota(ratelimit.WithClient(context.Background(), "ip:192.0.2.1"))
+	if err != nil || other.Spent != 0 {
+		t.Errorf("Quota() for another client = %+v, %v, want nothing spent", other, err)
+	}
+}
+
+func TestRateLimiting_ChargesEveryGeminiCall(t *testing.T) {
+	var calls int
+	called := func(result string) (string, error) {
+		calls++
+		return result, nil
+	}
+	withImages, _ := newTestImageStoreOption(t)
+	// Each Gemini call costs one dollar so that the spend counts the calls charged
+	limiter := ratelimit.New(ratelimit.Config{
+		DailyBudget: 100,
+		Costs:       map[ratelimit.Operation]float64{ratelimit.OpText: 1},
+	})
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateInflammatoryTextFunc: func(context.Context, string, int) (string, error) { return called("炎上文章") },
+			GenerateExplanationFunc:      func(context.Context, string, string) (string, error) { return called("解説") },
+			GenerateContentFunc:          func(context.Context, string) (string, error) { return called("a burning phone") },
+		},
+		nil,
+		&MockImageClient{
+			GenerateImagesFunc: func(context.Context, string, ...image.Option) ([][]byte, error) {
+				return [][]byte{[]byte("png")}, nil
+			},
+		},
+		withImages,
+		WithVisionClient(&mockVisionClient{
+			GenerateContentWithImageFunc: func(context.Context, string, []byte) (string, error) { return called("燃えるスマホ") },
+		}),
+		WithRateLimiter(limiter),
+	)
+	mutation, query := &mutationResolver{r}, &queryResolver{r}
+	ctx := ratelimit.WithClient(context.Background(), "user:alice")
+
+	spent := func() int {
+		t.Helper()
+		quota, err := query.Quota(ctx)
+		if err != nil {
+			t.Fatalf("Quota() error = %v", err)
+		}
+		return int(quota.Spent)
+	}
+
+	// The image prompt is written by Gemini
+	generated, err := mutation.GenerateImage(ctx, model.GenerateImageInput{Text: "炎上投稿"})
+	if err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+	if spent() != calls || calls != 1 {
+		t.Errorf("GenerateImage() charged %d Gemini calls for %d made, want 1", spent(), calls)
+	}
+
+	// Conversion, explanation and describing the attached image
+	if _, err := mutation.GenerateInflammatoryText(ctx, model.GenerateInput{OriginalText: "今日のランチ", Level: 3, ImageID: generated.ImageID}); err != nil {
+		t.Fatalf("GenerateInflammatoryText() error = %v", err)
+	}
+	if spent() != calls || calls != 4 {
+		t.Errorf("GenerateInflammatoryText() with an image charged %d Gemini calls in total for %d made, want 4", spent(), calls)
+	}
+
+	if _, err := mutation.GenerateInflammatoryText(ctx, model.GenerateInput{OriginalText: "今日のランチ", Level: 3}); err != nil {
+		t.Fatalf("GenerateInflammatoryText() error = %v", err)
+	}
+	if spent() != calls || calls != 6 {
+		t.Errorf("GenerateInflammatoryText() charged %d Gemini calls in total for %d made, want 6", spent(), calls)
+	}
+}
+
+// countingImageModerator counts the images it is asked to moderate and flags none
+type countingImageModerator struct {
+	calls int
+}
+
+func (m *countingImageModerator) ModerateImage(context.Context, []byte) (*ModerationResult, error) {
+	m.calls++
+	return &ModerationResult{}, nil
+}
+
+func TestRateLimiting_ChargesModeration(t *testing.T) {
+	withImages, _ := newTestImageStoreOption(t)
+	imageModerator := &countingImageModerator{}
+	policy := lenientPostingPolicy()
+	policy.Moderator = &mockModerator{result: &ModerationResult{Flagged: true, Reason: "差別的な表現"}}
+	policy.ImageModerator = imageModerator
+	// Each Gemini call costs one dollar so that the spend counts the calls charged
+	limiter := ratelimit.New(ratelimit.Config{
+		DailyBudget: 6,
+		Costs:       map[ratelimit.Operation]float64{ratelimit.OpText: 1},
+	})
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateContentFunc: func(context.Context, string) (string, error) { return "a burning phone", nil },
+		},
+		&MockTwitterClient{},
+		&MockImageClient{
+			GenerateImagesFunc: func(context.Context, string, ...image.Option) ([][]byte, error) {
+				return [][]byte{[]byte("png-1"), []byte("png-2")}, nil
+			},
+		},
+		withImages,
+		WithImageModerator(imageModerator),
+		WithPolicyEngine(newTestPolicyEngine(t, policy)),
+		WithRateLimiter(limiter),
+	)
+	mutation, query := &mutationResolver{r}, &queryResolver{r}
+	ctx := ratelimit.WithClient(context.Background(), "user:alice")
+
+	spent := func() float64 {
+		t.Helper()
+		quota, err := query.Quota(ctx)
+		if err != nil {
+			t.Fatalf("Quota() error = %v", err)
+		}
+		return quota.Spent
+	}
+
+	// The image prompt plus one moderation call per variant
+	generated, err := mutation.GenerateImage(ctx, model.GenerateImageInput{Text: "炎上投稿", SampleCount: intPtr(2)})
+	if err != nil {
+		t.Fatalf("GenerateImage() error = %v", err)
+	}
+	if spent() != 3 || imageModerator.calls != 2 {
+		t.Errorf("GenerateImage() spent %v with %d images moderated, want 3 and 2", spent(), imageModerator.calls)
+	}
+
+	// Text and image moderation are charged even when the policy blocks the post
+	postInput := model.TwitterPostInput{
+		Text:   "画像付き投稿",
+		Level:  intPtr(1),
+		Images: []*model.PostImageInput{{ImageID: generated.ImageID}},
+	}
+	result, err := mutation.PostToTwitter(ctx, postInput)
+	if err != nil || result.Success {
+		t.Fatalf("PostToTwitter() = %+v, %v, want a blocked post", result, err)
+	}
+	if spent() != 5 || imageModerator.calls != 3 {
+		t.Errorf("PostToTwitter() spent %v in total with %d images moderated, want 5 and 3", spent(), imageModerator.calls)
+	}
+
+	// Once the budget is spent moderation is no longer run
+	_, err = mutation.PostToTwitter(ctx, postInput)
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) || gqlErr.Extensions["code"] != "QUOTA_EXCEEDED" {
+		t.Fatalf("PostToTwitter() over budget error = %v, want QUOTA_EXCEEDED", err)
+	}
+	if imageModerator.calls != 3 {
+		t.Errorf("images moderated %d times, want no moderation over budget", imageModerator.calls)
+	}
+}
+
+func TestRateLimiting_ChargesPublishedPostsOnly(t *testing.T) {
+	var posted int
+	limiter := ratelimit.New(ratelimit.Config{
+		Rates: map[ratelimit.Operation]ratelimit.Rate{ratelimit.OpPost: {Limit: 1, Period: time.Hour}},
+	})
+	r := NewResolver(nil,
+		&MockTwitterClient{
+			PostTweetFunc: func(context.Context, string) (*twitter.TweetResult, error) {
+				posted++
+				return &twitter.TweetResult{ID: "1", URL: "https://twitter.com/enjo/status/1"}, nil
+			},
+		},
+		nil,
+		WithRateLimiter(limiter),
+	)
+	mutation := &mutationResolver{r}
+	ctx := ratelimit.WithClient(context.Background(), "user:alice")
+
+	// Invalid posts and posts waiting for confirmation take no posting token
+	rejected := []model.TwitterPostInput{
+		{Text: ""},
+		{Text: "画像付き投稿", Images: []*model.PostImageInput{{ImageID: stringPtr("missing")}}},
+		{Text: "炎上投稿"},
+	}
+	var confirmation *string
+	for _, input := range rejected {
+		result, err := mutation.PostToTwitter(ctx, input)
+		if err != nil || result.Success {
+			t.Fatalf("PostToTwitter(%+v) = %+v, %v, want a rejected post", input, result, err)
+		}
+		if result.ConfirmationToken != nil {
+			confirmation = result.ConfirmationToken
+		}
+	}
+	if confirmation == nil {
+		t.Fatal("PostToTwitter() issued no confirmation token")
+	}
+
+	confirmed := model.TwitterPostInput{Text: "炎上投稿", ConfirmationToken: confirmation}
+	if result, err := mutation.PostToTwitter(ctx, confirmed); err != nil || !result.Success {
+		t.Fatalf("confirmed PostToTwitter() = %+v, %v, want the post published", result, err)
+	}
+	_, err := mutation.PostToTwitter(ctx, confirmed)
+	var gqlErr *gqlerror.Error
+	if !errors.As(err, &gqlErr) || gqlErr.Extensions["code"] != "RATE_LIMITED" {
+		t.Errorf("second PostToTwitter() error = %v, want RATE_LIMITED", err)
+	}
+	if posted != 1 {
+		t.Errorf("published %d posts, want 1", posted)
+	}
+}
+
+func TestRateLimiting_Disabled(t *testing.T) {
+	r := NewResolver(nil, nil, nil)
+	if limiter := r.RateLimiter(); limiter != nil {
+		t.Error("RateLimiter() != nil without WithRateLimiter")
+	}
+	if quota, err := (&queryResolver{r}).Quota(context.Background()); quota != nil || err != nil {
+		t.Errorf("Quota() = %+v, %v, want nil", quota, err)
+	}
+}
@@ -3,6 +3,16 @@ package graph
 import (
 	"context"
 
+	"github.com/Tattsum/enjo/backend/accounts"
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/health"
+	"github.com/Tattsum/enjo/backend/image"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/overlay"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+	"github.com/Tattsum/enjo/backend/screenshot"
+	"github.com/Tattsum/enjo/backend/simulation"
 	"github.com/Tattsum/enjo/backend/twitter"
 )
 
@@ -22,25 +32,198 @@ type GeminiClient interface {
 type TwitterClient interface {
 	PostTweet(ctx context.Context, text string, options ...twitter.TweetOption) (*twitter.TweetResult, error)
 	PostTweetWithImage(ctx context.Context, text string, imageData []byte, options ...twitter.TweetOption) (*twitter.TweetResult, error)
+	PostTweetWithImages(ctx context.Context, text string, images []twitter.Image, options ...twitter.TweetOption) (*twitter.TweetResult, error)
+	DeleteTweet(ctx context.Context, tweetID string) error
+	GetTweetMetrics(ctx context.Context, tweetID string) (*twitter.TweetMetrics, error)
+	SearchReplies(ctx context.Context, tweetID string) ([]twitter.Reply, error)
 }
 
 // ImageClient is the interface for Image generation client
 type ImageClient interface {
-	GenerateImage(ctx context.Context, prompt string) ([]byte, error)
+	// GenerateImages returns one image per requested variant (see image.WithSampleCount)
+	GenerateImages(ctx context.Context, prompt string, options ...image.Option) ([][]byte, error)
 }
 
 // Resolver is the root resolver for GraphQL
 type Resolver struct {
-	geminiClient  GeminiClient
-	twitterClient TwitterClient
-	imageClient   ImageClient
+	geminiClient   GeminiClient
+	twitterClient  TwitterClient
+	imageClient    ImageClient
+	imagePrompts   *image.PromptPipeline
+	imageModerator ImageModerator
+	vision         VisionClient
+	postQueue      *queue.Queue
+	policyEngine   *PolicyEngine
+	simulations    *simulation.Store
+	imageStore     imagestore.Store
+	imageURLs      *imagestore.URLSigner
+	imageCatalog   *imagestore.Catalog
+	overlays       *overlay.Renderer
+	screenshots    *screenshot.Renderer
+	authenticator  *auth.Authenticator
+	rateLimiter    *ratelimit.Limiter
+	healthChecker  *health.Checker
+
+	// Per-user Twitter accounts; see WithTwitterAccounts
+	twitterAccounts     *accounts.Store
+	twitterAuth         TwitterAuthorizer
+	twitterClients      TwitterClientFactory
+	twitterLinkRedirect string
+}
+
+// ResolverOption is a functional option for optional Resolver dependencies
+type ResolverOption func(*Resolver)
+
+// WithPostQueue enables scheduled posting backed by the given queue
+func WithPostQueue(q *queue.Queue) ResolverOption {
+	return func(r *Resolver) {
+		r.postQueue = q
+	}
+}
+
+// WithSimulationStore persists simulations and the tweets posted from them
+func WithSimulationStore(s *simulation.Store) ResolverOption {
+	return func(r *Resolver) {
+		r.simulations = s
+	}
+}
+
+// WithImageStore stores generated images in store and hands out URLs signed by signer
+// instead of returning images inline as data URLs
+func WithImageStore(store imagestore.Store, signer *imagestore.URLSigner) ResolverOption {
+	return func(r *Resolver) {
+		r.imageStore = store
+		r.imageURLs = signer
+	}
+}
+
+// WithImageCatalog records how stored images were generated so they can be varied later
+func WithImageCatalog(catalog *imagestore.Catalog) ResolverOption {
+	return func(r *Resolver) {
+		r.imageCatalog = catalog
+	}
+}
+
+// WithImagePromptPipeline sets the pipeline that writes image prompts for posts.
+// Without it prompts are written by the Gemini client with the default policy terms.
+func WithImagePromptPipeline(pipeline *image.PromptPipeline) ResolverOption {
+	return func(r *Resolver) {
+		r.imagePrompts = pipeline
+	}
+}
+
+// WithImageModerator classifies every generated image and attaches the verdict to the result
+func WithImageModerator(moderator ImageModerator) ResolverOption {
+	return func(r *Resolver) {
+		r.imageModerator = moderator
+	}
+}
+
+// WithVisionClient sets the multimodal model used to describe uploaded images
+func WithVisionClient(client VisionClient) ResolverOption {
+	return func(r *Resolver) {
+		r.vision = client
+	}
+}
+
+// WithOverlayRenderer sets the renderer used to composite text onto images
+func WithOverlayRenderer(renderer *overlay.Renderer) ResolverOption {
+	return func(r *Resolver) {
+		r.overlays = renderer
+	}
+}
+
+// WithScreenshotRenderer sets the renderer used for simulation screenshots
+func WithScreenshotRenderer(renderer *screenshot.Renderer) ResolverOption {
+	return func(r *Resolver) {
+		r.screenshots = renderer
+	}
+}
+
+// WithPolicyEngine sets the posting policy applied before anything is published
+func WithPolicyEngine(engine *PolicyEngine) ResolverOption {
+	return func(r *Resolver) {
+		r.policyEngine = engine
+	}
+}
+
+// WithAuthenticator requires callers to authenticate and scopes history, scheduled posts and
+// posted tweets to the authenticated user
+func WithAuthenticator(authenticator *auth.Authenticator) ResolverOption {
+	return func(r *Resolver) {
+		r.authenticator = authenticator
+	}
+}
+
+// Authenticator returns the authenticator for the GraphQL endpoint, or nil when authentication is disabled
+func (r *Resolver) Authenticator() *auth.Authenticator {
+	return r.authenticator
 }
 
-// NewResolver creates a new Resolver with dependencies
-func NewResolver(geminiClient GeminiClient, twitterClient TwitterClient, imageClient ImageClient) *Resolver {
-	return &Resolver{
+// WithRateLimiter limits how often each client may generate text and images or post, and how
+// much estimated spend it may incur per day
+func WithRateLimiter(limiter *ratelimit.Limiter) ResolverOption {
+	return func(r *Resolver) {
+		r.rateLimiter = limiter
+	}
+}
+
+// RateLimiter returns the limiter applied to generation and posting, or nil when rate limiting is disabled
+func (r *Resolver) RateLimiter() *ratelimit.Limiter {
+	return r.rateLimiter
+}
+
+// WithHealthChecker reports the health of the service's dependencies in the health queries
+func WithHealthChecker(checker *health.Checker) ResolverOption {
+	return func(r *Resolver) {
+		r.healthChecker = checker
+	}
+}
+
+// HealthChecker returns the dependency health checker, or nil when health checks are not configured
+func (r *Resolver) HealthChecker() *health.Checker {
+	return r.healthChecker
+}
+
+// WithTwitterAccounts lets users link their own Twitter account through authorizer.
+// Posts are then published with a client built by clients from the user's access token, and the
+// shared client passed to NewResolver is only used for anonymous requests. Once linking completes
+// the user is redirected to redirectURL, or shown a confirmation page when it is empty.
+func WithTwitterAccounts(store *accounts.Store, authorizer TwitterAuthorizer, clients TwitterClientFactory, redirectURL string) ResolverOption {
+	return func(r *Resolver) {
+		r.twitterAccounts = store
+		r.twitterAuth = authorizer
+		r.twitterClients = clients
+		r.twitterLinkRedirect = redirectURL
+	}
+}
+
+// NewResolver creates a new Resolver with dependencies.
+// If no policy engine is supplied the DefaultPostingPolicy is enforced,
+// and overlays and screenshots are rendered with the embedded font unless renderers are supplied.
+func NewResolver(geminiClient GeminiClient, twitterClient TwitterClient, imageClient ImageClient, options ...ResolverOption) *Resolver {
+	r := &Resolver{
 		geminiClient:  geminiClient,
 		twitterClient: twitterClient,
 		imageClient:   imageClient,
 	}
+	for _, opt := range options {
+		opt(r)
+	}
+	if r.policyEngine == nil {
+		if engine, err := NewPolicyEngine(DefaultPostingPolicy()); err == nil {
+			r.policyEngine = engine
+		}
+	}
+	if r.overlays == nil {
+		if renderer, err := overlay.New(); err == nil {
+			r.overlays = renderer
+		}
+	}
+	if r.screenshots == nil {
+		if renderer, err := screenshot.New(); err == nil {
+			r.screenshots = renderer
+		}
+	}
+	return r
 }
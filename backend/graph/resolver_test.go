@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/image"
 	"github.com/Tattsum/enjo/backend/twitter"
 )
 
@@ -47,21 +48,34 @@ func (m *MockGeminiClient) GenerateContent(ctx context.Context, prompt string) (
 }
 
 // MockImageClient is a mock implementation of the Image client for testing
+// GenerateImageFunc is used for single-image tests when GenerateImagesFunc is not set.
 type MockImageClient struct {
-	GenerateImageFunc func(ctx context.Context, prompt string) ([]byte, error)
+	GenerateImageFunc  func(ctx context.Context, prompt string) ([]byte, error)
+	GenerateImagesFunc func(ctx context.Context, prompt string, options ...image.Option) ([][]byte, error)
 }
 
-func (m *MockImageClient) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
+func (m *MockImageClient) GenerateImages(ctx context.Context, prompt string, options ...image.Option) ([][]byte, error) {
+	if m.GenerateImagesFunc != nil {
+		return m.GenerateImagesFunc(ctx, prompt, options...)
+	}
 	if m.GenerateImageFunc != nil {
-		return m.GenerateImageFunc(ctx, prompt)
+		data, err := m.GenerateImageFunc(ctx, prompt)
+		if err != nil {
+			return nil, err
+		}
+		return [][]byte{data}, nil
 	}
 	return nil, errors.New("not implemented")
 }
 
 // MockTwitterClient is a mock implementation of the Twitter client for testing
 type MockTwitterClient struct {
-	PostTweetFunc          func(ctx context.Context, text string) (*twitter.TweetResult, error)
-	PostTweetWithImageFunc func(ctx context.Context, text string, imageData []byte) (*twitter.TweetResult, error)
+	PostTweetFunc           func(ctx context.Context, text string) (*twitter.TweetResult, error)
+	PostTweetWithImageFunc  func(ctx context.Context, text string, imageData []byte) (*twitter.TweetResult, error)
+	PostTweetWithImagesFunc func(ctx context.Context, text string, images []twitter.Image) (*twitter.TweetResult, error)
+	DeleteTweetFunc         func(ctx context.Context, tweetID string) error
+	GetTweetMetricsFunc     func(ctx context.Context, tweetID string) (*twitter.TweetMetrics, error)
+	SearchRepliesFunc       func(ctx context.Context, tweetID string) ([]twitter.Reply, error)
 }
 
 func (m *MockTwitterClient) PostTweet(ctx context.Context, text string, _ ...twitter.TweetOption) (*twitter.TweetResult, error) {
@@ -78,6 +92,34 @@ func (m *MockTwitterClient) PostTweetWithImage(ctx context.Context, text string,
 	return nil, errors.New("not implemented")
 }
 
+func (m *MockTwitterClient) PostTweetWithImages(ctx context.Context, text string, images []twitter.Image, _ ...twitter.TweetOption) (*twitter.TweetResult, error) {
+	if m.PostTweetWithImagesFunc != nil {
+		return m.PostTweetWithImagesFunc(ctx, text, images)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockTwitterClient) DeleteTweet(ctx context.Context, tweetID string) error {
+	if m.DeleteTweetFunc != nil {
+		return m.DeleteTweetFunc(ctx, tweetID)
+	}
+	return errors.New("not implemented")
+}
+
+func (m *MockTwitterClient) GetTweetMetrics(ctx context.Context, tweetID string) (*twitter.TweetMetrics, error) {
+	if m.GetTweetMetricsFunc != nil {
+		return m.GetTweetMetricsFunc(ctx, tweetID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *MockTwitterClient) SearchReplies(ctx context.Context, tweetID string) ([]twitter.Reply, error) {
+	if m.SearchRepliesFunc != nil {
+		return m.SearchRepliesFunc(ctx, tweetID)
+	}
+	return nil, errors.New("not implemented")
+}
+
 func TestQueryResolver_Health(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -253,7 +295,7 @@ func TestMutationResolver_GenerateReplies(t *testing.T) {
 			r := &Resolver{geminiClient: mockClient}
 			resolver := &mutationResolver{r}
 
-			got, err := resolver.GenerateReplies(context.Background(), tt.text)
+			got, err := resolver.GenerateReplies(context.Background(), tt.text, nil, nil, nil)
 
 			assertRepliesResult(t, got, err, tt.wantErr, tt.wantErrMsg, tt.wantCount)
 		})
@@ -434,7 +476,98 @@ func assertImageGenerationResult(t *testing.T, got *model.GenerateImageResult, e
 		t.Error("GenerateImage().ImageURL is empty")
 	}
 
+	if got.AltText != wantPrompt {
+		t.Errorf("GenerateImage().AltText = %v, want %v", got.AltText, wantPrompt)
+	}
+
 	if got.GeneratedAt == "" {
 		t.Error("GenerateImage().GeneratedAt is empty")
 	}
 }
+
+func TestMutationResolver_PostToTwitter_Images(t *testing.T) {
+	image1 := createImageDataURL([]byte("image-1"))
+	image2 := createImageDataURL([]byte("image-2"))
+
+	tests := []struct {
+		name        string
+		input       model.TwitterPostInput
+		wantSuccess bool
+		wantImages  int
+		wantErrMsg  string
+	}{
+		{
+			name: "posts legacy imageUrl together with images list",
+			input: model.TwitterPostInput{
+				Text:     "画像付き投稿",
+				ImageURL: &image1,
+				Images: []*model.PostImageInput{
+					{URL: &image2, AltText: stringPtr("炎上のイラスト")},
+				},
+			},
+			wantSuccess: true,
+			wantImages:  2,
+		},
+		{
+			name: "rejects more than four images",
+			input: model.TwitterPostInput{
+				Text: "画像付き投稿",
+				Images: []*model.PostImageInput{
+					{URL: &image1}, {URL: &image1}, {URL: &image1}, {URL: &image1}, {URL: &image1},
+				},
+			},
+			wantErrMsg: "画像は最大4枚までです",
+		},
+		{
+			name: "rejects alt text over 1000 characters",
+			input: model.TwitterPostInput{
+				Text:   "画像付き投稿",
+				Images: []*model.PostImageInput{{URL: &image1, AltText: stringPtr(strings.Repeat("a", 1001))}},
+			},
+			wantErrMsg: "代替テキスト",
+		},
+		{
+			name: "rejects images that are not data URLs",
+			input: model.TwitterPostInput{
+				Text:   "画像付き投稿",
+				Images: []*model.PostImageInput{{URL: stringPtr("https://example.com/a.png")}},
+			},
+			wantErrMsg: "画像データの取得に失敗しました",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posted []twitter.Image
+			mockClient := &MockTwitterClient{
+				PostTweetWithImagesFunc: func(_ context.Context, _ string, images []twitter.Image) (*twitter.TweetResult, error) {
+					posted = images
+					return &twitter.TweetResult{ID: "123", URL: "https://twitter.com/user/status/123"}, nil
+				},
+			}
+			resolver := &mutationResolver{&Resolver{twitterClient: mockClient}}
+
+			got, err := resolver.PostToTwitter(context.Background(), tt.input)
+			if err != nil {
+				t.Fatalf("PostToTwitter() unexpected error = %v", err)
+			}
+
+			if got.Success != tt.wantSuccess {
+				t.Fatalf("PostToTwitter().Success = %v, want %v (error: %v)", got.Success, tt.wantSuccess, got.ErrorMessage)
+			}
+			if !tt.wantSuccess {
+				if got.ErrorMessage == nil || !strings.Contains(*got.ErrorMessage, tt.wantErrMsg) {
+					t.Errorf("PostToTwitter().ErrorMessage = %v, want message containing %v", got.ErrorMessage, tt.wantErrMsg)
+				}
+				return
+			}
+
+			if len(posted) != tt.wantImages {
+				t.Fatalf("posted %d images, want %d", len(posted), tt.wantImages)
+			}
+			if posted[1].AltText != "炎上のイラスト" {
+				t.Errorf("second image alt text = %q, want %q", posted[1].AltText, "炎上のイラスト")
+			}
+		})
+	}
+}
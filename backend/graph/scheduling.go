@@ -0,0 +1,178 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/simulation"
+	"github.com/Tattsum/enjo/backend/twitter"
+)
+
+// errSchedulingDisabled is returned by scheduling resolvers when no post queue is configured
+var errSchedulingDisabled = errors.New("scheduled posting is not configured")
+
+// NewScheduledPostHandler returns a queue handler that publishes scheduled posts through the Twitter client.
+// Options supply the stores used to load images and record published tweets, and the linked accounts
+// posts are published from when WithTwitterAccounts is set.
+func NewScheduledPostHandler(twitterClient TwitterClient, options ...ResolverOption) queue.Handler {
+	r := &Resolver{twitterClient: twitterClient}
+	for _, opt := range options {
+		opt(r)
+	}
+	return r.publishScheduledPost
+}
+
+// NewJobHandler returns a queue handler that runs every kind of job: scheduled posts are published
+// as NewScheduledPostHandler does, and reply comparisons are classified with the Gemini client.
+func NewJobHandler(geminiClient GeminiClient, twitterClient TwitterClient, options ...ResolverOption) queue.Handler {
+	r := &Resolver{geminiClient: geminiClient, twitterClient: twitterClient}
+	for _, opt := range options {
+		opt(r)
+	}
+
+	return func(ctx context.Context, job *queue.Job) (*queue.Result, error) {
+		switch job.Kind {
+		case queue.KindPost:
+			return r.publishScheduledPost(ctx, job)
+		case queue.KindCompareReplies:
+			if _, err := r.compareReplies(ctx, job.OwnerID, job.TweetID); err != nil {
+				return nil, err
+			}
+			return &queue.Result{}, nil
+		default:
+			return nil, fmt.Errorf("unsupported job kind %q", job.Kind)
+		}
+	}
+}
+
+// publishScheduledPost publishes a scheduled post job. Failures before the tweet is sent, and
+// those the Twitter client reports as twitter.ErrNotPosted, are marked safe to retry.
+func (r *Resolver) publishScheduledPost(ctx context.Context, job *queue.Job) (*queue.Result, error) {
+	// Publish as the account of the user who scheduled the post
+	twitterClient, err := r.twitterForOwner(ctx, job.OwnerID)
+	if err != nil {
+		return nil, queue.SafeToRetry(err)
+	}
+
+	options := buildTweetOptions(job.AddHashtag, job.AddDisclaimer)
+
+	var result *twitter.TweetResult
+	if len(job.Images) > 0 {
+		images, extractErr := r.loadPostImages(ctx, job.OwnerID, fromQueueImages(job.Images))
+		if extractErr != nil {
+			return nil, queue.SafeToRetry(fmt.Errorf("failed to extract image data: %w", extractErr))
+		}
+		result, err = twitterClient.PostTweetWithImages(ctx, job.Text, images, options...)
+	} else {
+		result, err = twitterClient.PostTweet(ctx, job.Text, options...)
+	}
+	if errors.Is(err, twitter.ErrNotPosted) {
+		return nil, queue.SafeToRetry(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recordPostedTweet(ctx, r.simulations, simulation.Tweet{
+		TweetID:         result.ID,
+		TweetURL:        result.URL,
+		OwnerID:         job.OwnerID,
+		SimulationID:    job.SimulationID,
+		ScheduledPostID: job.ID,
+		Text:            job.Text,
+		Level:           job.Level,
+	})
+
+	return &queue.Result{TweetID: result.ID, TweetURL: result.URL}, nil
+}
+
+// buildTweetOptions converts the hashtag/disclaimer flags into tweet options
+func buildTweetOptions(addHashtag, addDisclaimer bool) []twitter.TweetOption {
+	var options []twitter.TweetOption
+	if addHashtag {
+		options = append(options, twitter.WithHashtag())
+	}
+	if addDisclaimer {
+		options = append(options, twitter.WithDisclaimer())
+	}
+	return options
+}
+
+// parseScheduledAt parses an RFC3339 timestamp and ensures it is in the future
+func parseScheduledAt(value string, now time.Time) (time.Time, error) {
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("scheduledAt must be an RFC3339 timestamp: %w", err)
+	}
+	if !at.After(now) {
+		return time.Time{}, errors.New("scheduledAt must be in the future")
+	}
+	return at, nil
+}
+
+// toScheduledPostModel converts a queue job into its GraphQL representation
+func toScheduledPostModel(job *queue.Job) *model.ScheduledPost {
+	history := make([]*model.ScheduledPostTransition, 0, len(job.History))
+	for _, h := range job.History {
+		history = append(history, &model.ScheduledPostTransition{
+			Status:  model.ScheduledPostStatus(h.Status),
+			At:      h.At.Format(time.RFC3339),
+			Message: optionalString(h.Message),
+		})
+	}
+
+	return &model.ScheduledPost{
+		ID:            job.ID,
+		Text:          job.Text,
+		HasImage:      len(job.Images) > 0,
+		ImageCount:    len(job.Images),
+		Level:         job.Level,
+		SimulationID:  optionalString(job.SimulationID),
+		AddHashtag:    job.AddHashtag,
+		AddDisclaimer: job.AddDisclaimer,
+		ScheduledAt:   job.ScheduledAt.Format(time.RFC3339),
+		Status:        model.ScheduledPostStatus(job.Status),
+		Attempts:      job.Attempts,
+		MaxAttempts:   job.MaxAttempts,
+		LastError:     optionalString(job.LastError),
+		TweetID:       optionalString(job.TweetID),
+		TweetURL:      optionalString(job.TweetURL),
+		CreatedAt:     job.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     job.UpdatedAt.Format(time.RFC3339),
+		History:       history,
+	}
+}
+
+// toQueueImages converts post images into their persisted queue form
+func toQueueImages(images []postImage) []queue.Image {
+	if len(images) == 0 {
+		return nil
+	}
+	converted := make([]queue.Image, 0, len(images))
+	for _, img := range images {
+		converted = append(converted, queue.Image{URL: img.URL, AltText: img.AltText})
+	}
+	return converted
+}
+
+// fromQueueImages converts persisted queue images back into post images
+func fromQueueImages(images []queue.Image) []postImage {
+	converted := make([]postImage, 0, len(images))
+	for _, img := range images {
+		converted = append(converted, postImage{URL: img.URL, AltText: img.AltText})
+	}
+	return converted
+}
+
+// toScheduledPostModels converts a list of queue jobs into their GraphQL representation
+func toScheduledPostModels(jobs []*queue.Job) []*model.ScheduledPost {
+	posts := make([]*model.ScheduledPost, 0, len(jobs))
+	for _, job := range jobs {
+		posts = append(posts, toScheduledPostModel(job))
+	}
+	return posts
+}
@@ -0,0 +1,284 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/store"
+	"github.com/Tattsum/enjo/backend/twitter"
+)
+
+func newSchedulingResolver(t *testing.T) *Resolver {
+	t.Helper()
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return NewResolver(nil, nil, nil, WithPostQueue(queue.New(db)), withLenientPolicy(t))
+}
+
+func TestScheduledPostHandler_Retries(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus queue.Status
+	}{
+		{"not posted", fmt.Errorf("rate limited: %w", twitter.ErrNotPosted), queue.StatusPending},
+		{"may have been posted", errors.New("timeout awaiting response"), queue.StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatalf("failed to open store: %v", err)
+			}
+			t.Cleanup(func() { db.Close() })
+			jobs := queue.New(db)
+			job, err := jobs.Enqueue(ctx, queue.Job{Text: "予約投稿", ScheduledAt: time.Now()})
+			if err != nil {
+				t.Fatalf("Enqueue() error = %v", err)
+			}
+
+			// Run the job through the worker, stopping it after the first attempt
+			workerCtx, stop := context.WithCancel(ctx)
+			handler := NewScheduledPostHandler(&MockTwitterClient{
+				PostTweetFunc: func(context.Context, string) (*twitter.TweetResult, error) {
+					return nil, tt.err
+				},
+			})
+			worker := queue.NewWorker(jobs, func(ctx context.Context, job *queue.Job) (*queue.Result, error) {
+				defer stop()
+				return handler(ctx, job)
+			}, time.Hour)
+			if err := worker.Run(workerCtx); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			got, err := jobs.Get(ctx, job.ID)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("Status = %v, want %v", got.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestMutationResolver_SchedulePost(t *testing.T) {
+	future := time.Now().Add(time.Hour).Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name       string
+		input      model.SchedulePostInput
+		wantErr    bool
+		wantErrMsg string
+	}{
+		{
+			name:  "successfully schedules a post",
+			input: model.SchedulePostInput{Text: "予約投稿", ScheduledAt: future, Level: intPtr(1)},
+		},
+		{
+			name:       "requires confirmation for high levels",
+			input:      model.SchedulePostInput{Text: "予約投稿", ScheduledAt: future, Level: intPtr(5)},
+			wantErr:    true,
+			wantErrMsg: "確認が必要です",
+		},
+		{
+			name:       "returns error when text is empty",
+			input:      model.SchedulePostInput{Text: "", ScheduledAt: future},
+			wantErr:    true,
+			wantErrMsg: "投稿内容が空です",
+		},
+		{
+			name:       "returns error when scheduled time is in the past",
+			input:      model.SchedulePostInput{Text: "予約投稿", ScheduledAt: past},
+			wantErr:    true,
+			wantErrMsg: "must be in the future",
+		},
+		{
+			name:       "returns error when scheduled time is malformed",
+			input:      model.SchedulePostInput{Text: "予約投稿", ScheduledAt: "tomorrow"},
+			wantErr:    true,
+			wantErrMsg: "RFC3339",
+		},
+		{
+			name:       "returns error when image is not a data URL",
+			input:      model.SchedulePostInput{Text: "予約投稿", ScheduledAt: future, ImageURL: stringPtr("https://example.com/a.png")},
+			wantErr:    true,
+			wantErrMsg: "invalid image",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &mutationResolver{newSchedulingResolver(t)}
+
+			got, err := resolver.SchedulePost(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SchedulePost() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.wantErrMsg) {
+					t.Errorf("SchedulePost() error = %v, want error containing %v", err, tt.wantErrMsg)
+				}
+				return
+			}
+
+			if got.ID == "" {
+				t.Error("SchedulePost() returned empty ID")
+			}
+			if got.Status != model.ScheduledPostStatusPending {
+				t.Errorf("SchedulePost().Status = %v, want PENDING", got.Status)
+			}
+			if len(got.History) != 1 {
+				t.Errorf("SchedulePost().History has %d entries, want 1", len(got.History))
+			}
+		})
+	}
+}
+
+func TestScheduledPostLifecycle(t *testing.T) {
+	ctx := context.Background()
+	r := newSchedulingResolver(t)
+	mutation := &mutationResolver{r}
+	query := &queryResolver{r}
+
+	scheduled, err := mutation.SchedulePost(ctx, model.SchedulePostInput{
+		Text:        "予約投稿",
+		Level:       intPtr(1),
+		ScheduledAt: time.Now().Add(time.Hour).Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("SchedulePost() error = %v", err)
+	}
+
+	newTime := time.Now().Add(2 * time.Hour).Format(time.RFC3339)
+	rescheduled, err := mutation.RescheduleScheduledPost(ctx, scheduled.ID, newTime)
+	if err != nil {
+		t.Fatalf("RescheduleScheduledPost() error = %v", err)
+	}
+	if rescheduled.ScheduledAt != newTime {
+		t.Errorf("ScheduledAt = %v, want %v", rescheduled.ScheduledAt, newTime)
+	}
+
+	cancelled, err := mutation.CancelScheduledPost(ctx, scheduled.ID)
+	if err != nil {
+		t.Fatalf("CancelScheduledPost() error = %v", err)
+	}
+	if cancelled.Status != model.ScheduledPostStatusCancelled {
+		t.Errorf("Status = %v, want CANCELLED", cancelled.Status)
+	}
+	if len(cancelled.History) != 3 {
+		t.Errorf("History has %d entries, want 3", len(cancelled.History))
+	}
+
+	status := model.ScheduledPostStatusPending
+	pending, err := query.ScheduledPosts(ctx, &status)
+	if err != nil {
+		t.Fatalf("ScheduledPosts() error = %v", err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("ScheduledPosts(PENDING) returned %d posts, want 0", len(pending))
+	}
+
+	got, err := query.ScheduledPost(ctx, scheduled.ID)
+	if err != nil {
+		t.Fatalf("ScheduledPost() error = %v", err)
+	}
+	if got == nil || got.Status != model.ScheduledPostStatusCancelled {
+		t.Errorf("ScheduledPost() = %+v, want cancelled post", got)
+	}
+
+	missing, err := query.ScheduledPost(ctx, "missing")
+	if err != nil || missing != nil {
+		t.Errorf("ScheduledPost(missing) = %v, %v, want nil, nil", missing, err)
+	}
+}
+
+func TestScheduling_DisabledWithoutQueue(t *testing.T) {
+	r := &Resolver{}
+
+	_, err := (&mutationResolver{r}).SchedulePost(context.Background(), model.SchedulePostInput{Text: "text"})
+	if !errors.Is(err, errSchedulingDisabled) {
+		t.Errorf("SchedulePost() error = %v, want errSchedulingDisabled", err)
+	}
+
+	_, err = (&queryResolver{r}).ScheduledPosts(context.Background(), nil)
+	if !errors.Is(err, errSchedulingDisabled) {
+		t.Errorf("ScheduledPosts() error = %v, want errSchedulingDisabled", err)
+	}
+}
+
+func TestNewScheduledPostHandler(t *testing.T) {
+	t.Run("posts text through the twitter client and records the tweet", func(t *testing.T) {
+		var postedText string
+		simulations := newTestSimulationStore(t)
+		handler := NewScheduledPostHandler(&MockTwitterClient{
+			PostTweetFunc: func(_ context.Context, text string) (*twitter.TweetResult, error) {
+				postedText = text
+				return &twitter.TweetResult{ID: "123", URL: "https://twitter.com/user/status/123"}, nil
+			},
+		}, WithSimulationStore(simulations))
+
+		result, err := handler(context.Background(), &queue.Job{ID: "job-1", Text: "予約投稿", SimulationID: "sim-1"})
+		if err != nil {
+			t.Fatalf("handler error = %v", err)
+		}
+		if postedText != "予約投稿" {
+			t.Errorf("posted text = %q, want %q", postedText, "予約投稿")
+		}
+		if result.TweetID != "123" {
+			t.Errorf("TweetID = %q, want %q", result.TweetID, "123")
+		}
+
+		recorded, err := simulations.GetTweet(context.Background(), "123")
+		if err != nil {
+			t.Fatalf("GetTweet() error = %v", err)
+		}
+		if recorded.SimulationID != "sim-1" || recorded.ScheduledPostID != "job-1" {
+			t.Errorf("recorded tweet = %+v, want simulation sim-1 and scheduled post job-1", recorded)
+		}
+	})
+
+	t.Run("posts images with alt text when job has them", func(t *testing.T) {
+		var posted []twitter.Image
+		handler := NewScheduledPostHandler(&MockTwitterClient{
+			PostTweetWithImagesFunc: func(_ context.Context, _ string, images []twitter.Image) (*twitter.TweetResult, error) {
+				posted = images
+				return &twitter.TweetResult{ID: "456"}, nil
+			},
+		})
+
+		job := &queue.Job{Text: "画像付き", Images: []queue.Image{
+			{URL: createImageDataURL([]byte("png-1")), AltText: "炎のイラスト"},
+			{URL: createImageDataURL([]byte("png-2"))},
+		}}
+		if _, err := handler(context.Background(), job); err != nil {
+			t.Fatalf("handler error = %v", err)
+		}
+		if len(posted) != 2 || string(posted[0].Data) != "png-1" || posted[0].AltText != "炎のイラスト" {
+			t.Errorf("posted images = %+v, want two images with alt text on the first", posted)
+		}
+	})
+
+	t.Run("returns error when twitter client is missing", func(t *testing.T) {
+		handler := NewScheduledPostHandler(nil)
+		if _, err := handler(context.Background(), &queue.Job{Text: "text"}); err == nil {
+			t.Fatal("expected error when twitter client is nil")
+		}
+	})
+}
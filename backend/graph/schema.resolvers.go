@@ -6,10 +6,18 @@ package graph
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	"github.com/Tattsum/enjo/backend/graph/generated"
 	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+	"github.com/Tattsum/enjo/backend/simulation"
 	"github.com/Tattsum/enjo/backend/twitter"
 )
 
@@ -19,47 +27,85 @@ func (r *mutationResolver) GenerateInflammatoryText(ctx context.Context, input m
 	if input.Level < 1 || input.Level > 5 {
 		return nil, fmt.Errorf("level must be between 1 and 5, got %d", input.Level)
 	}
+	// The conversion and the explanation are a Gemini call each; describing the image charges its own
+	if err := r.allow(ctx, ratelimit.OpText, 2); err != nil {
+		return nil, err
+	}
+	ctx, cacheReport := withCacheReport(ctx, input.BypassCache)
+
+	// Describe the attached image so that it is factored into the conversion and explanation
+	var imageDescription string
+	if input.ImageID != nil && *input.ImageID != "" {
+		description, err := r.imageDescription(ctx, *input.ImageID)
+		if err != nil {
+			return nil, err
+		}
+		imageDescription = description
+	}
+	original := withImageContext(input.OriginalText, imageDescription)
 
 	// Generate inflammatory text
-	inflammatoryText, err := r.geminiClient.GenerateInflammatoryText(ctx, input.OriginalText, input.Level)
+	inflammatoryText, err := r.geminiClient.GenerateInflammatoryText(ctx, original, input.Level)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate inflammatory text: %w", err)
 	}
 
 	// Generate explanation
-	explanation, err := r.geminiClient.GenerateExplanation(ctx, input.OriginalText, inflammatoryText)
+	explanation, err := r.geminiClient.GenerateExplanation(ctx, original, inflammatoryText)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate explanation: %w", err)
 	}
 
-	return &model.GenerateResult{
+	result := &model.GenerateResult{
 		InflammatoryText: inflammatoryText,
 		Explanation:      &explanation,
-	}, nil
+		Cache:            toCacheReportModel(cacheReport),
+	}
+
+	// Persist the simulation so posted tweets and replies can be linked to it later
+	if r.simulations != nil {
+		sim, err := r.simulations.Create(ctx, simulation.Simulation{
+			OwnerID:          currentOwner(ctx),
+			OriginalText:     input.OriginalText,
+			InflammatoryText: inflammatoryText,
+			Explanation:      explanation,
+			Level:            input.Level,
+			ImageID:          imageIDFromReference(stringValue(input.ImageID)),
+			ImageDescription: imageDescription,
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "failed to store simulation", "error", err)
+		} else {
+			result.SimulationID = &sim.ID
+		}
+	}
+
+	return result, nil
 }
 
 // GenerateReplies is the resolver for the generateReplies field.
-func (r *mutationResolver) GenerateReplies(ctx context.Context, text string) ([]*model.Reply, error) {
+func (r *mutationResolver) GenerateReplies(ctx context.Context, text string, simulationID *string, imageID *string, bypassCache *bool) ([]*model.Reply, error) {
 	// Validate input
 	if text == "" {
 		return nil, fmt.Errorf("text is required")
 	}
-
-	// Define reply types to generate
-	replyTypes := []struct {
-		Type        model.ReplyType
-		Description string
-	}{
-		{model.ReplyTypeLogicalCriticism, "正論で批判するタイプ"},
-		{model.ReplyTypeNitpicking, "揚げ足を取るタイプ"},
-		{model.ReplyTypeOffTarget, "的外れな批判"},
-		{model.ReplyTypeExcessiveDefense, "過剰に擁護するタイプ"},
+	if _, err := r.lookupSimulation(ctx, simulationID); err != nil {
+		return nil, err
+	}
+	if err := r.allow(ctx, ratelimit.OpText, len(replyPersonas)); err != nil {
+		return nil, err
+	}
+	imageDescription, err := r.replyImageDescription(ctx, simulationID, imageID)
+	if err != nil {
+		return nil, err
 	}
+	post := withImageContext(text, imageDescription)
 
-	// Generate replies for each type
-	replies := make([]*model.Reply, 0, len(replyTypes))
-	for i, rt := range replyTypes {
-		content, err := r.geminiClient.GenerateReply(ctx, text, rt.Description)
+	// Generate replies for each persona
+	replies := make([]*model.Reply, 0, len(replyPersonas))
+	for i, rt := range replyPersonas {
+		replyCtx, cacheReport := withCacheReport(ctx, bypassCache)
+		content, err := r.geminiClient.GenerateReply(replyCtx, post, rt.Description)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate reply for type %s: %w", rt.Type, err)
 		}
@@ -68,66 +114,103 @@ func (r *mutationResolver) GenerateReplies(ctx context.Context, text string) ([]
 			ID:      fmt.Sprintf("%d", i+1),
 			Type:    rt.Type,
 			Content: content,
+			Cached:  replyCached(cacheReport),
 		})
 	}
 
+	// Attach the replies to the simulation they were predicted for
+	if simulationID != nil && *simulationID != "" {
+		if _, err := r.simulations.SetReplies(ctx, *simulationID, toSimulationReplies(replies)); err != nil {
+			return nil, fmt.Errorf("failed to store replies: %w", err)
+		}
+	}
+
 	return replies, nil
 }
 
 // PostToTwitter is the resolver for the postToTwitter field.
 func (r *mutationResolver) PostToTwitter(ctx context.Context, input model.TwitterPostInput) (*model.TwitterPostResult, error) {
-	// Check if Twitter client is configured
-	if r.twitterClient == nil {
+	// Post as the user's linked account, or the shared account when no user is authenticated
+	twitterClient, err := r.twitterFor(ctx)
+	if err != nil {
 		return &model.TwitterPostResult{
 			Success:      false,
-			ErrorMessage: stringPtr("Twitter API が設定されていません。環境変数を確認してください。"),
+			ErrorMessage: stringPtr(twitterUnavailableMessage(err)),
 		}, nil
 	}
 
 	// Validate input
-	if input.Text == "" {
+	if err := validatePostText(input.Text); err != nil {
 		return &model.TwitterPostResult{
 			Success:      false,
-			ErrorMessage: stringPtr("投稿内容が空です"),
+			ErrorMessage: stringPtr(err.Error()),
 		}, nil
 	}
 
-	// Check character limit (considering runes for proper Unicode counting)
-	if len([]rune(input.Text)) > 280 {
+	// Collect attached images (legacy imageUrl plus the images list)
+	images, err := collectPostImages(input.ImageURL, input.Images)
+	if err != nil {
 		return &model.TwitterPostResult{
 			Success:      false,
-			ErrorMessage: stringPtr("投稿内容が280文字を超えています"),
+			ErrorMessage: stringPtr(err.Error()),
 		}, nil
 	}
 
-	// Build tweet options
-	var options []twitter.TweetOption
-	if input.AddHashtag != nil && *input.AddHashtag {
-		options = append(options, twitter.WithHashtag())
+	sim, err := r.postSimulation(ctx, input.SimulationID, input.Text)
+	if err != nil {
+		return &model.TwitterPostResult{
+			Success:      false,
+			ErrorMessage: stringPtr(err.Error()),
+		}, nil
+	}
+
+	// Load image data from data URLs or the image store
+	decoded, err := r.loadPostImages(ctx, currentOwner(ctx), images)
+	if err != nil {
+		return &model.TwitterPostResult{
+			Success:      false,
+			ErrorMessage: stringPtr(fmt.Sprintf("画像データの取得に失敗しました: %v", err)),
+		}, nil
+	}
+
+	// Apply the posting policy (forced disclaimer/hashtag, moderation, confirmation)
+	postRequest := PostRequest{
+		Text:              input.Text,
+		Level:             input.Level,
+		KnownLevel:        simulationLevel(sim),
+		AddHashtag:        boolValue(input.AddHashtag),
+		AddDisclaimer:     boolValue(input.AddDisclaimer),
+		ConfirmationToken: stringValue(input.ConfirmationToken),
+		Images:            imageBytes(decoded),
 	}
-	if input.AddDisclaimer != nil && *input.AddDisclaimer {
-		options = append(options, twitter.WithDisclaimer())
+	if err := r.allowModeration(ctx, postRequest); err != nil {
+		return nil, err
+	}
+	decision, err := r.evaluatePostingPolicy(ctx, postRequest)
+	if err != nil {
+		return &model.TwitterPostResult{
+			Success:      false,
+			ErrorMessage: stringPtr(err.Error()),
+		}, nil
+	}
+	if decision.Action != PolicyActionAllow {
+		return policyRejectedResult(decision), nil
+	}
+	// Only posts that will be published count against the posting limit
+	if err := r.allow(ctx, ratelimit.OpPost, 1); err != nil {
+		return nil, err
 	}
 
-	// Check if image URL is provided
-	var result *twitter.TweetResult
-	var err error
-
-	if input.ImageURL != nil && *input.ImageURL != "" {
-		// Extract image data from data URL
-		imageData, extractErr := extractImageDataFromURL(*input.ImageURL)
-		if extractErr != nil {
-			return &model.TwitterPostResult{
-				Success:      false,
-				ErrorMessage: stringPtr(fmt.Sprintf("画像データの取得に失敗しました: %v", extractErr)),
-			}, nil
-		}
+	// Build tweet options
+	options := buildTweetOptions(decision.AddHashtag, decision.AddDisclaimer)
 
-		// Post with image
-		result, err = r.twitterClient.PostTweetWithImage(ctx, input.Text, imageData, options...)
+	var result *twitter.TweetResult
+	if len(decoded) > 0 {
+		// Post with images
+		result, err = twitterClient.PostTweetWithImages(ctx, input.Text, decoded, options...)
 	} else {
 		// Post without image
-		result, err = r.twitterClient.PostTweet(ctx, input.Text, options...)
+		result, err = twitterClient.PostTweet(ctx, input.Text, options...)
 	}
 
 	if err != nil {
@@ -137,10 +220,20 @@ func (r *mutationResolver) PostToTwitter(ctx context.Context, input model.Twitte
 		}, nil
 	}
 
+	recordPostedTweet(ctx, r.simulations, simulation.Tweet{
+		TweetID:      result.ID,
+		TweetURL:     result.URL,
+		OwnerID:      currentOwner(ctx),
+		SimulationID: stringValue(input.SimulationID),
+		Text:         input.Text,
+		Level:        decision.Level,
+	})
+
 	return &model.TwitterPostResult{
 		Success:  true,
 		TweetID:  &result.ID,
 		TweetURL: &result.URL,
+		Policy:   toPolicyDecisionModel(decision),
 	}, nil
 }
 
@@ -156,36 +249,575 @@ func (r *mutationResolver) GenerateImage(ctx context.Context, input model.Genera
 	if input.OriginalText != nil && *input.OriginalText != "" {
 		textForPrompt = *input.OriginalText
 	}
+	sampleCount, err := parseSampleCount(input.SampleCount)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := parseImageSeed(input.Seed)
+	if err != nil {
+		return nil, err
+	}
+	// Writing the image prompt is a Gemini call before the images are generated, and each image
+	// is moderated with another when image moderation is on
+	if err := r.allow(ctx, ratelimit.OpText, 1+r.imageModerationCalls(sampleCount)); err != nil {
+		return nil, err
+	}
+	if err := r.allow(ctx, ratelimit.OpImage, sampleCount); err != nil {
+		return nil, err
+	}
+	ctx, cacheReport := withCacheReport(ctx, input.BypassCache)
+
+	// Write the image prompt for the post in the requested style
+	style := imageStyleName(input.Style)
+	aspectRatio := imageAspectRatio(input.AspectRatio)
+	imagePrompt, err := r.writeImagePrompt(ctx, textForPrompt, style, aspectRatio, input.SanitizePrompt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the variants using Imagen and store each of them
+	result, err := r.generateImageVariants(ctx, imageGeneration{
+		prompt:           imagePrompt.Prompt,
+		negativePrompt:   stringValue(input.NegativePrompt),
+		style:            style,
+		aspectRatio:      aspectRatio,
+		seed:             seed,
+		sampleCount:      sampleCount,
+		model:            stringValue(input.Model),
+		imageSize:        stringValue(input.ImageSize),
+		personGeneration: personGenerationValue(input.PersonGeneration),
+		safetySetting:    safetySettingValue(input.SafetySetting),
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.PromptReasoning = optionalString(imagePrompt.Reasoning)
+	result.Cache = toCacheReportModel(cacheReport)
+	return result, nil
+}
+
+// SchedulePost is the resolver for the schedulePost field.
+func (r *mutationResolver) SchedulePost(ctx context.Context, input model.SchedulePostInput) (*model.ScheduledPost, error) {
+	if r.postQueue == nil {
+		return nil, errSchedulingDisabled
+	}
+
+	// Validate input
+	if err := validatePostText(input.Text); err != nil {
+		return nil, err
+	}
+	scheduledAt, err := parseScheduledAt(input.ScheduledAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := collectPostImages(input.ImageURL, input.Images)
+	if err != nil {
+		return nil, err
+	}
+	// Reject unloadable images now rather than when the worker picks the job up
+	decoded, err := r.loadPostImages(ctx, currentOwner(ctx), images)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	sim, err := r.postSimulation(ctx, input.SimulationID, input.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	// Apply the posting policy up front so the worker only publishes approved posts
+	postRequest := PostRequest{
+		Text:              input.Text,
+		Level:             input.Level,
+		KnownLevel:        simulationLevel(sim),
+		AddHashtag:        boolValue(input.AddHashtag),
+		AddDisclaimer:     boolValue(input.AddDisclaimer),
+		ConfirmationToken: stringValue(input.ConfirmationToken),
+		Images:            imageBytes(decoded),
+	}
+	if err := r.allowModeration(ctx, postRequest); err != nil {
+		return nil, err
+	}
+	decision, err := r.evaluatePostingPolicy(ctx, postRequest)
+	if err != nil {
+		return nil, err
+	}
+	if decision.Action != PolicyActionAllow {
+		return nil, policyRejectedError(decision)
+	}
+	// Only posts that will be queued count against the posting limit
+	if err := r.allow(ctx, ratelimit.OpPost, 1); err != nil {
+		return nil, err
+	}
+
+	// Keep only image IDs in the queue rather than whole data URLs
+	images, err = r.persistInlineImages(ctx, images)
+	if err != nil {
+		return nil, err
+	}
+
+	job := queue.Job{
+		OwnerID:       currentOwner(ctx),
+		Text:          input.Text,
+		Images:        toQueueImages(images),
+		Level:         decision.Level,
+		SimulationID:  stringValue(input.SimulationID),
+		AddHashtag:    decision.AddHashtag,
+		AddDisclaimer: decision.AddDisclaimer,
+		ScheduledAt:   scheduledAt,
+	}
+
+	enqueued, err := r.postQueue.Enqueue(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to schedule post: %w", err)
+	}
+
+	return toScheduledPostModel(enqueued), nil
+}
+
+// CancelScheduledPost is the resolver for the cancelScheduledPost field.
+func (r *mutationResolver) CancelScheduledPost(ctx context.Context, id string) (*model.ScheduledPost, error) {
+	if r.postQueue == nil {
+		return nil, errSchedulingDisabled
+	}
+
+	if _, err := r.getScheduledJob(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to cancel scheduled post: %w", err)
+	}
+	job, err := r.postQueue.Cancel(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel scheduled post: %w", err)
+	}
 
-	// Generate image prompt using Gemini
-	imagePrompt, err := generateImagePromptFromText(ctx, r.geminiClient, textForPrompt)
+	return toScheduledPostModel(job), nil
+}
+
+// RescheduleScheduledPost is the resolver for the rescheduleScheduledPost field.
+func (r *mutationResolver) RescheduleScheduledPost(ctx context.Context, id string, scheduledAt string) (*model.ScheduledPost, error) {
+	if r.postQueue == nil {
+		return nil, errSchedulingDisabled
+	}
+
+	at, err := parseScheduledAt(scheduledAt, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.getScheduledJob(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to reschedule post: %w", err)
+	}
+	job, err := r.postQueue.Reschedule(ctx, id, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reschedule post: %w", err)
+	}
+
+	return toScheduledPostModel(job), nil
+}
+
+// DeleteTweet is the resolver for the deleteTweet field.
+func (r *mutationResolver) DeleteTweet(ctx context.Context, tweetID string) (*model.PostedTweet, error) {
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+	twitterClient, err := r.twitterFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only tweets published through the simulator can be deleted
+	tweet, err := r.getPostedTweet(ctx, tweetID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate image prompt: %w", err)
+		return nil, err
+	}
+	if tweet.Status == simulation.TweetStatusDeleted {
+		return toPostedTweetModel(tweet), nil
+	}
+
+	// A tweet that is already gone on Twitter is recorded as deleted as well
+	if err := twitterClient.DeleteTweet(ctx, tweetID); err != nil && !errors.Is(err, twitter.ErrTweetNotFound) {
+		return nil, fmt.Errorf("failed to delete tweet: %w", err)
 	}
 
-	// Generate image using Imagen
-	imageData, err := r.imageClient.GenerateImage(ctx, imagePrompt)
+	deleted, err := r.simulations.MarkDeleted(ctx, tweetID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate image: %w", err)
+		return nil, fmt.Errorf("failed to record deleted tweet: %w", err)
 	}
+	return toPostedTweetModel(deleted), nil
+}
 
-	// For now, we'll encode the image data as base64 and return it as a data URL
-	// In production, you would upload to GCS and return a proper URL
-	imageURL := createImageDataURL(imageData)
+// RefreshTweetMetrics is the resolver for the refreshTweetMetrics field.
+func (r *mutationResolver) RefreshTweetMetrics(ctx context.Context, tweetID string) (*model.PostedTweet, error) {
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+	twitterClient, err := r.twitterFor(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	// Get current timestamp
-	generatedAt := getCurrentTimestamp()
+	tweet, err := r.getPostedTweet(ctx, tweetID)
+	if err != nil {
+		return nil, err
+	}
+	if tweet.Status == simulation.TweetStatusDeleted {
+		return toPostedTweetModel(tweet), nil
+	}
+
+	metrics, err := twitterClient.GetTweetMetrics(ctx, tweetID)
+	if errors.Is(err, twitter.ErrTweetNotFound) {
+		tweet, err = r.simulations.MarkUnavailable(ctx, tweetID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to update tweet status: %w", err)
+		}
+		return toPostedTweetModel(tweet), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tweet metrics: %w", err)
+	}
 
+	tweet, err = r.simulations.UpdateMetrics(ctx, tweetID, fromTweetMetrics(metrics))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store tweet metrics: %w", err)
+	}
+	return toPostedTweetModel(tweet), nil
+}
+
+// CompareReplies is the resolver for the compareReplies field.
+func (r *mutationResolver) CompareReplies(ctx context.Context, tweetID string) (*model.ReplyComparisonJob, error) {
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+	if r.postQueue == nil {
+		return nil, errComparisonQueueDisabled
+	}
+
+	// Reject what the job would fail on before queueing it
+	if _, err := r.twitterFor(ctx); err != nil {
+		return nil, err
+	}
+	owner := currentOwner(ctx)
+	if _, _, err := r.comparisonSource(ctx, owner, tweetID); err != nil {
+		return nil, err
+	}
+
+	// A comparison still waiting for the tweet answers this request too
+	latest, err := r.latestComparisonJob(ctx, tweetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reply comparisons: %w", err)
+	}
+	if latest != nil && (latest.Status == queue.StatusPending || latest.Status == queue.StatusRunning) {
+		return toReplyComparisonJobModel(latest, nil), nil
+	}
+
+	if err := r.allow(ctx, ratelimit.OpText, 1); err != nil {
+		return nil, err
+	}
+	job, err := r.postQueue.Enqueue(ctx, queue.Job{
+		Kind:        queue.KindCompareReplies,
+		OwnerID:     owner,
+		TweetID:     tweetID,
+		ScheduledAt: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue reply comparison: %w", err)
+	}
+
+	return toReplyComparisonJobModel(job, nil), nil
+}
+
+// VaryImage is the resolver for the varyImage field.
+func (r *mutationResolver) VaryImage(ctx context.Context, input model.VaryImageInput) (*model.GenerateImageResult, error) {
+	if r.imageStore == nil || r.imageCatalog == nil {
+		return nil, errImageVariationsDisabled
+	}
+
+	id := imageIDFromReference(input.ImageID)
+	record, err := r.getImageRecord(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load image %s: %w", id, err)
+	}
+
+	gen, err := imageVariation(record, input)
+	if err != nil {
+		return nil, err
+	}
+	if calls := r.imageModerationCalls(gen.sampleCount); calls > 0 {
+		if err := r.allow(ctx, ratelimit.OpText, calls); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.allow(ctx, ratelimit.OpImage, gen.sampleCount); err != nil {
+		return nil, err
+	}
+	ctx, cacheReport := withCacheReport(ctx, input.BypassCache)
+	result, err := r.generateImageVariants(ctx, gen)
+	if err != nil {
+		return nil, err
+	}
+	result.Cache = toCacheReportModel(cacheReport)
+	return result, nil
+}
+
+// OverlayImage is the resolver for the overlayImage field.
+func (r *mutationResolver) OverlayImage(ctx context.Context, input model.ImageOverlayInput) (*model.GenerateImageResult, error) {
+	if r.overlays == nil {
+		return nil, errOverlayDisabled
+	}
+
+	src, parent, err := r.overlaySource(ctx, input.ImageID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	spec := toOverlaySpec(input)
+	rendered, err := r.overlays.Render(src, spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render overlay: %w", err)
+	}
+
+	// The overlay keeps the source's generation settings so it can be varied like its parent
+	var record imagestore.Record
+	if parent.Prompt != "" {
+		record = imageGeneration{
+			prompt:           parent.Prompt,
+			negativePrompt:   parent.NegativePrompt,
+			style:            parent.Style,
+			aspectRatio:      parent.AspectRatio,
+			seed:             parent.Seed,
+			parentID:         parent.ID,
+			model:            parent.Model,
+			imageSize:        parent.ImageSize,
+			personGeneration: parent.PersonGeneration,
+			safetySetting:    parent.SafetySetting,
+		}.record()
+	}
+	imageID, imageURL, err := r.storeImage(ctx, rendered, record)
+	if err != nil {
+		return nil, err
+	}
+
+	variant := &model.GeneratedImage{
+		ImageID:        optionalString(imageID),
+		ImageURL:       imageURL,
+		Seed:           seedValue(parent.Seed),
+		NegativePrompt: optionalString(parent.NegativePrompt),
+		ParentImageID:  optionalString(parent.ID),
+	}
 	return &model.GenerateImageResult{
-		ImageURL:    imageURL,
-		Prompt:      imagePrompt,
-		GeneratedAt: generatedAt,
+		ImageID:     variant.ImageID,
+		ImageURL:    variant.ImageURL,
+		Prompt:      parent.Prompt,
+		AltText:     overlayAltText(spec, parent.Prompt),
+		GeneratedAt: getCurrentTimestamp(),
+		Variants:    []*model.GeneratedImage{variant},
+	}, nil
+}
+
+// RenderScreenshot is the resolver for the renderScreenshot field.
+func (r *mutationResolver) RenderScreenshot(ctx context.Context, simulationID string, theme *model.ScreenshotTheme, imageID *string) (*model.Screenshot, error) {
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+	if r.screenshots == nil {
+		return nil, errScreenshotsDisabled
+	}
+
+	sim, err := r.getSimulation(ctx, simulationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get simulation: %w", err)
+	}
+	if (imageID == nil || *imageID == "") && sim.ImageID != "" {
+		imageID = &sim.ImageID
+	}
+	var attached []byte
+	if imageID != nil && *imageID != "" {
+		if attached, err = r.loadImage(ctx, *imageID); err != nil {
+			return nil, fmt.Errorf("invalid image: %w", err)
+		}
+	}
+
+	selected := screenshotTheme(theme)
+	rendered, err := r.screenshots.Render(toScreenshotPost(sim, attached), toScreenshotTheme(selected))
+	if err != nil {
+		return nil, fmt.Errorf("failed to render screenshot: %w", err)
+	}
+	id, url, err := r.storeImage(ctx, rendered, imagestore.Record{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Screenshot{
+		SimulationID: sim.ID,
+		ImageID:      optionalString(id),
+		ImageURL:     url,
+		Theme:        selected,
+		GeneratedAt:  getCurrentTimestamp(),
 	}, nil
 }
 
+// ConnectTwitterAccount is the resolver for the connectTwitterAccount field.
+func (r *mutationResolver) ConnectTwitterAccount(ctx context.Context) (*model.TwitterAuthorization, error) {
+	return r.connectTwitterAccount(ctx)
+}
+
+// DisconnectTwitterAccount is the resolver for the disconnectTwitterAccount field.
+func (r *mutationResolver) DisconnectTwitterAccount(ctx context.Context) (bool, error) {
+	return r.disconnectTwitterAccount(ctx)
+}
+
+// UploadImage is the resolver for the uploadImage field.
+func (r *mutationResolver) UploadImage(ctx context.Context, file graphql.Upload, simulationID *string) (*model.UploadedImage, error) {
+	return r.uploadImage(ctx, file, simulationID)
+}
+
 // Health is the resolver for the health field.
 func (r *queryResolver) Health(ctx context.Context) (string, error) {
-	return "OK", nil
+	return r.healthStatus(ctx), nil
+}
+
+// HealthReport is the resolver for the healthReport field.
+func (r *queryResolver) HealthReport(ctx context.Context) (*model.HealthReport, error) {
+	return r.healthReport(ctx), nil
+}
+
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
+	return currentUser(ctx), nil
+}
+
+// TwitterAccount is the resolver for the twitterAccount field.
+func (r *queryResolver) TwitterAccount(ctx context.Context) (*model.TwitterAccount, error) {
+	return r.linkedTwitterAccount(ctx)
+}
+
+// Quota is the resolver for the quota field.
+func (r *queryResolver) Quota(ctx context.Context) (*model.Quota, error) {
+	return r.quota(ctx), nil
+}
+
+// ScheduledPosts is the resolver for the scheduledPosts field.
+func (r *queryResolver) ScheduledPosts(ctx context.Context, status *model.ScheduledPostStatus) ([]*model.ScheduledPost, error) {
+	if r.postQueue == nil {
+		return nil, errSchedulingDisabled
+	}
+
+	var filter *queue.Status
+	if status != nil {
+		s := queue.Status(*status)
+		filter = &s
+	}
+
+	jobs, err := r.listScheduledJobs(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scheduled posts: %w", err)
+	}
+
+	return toScheduledPostModels(jobs), nil
+}
+
+// ScheduledPost is the resolver for the scheduledPost field.
+func (r *queryResolver) ScheduledPost(ctx context.Context, id string) (*model.ScheduledPost, error) {
+	if r.postQueue == nil {
+		return nil, errSchedulingDisabled
+	}
+
+	job, err := r.getScheduledJob(ctx, id)
+	if errors.Is(err, queue.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get scheduled post: %w", err)
+	}
+
+	return toScheduledPostModel(job), nil
+}
+
+// Simulation is the resolver for the simulation field.
+func (r *queryResolver) Simulation(ctx context.Context, id string) (*model.Simulation, error) {
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+
+	sim, err := r.getSimulation(ctx, id)
+	if errors.Is(err, simulation.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get simulation: %w", err)
+	}
+
+	tweets, err := r.listPostedTweets(ctx, sim.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list posted tweets: %w", err)
+	}
+
+	return toSimulationModel(sim, tweets), nil
+}
+
+// PostedTweets is the resolver for the postedTweets field.
+func (r *queryResolver) PostedTweets(ctx context.Context, simulationID *string) ([]*model.PostedTweet, error) {
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+
+	tweets, err := r.listPostedTweets(ctx, stringValue(simulationID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list posted tweets: %w", err)
+	}
+
+	return toPostedTweetModels(tweets), nil
+}
+
+// PostedTweet is the resolver for the postedTweet field.
+func (r *queryResolver) PostedTweet(ctx context.Context, tweetID string) (*model.PostedTweet, error) {
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+
+	tweet, err := r.getPostedTweet(ctx, tweetID)
+	if errors.Is(err, simulation.ErrTweetNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get posted tweet: %w", err)
+	}
+
+	return toPostedTweetModel(tweet), nil
+}
+
+// ReplyComparison is the resolver for the replyComparison field.
+func (r *queryResolver) ReplyComparison(ctx context.Context, tweetID string) (*model.ReplyComparisonJob, error) {
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+	if r.postQueue == nil {
+		return nil, errComparisonQueueDisabled
+	}
+
+	// Comparisons are visible to the owner of the tweet they were made for
+	if _, err := r.getPostedTweet(ctx, tweetID); err != nil {
+		if errors.Is(err, simulation.ErrTweetNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get posted tweet: %w", err)
+	}
+
+	job, err := r.latestComparisonJob(ctx, tweetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reply comparisons: %w", err)
+	}
+	if job == nil {
+		return nil, nil
+	}
+	if job.Status != queue.StatusSucceeded {
+		return toReplyComparisonJobModel(job, nil), nil
+	}
+
+	comparison, err := r.simulations.GetComparison(ctx, tweetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reply comparison: %w", err)
+	}
+
+	return toReplyComparisonJobModel(job, comparison), nil
 }
 
 // Mutation returns generated.MutationResolver implementation.
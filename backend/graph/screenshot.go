@@ -0,0 +1,58 @@
+package graph
+
+import (
+	"errors"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/screenshot"
+	"github.com/Tattsum/enjo/backend/simulation"
+)
+
+// errScreenshotsDisabled is returned by renderScreenshot when no screenshot renderer is configured
+var errScreenshotsDisabled = errors.New("screenshots are not configured")
+
+// screenshotAuthor is the fake account the simulated post is shown under
+var screenshotAuthor = screenshot.Account{DisplayName: "あなた", Handle: "you"}
+
+// toScreenshotPost builds the mock-up of a simulation: its inflammatory text and the replies in persona order
+func toScreenshotPost(sim *simulation.Simulation, image []byte) screenshot.Post {
+	post := screenshot.Post{
+		Author:   screenshotAuthor,
+		Text:     sim.InflammatoryText,
+		Image:    image,
+		PostedAt: sim.CreatedAt,
+	}
+	for _, reply := range sim.Replies {
+		post.Replies = append(post.Replies, screenshot.Reply{
+			Author: personaAccount(reply.Type),
+			Text:   reply.Content,
+		})
+	}
+	return post
+}
+
+// personaAccount returns the fake account of a reply type
+func personaAccount(replyType string) screenshot.Account {
+	for _, persona := range replyPersonas {
+		if string(persona.Type) == replyType {
+			return screenshot.Account{DisplayName: persona.DisplayName, Handle: persona.Handle}
+		}
+	}
+	return screenshot.Account{DisplayName: "名無しさん", Handle: "anonymous"}
+}
+
+// screenshotTheme returns the requested theme, light by default
+func screenshotTheme(theme *model.ScreenshotTheme) model.ScreenshotTheme {
+	if theme == nil {
+		return model.ScreenshotThemeLight
+	}
+	return *theme
+}
+
+// toScreenshotTheme converts the GraphQL theme to the renderer's theme
+func toScreenshotTheme(theme model.ScreenshotTheme) screenshot.Theme {
+	if theme == model.ScreenshotThemeDark {
+		return screenshot.ThemeDark
+	}
+	return screenshot.ThemeLight
+}
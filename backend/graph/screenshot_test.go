@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/simulation"
+)
+
+func TestMutationResolver_RenderScreenshot(t *testing.T) {
+	ctx := context.Background()
+	simulations := newTestSimulationStore(t)
+	withImages, images := newTestImageStoreOption(t)
+
+	sim, err := simulations.Create(ctx, simulation.Simulation{
+		OriginalText:     "会議が長い",
+		InflammatoryText: "会議が長い会社は全部潰れればいい",
+		Level:            3,
+		Replies: []simulation.Reply{
+			{ID: "r1", Type: string(model.ReplyTypeLogicalCriticism), Content: "会議にも意味はあります"},
+			{ID: "r2", Type: string(model.ReplyTypeExcessiveDefense), Content: "わかる！"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	r := NewResolver(nil, nil, nil, WithSimulationStore(simulations), withImages)
+	mutation := &mutationResolver{r}
+
+	dark := model.ScreenshotThemeDark
+	attached := createImageDataURL(testPNG(t))
+	got, err := mutation.RenderScreenshot(ctx, sim.ID, &dark, &attached)
+	if err != nil {
+		t.Fatalf("RenderScreenshot() error = %v", err)
+	}
+	if got.SimulationID != sim.ID || got.Theme != model.ScreenshotThemeDark || got.ImageID == nil {
+		t.Fatalf("RenderScreenshot() = %+v, want a stored dark screenshot of the simulation", got)
+	}
+
+	data, err := images.Get(ctx, *got.ImageID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if _, err := png.DecodeConfig(bytes.NewReader(data)); err != nil {
+		t.Errorf("stored screenshot is not a PNG: %v", err)
+	}
+
+	if _, err := mutation.RenderScreenshot(ctx, "missing", nil, nil); !errors.Is(err, simulation.ErrNotFound) {
+		t.Errorf("RenderScreenshot(missing) error = %v, want simulation.ErrNotFound", err)
+	}
+	if _, err := (&mutationResolver{&Resolver{}}).RenderScreenshot(ctx, sim.ID, nil, nil); !errors.Is(err, errSimulationsDisabled) {
+		t.Errorf("RenderScreenshot() without store error = %v, want errSimulationsDisabled", err)
+	}
+
+	inline, err := (&mutationResolver{NewResolver(nil, nil, nil, WithSimulationStore(simulations))}).RenderScreenshot(ctx, sim.ID, nil, nil)
+	if err != nil {
+		t.Fatalf("RenderScreenshot() without image store error = %v", err)
+	}
+	if inline.Theme != model.ScreenshotThemeLight || !strings.HasPrefix(inline.ImageURL, "data:image/png;base64,") {
+		t.Errorf("RenderScreenshot() = %+v, want an inline light screenshot", inline)
+	}
+}
+
+func TestToScreenshotPost(t *testing.T) {
+	post := toScreenshotPost(&simulation.Simulation{
+		InflammatoryText: "炎上投稿",
+		Replies: []simulation.Reply{
+			{Type: string(model.ReplyTypeNitpicking), Content: "揚げ足"},
+			{Type: "UNKNOWN", Content: "その他"},
+		},
+	}, nil)
+
+	if post.Text != "炎上投稿" || len(post.Replies) != 2 {
+		t.Fatalf("toScreenshotPost() = %+v, want the inflammatory text and both replies", post)
+	}
+	if post.Replies[0].Author.Handle != "ageashi_meijin" {
+		t.Errorf("nitpicking reply author = %+v, want the persona account", post.Replies[0].Author)
+	}
+	if post.Replies[1].Author.Handle != "anonymous" {
+		t.Errorf("unknown reply author = %+v, want the anonymous account", post.Replies[1].Author)
+	}
+}
@@ -0,0 +1,143 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/simulation"
+	"github.com/Tattsum/enjo/backend/twitter"
+)
+
+var (
+	// errSimulationsDisabled is returned by resolvers that need persisted simulations when no store is configured
+	errSimulationsDisabled = errors.New("simulation history is not configured")
+	// errTwitterDisabled is returned by tweet lifecycle resolvers when no Twitter client is configured
+	errTwitterDisabled = errors.New("twitter client is not configured")
+	// errSimulationTextMismatch is returned when a post names a simulation but publishes other text
+	errSimulationTextMismatch = errors.New("投稿テキストがシミュレーションの炎上文章と一致しません")
+)
+
+// recordPostedTweet stores the mapping between a published tweet and its simulation.
+// Failures are logged rather than returned because the tweet has already been published.
+func recordPostedTweet(ctx context.Context, simulations *simulation.Store, tweet simulation.Tweet) {
+	if simulations == nil {
+		return
+	}
+	if _, err := simulations.RecordTweet(ctx, tweet); err != nil {
+		slog.WarnContext(ctx, "failed to record posted tweet", "tweet_id", tweet.TweetID, "error", err)
+	}
+}
+
+// lookupSimulation returns the simulation with an optional ID, or nil when no ID is given
+func (r *Resolver) lookupSimulation(ctx context.Context, simulationID *string) (*simulation.Simulation, error) {
+	if simulationID == nil || *simulationID == "" {
+		return nil, nil
+	}
+	if r.simulations == nil {
+		return nil, errSimulationsDisabled
+	}
+	return r.getSimulation(ctx, *simulationID)
+}
+
+// postSimulation returns the simulation a post names, if any, after checking that the post
+// publishes its rewrite, so that the level recorded for the rewrite applies to the post
+func (r *Resolver) postSimulation(ctx context.Context, simulationID *string, text string) (*simulation.Simulation, error) {
+	sim, err := r.lookupSimulation(ctx, simulationID)
+	if err != nil || sim == nil {
+		return sim, err
+	}
+	if strings.TrimSpace(text) != strings.TrimSpace(sim.InflammatoryText) {
+		return nil, errSimulationTextMismatch
+	}
+	return sim, nil
+}
+
+// simulationLevel returns the level recorded in sim, or 0 when there is no simulation
+func simulationLevel(sim *simulation.Simulation) int {
+	if sim == nil {
+		return 0
+	}
+	return sim.Level
+}
+
+// toSimulationReplies converts generated replies into their persisted form
+func toSimulationReplies(replies []*model.Reply) []simulation.Reply {
+	converted := make([]simulation.Reply, 0, len(replies))
+	for _, reply := range replies {
+		converted = append(converted, simulation.Reply{ID: reply.ID, Type: string(reply.Type), Content: reply.Content})
+	}
+	return converted
+}
+
+// toSimulationModel converts a simulation and its tweets into their GraphQL representation
+func toSimulationModel(sim *simulation.Simulation, tweets []*simulation.Tweet) *model.Simulation {
+	replies := make([]*model.Reply, 0, len(sim.Replies))
+	for _, reply := range sim.Replies {
+		replies = append(replies, &model.Reply{ID: reply.ID, Type: model.ReplyType(reply.Type), Content: reply.Content})
+	}
+
+	return &model.Simulation{
+		ID:               sim.ID,
+		OriginalText:     sim.OriginalText,
+		InflammatoryText: sim.InflammatoryText,
+		Explanation:      optionalString(sim.Explanation),
+		Level:            sim.Level,
+		Replies:          replies,
+		Tweets:           toPostedTweetModels(tweets),
+		ImageID:          optionalString(sim.ImageID),
+		ImageDescription: optionalString(sim.ImageDescription),
+		CreatedAt:        sim.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// toPostedTweetModel converts a posted tweet record into its GraphQL representation
+func toPostedTweetModel(tweet *simulation.Tweet) *model.PostedTweet {
+	result := &model.PostedTweet{
+		TweetID:         tweet.TweetID,
+		TweetURL:        tweet.TweetURL,
+		SimulationID:    optionalString(tweet.SimulationID),
+		ScheduledPostID: optionalString(tweet.ScheduledPostID),
+		Text:            tweet.Text,
+		Level:           tweet.Level,
+		Status:          model.TweetStatus(tweet.Status),
+		PostedAt:        tweet.PostedAt.Format(time.RFC3339),
+	}
+	if tweet.DeletedAt != nil {
+		result.DeletedAt = stringPtr(tweet.DeletedAt.Format(time.RFC3339))
+	}
+	if tweet.Metrics != nil {
+		result.Metrics = &model.TweetMetrics{
+			LikeCount:       tweet.Metrics.LikeCount,
+			RepostCount:     tweet.Metrics.RetweetCount,
+			ReplyCount:      tweet.Metrics.ReplyCount,
+			QuoteCount:      tweet.Metrics.QuoteCount,
+			ImpressionCount: tweet.Metrics.ImpressionCount,
+			FetchedAt:       tweet.Metrics.FetchedAt.Format(time.RFC3339),
+		}
+	}
+	return result
+}
+
+// toPostedTweetModels converts a list of posted tweet records into their GraphQL representation
+func toPostedTweetModels(tweets []*simulation.Tweet) []*model.PostedTweet {
+	converted := make([]*model.PostedTweet, 0, len(tweets))
+	for _, tweet := range tweets {
+		converted = append(converted, toPostedTweetModel(tweet))
+	}
+	return converted
+}
+
+// fromTweetMetrics converts metrics fetched from Twitter into a persisted snapshot
+func fromTweetMetrics(metrics *twitter.TweetMetrics) simulation.Metrics {
+	return simulation.Metrics{
+		LikeCount:       metrics.LikeCount,
+		RetweetCount:    metrics.RetweetCount,
+		ReplyCount:      metrics.ReplyCount,
+		QuoteCount:      metrics.QuoteCount,
+		ImpressionCount: metrics.ImpressionCount,
+	}
+}
@@ -0,0 +1,222 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/simulation"
+	"github.com/Tattsum/enjo/backend/store"
+	"github.com/Tattsum/enjo/backend/twitter"
+)
+
+func newTestSimulationStore(t *testing.T) *simulation.Store {
+	t.Helper()
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return simulation.New(db)
+}
+
+func TestSimulationLifecycle(t *testing.T) {
+	ctx := context.Background()
+	var deleted string
+	twitterClient := &MockTwitterClient{
+		PostTweetFunc: func(_ context.Context, _ string) (*twitter.TweetResult, error) {
+			return &twitter.TweetResult{ID: "100", URL: "https://twitter.com/user/status/100"}, nil
+		},
+		GetTweetMetricsFunc: func(_ context.Context, _ string) (*twitter.TweetMetrics, error) {
+			return &twitter.TweetMetrics{LikeCount: 3, RetweetCount: 1, ReplyCount: 2}, nil
+		},
+		DeleteTweetFunc: func(_ context.Context, tweetID string) error {
+			deleted = tweetID
+			return nil
+		},
+	}
+	geminiClient := &MockGeminiClient{
+		GenerateInflammatoryTextFunc: func(_ context.Context, _ string, _ int) (string, error) {
+			return "炎上文章", nil
+		},
+		GenerateExplanationFunc: func(_ context.Context, _, _ string) (string, error) {
+			return "解説", nil
+		},
+		GenerateReplyFunc: func(_ context.Context, _, _ string) (string, error) {
+			return "リプライ", nil
+		},
+	}
+	r := &Resolver{geminiClient: geminiClient, twitterClient: twitterClient, simulations: newTestSimulationStore(t)}
+	mutation := &mutationResolver{r}
+	query := &queryResolver{r}
+
+	generated, err := mutation.GenerateInflammatoryText(ctx, model.GenerateInput{OriginalText: "元の文章", Level: 2})
+	if err != nil {
+		t.Fatalf("GenerateInflammatoryText() error = %v", err)
+	}
+	if generated.SimulationID == nil {
+		t.Fatal("GenerateInflammatoryText() did not return a simulation ID")
+	}
+
+	if _, err := mutation.GenerateReplies(ctx, generated.InflammatoryText, generated.SimulationID, nil, nil); err != nil {
+		t.Fatalf("GenerateReplies() error = %v", err)
+	}
+
+	posted, err := mutation.PostToTwitter(ctx, model.TwitterPostInput{Text: generated.InflammatoryText, SimulationID: generated.SimulationID})
+	if err != nil || !posted.Success {
+		t.Fatalf("PostToTwitter() = %+v, %v, want success", posted, err)
+	}
+
+	sim, err := query.Simulation(ctx, *generated.SimulationID)
+	if err != nil {
+		t.Fatalf("Simulation() error = %v", err)
+	}
+	if len(sim.Replies) != 4 || len(sim.Tweets) != 1 || sim.Tweets[0].TweetID != "100" {
+		t.Fatalf("Simulation() = %+v, want 4 replies and the posted tweet", sim)
+	}
+
+	refreshed, err := mutation.RefreshTweetMetrics(ctx, "100")
+	if err != nil {
+		t.Fatalf("RefreshTweetMetrics() error = %v", err)
+	}
+	if refreshed.Metrics == nil || refreshed.Metrics.LikeCount != 3 || refreshed.Metrics.RepostCount != 1 {
+		t.Errorf("RefreshTweetMetrics().Metrics = %+v, want fetched metrics", refreshed.Metrics)
+	}
+
+	removed, err := mutation.DeleteTweet(ctx, "100")
+	if err != nil {
+		t.Fatalf("DeleteTweet() error = %v", err)
+	}
+	if deleted != "100" || removed.Status != model.TweetStatusDeleted || removed.DeletedAt == nil {
+		t.Errorf("DeleteTweet() = %+v, want deleted tweet 100", removed)
+	}
+
+	tweets, err := query.PostedTweets(ctx, generated.SimulationID)
+	if err != nil {
+		t.Fatalf("PostedTweets() error = %v", err)
+	}
+	if len(tweets) != 1 || tweets[0].Status != model.TweetStatusDeleted {
+		t.Errorf("PostedTweets() = %+v, want the deleted tweet", tweets)
+	}
+}
+
+func TestPostToTwitter_SimulationLevel(t *testing.T) {
+	ctx := context.Background()
+	var posted []string
+	r := NewResolver(
+		&MockGeminiClient{
+			GenerateInflammatoryTextFunc: func(_ context.Context, _ string, _ int) (string, error) { return "炎上文章", nil },
+			GenerateExplanationFunc:      func(_ context.Context, _, _ string) (string, error) { return "解説", nil },
+		},
+		&MockTwitterClient{
+			PostTweetFunc: func(_ context.Context, text string) (*twitter.TweetResult, error) {
+				posted = append(posted, text)
+				return &twitter.TweetResult{ID: "100"}, nil
+			},
+		},
+		nil,
+		WithSimulationStore(newTestSimulationStore(t)),
+	)
+	mutation := &mutationResolver{r}
+
+	generated, err := mutation.GenerateInflammatoryText(ctx, model.GenerateInput{OriginalText: "元の文章", Level: 4})
+	if err != nil {
+		t.Fatalf("GenerateInflammatoryText() error = %v", err)
+	}
+
+	// The stored level applies whatever level the client claims
+	first, err := mutation.PostToTwitter(ctx, model.TwitterPostInput{Text: "炎上文章", Level: intPtr(1), SimulationID: generated.SimulationID})
+	if err != nil {
+		t.Fatalf("PostToTwitter() error = %v", err)
+	}
+	if first.Success || !first.ConfirmationRequired || first.Policy.Level != 4 {
+		t.Fatalf("PostToTwitter() = %+v (policy %+v), want confirmation required at level 4", first, first.Policy)
+	}
+
+	// Text other than the simulation's rewrite cannot borrow its level
+	edited, err := mutation.PostToTwitter(ctx, model.TwitterPostInput{Text: "別の文章", SimulationID: generated.SimulationID})
+	if err != nil {
+		t.Fatalf("PostToTwitter() error = %v", err)
+	}
+	if edited.Success || edited.ErrorMessage == nil || *edited.ErrorMessage != errSimulationTextMismatch.Error() {
+		t.Errorf("PostToTwitter() with other text = %+v, want a text mismatch", edited)
+	}
+
+	confirmed, err := mutation.PostToTwitter(ctx, model.TwitterPostInput{
+		Text:              "炎上文章",
+		Level:             intPtr(1),
+		SimulationID:      generated.SimulationID,
+		ConfirmationToken: first.ConfirmationToken,
+	})
+	if err != nil || !confirmed.Success {
+		t.Fatalf("PostToTwitter() with token = %+v, %v, want success", confirmed, err)
+	}
+	if len(posted) != 1 {
+		t.Errorf("posted %d tweets, want 1", len(posted))
+	}
+	tweets, err := (&queryResolver{r}).PostedTweets(ctx, generated.SimulationID)
+	if err != nil {
+		t.Fatalf("PostedTweets() error = %v", err)
+	}
+	if len(tweets) != 1 || tweets[0].Level != 4 {
+		t.Errorf("PostedTweets() = %+v, want one tweet recorded at level 4", tweets)
+	}
+}
+
+func TestMutationResolver_RefreshTweetMetrics_Unavailable(t *testing.T) {
+	ctx := context.Background()
+	simulations := newTestSimulationStore(t)
+	if _, err := simulations.RecordTweet(ctx, simulation.Tweet{TweetID: "200", Text: "投稿"}); err != nil {
+		t.Fatalf("RecordTweet() error = %v", err)
+	}
+
+	r := &Resolver{
+		twitterClient: &MockTwitterClient{
+			GetTweetMetricsFunc: func(_ context.Context, _ string) (*twitter.TweetMetrics, error) {
+				return nil, twitter.ErrTweetNotFound
+			},
+		},
+		simulations: simulations,
+	}
+
+	got, err := (&mutationResolver{r}).RefreshTweetMetrics(ctx, "200")
+	if err != nil {
+		t.Fatalf("RefreshTweetMetrics() error = %v", err)
+	}
+	if got.Status != model.TweetStatusUnavailable {
+		t.Errorf("Status = %v, want UNAVAILABLE", got.Status)
+	}
+}
+
+func TestTweetLifecycle_Errors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("requires a simulation store", func(t *testing.T) {
+		r := &Resolver{twitterClient: &MockTwitterClient{}}
+		if _, err := (&mutationResolver{r}).DeleteTweet(ctx, "1"); !errors.Is(err, errSimulationsDisabled) {
+			t.Errorf("DeleteTweet() error = %v, want errSimulationsDisabled", err)
+		}
+	})
+
+	t.Run("rejects tweets not posted by the simulator", func(t *testing.T) {
+		r := &Resolver{twitterClient: &MockTwitterClient{}, simulations: newTestSimulationStore(t)}
+		if _, err := (&mutationResolver{r}).DeleteTweet(ctx, "unknown"); !errors.Is(err, simulation.ErrTweetNotFound) {
+			t.Errorf("DeleteTweet() error = %v, want ErrTweetNotFound", err)
+		}
+	})
+
+	t.Run("rejects posts for unknown simulations", func(t *testing.T) {
+		r := &Resolver{twitterClient: &MockTwitterClient{}, simulations: newTestSimulationStore(t)}
+		got, err := (&mutationResolver{r}).PostToTwitter(ctx, model.TwitterPostInput{Text: "投稿", SimulationID: stringPtr("missing")})
+		if err != nil {
+			t.Fatalf("PostToTwitter() error = %v", err)
+		}
+		if got.Success {
+			t.Error("PostToTwitter() succeeded for an unknown simulation")
+		}
+	})
+}
@@ -0,0 +1,285 @@
+package graph
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/accounts"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/twitter"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+var (
+	// errAccountLinkingDisabled is returned by account linking resolvers when no account store is configured
+	errAccountLinkingDisabled = errors.New("twitter account linking is not configured")
+	// errTwitterNotLinked is returned when an authenticated user without a linked account tries to use Twitter
+	errTwitterNotLinked = errors.New("no Twitter account is linked; link one with connectTwitterAccount")
+	// errLinkingRequiresLogin is returned when an anonymous user tries to link or unlink an account
+	errLinkingRequiresLogin = errors.New("sign in to link a Twitter account")
+)
+
+// linkingRequiresLoginError reports errLinkingRequiresLogin with the code the auth middleware uses
+// for unauthenticated requests
+func linkingRequiresLoginError() error {
+	return &gqlerror.Error{
+		Err:        errLinkingRequiresLogin,
+		Message:    errLinkingRequiresLogin.Error(),
+		Extensions: map[string]any{"code": "UNAUTHENTICATED"},
+	}
+}
+
+// twitterStateCookie holds the state of the authorization the browser started. The callback only
+// completes authorizations whose state the browser presents, so a user cannot be tricked into
+// approving, in their own browser, an authorization someone else started (and end up linking their
+// Twitter account to the other person's user).
+const twitterStateCookie = "enjo_twitter_link"
+
+// newLinkState returns a random authorization state
+func newLinkState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate authorization state: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// TwitterAuthorizer runs the OAuth flow that links a user's Twitter account
+type TwitterAuthorizer interface {
+	RequestToken(ctx context.Context) (*twitter.RequestToken, error)
+	AccessToken(ctx context.Context, requestToken, requestSecret, verifier string) (*twitter.Credentials, error)
+}
+
+// TwitterClientFactory builds a client that acts as the account the access token belongs to
+type TwitterClientFactory func(accessToken, accessTokenSecret string) (TwitterClient, error)
+
+// twitterFor returns the Twitter client to use for the current request
+func (r *Resolver) twitterFor(ctx context.Context) (TwitterClient, error) {
+	return r.twitterForOwner(ctx, currentOwner(ctx))
+}
+
+// twitterForOwner returns a client that posts as the account ownerID linked. Without a linked account,
+// anonymous requests fall back to the shared account configured at startup; authenticated users
+// must link their own so that nobody posts from someone else's account.
+func (r *Resolver) twitterForOwner(ctx context.Context, ownerID string) (TwitterClient, error) {
+	if r.twitterAccounts != nil && ownerID != "" {
+		account, err := r.twitterAccounts.Get(ctx, ownerID, accounts.PlatformTwitter)
+		switch {
+		case err == nil:
+			client, err := r.twitterClients(account.AccessToken, account.AccessTokenSecret)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create Twitter client: %w", err)
+			}
+			return client, nil
+		case errors.Is(err, accounts.ErrNotFound):
+			return nil, errTwitterNotLinked
+		default:
+			return nil, fmt.Errorf("failed to load linked Twitter account: %w", err)
+		}
+	}
+
+	if r.twitterClient == nil {
+		return nil, errTwitterDisabled
+	}
+	return r.twitterClient, nil
+}
+
+// twitterUnavailableMessage is the user-facing reason postToTwitter cannot post
+func twitterUnavailableMessage(err error) string {
+	switch {
+	case errors.Is(err, errTwitterDisabled):
+		return "Twitter API が設定されていません。環境変数を確認してください。"
+	case errors.Is(err, errTwitterNotLinked):
+		return "Twitter アカウントが連携されていません。connectTwitterAccount で連携してください。"
+	default:
+		return err.Error()
+	}
+}
+
+// connectTwitterAccount starts linking a Twitter account to the current user
+func (r *Resolver) connectTwitterAccount(ctx context.Context) (*model.TwitterAuthorization, error) {
+	if r.twitterAccounts == nil {
+		return nil, errAccountLinkingDisabled
+	}
+	if currentOwner(ctx) == "" {
+		return nil, linkingRequiresLoginError()
+	}
+
+	state, err := newLinkState()
+	if err != nil {
+		return nil, err
+	}
+	requestToken, err := r.twitterAuth.RequestToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	err = r.twitterAccounts.SavePending(ctx, accounts.Pending{
+		RequestToken:  requestToken.Token,
+		RequestSecret: requestToken.Secret,
+		State:         state,
+		OwnerID:       currentOwner(ctx),
+		Platform:      accounts.PlatformTwitter,
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = setCookie(ctx, &http.Cookie{
+		Name:     twitterStateCookie,
+		Value:    state,
+		Path:     "/",
+		MaxAge:   int(accounts.PendingTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode, // Sent on the top-level redirect back from Twitter
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TwitterAuthorization{
+		AuthorizeURL: requestToken.AuthorizeURL,
+		ExpiresAt:    time.Now().Add(accounts.PendingTTL).Format(time.RFC3339),
+	}, nil
+}
+
+// linkedTwitterAccount returns the current user's linked account, or nil when none is linked
+func (r *Resolver) linkedTwitterAccount(ctx context.Context) (*model.TwitterAccount, error) {
+	if r.twitterAccounts == nil {
+		return nil, nil
+	}
+	account, err := r.twitterAccounts.Get(ctx, currentOwner(ctx), accounts.PlatformTwitter)
+	if errors.Is(err, accounts.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load linked Twitter account: %w", err)
+	}
+	return &model.TwitterAccount{
+		UserID:      account.UserID,
+		ScreenName:  account.ScreenName,
+		ConnectedAt: account.ConnectedAt.Format(time.RFC3339),
+	}, nil
+}
+
+// TwitterCallbackHandler returns the handler Twitter redirects users to after they approve or deny
+// the app, or nil when account linking is not configured. The request token in the callback
+// identifies the user who started the authorization, so the route needs no credentials; the state
+// cookie set by connectTwitterAccount ties it to the browser that started it.
+func (r *Resolver) TwitterCallbackHandler() http.Handler {
+	if r.twitterAccounts == nil {
+		return nil
+	}
+	return http.HandlerFunc(r.serveTwitterCallback)
+}
+
+// Outcomes of the OAuth callback, passed to the redirect URL as the twitter query parameter
+const (
+	linkResultLinked = "linked"
+	linkResultDenied = "denied"
+	linkResultFailed = "failed"
+)
+
+func (r *Resolver) serveTwitterCallback(w http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	// The state is used at most once, whatever the outcome
+	var state string
+	if cookie, err := req.Cookie(twitterStateCookie); err == nil {
+		state = cookie.Value
+	}
+	http.SetCookie(w, &http.Cookie{Name: twitterStateCookie, Path: "/", MaxAge: -1, HttpOnly: true, Secure: isHTTPS(req)})
+
+	// Twitter sends the request token back as "denied" when the user declines
+	if denied := query.Get("denied"); denied != "" {
+		if state != "" {
+			if _, err := r.twitterAccounts.TakePending(ctx, denied, state); err != nil {
+				slog.WarnContext(ctx, "denied Twitter authorization not found", "error", err)
+			}
+		}
+		r.finishTwitterLink(w, req, linkResultDenied)
+		return
+	}
+
+	if state == "" {
+		slog.WarnContext(ctx, "Twitter authorization callback rejected", "error", "no authorization state cookie")
+		r.finishTwitterLink(w, req, linkResultFailed)
+		return
+	}
+	pending, err := r.twitterAccounts.TakePending(ctx, query.Get("oauth_token"), state)
+	if err != nil {
+		slog.WarnContext(ctx, "Twitter authorization callback rejected", "error", err)
+		r.finishTwitterLink(w, req, linkResultFailed)
+		return
+	}
+	credentials, err := r.twitterAuth.AccessToken(ctx, pending.RequestToken, pending.RequestSecret, query.Get("oauth_verifier"))
+	if err != nil {
+		slog.WarnContext(ctx, "Twitter authorization failed", "error", err)
+		r.finishTwitterLink(w, req, linkResultFailed)
+		return
+	}
+
+	_, err = r.twitterAccounts.Save(ctx, accounts.Account{
+		OwnerID:           pending.OwnerID,
+		Platform:          accounts.PlatformTwitter,
+		UserID:            credentials.UserID,
+		ScreenName:        credentials.ScreenName,
+		AccessToken:       credentials.AccessToken,
+		AccessTokenSecret: credentials.AccessTokenSecret,
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to store linked Twitter account", "error", err)
+		r.finishTwitterLink(w, req, linkResultFailed)
+		return
+	}
+
+	slog.InfoContext(ctx, "linked Twitter account", "screen_name", credentials.ScreenName)
+	r.finishTwitterLink(w, req, linkResultLinked)
+}
+
+// finishTwitterLink sends the user back to the frontend with the outcome, or shows it
+// directly when no redirect URL is configured
+func (r *Resolver) finishTwitterLink(w http.ResponseWriter, req *http.Request, result string) {
+	if r.twitterLinkRedirect != "" {
+		target, err := url.Parse(r.twitterLinkRedirect)
+		if err == nil {
+			values := target.Query()
+			values.Set("twitter", result)
+			target.RawQuery = values.Encode()
+			http.Redirect(w, req, target.String(), http.StatusFound)
+			return
+		}
+		slog.ErrorContext(req.Context(), "invalid Twitter link redirect URL", "error", err)
+	}
+
+	status, message := http.StatusOK, "Twitter アカウントを連携しました。このウィンドウを閉じてください。"
+	switch result {
+	case linkResultDenied:
+		status, message = http.StatusOK, "Twitter アカウントの連携はキャンセルされました。"
+	case linkResultFailed:
+		status, message = http.StatusBadRequest, "Twitter アカウントの連携に失敗しました。もう一度お試しください。"
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintln(w, message)
+}
+
+// disconnectTwitterAccount removes the current user's linked account
+func (r *Resolver) disconnectTwitterAccount(ctx context.Context) (bool, error) {
+	if r.twitterAccounts == nil {
+		return false, errAccountLinkingDisabled
+	}
+	if currentOwner(ctx) == "" {
+		return false, linkingRequiresLoginError()
+	}
+	if err := r.twitterAccounts.Delete(ctx, currentOwner(ctx), accounts.PlatformTwitter); err != nil {
+		return false, fmt.Errorf("failed to remove linked Twitter account: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,264 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tattsum/enjo/backend/accounts"
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/secret"
+	"github.com/Tattsum/enjo/backend/store"
+	"github.com/Tattsum/enjo/backend/twitter"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// mockTwitterAuthorizer issues request tokens and accepts the verifier "approved"
+type mockTwitterAuthorizer struct{}
+
+func (mockTwitterAuthorizer) RequestToken(context.Context) (*twitter.RequestToken, error) {
+	return &twitter.RequestToken{Token: "request-token", Secret: "request-secret", AuthorizeURL: "https://twitter.example.com/authorize"}, nil
+}
+
+func (mockTwitterAuthorizer) AccessToken(_ context.Context, token, secret, verifier string) (*twitter.Credentials, error) {
+	if token != "request-token" || secret != "request-secret" || verifier != "approved" {
+		return nil, errors.New("invalid verifier")
+	}
+	return &twitter.Credentials{AccessToken: "alice-token", AccessTokenSecret: "alice-secret", UserID: "42", ScreenName: "alice_enjo"}, nil
+}
+
+// newTwitterAccountsOption returns WithTwitterAccounts backed by a test store. Clients built for
+// linked accounts post tweets whose URL names the access token they were built from.
+func newTwitterAccountsOption(t *testing.T, redirectURL string) ResolverOption {
+	t.Helper()
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	box, err := secret.New(bytes.Repeat([]byte{1}, secret.KeySize))
+	if err != nil {
+		t.Fatalf("secret.New() error = %v", err)
+	}
+
+	clients := func(accessToken, _ string) (TwitterClient, error) {
+		return &MockTwitterClient{
+			PostTweetFunc: func(context.Context, string) (*twitter.TweetResult, error) {
+				return &twitter.TweetResult{ID: "1", URL: "https://twitter.com/" + accessToken + "/status/1"}, nil
+			},
+		}, nil
+	}
+	return WithTwitterAccounts(accounts.New(db, box), mockTwitterAuthorizer{}, clients, redirectURL)
+}
+
+// connectTwitter starts linking an account as the user in ctx and returns the state cookie the
+// browser would keep
+func connectTwitter(t *testing.T, ctx context.Context, mutation *mutationResolver) (*model.TwitterAuthorization, *http.Cookie) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", http.NoBody).WithContext(ctx)
+	ctx, cookies := withResponseCookies(req)
+	authorization, err := mutation.ConnectTwitterAccount(ctx)
+	if err != nil {
+		t.Fatalf("ConnectTwitterAccount() error = %v", err)
+	}
+	for _, cookie := range cookies.cookies {
+		if cookie.Name == twitterStateCookie {
+			return authorization, cookie
+		}
+	}
+	t.Fatal("ConnectTwitterAccount() set no state cookie")
+	return nil, nil
+}
+
+// twitterCallback calls the OAuth callback as a browser holding cookie, or no cookie when nil
+func twitterCallback(r *Resolver, query string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/oauth/twitter/callback?"+query, http.NoBody)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.TwitterCallbackHandler().ServeHTTP(w, req)
+	return w
+}
+
+func TestTwitterAccountLinking(t *testing.T) {
+	shared := &MockTwitterClient{
+		PostTweetFunc: func(context.Context, string) (*twitter.TweetResult, error) {
+			return &twitter.TweetResult{ID: "2", URL: "https://twitter.com/shared/status/2"}, nil
+		},
+	}
+	r := NewResolver(nil, shared, nil, newTwitterAccountsOption(t, "https://enjo.example.com/settings"), withLenientPolicy(t))
+	mutation, query := &mutationResolver{r}, &queryResolver{r}
+
+	alice := auth.WithUser(context.Background(), &auth.User{ID: "alice"})
+	bob := auth.WithUser(context.Background(), &auth.User{ID: "bob"})
+	post := func(ctx context.Context) *model.TwitterPostResult {
+		t.Helper()
+		result, err := mutation.PostToTwitter(ctx, model.TwitterPostInput{Text: "テスト投稿", Level: intPtr(1)})
+		if err != nil {
+			t.Fatalf("PostToTwitter() error = %v", err)
+		}
+		return result
+	}
+
+	// Authenticated users without a linked account cannot post from the shared account
+	if result := post(alice); result.Success || stringValue(result.ErrorMessage) != twitterUnavailableMessage(errTwitterNotLinked) {
+		t.Errorf("PostToTwitter() before linking = %+v, want the not-linked message", result)
+	}
+
+	authorization, state := connectTwitter(t, alice, mutation)
+	if authorization.AuthorizeURL != "https://twitter.example.com/authorize" {
+		t.Errorf("AuthorizeURL = %q", authorization.AuthorizeURL)
+	}
+	if !state.HttpOnly || state.SameSite != http.SameSiteLaxMode {
+		t.Errorf("state cookie = %+v, want HttpOnly and SameSite=Lax", state)
+	}
+
+	w := twitterCallback(r, "oauth_token=request-token&oauth_verifier=approved", state)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://enjo.example.com/settings?twitter=linked" {
+		t.Fatalf("callback = %d to %q, want a redirect reporting the link", w.Code, w.Header().Get("Location"))
+	}
+	if replayed := twitterCallback(r, "oauth_token=request-token&oauth_verifier=approved", state); replayed.Header().Get("Location") != "https://enjo.example.com/settings?twitter=failed" {
+		t.Errorf("replayed callback redirected to %q, want failure", replayed.Header().Get("Location"))
+	}
+
+	account, err := query.TwitterAccount(alice)
+	if err != nil || account == nil || account.ScreenName != "alice_enjo" {
+		t.Fatalf("TwitterAccount() = %+v, %v, want alice_enjo", account, err)
+	}
+	if account, _ := query.TwitterAccount(bob); account != nil {
+		t.Errorf("TwitterAccount() for another user = %+v, want nil", account)
+	}
+
+	// Posts go out from the linked account; anonymous requests still use the shared one
+	if result := post(alice); stringValue(result.TweetURL) != "https://twitter.com/alice-token/status/1" {
+		t.Errorf("PostToTwitter() after linking = %+v, want a post from the linked account", result)
+	}
+	if result := post(context.Background()); stringValue(result.TweetURL) != "https://twitter.com/shared/status/2" {
+		t.Errorf("anonymous PostToTwitter() = %+v, want a post from the shared account", result)
+	}
+
+	// Scheduled posts are published from their owner's account
+	handler := NewScheduledPostHandler(shared, newTwitterAccountsOption(t, ""))
+	if _, err := handler(context.Background(), &queue.Job{Text: "予約投稿", OwnerID: "bob"}); !errors.Is(err, errTwitterNotLinked) {
+		t.Errorf("scheduled post without a linked account error = %v, want errTwitterNotLinked", err)
+	}
+
+	if ok, err := mutation.DisconnectTwitterAccount(alice); err != nil || !ok {
+		t.Fatalf("DisconnectTwitterAccount() = %v, %v", ok, err)
+	}
+	if account, _ := query.TwitterAccount(alice); account != nil {
+		t.Errorf("TwitterAccount() after disconnecting = %+v, want nil", account)
+	}
+}
+
+func TestTwitterCallback_WithoutRedirect(t *testing.T) {
+	r := NewResolver(nil, nil, nil, newTwitterAccountsOption(t, ""))
+	alice := auth.WithUser(context.Background(), &auth.User{ID: "alice"})
+	_, state := connectTwitter(t, alice, &mutationResolver{r})
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{name: "denied", query: "denied=request-token", wantStatus: http.StatusOK},
+		{name: "unknown request token", query: "oauth_token=unknown&oauth_verifier=approved", wantStatus: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := twitterCallback(r, tt.query, state)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestTwitterCallback_RequiresState(t *testing.T) {
+	r := NewResolver(nil, nil, nil, newTwitterAccountsOption(t, ""))
+	mutation, query := &mutationResolver{r}, &queryResolver{r}
+	alice := auth.WithUser(context.Background(), &auth.User{ID: "alice"})
+	_, state := connectTwitter(t, alice, mutation)
+
+	// Someone who approves alice's authorization in their own browser must not link their account to her
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+	}{
+		{name: "no state cookie", cookie: nil},
+		{name: "state of another authorization", cookie: &http.Cookie{Name: twitterStateCookie, Value: "forged"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := twitterCallback(r, "oauth_token=request-token&oauth_verifier=approved", tt.cookie)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+			if account, _ := query.TwitterAccount(alice); account != nil {
+				t.Errorf("TwitterAccount() = %+v, want nil", account)
+			}
+		})
+	}
+
+	// The rejected callbacks leave the authorization to the browser that started it
+	w := twitterCallback(r, "oauth_token=request-token&oauth_verifier=approved", state)
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback with the state cookie status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if cleared := w.Result().Cookies(); len(cleared) != 1 || cleared[0].Name != twitterStateCookie || cleared[0].MaxAge >= 0 {
+		t.Errorf("callback cookies = %+v, want the state cookie cleared", cleared)
+	}
+	if account, _ := query.TwitterAccount(alice); account == nil {
+		t.Error("TwitterAccount() = nil after linking")
+	}
+}
+
+func TestTwitterAccountLinking_RequiresLogin(t *testing.T) {
+	shared := &MockTwitterClient{
+		PostTweetFunc: func(context.Context, string) (*twitter.TweetResult, error) {
+			return &twitter.TweetResult{ID: "2", URL: "https://twitter.com/shared/status/2"}, nil
+		},
+	}
+	r := NewResolver(nil, shared, nil, newTwitterAccountsOption(t, ""), withLenientPolicy(t))
+	mutation := &mutationResolver{r}
+	ctx := context.Background()
+
+	// Anonymous users share the account configured at startup and must not replace or remove it
+	if _, err := mutation.ConnectTwitterAccount(ctx); !errors.Is(err, errLinkingRequiresLogin) {
+		t.Errorf("ConnectTwitterAccount() error = %v, want errLinkingRequiresLogin", err)
+	}
+	if _, err := mutation.DisconnectTwitterAccount(ctx); !errors.Is(err, errLinkingRequiresLogin) {
+		t.Errorf("DisconnectTwitterAccount() error = %v, want errLinkingRequiresLogin", err)
+	}
+	var gqlErr *gqlerror.Error
+	if _, err := mutation.ConnectTwitterAccount(ctx); !errors.As(err, &gqlErr) || gqlErr.Extensions["code"] != "UNAUTHENTICATED" {
+		t.Errorf("ConnectTwitterAccount() error = %v, want code UNAUTHENTICATED", err)
+	}
+
+	result, err := mutation.PostToTwitter(ctx, model.TwitterPostInput{Text: "テスト投稿", Level: intPtr(1)})
+	if err != nil || stringValue(result.TweetURL) != "https://twitter.com/shared/status/2" {
+		t.Errorf("anonymous PostToTwitter() = %+v, %v, want a post from the shared account", result, err)
+	}
+}
+
+func TestTwitterAccountLinking_Disabled(t *testing.T) {
+	r := NewResolver(nil, nil, nil)
+	if handler := r.TwitterCallbackHandler(); handler != nil {
+		t.Error("TwitterCallbackHandler() != nil without an account store")
+	}
+	if _, err := (&mutationResolver{r}).ConnectTwitterAccount(context.Background()); !errors.Is(err, errAccountLinkingDisabled) {
+		t.Errorf("ConnectTwitterAccount() error = %v, want errAccountLinkingDisabled", err)
+	}
+	if account, err := (&queryResolver{r}).TwitterAccount(context.Background()); account != nil || err != nil {
+		t.Errorf("TwitterAccount() = %+v, %v, want nil", account, err)
+	}
+}
@@ -0,0 +1,175 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/imageproc"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+)
+
+// maxUploadBytes is the largest image accepted for upload
+const maxUploadBytes = 10 << 20
+
+var (
+	// errUploadTooLarge is returned for uploads over maxUploadBytes
+	errUploadTooLarge = fmt.Errorf("画像サイズは%dMB以下にしてください", maxUploadBytes>>20)
+	// errUnsupportedUploadFormat is returned for uploads that are not PNG, JPEG or WebP
+	errUnsupportedUploadFormat = errors.New("画像はPNG・JPEG・WebPのいずれかにしてください")
+	// errUploadDimensions is returned for uploads wider or taller than imageproc.MaxSourceDimension
+	errUploadDimensions = fmt.Errorf("画像の幅と高さは%dピクセル以下にしてください", imageproc.MaxSourceDimension)
+	// errUploadAttachNeedsStore is returned when an upload is attached to a simulation without an image store
+	errUploadAttachNeedsStore = errors.New("attaching an uploaded image to a simulation requires an image store")
+)
+
+// imageDescriptionPrompt asks Gemini to describe an uploaded image for the simulation
+const imageDescriptionPrompt = `この画像はSNS投稿に添付される予定です。炎上リスクの分析に使えるよう、写っているもの・雰囲気・読み取れる文字を日本語で2〜3文で客観的に説明してください。説明のみを出力してください。`
+
+// readUpload reads an uploaded file, validates its size, format and dimensions and re-encodes it.
+// Re-encoding drops metadata such as EXIF location; opaque images become JPEG and images with
+// transparency stay PNG. Uploads keep their size; they are fitted to a platform when posted.
+func readUpload(file graphql.Upload) (*imageproc.Result, error) {
+	if file.Size > maxUploadBytes {
+		return nil, errUploadTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(file.File, maxUploadBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload: %w", err)
+	}
+	if len(data) > maxUploadBytes {
+		return nil, errUploadTooLarge
+	}
+
+	result, err := imageproc.Process(data, imageproc.Budget{})
+	switch {
+	case errors.Is(err, imageproc.ErrUnsupportedFormat):
+		return nil, errUnsupportedUploadFormat
+	case errors.Is(err, imageproc.ErrSourceTooLarge):
+		return nil, errUploadDimensions
+	case err != nil:
+		return nil, err
+	}
+	return result, nil
+}
+
+// describeImage asks the multimodal model to describe an image.
+// Failures are logged and return an empty description, since the image is usable without one.
+func (r *Resolver) describeImage(ctx context.Context, data []byte) string {
+	if r.vision == nil {
+		return ""
+	}
+
+	description, err := r.vision.GenerateContentWithImage(ctx, imageDescriptionPrompt, data)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to describe image", "error", err)
+		return ""
+	}
+	return description
+}
+
+// uploadImage stores a validated upload, describes it and optionally attaches it to a simulation
+func (r *Resolver) uploadImage(ctx context.Context, file graphql.Upload, simulationID *string) (*model.UploadedImage, error) {
+	attach := simulationID != nil && *simulationID != ""
+	if attach && r.imageStore == nil {
+		return nil, errUploadAttachNeedsStore
+	}
+	if _, err := r.lookupSimulation(ctx, simulationID); err != nil {
+		return nil, err
+	}
+	// Describing and moderating the image are a Gemini call each
+	calls := r.imageModerationCalls(1)
+	if r.vision != nil {
+		calls++
+	}
+	if calls > 0 {
+		if err := r.allow(ctx, ratelimit.OpText, calls); err != nil {
+			return nil, err
+		}
+	}
+
+	upload, err := readUpload(file)
+	if err != nil {
+		return nil, err
+	}
+	description := r.describeImage(ctx, upload.Data)
+	id, url, err := r.storeImage(ctx, upload.Data, imagestore.Record{Description: description})
+	if err != nil {
+		return nil, err
+	}
+	if attach {
+		if _, err := r.simulations.SetImage(ctx, *simulationID, id, description); err != nil {
+			return nil, fmt.Errorf("failed to attach image to simulation: %w", err)
+		}
+	}
+
+	result := &model.UploadedImage{
+		ImageID:     optionalString(id),
+		ImageURL:    url,
+		ContentType: upload.ContentType,
+		Width:       upload.Width,
+		Height:      upload.Height,
+		Size:        len(upload.Data),
+		Description: optionalString(description),
+		Moderation:  r.moderateImage(ctx, upload.Data),
+	}
+	if attach {
+		result.SimulationID = simulationID
+	}
+	return result, nil
+}
+
+// imageDescription returns the description of a referenced image for use in text generation.
+// Uploads are described when they are stored; other images are described on demand, which is
+// charged as a Gemini call.
+func (r *Resolver) imageDescription(ctx context.Context, ref string) (string, error) {
+	if r.imageCatalog != nil {
+		if record, err := r.getImageRecord(ctx, imageIDFromReference(ref)); err == nil && record.Description != "" {
+			return record.Description, nil
+		}
+	}
+
+	data, err := r.loadImage(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid image: %w", err)
+	}
+	if r.vision != nil {
+		if err := r.allow(ctx, ratelimit.OpText, 1); err != nil {
+			return "", err
+		}
+	}
+	return r.describeImage(ctx, data), nil
+}
+
+// replyImageDescription returns the description of the image replies should take into account:
+// the given image, otherwise the image attached to the simulation
+func (r *Resolver) replyImageDescription(ctx context.Context, simulationID, imageID *string) (string, error) {
+	if imageID != nil && *imageID != "" {
+		return r.imageDescription(ctx, *imageID)
+	}
+	if simulationID == nil || *simulationID == "" || r.simulations == nil {
+		return "", nil
+	}
+
+	sim, err := r.getSimulation(ctx, *simulationID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get simulation: %w", err)
+	}
+	return sim.ImageDescription, nil
+}
+
+// withImageContext appends the description of the attached image to a post so that
+// Gemini takes the image into account. Without a description the post is returned unchanged.
+func withImageContext(text, description string) string {
+	if description == "" {
+		return text
+	}
+	return text + "\n\n【添付画像の内容】\n" + description +
+		"\n（この投稿には上記の画像が添付されます。画像によって炎上しやすさが増す場合はそれも踏まえてください。出力に画像の説明そのものは含めないでください）"
+}
@@ -0,0 +1,219 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	goimage "image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/Tattsum/enjo/backend/graph/model"
+	"github.com/Tattsum/enjo/backend/imageproc"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/simulation"
+	"github.com/Tattsum/enjo/backend/store"
+)
+
+// encodeTestImage encodes a solid image of the given size with the given alpha
+func encodeTestImage(t *testing.T, width, height int, alpha uint8, asJPEG bool) []byte {
+	t.Helper()
+
+	img := goimage.NewNRGBA(goimage.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.NRGBA{R: 0xff, G: 0x45, B: 0x00, A: alpha})
+		}
+	}
+	var buf bytes.Buffer
+	var err error
+	if asJPEG {
+		err = jpeg.Encode(&buf, img, nil)
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func testUpload(data []byte) graphql.Upload {
+	return graphql.Upload{File: bytes.NewReader(data), Filename: "upload", Size: int64(len(data))}
+}
+
+func TestReadUpload(t *testing.T) {
+	tests := []struct {
+		name            string
+		data            []byte
+		wantContentType string
+		wantErr         error
+	}{
+		{
+			name:            "opaque PNG is re-encoded as JPEG",
+			data:            encodeTestImage(t, 40, 30, 0xff, false),
+			wantContentType: "image/jpeg",
+		},
+		{
+			name:            "PNG with transparency stays PNG",
+			data:            encodeTestImage(t, 40, 30, 0x80, false),
+			wantContentType: "image/png",
+		},
+		{
+			name:            "JPEG is re-encoded as JPEG",
+			data:            encodeTestImage(t, 40, 30, 0xff, true),
+			wantContentType: "image/jpeg",
+		},
+		{
+			name:    "rejects non images",
+			data:    []byte("GIF89a not really"),
+			wantErr: errUnsupportedUploadFormat,
+		},
+		{
+			name:    "rejects uploads over the size limit",
+			data:    make([]byte, maxUploadBytes+1),
+			wantErr: errUploadTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readUpload(testUpload(tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("readUpload() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.ContentType != tt.wantContentType || got.Width != 40 || got.Height != 30 {
+				t.Errorf("readUpload() = %s %dx%d, want %s 40x30", got.ContentType, got.Width, got.Height, tt.wantContentType)
+			}
+			if _, _, err := goimage.Decode(bytes.NewReader(got.Data)); err != nil {
+				t.Errorf("readUpload() returned undecodable data: %v", err)
+			}
+		})
+	}
+
+	t.Run("rejects oversized dimensions before decoding", func(t *testing.T) {
+		_, err := readUpload(testUpload(encodeTestImage(t, imageproc.MaxSourceDimension+1, 1, 0xff, false)))
+		if !errors.Is(err, errUploadDimensions) {
+			t.Errorf("readUpload() error = %v, want errUploadDimensions", err)
+		}
+	})
+}
+
+func TestUploadImage_FactorsIntoGeneration(t *testing.T) {
+	ctx := context.Background()
+	withImages, _ := newTestImageStoreOption(t)
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var originals, replyPosts []string
+	gemini := &MockGeminiClient{
+		GenerateInflammatoryTextFunc: func(_ context.Context, original string, _ int) (string, error) {
+			originals = append(originals, original)
+			return "炎上文章", nil
+		},
+		GenerateExplanationFunc: func(_ context.Context, original, _ string) (string, error) {
+			originals = append(originals, original)
+			return "画像が火に油を注いでいます", nil
+		},
+		GenerateReplyFunc: func(_ context.Context, text, _ string) (string, error) {
+			replyPosts = append(replyPosts, text)
+			return "リプライ", nil
+		},
+	}
+	var described int
+	vision := &mockVisionClient{
+		GenerateContentWithImageFunc: func(_ context.Context, _ string, _ []byte) (string, error) {
+			described++
+			return "高級寿司の写真", nil
+		},
+	}
+	r := NewResolver(gemini, nil, nil,
+		withImages,
+		WithImageCatalog(imagestore.NewCatalog(db)),
+		WithSimulationStore(simulation.New(db)),
+		WithVisionClient(vision),
+	)
+	mutation := &mutationResolver{r}
+
+	uploaded, err := mutation.UploadImage(ctx, testUpload(encodeTestImage(t, 40, 30, 0xff, false)), nil)
+	if err != nil {
+		t.Fatalf("UploadImage() error = %v", err)
+	}
+	if uploaded.ImageID == nil || uploaded.ContentType != "image/jpeg" || uploaded.Description == nil || *uploaded.Description != "高級寿司の写真" {
+		t.Fatalf("UploadImage() = %+v, want a stored, described JPEG", uploaded)
+	}
+
+	generated, err := mutation.GenerateInflammatoryText(ctx, model.GenerateInput{OriginalText: "今日のランチ", Level: 3, ImageID: uploaded.ImageID})
+	if err != nil {
+		t.Fatalf("GenerateInflammatoryText() error = %v", err)
+	}
+	if described != 1 {
+		t.Errorf("image was described %d times, want the stored description to be reused", described)
+	}
+	for _, original := range originals {
+		if !strings.Contains(original, "今日のランチ") || !strings.Contains(original, "高級寿司の写真") {
+			t.Errorf("Gemini input = %q, want the post with the image description", original)
+		}
+	}
+
+	if _, err := mutation.GenerateReplies(ctx, generated.InflammatoryText, generated.SimulationID, nil, nil); err != nil {
+		t.Fatalf("GenerateReplies() error = %v", err)
+	}
+	if len(replyPosts) == 0 || !strings.Contains(replyPosts[0], "高級寿司の写真") {
+		t.Errorf("reply input = %q, want the simulation's image description", replyPosts)
+	}
+
+	if _, err := mutation.VaryImage(ctx, model.VaryImageInput{ImageID: *uploaded.ImageID}); !errors.Is(err, errImageNotGenerated) {
+		t.Errorf("VaryImage(upload) error = %v, want errImageNotGenerated", err)
+	}
+}
+
+func TestUploadImage_AttachesToSimulation(t *testing.T) {
+	ctx := context.Background()
+	withImages, _ := newTestImageStoreOption(t)
+	simulations := newTestSimulationStore(t)
+	sim, err := simulations.Create(ctx, simulation.Simulation{OriginalText: "元の文章", InflammatoryText: "炎上文章", Level: 2})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	r := NewResolver(nil, nil, nil, withImages, WithSimulationStore(simulations))
+	mutation := &mutationResolver{r}
+
+	uploaded, err := mutation.UploadImage(ctx, testUpload(encodeTestImage(t, 40, 30, 0xff, false)), &sim.ID)
+	if err != nil {
+		t.Fatalf("UploadImage() error = %v", err)
+	}
+	if uploaded.SimulationID == nil || *uploaded.SimulationID != sim.ID || uploaded.Description != nil {
+		t.Errorf("UploadImage() = %+v, want it attached without a description", uploaded)
+	}
+
+	got, err := (&queryResolver{r}).Simulation(ctx, sim.ID)
+	if err != nil {
+		t.Fatalf("Simulation() error = %v", err)
+	}
+	if got.ImageID == nil || *got.ImageID != *uploaded.ImageID {
+		t.Errorf("Simulation().ImageID = %v, want %s", got.ImageID, *uploaded.ImageID)
+	}
+
+	missing := "missing"
+	if _, err := mutation.UploadImage(ctx, testUpload(encodeTestImage(t, 4, 4, 0xff, false)), &missing); err == nil {
+		t.Error("UploadImage() to a missing simulation succeeded, want error")
+	}
+	noStore := NewResolver(nil, nil, nil, WithSimulationStore(simulations))
+	if _, err := (&mutationResolver{noStore}).UploadImage(ctx, testUpload(encodeTestImage(t, 4, 4, 0xff, false)), &sim.ID); !errors.Is(err, errUploadAttachNeedsStore) {
+		t.Errorf("UploadImage() without a store error = %v, want errUploadAttachNeedsStore", err)
+	}
+}
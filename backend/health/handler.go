@@ -0,0 +1,61 @@
+package health
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// readyzResponse is the body of the readiness endpoint. Errors are left out since the
+// endpoint is public; the health GraphQL query reports them to authenticated callers.
+type readyzResponse struct {
+	Status       Status             `json:"status"`
+	Dependencies []readyzDependency `json:"dependencies"`
+}
+
+type readyzDependency struct {
+	Name      string `json:"name"`
+	Status    Status `json:"status"`
+	Critical  bool   `json:"critical"`
+	LatencyMs int64  `json:"latencyMs"`
+}
+
+// Livez reports that the process is up and serving requests. It checks no dependency, so a
+// failing dependency never gets the process restarted.
+func Livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz returns a handler reporting whether the service is ready to receive traffic. It
+// responds 503 Service Unavailable when a critical dependency is down, so it can gate deploys
+// and load balancers.
+func (c *Checker) Readyz() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Check(r.Context())
+
+		body := readyzResponse{Status: report.Status, Dependencies: make([]readyzDependency, 0, len(report.Dependencies))}
+		for _, result := range report.Dependencies {
+			body.Dependencies = append(body.Dependencies, readyzDependency{
+				Name:      result.Name,
+				Status:    result.Status,
+				Critical:  result.Critical,
+				LatencyMs: result.Latency.Milliseconds(),
+			})
+		}
+
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		writeJSON(w, r, status, body)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.ErrorContext(r.Context(), "failed to encode health response", "error", err)
+	}
+}
@@ -0,0 +1,217 @@
+// Package health checks the dependencies of the service with cheap, cached probes and serves
+// the liveness and readiness endpoints.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Status is the health of a dependency or of the whole service
+type Status string
+
+const (
+	// StatusHealthy means the dependency answered its last probe in time
+	StatusHealthy Status = "HEALTHY"
+	// StatusDegraded means the dependency answered slowly, or for the service, that an optional
+	// dependency is down
+	StatusDegraded Status = "DEGRADED"
+	// StatusDown means the last probe failed, or for the service, that a critical dependency is down
+	StatusDown Status = "DOWN"
+	// StatusUnconfigured means the dependency is optional and not configured
+	StatusUnconfigured Status = "UNCONFIGURED"
+)
+
+// Defaults used by New
+const (
+	// DefaultTTL is how long a probe result is reused, so readiness checks stay cheap
+	DefaultTTL = 30 * time.Second
+	// DefaultTimeout bounds each probe
+	DefaultTimeout = 5 * time.Second
+	// DefaultSlowThreshold is the latency above which a dependency is reported as degraded
+	DefaultSlowThreshold = 2 * time.Second
+)
+
+// Probe checks that a dependency is usable. Probes must be cheap: they run every TTL.
+type Probe func(ctx context.Context) error
+
+// Dependency is something the service needs to work
+type Dependency struct {
+	Name string
+	// Probe checks the dependency; nil means the dependency is not configured
+	Probe Probe
+	// Critical dependencies make the service unready when they are down
+	Critical bool
+	// TTL overrides how long the result is reused, e.g. for APIs that rate limit the probe
+	TTL time.Duration
+}
+
+// Result is the outcome of the latest probe of a dependency
+type Result struct {
+	Name     string
+	Critical bool
+	Status   Status
+	Latency  time.Duration
+	// LastError is the error of the latest probe; empty when it succeeded
+	LastError string
+	// CheckedAt is when the latest probe ran; zero for unconfigured dependencies
+	CheckedAt time.Time
+	// LastSuccessAt is when a probe last succeeded; zero if none has
+	LastSuccessAt time.Time
+}
+
+// Configured reports whether the dependency is configured
+func (r Result) Configured() bool {
+	return r.Status != StatusUnconfigured
+}
+
+// Report is the health of the service and each of its dependencies
+type Report struct {
+	Status       Status
+	CheckedAt    time.Time
+	Dependencies []Result
+}
+
+// Ready reports whether the service can serve traffic, i.e. no critical dependency is down
+func (r Report) Ready() bool {
+	return r.Status != StatusDown
+}
+
+// dependency is a Dependency and its latest result
+type dependency struct {
+	Dependency
+
+	mu     sync.Mutex // Held while probing, so concurrent checks share one probe
+	result Result
+}
+
+// Checker probes dependencies and caches the results
+type Checker struct {
+	dependencies  []*dependency
+	ttl           time.Duration
+	timeout       time.Duration
+	slowThreshold time.Duration
+	now           func() time.Time
+}
+
+// Option is a functional option for the checker
+type Option func(*Checker)
+
+// WithTTL sets how long probe results are reused
+func WithTTL(ttl time.Duration) Option {
+	return func(c *Checker) {
+		c.ttl = ttl
+	}
+}
+
+// WithTimeout sets the time limit of each probe
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Checker) {
+		c.timeout = timeout
+	}
+}
+
+// WithSlowThreshold sets the latency above which a dependency is degraded
+func WithSlowThreshold(threshold time.Duration) Option {
+	return func(c *Checker) {
+		c.slowThreshold = threshold
+	}
+}
+
+// WithClock overrides the clock used for caching (useful for testing)
+func WithClock(now func() time.Time) Option {
+	return func(c *Checker) {
+		c.now = now
+	}
+}
+
+// New creates a checker for dependencies, reported in the given order
+func New(dependencies []Dependency, options ...Option) *Checker {
+	c := &Checker{
+		ttl:           DefaultTTL,
+		timeout:       DefaultTimeout,
+		slowThreshold: DefaultSlowThreshold,
+		now:           time.Now,
+	}
+	for _, dep := range dependencies {
+		c.dependencies = append(c.dependencies, &dependency{
+			Dependency: dep,
+			result:     Result{Name: dep.Name, Critical: dep.Critical, Status: StatusUnconfigured},
+		})
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c
+}
+
+// Check returns the health of every dependency, probing in parallel those whose result is older
+// than the TTL
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: StatusHealthy, CheckedAt: c.now(), Dependencies: make([]Result, len(c.dependencies))}
+
+	var wg sync.WaitGroup
+	for i, dep := range c.dependencies {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Dependencies[i] = c.check(ctx, dep)
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range report.Dependencies {
+		switch {
+		case result.Status == StatusDown && result.Critical:
+			report.Status = StatusDown
+		case (result.Status == StatusDown || result.Status == StatusDegraded) && report.Status == StatusHealthy:
+			report.Status = StatusDegraded
+		}
+	}
+	return report
+}
+
+// check returns the cached result of dep, probing it first if the result is stale
+func (c *Checker) check(ctx context.Context, dep *dependency) Result {
+	if dep.Probe == nil {
+		return dep.result
+	}
+
+	dep.mu.Lock()
+	defer dep.mu.Unlock()
+	ttl := c.ttl
+	if dep.TTL > 0 {
+		ttl = dep.TTL
+	}
+	now := c.now()
+	if !dep.result.CheckedAt.IsZero() && now.Sub(dep.result.CheckedAt) < ttl {
+		return dep.result
+	}
+
+	// The result is shared with other callers, so it must not fail because this caller gave up
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+	start := time.Now()
+	err := dep.Probe(ctx)
+	latency := time.Since(start)
+
+	result := dep.result
+	result.CheckedAt = now
+	result.Latency = latency
+	switch {
+	case err != nil:
+		result.Status = StatusDown
+		result.LastError = err.Error()
+	case latency > c.slowThreshold:
+		result.Status = StatusDegraded
+		result.LastError = ""
+		result.LastSuccessAt = now
+	default:
+		result.Status = StatusHealthy
+		result.LastError = ""
+		result.LastSuccessAt = now
+	}
+	dep.result = result
+	return result
+}
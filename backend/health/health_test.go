@@ -0,0 +1,221 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingProbe returns a probe that fails with err and counts its calls
+func countingProbe(calls *atomic.Int32, err error) Probe {
+	return func(context.Context) error {
+		calls.Add(1)
+		return err
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	failure := errors.New("connection refused")
+	tests := []struct {
+		name         string
+		dependencies []Dependency
+		want         Status
+	}{
+		{
+			name: "all healthy",
+			dependencies: []Dependency{
+				{Name: "gemini", Probe: func(context.Context) error { return nil }, Critical: true},
+				{Name: "storage", Probe: func(context.Context) error { return nil }},
+			},
+			want: StatusHealthy,
+		},
+		{
+			name: "unconfigured dependencies are ignored",
+			dependencies: []Dependency{
+				{Name: "gemini", Probe: func(context.Context) error { return nil }, Critical: true},
+				{Name: "twitter"},
+			},
+			want: StatusHealthy,
+		},
+		{
+			name: "optional dependency down",
+			dependencies: []Dependency{
+				{Name: "gemini", Probe: func(context.Context) error { return nil }, Critical: true},
+				{Name: "storage", Probe: func(context.Context) error { return failure }},
+			},
+			want: StatusDegraded,
+		},
+		{
+			name: "critical dependency down",
+			dependencies: []Dependency{
+				{Name: "gemini", Probe: func(context.Context) error { return failure }, Critical: true},
+				{Name: "storage", Probe: func(context.Context) error { return nil }},
+			},
+			want: StatusDown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := New(tt.dependencies).Check(context.Background())
+
+			if report.Status != tt.want {
+				t.Errorf("Status = %s, want %s", report.Status, tt.want)
+			}
+			if report.Ready() != (tt.want != StatusDown) {
+				t.Errorf("Ready() = %v for status %s", report.Ready(), report.Status)
+			}
+			if len(report.Dependencies) != len(tt.dependencies) {
+				t.Fatalf("got %d dependencies, want %d", len(report.Dependencies), len(tt.dependencies))
+			}
+			for i, result := range report.Dependencies {
+				if result.Name != tt.dependencies[i].Name {
+					t.Errorf("Dependencies[%d].Name = %q, want %q", i, result.Name, tt.dependencies[i].Name)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckResult(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var failing atomic.Bool
+	checker := New([]Dependency{{
+		Name: "database",
+		Probe: func(context.Context) error {
+			if failing.Load() {
+				return errors.New("database is closed")
+			}
+			return nil
+		},
+	}}, WithTTL(time.Second), WithClock(func() time.Time { return now }))
+
+	result := checker.Check(context.Background()).Dependencies[0]
+	if result.Status != StatusHealthy || result.LastError != "" || !result.LastSuccessAt.Equal(now) {
+		t.Errorf("healthy result = %+v", result)
+	}
+
+	failing.Store(true)
+	now = now.Add(time.Minute)
+	result = checker.Check(context.Background()).Dependencies[0]
+	if result.Status != StatusDown {
+		t.Errorf("Status = %s, want %s", result.Status, StatusDown)
+	}
+	if result.LastError != "database is closed" {
+		t.Errorf("LastError = %q", result.LastError)
+	}
+	if !result.CheckedAt.Equal(now) || result.LastSuccessAt.Equal(now) {
+		t.Errorf("CheckedAt = %v, LastSuccessAt = %v; want the success before the failure", result.CheckedAt, result.LastSuccessAt)
+	}
+	if !result.Configured() {
+		t.Error("Configured() = false for a probed dependency")
+	}
+}
+
+func TestCheckCachesResults(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var defaultCalls, longCalls atomic.Int32
+	checker := New([]Dependency{
+		{Name: "gemini", Probe: countingProbe(&defaultCalls, nil)},
+		{Name: "twitter", Probe: countingProbe(&longCalls, nil), TTL: time.Hour},
+	}, WithTTL(30*time.Second), WithClock(func() time.Time { return now }))
+
+	checker.Check(context.Background())
+	checker.Check(context.Background())
+	if defaultCalls.Load() != 1 || longCalls.Load() != 1 {
+		t.Errorf("probes ran %d and %d times within the TTL, want 1", defaultCalls.Load(), longCalls.Load())
+	}
+
+	now = now.Add(time.Minute)
+	checker.Check(context.Background())
+	if defaultCalls.Load() != 2 {
+		t.Errorf("gemini probe ran %d times after the TTL, want 2", defaultCalls.Load())
+	}
+	if longCalls.Load() != 1 {
+		t.Errorf("twitter probe ran %d times within its own TTL, want 1", longCalls.Load())
+	}
+}
+
+func TestCheckSlowProbe(t *testing.T) {
+	checker := New([]Dependency{{
+		Name: "imagen",
+		Probe: func(context.Context) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		},
+	}}, WithSlowThreshold(time.Millisecond))
+
+	report := checker.Check(context.Background())
+	if report.Dependencies[0].Status != StatusDegraded {
+		t.Errorf("Status = %s, want %s", report.Dependencies[0].Status, StatusDegraded)
+	}
+	if report.Status != StatusDegraded {
+		t.Errorf("report Status = %s, want %s", report.Status, StatusDegraded)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	checker := New([]Dependency{{
+		Name: "storage",
+		Probe: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+		Critical: true,
+	}}, WithTimeout(10*time.Millisecond))
+
+	report := checker.Check(context.Background())
+	if report.Status != StatusDown {
+		t.Errorf("Status = %s, want %s", report.Status, StatusDown)
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	tests := []struct {
+		name       string
+		probe      Probe
+		wantStatus int
+	}{
+		{name: "ready", probe: func(context.Context) error { return nil }, wantStatus: http.StatusOK},
+		{name: "critical dependency down", probe: func(context.Context) error { return errors.New("secret detail") }, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := New([]Dependency{{Name: "database", Probe: tt.probe, Critical: true}})
+
+			w := httptest.NewRecorder()
+			checker.Readyz().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", http.NoBody))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			var body readyzResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid body %q: %v", w.Body.String(), err)
+			}
+			if len(body.Dependencies) != 1 || body.Dependencies[0].Name != "database" {
+				t.Errorf("dependencies = %+v", body.Dependencies)
+			}
+			if got := w.Body.String(); strings.Contains(got, "secret detail") {
+				t.Errorf("readiness response leaks the probe error: %s", got)
+			}
+		})
+	}
+}
+
+func TestLivez(t *testing.T) {
+	w := httptest.NewRecorder()
+	Livez(w, httptest.NewRequest(http.MethodGet, "/livez", http.NoBody))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+	}
+}
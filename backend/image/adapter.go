@@ -22,3 +22,21 @@ func (a *Adapter) GenerateImage(ctx context.Context, prompt string) ([]byte, err
 	}
 	return result.ImageData, nil
 }
+
+// GenerateImages generates one or more image variants and returns their data
+func (a *Adapter) GenerateImages(ctx context.Context, prompt string, options ...Option) ([][]byte, error) {
+	results, err := a.client.GenerateImages(ctx, prompt, options...)
+	if err != nil {
+		return nil, err
+	}
+	images := make([][]byte, 0, len(results))
+	for _, result := range results {
+		images = append(images, result.ImageData)
+	}
+	return images, nil
+}
+
+// RenderRequest returns the request the client sends for prompt and options (see Client.RenderRequest)
+func (a *Adapter) RenderRequest(prompt string, options ...Option) (string, error) {
+	return a.client.RenderRequest(prompt, options...)
+}
@@ -2,31 +2,62 @@ package image
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
 	"time"
+	"unicode/utf8"
 
 	"cloud.google.com/go/vertexai/genai"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/Tattsum/enjo/backend/tracing"
 )
 
 const (
-	// Default model for image generation (Imagen 2)
-	// Note: As of 2024, Imagen 2 is available as "imagegeneration@006"
-	// Imagen 3 may not be available in all regions/projects yet
-	defaultImageModel = "imagegeneration@002"
+	// DefaultModel is the Imagen model used unless WithModel selects another, per deployment or request
+	DefaultModel = "imagen-3.0-generate-002"
 	// Default aspect ratio (1:1 for Twitter)
 	defaultAspectRatio = "1:1"
 	// Default location for Vertex AI
 	defaultLocation = "us-central1"
 	// Default number of images to generate
 	defaultSampleCount = 1
+	// MaxSampleCount is the largest number of images Imagen returns for one request
+	MaxSampleCount = 4
+	// defaultNegativePrompt steers Imagen away from common artifacts
+	defaultNegativePrompt = "blurry, low quality, distorted, watermark, text"
+)
+
+// Values accepted by WithPersonGeneration
+const (
+	PersonGenerationDontAllow  = "dont_allow"
+	PersonGenerationAllowAdult = "allow_adult"
+	PersonGenerationAllowAll   = "allow_all"
+)
+
+// Values accepted by WithSafetySetting
+const (
+	SafetyBlockLowAndAbove    = "block_low_and_above"
+	SafetyBlockMediumAndAbove = "block_medium_and_above"
+	SafetyBlockOnlyHigh       = "block_only_high"
+	SafetyBlockNone           = "block_none"
 )
 
+// ErrInvalidOption is returned when an option has a value Imagen does not accept
+var ErrInvalidOption = errors.New("invalid image option")
+
+// modelPattern restricts model IDs to characters that are safe in the endpoint path
+var modelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.@_-]*$`)
+
 // Client is a Vertex AI client for generating images using Imagen
 type Client struct {
 	client    *genai.Client
 	projectID string
 	location  string
+	// defaults are deployment-wide options applied before the options of each request
+	defaults []Option
 }
 
 // Result represents the result of image generation
@@ -46,16 +77,60 @@ type imageOptions struct {
 	aspectRatio string
 	width       int
 	height      int
+	sampleCount int
+	seed        *int64
+	// negativePrompt replaces the default negative prompt when non-empty
+	negativePrompt   string
+	model            string
+	imageSize        string
+	personGeneration string
+	safetySetting    string
+}
+
+// newImageOptions applies options on top of the package defaults
+func newImageOptions(options ...Option) *imageOptions {
+	opts := &imageOptions{
+		aspectRatio:    defaultAspectRatio,
+		sampleCount:    defaultSampleCount,
+		negativePrompt: defaultNegativePrompt,
+		model:          DefaultModel,
+	}
+	for _, opt := range options {
+		opt(opts)
+	}
+	return opts
 }
 
-// NewClient creates a new Imagen API client using Application Default Credentials
-func NewClient(ctx context.Context, projectID, location string) (*Client, error) {
+// validate rejects values Imagen would not accept
+func (o *imageOptions) validate() error {
+	if !modelPattern.MatchString(o.model) {
+		return fmt.Errorf("%w: model %q", ErrInvalidOption, o.model)
+	}
+	switch o.personGeneration {
+	case "", PersonGenerationDontAllow, PersonGenerationAllowAdult, PersonGenerationAllowAll:
+	default:
+		return fmt.Errorf("%w: personGeneration %q", ErrInvalidOption, o.personGeneration)
+	}
+	switch o.safetySetting {
+	case "", SafetyBlockLowAndAbove, SafetyBlockMediumAndAbove, SafetyBlockOnlyHigh, SafetyBlockNone:
+	default:
+		return fmt.Errorf("%w: safetySetting %q", ErrInvalidOption, o.safetySetting)
+	}
+	return nil
+}
+
+// NewClient creates a new Imagen API client using Application Default Credentials.
+// defaults configure every request of this deployment; request options take precedence.
+func NewClient(ctx context.Context, projectID, location string, defaults ...Option) (*Client, error) {
 	if projectID == "" {
 		return nil, errors.New("GCP project ID is required")
 	}
 	if location == "" {
 		location = defaultLocation
 	}
+	if err := newImageOptions(defaults...).validate(); err != nil {
+		return nil, err
+	}
 
 	client, err := genai.NewClient(ctx, projectID, location)
 	if err != nil {
@@ -66,6 +141,7 @@ func NewClient(ctx context.Context, projectID, location string) (*Client, error)
 		client:    client,
 		projectID: projectID,
 		location:  location,
+		defaults:  defaults,
 	}, nil
 }
 
@@ -79,42 +155,91 @@ func (c *Client) Close() error {
 
 // GenerateImage generates an image based on the prompt
 func (c *Client) GenerateImage(ctx context.Context, prompt string, options ...Option) (*Result, error) {
-	if prompt == "" {
-		return nil, errors.New("prompt is required")
+	results, err := c.GenerateImages(ctx, prompt, options...)
+	if err != nil {
+		return nil, err
 	}
+	return results[0], nil
+}
 
-	// Apply options
-	opts := &imageOptions{
-		aspectRatio: defaultAspectRatio,
-	}
-	for _, opt := range options {
-		opt(opts)
+// GenerateImages generates one or more variants of an image based on the prompt.
+// The number of variants is set with WithSampleCount.
+func (c *Client) GenerateImages(ctx context.Context, prompt string, options ...Option) ([]*Result, error) {
+	if prompt == "" {
+		return nil, errors.New("prompt is required")
 	}
 
-	// Add style to the prompt if specified
-	enhancedPrompt := prompt
-	if opts.style != "" {
-		enhancedPrompt = prompt + getStyleHint(opts.style)
+	// Apply the deployment defaults, then the request options
+	opts := newImageOptions(append(append([]Option{}, c.defaults...), options...)...)
+	if err := opts.validate(); err != nil {
+		return nil, err
 	}
 
-	// Generate the image using REST API
+	// Generate the images using REST API
 	// The current genai SDK doesn't fully support Imagen API
-	imageDataBase64, err := c.generateImageViaREST(ctx, enhancedPrompt, opts)
+	styled := styledPrompt(prompt, opts.style)
+	spanCtx, span := tracing.Start(ctx, "imagen.generate",
+		attribute.String("gen_ai.system", "vertex_ai"),
+		attribute.String("gen_ai.request.model", opts.model),
+		attribute.Int("enjo.prompt.length", utf8.RuneCountInString(styled)),
+		attribute.Int("enjo.images.requested", opts.sampleCount),
+		attribute.String("enjo.aspect_ratio", opts.aspectRatio),
+	)
+	start := time.Now()
+	images, err := c.generateImageViaREST(spanCtx, styled, opts)
+	observe(opts.model, start, opts.sampleCount, len(images), err)
+	span.SetAttributes(attribute.Int("enjo.images.generated", len(images)))
+	tracing.End(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate image: %w", err)
 	}
 
-	if len(imageDataBase64) == 0 {
+	generatedAt := time.Now()
+	results := make([]*Result, 0, len(images))
+	for _, imageData := range images {
+		if len(imageData) == 0 {
+			continue
+		}
+		results = append(results, &Result{
+			ImageData:   imageData,
+			Prompt:      prompt,
+			GeneratedAt: generatedAt,
+		})
+	}
+	if len(results) == 0 {
 		return nil, errors.New("no image data generated")
 	}
 
-	result := &Result{
-		ImageData:   imageDataBase64, // Base64 encoded
-		Prompt:      prompt,
-		GeneratedAt: time.Now(),
+	return results, nil
+}
+
+// RenderRequest returns the model and the predict request sent for prompt with the deployment
+// defaults and options applied. Caches key on it, so any change to a parameter, default or
+// style hint makes a different request.
+func (c *Client) RenderRequest(prompt string, options ...Option) (string, error) {
+	return RenderRequest(prompt, append(append([]Option{}, c.defaults...), options...)...)
+}
+
+// RenderRequest returns the model and the predict request sent for prompt with options
+func RenderRequest(prompt string, options ...Option) (string, error) {
+	opts := newImageOptions(options...)
+	if err := opts.validate(); err != nil {
+		return "", err
 	}
 
-	return result, nil
+	body, err := json.Marshal(buildImagenRequest(styledPrompt(prompt, opts.style), opts))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	return opts.model + "\n" + string(body), nil
+}
+
+// styledPrompt appends the hint for style to prompt, if a style is set
+func styledPrompt(prompt, style string) string {
+	if style == "" {
+		return prompt
+	}
+	return prompt + getStyleHint(style)
 }
 
 // getStyleHint returns the style hint for the given style
@@ -147,6 +272,68 @@ func WithAspectRatio(ratio string) Option {
 	}
 }
 
+// WithSampleCount sets how many variants to generate, clamped to 1..MaxSampleCount
+func WithSampleCount(n int) Option {
+	return func(opts *imageOptions) {
+		opts.sampleCount = min(max(n, 1), MaxSampleCount)
+	}
+}
+
+// WithSeed makes generation deterministic for the given seed
+func WithSeed(seed int64) Option {
+	return func(opts *imageOptions) {
+		opts.seed = &seed
+	}
+}
+
+// WithNegativePrompt replaces the default negative prompt.
+// An empty string keeps the default.
+func WithNegativePrompt(negativePrompt string) Option {
+	return func(opts *imageOptions) {
+		if negativePrompt != "" {
+			opts.negativePrompt = negativePrompt
+		}
+	}
+}
+
+// WithoutNegativePrompt sends no negative prompt, for models that do not support one
+func WithoutNegativePrompt() Option {
+	return func(opts *imageOptions) {
+		opts.negativePrompt = ""
+	}
+}
+
+// WithModel selects the Imagen model, e.g. "imagen-3.0-generate-002".
+// An empty string keeps the default.
+func WithModel(model string) Option {
+	return func(opts *imageOptions) {
+		if model != "" {
+			opts.model = model
+		}
+	}
+}
+
+// WithImageSize sets Imagen's sampleImageSize (e.g. "1K" or "2K"), for models that support it
+func WithImageSize(size string) Option {
+	return func(opts *imageOptions) {
+		opts.imageSize = size
+	}
+}
+
+// WithPersonGeneration controls whether people may be generated (see the PersonGeneration constants)
+func WithPersonGeneration(setting string) Option {
+	return func(opts *imageOptions) {
+		opts.personGeneration = setting
+	}
+}
+
+// WithSafetySetting sets the Responsible AI filter threshold (see the Safety constants)
+func WithSafetySetting(setting string) Option {
+	return func(opts *imageOptions) {
+		opts.safetySetting = setting
+	}
+}
+
 // WithSize sets the image dimensions
 func WithSize(width, height int) Option {
 	return func(opts *imageOptions) {
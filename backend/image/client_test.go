@@ -2,6 +2,8 @@ package image
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 )
 
@@ -187,4 +189,91 @@ func TestImageOptions(t *testing.T) {
 			t.Errorf("expected height 1024, got %d", opts.height)
 		}
 	})
+
+	t.Run("WithSampleCount option clamps to the supported range", func(t *testing.T) {
+		for n, want := range map[int]int{0: 1, 1: 1, 3: 3, 4: 4, 10: MaxSampleCount} {
+			opts := &imageOptions{}
+			WithSampleCount(n)(opts)
+			if opts.sampleCount != want {
+				t.Errorf("WithSampleCount(%d): expected %d, got %d", n, want, opts.sampleCount)
+			}
+		}
+	})
+
+	t.Run("WithSeed option", func(t *testing.T) {
+		opts := &imageOptions{}
+		WithSeed(42)(opts)
+		if opts.seed == nil || *opts.seed != 42 {
+			t.Errorf("expected seed 42, got %v", opts.seed)
+		}
+	})
+
+	t.Run("WithNegativePrompt option keeps default when empty", func(t *testing.T) {
+		opts := &imageOptions{negativePrompt: defaultNegativePrompt}
+		WithNegativePrompt("")(opts)
+		if opts.negativePrompt != defaultNegativePrompt {
+			t.Errorf("expected default negative prompt, got %s", opts.negativePrompt)
+		}
+		WithNegativePrompt("people")(opts)
+		if opts.negativePrompt != "people" {
+			t.Errorf("expected negative prompt 'people', got %s", opts.negativePrompt)
+		}
+	})
+
+	t.Run("request options override deployment defaults", func(t *testing.T) {
+		opts := newImageOptions(
+			WithModel("imagen-4.0-generate-001"), WithSafetySetting(SafetyBlockOnlyHigh), WithoutNegativePrompt(),
+			WithModel(""), WithSafetySetting(SafetyBlockLowAndAbove),
+		)
+		if opts.model != "imagen-4.0-generate-001" {
+			t.Errorf("expected empty model to keep the default, got %s", opts.model)
+		}
+		if opts.safetySetting != SafetyBlockLowAndAbove {
+			t.Errorf("expected the later safety setting, got %s", opts.safetySetting)
+		}
+		if opts.negativePrompt != "" {
+			t.Errorf("expected no negative prompt, got %s", opts.negativePrompt)
+		}
+	})
+
+	t.Run("validate rejects unknown values", func(t *testing.T) {
+		for name, opt := range map[string]Option{
+			"model":            WithModel("../../other-endpoint"),
+			"personGeneration": WithPersonGeneration("everyone"),
+			"safetySetting":    WithSafetySetting("block_everything"),
+		} {
+			if err := newImageOptions(opt).validate(); !errors.Is(err, ErrInvalidOption) {
+				t.Errorf("%s: expected ErrInvalidOption, got %v", name, err)
+			}
+		}
+		if err := newImageOptions(WithPersonGeneration(PersonGenerationAllowAdult)).validate(); err != nil {
+			t.Errorf("expected valid options, got %v", err)
+		}
+	})
+}
+
+func TestRenderRequest(t *testing.T) {
+	base, err := RenderRequest("a flame", WithStyle("MEME"))
+	if err != nil {
+		t.Fatalf("RenderRequest() error = %v", err)
+	}
+	if !strings.Contains(base, DefaultModel) || !strings.Contains(base, "meme style") {
+		t.Errorf("RenderRequest() = %s, want the model and the styled prompt", base)
+	}
+	if again, _ := RenderRequest("a flame", WithStyle("MEME")); again != base {
+		t.Errorf("RenderRequest() is not deterministic: %s != %s", again, base)
+	}
+
+	client := &Client{defaults: []Option{WithModel("imagen-4.0-generate-001")}}
+	withDefaults, err := client.RenderRequest("a flame", WithStyle("MEME"))
+	if err != nil {
+		t.Fatalf("Client.RenderRequest() error = %v", err)
+	}
+	if withDefaults == base || !strings.Contains(withDefaults, "imagen-4.0-generate-001") {
+		t.Errorf("Client.RenderRequest() = %s, want the deployment model", withDefaults)
+	}
+
+	if _, err := RenderRequest("a flame", WithSafetySetting("invalid")); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("RenderRequest() error = %v, want ErrInvalidOption", err)
+	}
 }
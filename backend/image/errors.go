@@ -0,0 +1,48 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// FilteredError is returned when Imagen's Responsible AI filters removed every requested image
+type FilteredError struct {
+	// Reasons are Imagen's raiFilteredReason messages; empty when Imagen gave none
+	Reasons []string
+}
+
+func (e *FilteredError) Error() string {
+	if len(e.Reasons) == 0 {
+		return "all images were filtered by Imagen's safety filters"
+	}
+	return "all images were filtered by Imagen's safety filters: " + strings.Join(e.Reasons, "; ")
+}
+
+// APIError is an unsuccessful response from the Imagen API, e.g. a prompt rejected by the safety filters
+type APIError struct {
+	StatusCode int
+	Status     string // Google API status such as "INVALID_ARGUMENT"
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Status == "" {
+		return fmt.Sprintf("API error (status %d): %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("API error (status %d %s): %s", e.StatusCode, e.Status, e.Message)
+}
+
+// newAPIError parses a Google API error body, falling back to the raw body as the message
+func newAPIError(statusCode int, body []byte) *APIError {
+	var payload struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil || payload.Error.Message == "" {
+		return &APIError{StatusCode: statusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return &APIError{StatusCode: statusCode, Status: payload.Error.Status, Message: payload.Error.Message}
+}
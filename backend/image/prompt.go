@@ -2,8 +2,11 @@ package image
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
+	"strings"
 )
 
 // GeminiClient is an interface for generating content using Gemini
@@ -11,37 +14,189 @@ type GeminiClient interface {
 	GenerateContent(ctx context.Context, prompt string) (string, error)
 }
 
-// GenerateImagePrompt generates an image generation prompt from inflammatory text using Gemini
-func GenerateImagePrompt(ctx context.Context, geminiClient GeminiClient, inflammatoryText string) (string, error) {
-	if inflammatoryText == "" {
-		return "", errors.New("inflammatory text is required")
+// ErrEmptyPost is returned when a prompt is requested for an empty post
+var ErrEmptyPost = errors.New("post text is required")
+
+// PromptRequest describes the image to write a prompt for
+type PromptRequest struct {
+	Post        string // Post the image accompanies
+	Style       string // Style name such as "MEME"; empty for the general template
+	AspectRatio string // Aspect ratio such as "16:9"; empty for square
+	Sanitize    bool   // Removes policy terms from the generated prompt
+}
+
+// PromptResult is the prompt written for an image and why it was written that way
+type PromptResult struct {
+	Prompt    string
+	Reasoning string   // Gemini's explanation of the visual concept; empty when it gave none
+	Removed   []string // Policy terms removed by sanitization, in the order of the term list
+}
+
+// defaultPolicyTerms are words that commonly get Imagen prompts rejected or filtered
+var defaultPolicyTerms = []string{
+	"blood", "bloody", "gore", "gory", "corpse", "dead body", "kill", "killing", "murder",
+	"suicide", "self-harm", "gun", "guns", "rifle", "weapon", "weapons", "knife", "bomb", "explosion",
+	"nude", "naked", "nsfw", "sexy", "lingerie",
+	"drug", "drugs", "cocaine",
+	"celebrity", "politician", "logo", "trademark",
+}
+
+// styleDirections are the style-specific requirements of the prompt template
+var styleDirections = map[string][]string{
+	"REALISTIC": {
+		"写真のようにリアルな描写（photorealistic）",
+		"自然な光と質感、実在しそうなシーン",
+	},
+	"ILLUSTRATION": {
+		"カラフルで目を引くデジタルイラスト",
+		"ポップで遊び心のある構図",
+	},
+	"MEME": {
+		"ネットミーム風の誇張された表情やリアクション",
+		"日本のネット文化に馴染む、思わず笑える構図",
+	},
+	"DRAMATIC": {
+		"映画のワンシーンのようなドラマチックな照明",
+		"緊張感のある大胆な構図",
+	},
+}
+
+// defaultDirections are used when no style or an unknown style is requested
+var defaultDirections = []string{
+	"SNS映えする構図",
+	"ミーム的な要素",
+	"日本のネット文化に馴染む表現",
+}
+
+// PromptPipeline writes Imagen prompts for posts using Gemini
+type PromptPipeline struct {
+	client GeminiClient
+	terms  []string
+}
+
+// PromptOption is a functional option for the prompt pipeline
+type PromptOption func(*PromptPipeline)
+
+// WithPolicyTerms replaces the terms removed by sanitization
+func WithPolicyTerms(terms []string) PromptOption {
+	return func(p *PromptPipeline) {
+		p.terms = terms
 	}
+}
+
+// NewPromptPipeline creates a prompt pipeline backed by the Gemini client
+func NewPromptPipeline(client GeminiClient, options ...PromptOption) *PromptPipeline {
+	p := &PromptPipeline{client: client, terms: defaultPolicyTerms}
+	for _, opt := range options {
+		opt(p)
+	}
+	return p
+}
+
+// promptResponse is the JSON document Gemini is asked to return
+type promptResponse struct {
+	Prompt    string `json:"prompt"`
+	Reasoning string `json:"reasoning"`
+}
 
-	// Build the prompt template
-	promptTemplate := buildImagePromptTemplate(inflammatoryText)
+// Generate writes an English Imagen prompt for the post in the requested style
+func (p *PromptPipeline) Generate(ctx context.Context, req PromptRequest) (*PromptResult, error) {
+	if strings.TrimSpace(req.Post) == "" {
+		return nil, ErrEmptyPost
+	}
 
-	// Use Gemini to generate the image prompt
-	imagePrompt, err := geminiClient.GenerateContent(ctx, promptTemplate)
+	raw, err := p.client.GenerateContent(ctx, buildImagePromptTemplate(req))
 	if err != nil {
-		return "", fmt.Errorf("failed to generate image prompt: %w", err)
+		return nil, fmt.Errorf("failed to generate image prompt: %w", err)
 	}
 
-	return imagePrompt, nil
+	result := parsePromptResponse(raw)
+	if req.Sanitize {
+		result.Prompt, result.Removed = sanitizePrompt(result.Prompt, p.terms)
+	}
+	if result.Prompt == "" {
+		return nil, errors.New("failed to generate image prompt: empty prompt")
+	}
+	return result, nil
 }
 
-// buildImagePromptTemplate builds a template for generating image prompts
-func buildImagePromptTemplate(inflammatoryText string) string {
-	template := fmt.Sprintf(`Create a short English image prompt (max 80 words) for this social media post:
+// buildImagePromptTemplate builds the Gemini prompt for writing an image prompt
+func buildImagePromptTemplate(req PromptRequest) string {
+	directions, ok := styleDirections[req.Style]
+	if !ok {
+		directions = defaultDirections
+	}
 
-"%s"
+	var b strings.Builder
+	b.WriteString("以下の炎上投稿に合わせた、視覚的にインパクトのある画像生成プロンプトを作成してください。\n\n")
+	fmt.Fprintf(&b, "【投稿】\n%s\n\n", req.Post)
+	b.WriteString("【要件】\n- 投稿の雰囲気を視覚的に表現\n- 炎のモチーフを含める\n")
+	for _, d := range directions {
+		fmt.Fprintf(&b, "- %s\n", d)
+	}
+	fmt.Fprintf(&b, "- %s\n", compositionHint(req.AspectRatio))
+	b.WriteString("- 実在の人物・ロゴ・暴力・性的な表現は含めない\n\n")
+	b.WriteString(`次のJSONのみを出力してください。説明は不要です。
+{"prompt": "英語の画像生成プロンプト（80語以内）", "reasoning": "このビジュアルにした理由（日本語で1〜2文）"}`)
+	return b.String()
+}
 
-Style:
-- Colorful, eye-catching illustration
-- Fun and playful composition
-- Social media friendly, vibrant colors
-- Modern digital art style
+// compositionHint describes the framing suited to the aspect ratio
+func compositionHint(aspectRatio string) string {
+	switch aspectRatio {
+	case "16:9", "4:3":
+		return "横長の画面を活かしたワイドな構図"
+	case "9:16", "3:4":
+		return "縦長の画面に収まる縦構図"
+	default:
+		return "正方形の画面に収まる中央寄せの構図"
+	}
+}
 
-Output ONLY the English prompt, no explanations.`, inflammatoryText)
+// parsePromptResponse reads Gemini's JSON answer. Answers that are not JSON are used as the prompt itself.
+func parsePromptResponse(raw string) *PromptResult {
+	var resp promptResponse
+	if err := json.Unmarshal([]byte(extractJSONObject(raw)), &resp); err != nil || resp.Prompt == "" {
+		return &PromptResult{Prompt: strings.TrimSpace(raw)}
+	}
+	return &PromptResult{Prompt: strings.TrimSpace(resp.Prompt), Reasoning: strings.TrimSpace(resp.Reasoning)}
+}
+
+// extractJSONObject returns the outermost JSON object in s, tolerating markdown code fences around it
+func extractJSONObject(s string) string {
+	start := strings.Index(s, "{")
+	end := strings.LastIndex(s, "}")
+	if start < 0 || end < start {
+		return s
+	}
+	return s[start : end+1]
+}
+
+// sanitizePrompt removes whole-word, case-insensitive occurrences of terms from prompt
+// and returns the cleaned prompt with the terms that were removed
+func sanitizePrompt(prompt string, terms []string) (string, []string) {
+	var removed []string
+	for _, term := range terms {
+		pattern := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `\b`)
+		if pattern.MatchString(prompt) {
+			removed = append(removed, term)
+			prompt = pattern.ReplaceAllString(prompt, "")
+		}
+	}
+	return tidyPrompt(prompt), removed
+}
+
+var (
+	// repeatedSpaces matches runs of whitespace left behind by removed terms
+	repeatedSpaces = regexp.MustCompile(`\s+`)
+	// danglingPunctuation matches separators left without a word in between
+	danglingPunctuation = regexp.MustCompile(`\s*([,;])(\s*[,;])+`)
+)
 
-	return template
+// tidyPrompt collapses the whitespace and separators left behind by sanitization
+func tidyPrompt(prompt string) string {
+	prompt = repeatedSpaces.ReplaceAllString(prompt, " ")
+	prompt = danglingPunctuation.ReplaceAllString(prompt, "$1")
+	prompt = strings.ReplaceAll(prompt, " ,", ",")
+	return strings.Trim(prompt, " ,;")
 }
@@ -2,6 +2,7 @@ package image
 
 import (
 	"context"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -18,67 +19,144 @@ func (m *mockGeminiClient) GenerateContent(ctx context.Context, prompt string) (
 	return "A dramatic scene with flames and social media chaos", nil
 }
 
-func TestGenerateImagePrompt(t *testing.T) {
+func TestPromptPipeline_Generate(t *testing.T) {
 	ctx := context.Background()
 
-	t.Run("successful prompt generation", func(t *testing.T) {
+	t.Run("returns the prompt and reasoning", func(t *testing.T) {
 		mockClient := &mockGeminiClient{
 			generateContentFunc: func(_ context.Context, prompt string) (string, error) {
-				// Verify that the prompt contains the inflammatory text
 				if !strings.Contains(prompt, "テスト投稿") {
-					t.Error("expected prompt to contain inflammatory text")
+					t.Error("expected prompt to contain the post")
 				}
-				return "A dramatic image of fire and controversy on social media", nil
+				return "```json\n{\"prompt\": \"A phone engulfed in cartoon flames\", \"reasoning\": \"炎上を文字通り表現\"}\n```", nil
 			},
 		}
 
-		text := "テスト投稿"
-		result, err := GenerateImagePrompt(ctx, mockClient, text)
+		result, err := NewPromptPipeline(mockClient).Generate(ctx, PromptRequest{Post: "テスト投稿"})
 		if err != nil {
 			t.Fatalf("expected no error, got %v", err)
 		}
-		if result == "" {
-			t.Error("expected non-empty result")
+		if result.Prompt != "A phone engulfed in cartoon flames" || result.Reasoning != "炎上を文字通り表現" {
+			t.Errorf("unexpected result %+v", result)
 		}
 	})
 
-	t.Run("error when inflammatory text is empty", func(t *testing.T) {
-		mockClient := &mockGeminiClient{}
-		_, err := GenerateImagePrompt(ctx, mockClient, "")
-		if err == nil {
-			t.Fatal("expected error when inflammatory text is empty")
+	t.Run("uses a plain text answer as the prompt", func(t *testing.T) {
+		result, err := NewPromptPipeline(&mockGeminiClient{}).Generate(ctx, PromptRequest{Post: "テスト投稿"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if result.Prompt != "A dramatic scene with flames and social media chaos" || result.Reasoning != "" {
+			t.Errorf("unexpected result %+v", result)
 		}
 	})
 
-	t.Run("error from gemini client", func(t *testing.T) {
+	t.Run("sanitizes policy terms when requested", func(t *testing.T) {
 		mockClient := &mockGeminiClient{
 			generateContentFunc: func(_ context.Context, _ string) (string, error) {
-				return "", context.DeadlineExceeded
+				return `{"prompt": "An angry crowd, blood on the floor, a Gun in hand", "reasoning": "r"}`, nil
 			},
 		}
+		pipeline := NewPromptPipeline(mockClient)
+
+		result, err := pipeline.Generate(ctx, PromptRequest{Post: "テスト投稿", Sanitize: true})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if result.Prompt != "An angry crowd, on the floor, a in hand" {
+			t.Errorf("unexpected sanitized prompt %q", result.Prompt)
+		}
+		if len(result.Removed) != 2 || result.Removed[0] != "blood" || result.Removed[1] != "gun" {
+			t.Errorf("expected blood and gun to be removed, got %q", result.Removed)
+		}
 
-		text := "テスト投稿"
-		_, err := GenerateImagePrompt(ctx, mockClient, text)
-		if err == nil {
-			t.Fatal("expected error from gemini client")
+		unsanitized, err := pipeline.Generate(ctx, PromptRequest{Post: "テスト投稿"})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if !strings.Contains(unsanitized.Prompt, "blood") || len(unsanitized.Removed) != 0 {
+			t.Errorf("expected the prompt to be left untouched, got %+v", unsanitized)
 		}
 	})
-}
 
-func TestBuildImagePromptTemplate(t *testing.T) {
-	t.Run("builds prompt with inflammatory text", func(t *testing.T) {
-		text := "これは炎上しやすい投稿です"
-		prompt := buildImagePromptTemplate(text)
+	t.Run("custom policy terms", func(t *testing.T) {
+		mockClient := &mockGeminiClient{
+			generateContentFunc: func(_ context.Context, _ string) (string, error) {
+				return `{"prompt": "smartphone, logo, fire"}`, nil
+			},
+		}
 
-		if !strings.Contains(prompt, text) {
-			t.Errorf("expected prompt to contain text %q", text)
+		result, err := NewPromptPipeline(mockClient, WithPolicyTerms([]string{"smartphone"})).Generate(ctx, PromptRequest{Post: "x", Sanitize: true})
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
 		}
-		// Updated to match new prompt template (social media friendly, colorful)
-		if !strings.Contains(prompt, "social media") && !strings.Contains(prompt, "Social media") {
-			t.Error("expected prompt to mention social media")
+		if result.Prompt != "logo, fire" {
+			t.Errorf("unexpected sanitized prompt %q", result.Prompt)
 		}
-		if !strings.Contains(prompt, "English") {
-			t.Error("expected prompt to request English output")
+	})
+
+	t.Run("error when the post is empty", func(t *testing.T) {
+		_, err := NewPromptPipeline(&mockGeminiClient{}).Generate(ctx, PromptRequest{Post: " "})
+		if !errors.Is(err, ErrEmptyPost) {
+			t.Fatalf("expected ErrEmptyPost, got %v", err)
 		}
 	})
+
+	t.Run("error when sanitization leaves nothing", func(t *testing.T) {
+		mockClient := &mockGeminiClient{
+			generateContentFunc: func(_ context.Context, _ string) (string, error) {
+				return `{"prompt": "gore, blood"}`, nil
+			},
+		}
+		if _, err := NewPromptPipeline(mockClient).Generate(ctx, PromptRequest{Post: "x", Sanitize: true}); err == nil {
+			t.Fatal("expected error for an empty prompt")
+		}
+	})
+
+	t.Run("error from gemini client", func(t *testing.T) {
+		mockClient := &mockGeminiClient{
+			generateContentFunc: func(_ context.Context, _ string) (string, error) {
+				return "", context.DeadlineExceeded
+			},
+		}
+		_, err := NewPromptPipeline(mockClient).Generate(ctx, PromptRequest{Post: "テスト投稿"})
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected error from gemini client, got %v", err)
+		}
+	})
+}
+
+func TestBuildImagePromptTemplate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  PromptRequest
+		want []string
+	}{
+		{
+			name: "general template",
+			req:  PromptRequest{Post: "これは炎上しやすい投稿です"},
+			want: []string{"これは炎上しやすい投稿です", "炎のモチーフ", "SNS映え", "正方形", "JSON"},
+		},
+		{
+			name: "style specific template",
+			req:  PromptRequest{Post: "投稿", Style: "REALISTIC", AspectRatio: "16:9"},
+			want: []string{"photorealistic", "横長"},
+		},
+		{
+			name: "portrait composition",
+			req:  PromptRequest{Post: "投稿", Style: "MEME", AspectRatio: "9:16"},
+			want: []string{"ネットミーム", "縦長"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt := buildImagePromptTemplate(tt.req)
+			for _, want := range tt.want {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("expected prompt to contain %q", want)
+				}
+			}
+		})
+	}
 }
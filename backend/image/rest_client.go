@@ -3,15 +3,23 @@ package image
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"golang.org/x/oauth2/google"
+
+	"github.com/Tattsum/enjo/backend/logging"
+	"github.com/Tattsum/enjo/backend/metrics"
 )
 
+// cloudPlatformScope is the OAuth2 scope of the Vertex AI API
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
 // ImagenRequest represents the request to Imagen API
 type ImagenRequest struct {
 	Instances  []ImagenInstance `json:"instances"`
@@ -29,6 +37,13 @@ type ImagenParameters struct {
 	AspectRatio     string `json:"aspectRatio,omitempty"`
 	NegativePrompt  string `json:"negativePrompt,omitempty"`
 	SampleImageSize string `json:"sampleImageSize,omitempty"`
+	Seed            *int64 `json:"seed,omitempty"`
+	// AddWatermark must be disabled for the seed to take effect
+	AddWatermark     *bool  `json:"addWatermark,omitempty"`
+	PersonGeneration string `json:"personGeneration,omitempty"`
+	SafetySetting    string `json:"safetySetting,omitempty"`
+	// IncludeRaiReason makes Imagen explain images removed by its safety filters
+	IncludeRaiReason bool `json:"includeRaiReason"`
 }
 
 // ImagenResponse represents the response from Imagen API
@@ -40,12 +55,84 @@ type ImagenResponse struct {
 type ImagenPrediction struct {
 	BytesBase64Encoded string `json:"bytesBase64Encoded"`
 	MimeType           string `json:"mimeType"`
+	// RaiFilteredReason explains why an image was filtered; such predictions carry no image
+	RaiFilteredReason string `json:"raiFilteredReason,omitempty"`
 }
 
-// generateImageViaREST generates an image using Imagen REST API
-func (c *Client) generateImageViaREST(ctx context.Context, prompt string, opts *imageOptions) ([]byte, error) {
+// buildImagenRequest builds the predict request payload for prompt and opts
+func buildImagenRequest(prompt string, opts *imageOptions) ImagenRequest {
+	request := ImagenRequest{
+		Instances: []ImagenInstance{
+			{Prompt: prompt},
+		},
+		Parameters: ImagenParameters{
+			SampleCount:      opts.sampleCount,
+			AspectRatio:      opts.aspectRatio,
+			NegativePrompt:   opts.negativePrompt,
+			SampleImageSize:  opts.imageSize,
+			PersonGeneration: opts.personGeneration,
+			SafetySetting:    opts.safetySetting,
+			IncludeRaiReason: true,
+		},
+	}
+	if opts.seed != nil {
+		addWatermark := false
+		request.Parameters.Seed = opts.seed
+		request.Parameters.AddWatermark = &addWatermark
+	}
+	return request
+}
+
+// decodePredictions decodes every prediction in an Imagen response into raw image bytes.
+// Images removed by the safety filters are skipped; if none are left a *FilteredError is returned.
+func decodePredictions(body []byte) ([][]byte, error) {
+	var imagenResp ImagenResponse
+	if err := json.Unmarshal(body, &imagenResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	images := make([][]byte, 0, len(imagenResp.Predictions))
+	var reasons []string
+	for i, prediction := range imagenResp.Predictions {
+		if prediction.BytesBase64Encoded == "" {
+			if prediction.RaiFilteredReason != "" {
+				reasons = append(reasons, prediction.RaiFilteredReason)
+			}
+			continue
+		}
+
+		// Decode the base64 payload into raw image bytes
+		imageData, err := base64.StdEncoding.DecodeString(prediction.BytesBase64Encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image data %d: %w", i, err)
+		}
+		images = append(images, imageData)
+	}
+
+	// Imagen omits filtered images, so an empty response also means everything was filtered
+	if len(images) == 0 {
+		return nil, &FilteredError{Reasons: reasons}
+	}
+	return images, nil
+}
+
+// Ping checks that an access token for the Imagen API can be obtained, which catches missing
+// or expired credentials without paying for a prediction
+func (c *Client) Ping(ctx context.Context) error {
+	creds, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
+	if err != nil {
+		return fmt.Errorf("failed to get credentials: %w", err)
+	}
+	if _, err := creds.TokenSource.Token(); err != nil {
+		return fmt.Errorf("failed to get token: %w", err)
+	}
+	return nil
+}
+
+// generateImageViaREST generates images using Imagen REST API
+func (c *Client) generateImageViaREST(ctx context.Context, prompt string, opts *imageOptions) ([][]byte, error) {
 	// Get OAuth2 token
-	creds, err := google.FindDefaultCredentials(ctx, "https://www.googleapis.com/auth/cloud-platform")
+	creds, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get credentials: %w", err)
 	}
@@ -61,23 +148,10 @@ func (c *Client) generateImageViaREST(ctx context.Context, prompt string, opts *
 		c.location,
 		c.projectID,
 		c.location,
-		defaultImageModel,
+		opts.model,
 	)
 
-	// Build the request payload
-	request := ImagenRequest{
-		Instances: []ImagenInstance{
-			{Prompt: prompt},
-		},
-		Parameters: ImagenParameters{
-			SampleCount:     defaultSampleCount,
-			AspectRatio:     opts.aspectRatio,
-			NegativePrompt:  "blurry, low quality, distorted, watermark, text",
-			SampleImageSize: "1024",
-		},
-	}
-
-	requestBody, err := json.Marshal(request)
+	requestBody, err := json.Marshal(buildImagenRequest(prompt, opts))
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
@@ -91,8 +165,8 @@ func (c *Client) generateImageViaREST(ctx context.Context, prompt string, opts *
 	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
 	req.Header.Set("Content-Type", "application/json")
 
-	// Send the request
-	client := &http.Client{}
+	// Send the request, forwarding the request ID and logging the call
+	client := &http.Client{Transport: logging.NewTransport("imagen", nil)}
 	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
@@ -107,21 +181,26 @@ func (c *Client) generateImageViaREST(ctx context.Context, prompt string, opts *
 
 	// Check for errors
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return nil, newAPIError(resp.StatusCode, body)
 	}
 
-	// Parse the response
-	var imagenResp ImagenResponse
-	if err := json.Unmarshal(body, &imagenResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
+	return decodePredictions(body)
+}
 
-	// Extract image data
-	if len(imagenResp.Predictions) == 0 {
-		return nil, errors.New("no predictions in response")
+// observe records a predict request for model in metrics. Imagen omits filtered images from
+// its response, so every requested image that did not come back counts as a safety block.
+func observe(model string, start time.Time, requested, generated int, err error) {
+	var filtered *FilteredError
+	switch {
+	case errors.As(err, &filtered):
+		metrics.ObserveUpstream(metrics.ServiceImagen, model, metrics.OutcomeBlocked, start)
+		metrics.SafetyBlocks.WithLabelValues(metrics.ServiceImagen, model).Add(float64(requested))
+	case err != nil:
+		metrics.ObserveUpstream(metrics.ServiceImagen, model, metrics.OutcomeError, start)
+	default:
+		metrics.ObserveUpstream(metrics.ServiceImagen, model, metrics.OutcomeOK, start)
+		if generated < requested {
+			metrics.SafetyBlocks.WithLabelValues(metrics.ServiceImagen, model).Add(float64(requested - generated))
+		}
 	}
-
-	// Image is base64 encoded, but we'll return it as-is for now
-	// The caller will handle base64 decoding
-	return []byte(imagenResp.Predictions[0].BytesBase64Encoded), nil
 }
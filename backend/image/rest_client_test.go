@@ -0,0 +1,132 @@
+package image
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestBuildImagenRequest(t *testing.T) {
+	t.Run("without seed keeps the watermark setting untouched", func(t *testing.T) {
+		req := buildImagenRequest("prompt", &imageOptions{aspectRatio: "1:1", sampleCount: 3, negativePrompt: "text"})
+
+		if req.Instances[0].Prompt != "prompt" {
+			t.Errorf("expected prompt 'prompt', got %s", req.Instances[0].Prompt)
+		}
+		if req.Parameters.SampleCount != 3 {
+			t.Errorf("expected sampleCount 3, got %d", req.Parameters.SampleCount)
+		}
+		if req.Parameters.NegativePrompt != "text" {
+			t.Errorf("expected negativePrompt 'text', got %s", req.Parameters.NegativePrompt)
+		}
+		if req.Parameters.Seed != nil || req.Parameters.AddWatermark != nil {
+			t.Errorf("expected no seed or watermark setting, got %v, %v", req.Parameters.Seed, req.Parameters.AddWatermark)
+		}
+	})
+
+	t.Run("passes model independent settings through", func(t *testing.T) {
+		req := buildImagenRequest("prompt", &imageOptions{
+			sampleCount:      1,
+			imageSize:        "2K",
+			personGeneration: PersonGenerationDontAllow,
+			safetySetting:    SafetyBlockOnlyHigh,
+		})
+
+		p := req.Parameters
+		if p.SampleImageSize != "2K" || p.PersonGeneration != "dont_allow" || p.SafetySetting != "block_only_high" {
+			t.Errorf("expected size and safety settings to be passed through, got %+v", p)
+		}
+		if !p.IncludeRaiReason {
+			t.Error("expected includeRaiReason to be set")
+		}
+	})
+
+	t.Run("with seed disables the watermark", func(t *testing.T) {
+		seed := int64(7)
+		req := buildImagenRequest("prompt", &imageOptions{sampleCount: 1, seed: &seed})
+
+		if req.Parameters.Seed == nil || *req.Parameters.Seed != 7 {
+			t.Errorf("expected seed 7, got %v", req.Parameters.Seed)
+		}
+		if req.Parameters.AddWatermark == nil || *req.Parameters.AddWatermark {
+			t.Errorf("expected addWatermark false, got %v", req.Parameters.AddWatermark)
+		}
+	})
+}
+
+func TestDecodePredictions(t *testing.T) {
+	encode := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+	t.Run("decodes every prediction", func(t *testing.T) {
+		body := []byte(`{"predictions":[{"bytesBase64Encoded":"` + encode("png-1") + `"},{"bytesBase64Encoded":"` + encode("png-2") + `"}]}`)
+
+		images, err := decodePredictions(body)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(images) != 2 || string(images[0]) != "png-1" || string(images[1]) != "png-2" {
+			t.Errorf("expected two decoded images, got %q", images)
+		}
+	})
+
+	t.Run("filtered error when there are no predictions", func(t *testing.T) {
+		_, err := decodePredictions([]byte(`{}`))
+		var filtered *FilteredError
+		if !errors.As(err, &filtered) {
+			t.Fatalf("expected FilteredError, got %v", err)
+		}
+		if len(filtered.Reasons) != 0 {
+			t.Errorf("expected no reasons, got %q", filtered.Reasons)
+		}
+	})
+
+	t.Run("filtered error carries the RAI reasons", func(t *testing.T) {
+		body := []byte(`{"predictions":[{"raiFilteredReason":"reason one"},{"raiFilteredReason":"reason two"}]}`)
+
+		_, err := decodePredictions(body)
+		var filtered *FilteredError
+		if !errors.As(err, &filtered) {
+			t.Fatalf("expected FilteredError, got %v", err)
+		}
+		if len(filtered.Reasons) != 2 || filtered.Reasons[0] != "reason one" {
+			t.Errorf("expected both reasons, got %q", filtered.Reasons)
+		}
+	})
+
+	t.Run("skips filtered predictions when others succeed", func(t *testing.T) {
+		body := []byte(`{"predictions":[{"raiFilteredReason":"reason"},{"bytesBase64Encoded":"` + encode("png-1") + `"}]}`)
+
+		images, err := decodePredictions(body)
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+		if len(images) != 1 || string(images[0]) != "png-1" {
+			t.Errorf("expected the unfiltered image, got %q", images)
+		}
+	})
+
+	t.Run("error when payload is not base64", func(t *testing.T) {
+		if _, err := decodePredictions([]byte(`{"predictions":[{"bytesBase64Encoded":"%%%"}]}`)); err == nil {
+			t.Fatal("expected error for invalid base64")
+		}
+	})
+}
+
+func TestNewAPIError(t *testing.T) {
+	t.Run("parses Google API errors", func(t *testing.T) {
+		err := newAPIError(http.StatusBadRequest, []byte(`{"error":{"code":400,"message":"prompt contains sensitive words","status":"INVALID_ARGUMENT"}}`))
+
+		if err.StatusCode != 400 || err.Status != "INVALID_ARGUMENT" || err.Message != "prompt contains sensitive words" {
+			t.Errorf("unexpected API error %+v", err)
+		}
+	})
+
+	t.Run("falls back to the raw body", func(t *testing.T) {
+		err := newAPIError(http.StatusBadGateway, []byte("upstream failure\n"))
+
+		if err.Status != "" || err.Message != "upstream failure" {
+			t.Errorf("unexpected API error %+v", err)
+		}
+	})
+}
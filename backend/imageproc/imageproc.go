@@ -0,0 +1,228 @@
+// Package imageproc prepares still images for outbound platforms: it decodes PNG, JPEG and
+// WebP, crops to a target aspect ratio, scales down and re-encodes within a platform's byte
+// budget. Re-encoding always drops metadata such as EXIF location and camera details.
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // Register the WebP decoder
+)
+
+// MaxSourceDimension is the largest width or height decoded, checked before the pixels are read
+const MaxSourceDimension = 8192
+
+var (
+	// ErrUnsupportedFormat is returned for data that is not a PNG, JPEG or WebP image
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	// ErrSourceTooLarge is returned for images wider or taller than MaxSourceDimension
+	ErrSourceTooLarge = fmt.Errorf("image exceeds %dx%d pixels", MaxSourceDimension, MaxSourceDimension)
+	// ErrOverBudget is returned when an image cannot be encoded within the byte budget
+	ErrOverBudget = errors.New("image cannot be encoded within the byte budget")
+)
+
+// Budget describes the still images a platform accepts
+type Budget struct {
+	MaxBytes     int     // Largest encoded image; 0 for no limit
+	MaxDimension int     // Longest edge in pixels; 0 keeps the original size
+	AspectRatio  float64 // Width divided by height to center-crop to; 0 keeps the original ratio
+}
+
+// Budgets for the platforms images are posted to
+var (
+	// Twitter accepts images up to 5MB and scales anything over 4096px
+	Twitter = Budget{MaxBytes: 5 << 20, MaxDimension: 4096}
+	// Bluesky accepts images up to 1MB and displays them at up to 2000px
+	Bluesky = Budget{MaxBytes: 1_000_000, MaxDimension: 2000}
+	// Mastodon accepts images up to 16MB and scales anything over 3840px
+	Mastodon = Budget{MaxBytes: 16 << 20, MaxDimension: 3840}
+)
+
+// Platforms maps platform names to their budgets
+var Platforms = map[string]Budget{
+	"twitter":  Twitter,
+	"bluesky":  Bluesky,
+	"mastodon": Mastodon,
+}
+
+// sourceFormats are the content types Decode accepts
+var sourceFormats = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/webp": true,
+}
+
+const (
+	// scaleStep is how much an image shrinks each time it does not fit the budget at any quality
+	scaleStep = 0.75
+	// minEdge is the shortest edge an image is shrunk to before giving up on the budget
+	minEdge = 64
+)
+
+// jpegQualities are tried in order until an opaque image fits the budget
+var jpegQualities = []int{90, 80, 70, 60, 50}
+
+// Result is an image prepared for a platform
+type Result struct {
+	Data        []byte
+	ContentType string // "image/jpeg" for opaque images, "image/png" for images with transparency
+	Width       int
+	Height      int
+}
+
+// Decode decodes a PNG, JPEG or WebP image after checking its dimensions
+func Decode(data []byte) (image.Image, error) {
+	if !sourceFormats[http.DetectContentType(data)] {
+		return nil, ErrUnsupportedFormat
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedFormat, err)
+	}
+	if config.Width > MaxSourceDimension || config.Height > MaxSourceDimension {
+		return nil, ErrSourceTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// Process decodes an image and prepares it for the budget
+func Process(data []byte, budget Budget) (*Result, error) {
+	img, err := Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return Fit(img, budget)
+}
+
+// Fit crops, scales and encodes an image so that it satisfies the budget.
+// Opaque images are encoded as JPEG at the highest quality that fits; images with
+// transparency are encoded as PNG. Images that still do not fit are scaled down further.
+func Fit(img image.Image, budget Budget) (*Result, error) {
+	img = Crop(img, budget.AspectRatio)
+	img = Resize(img, budget.MaxDimension)
+
+	opaque := isOpaque(img)
+	for {
+		data, contentType, err := encode(img, opaque, budget.MaxBytes)
+		if err != nil {
+			return nil, err
+		}
+		bounds := img.Bounds()
+		if data != nil {
+			return &Result{Data: data, ContentType: contentType, Width: bounds.Dx(), Height: bounds.Dy()}, nil
+		}
+
+		w, h := int(float64(bounds.Dx())*scaleStep), int(float64(bounds.Dy())*scaleStep)
+		if min(w, h) < minEdge {
+			return nil, fmt.Errorf("%w of %d bytes", ErrOverBudget, budget.MaxBytes)
+		}
+		img = scale(img, w, h)
+	}
+}
+
+// encode encodes img at the best quality within maxBytes and returns nil data when nothing fits
+func encode(img image.Image, opaque bool, maxBytes int) ([]byte, string, error) {
+	var buf bytes.Buffer
+	if !opaque {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		if maxBytes > 0 && buf.Len() > maxBytes {
+			return nil, "", nil
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+
+	for _, quality := range jpegQualities {
+		buf.Reset()
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+			return nil, "", fmt.Errorf("failed to encode image: %w", err)
+		}
+		if maxBytes <= 0 || buf.Len() <= maxBytes {
+			return buf.Bytes(), "image/jpeg", nil
+		}
+	}
+	return nil, "", nil
+}
+
+// Crop center-crops img to the aspect ratio (width divided by height).
+// A ratio of 0 or one the image already has returns img unchanged.
+func Crop(img image.Image, ratio float64) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if ratio <= 0 || w == 0 || h == 0 {
+		return img
+	}
+
+	cropW, cropH := w, int(float64(w)/ratio+0.5)
+	if cropH > h {
+		cropW, cropH = int(float64(h)*ratio+0.5), h
+	}
+	if cropW == w && cropH == h {
+		return img
+	}
+
+	x := bounds.Min.X + (w-cropW)/2
+	y := bounds.Min.Y + (h-cropH)/2
+	rect := image.Rect(x, y, x+cropW, y+cropH)
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, cropW, cropH))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// Resize scales img down so that its longest edge is at most maxDimension, keeping its aspect ratio.
+// Images that already fit, and a maxDimension of 0, return img unchanged.
+func Resize(img image.Image, maxDimension int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if maxDimension <= 0 || max(w, h) <= maxDimension {
+		return img
+	}
+
+	if w >= h {
+		return scale(img, maxDimension, max(1, h*maxDimension/w))
+	}
+	return scale(img, max(1, w*maxDimension/h), maxDimension)
+}
+
+// scale resamples img to exactly w by h pixels
+func scale(img image.Image, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), xdraw.Src, nil)
+	return dst
+}
+
+// isOpaque reports whether every pixel of img is fully opaque
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a != 0xffff {
+				return false
+			}
+		}
+	}
+	return true
+}
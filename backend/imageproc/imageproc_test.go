@@ -0,0 +1,217 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math/rand/v2"
+	"testing"
+)
+
+// noiseImage returns an image of random pixels, which compresses poorly
+func noiseImage(width, height int, alpha uint8) *image.NRGBA {
+	rng := rand.New(rand.NewPCG(1, 2))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.NRGBA{R: uint8(rng.IntN(256)), G: uint8(rng.IntN(256)), B: uint8(rng.IntN(256)), A: alpha})
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// webpPixel is a 1x1 lossless WebP image
+const webpPixel = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+func TestDecode(t *testing.T) {
+	webp, err := base64.StdEncoding.DecodeString(webpPixel)
+	if err != nil {
+		t.Fatalf("failed to decode WebP fixture: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "png", data: encodePNG(t, noiseImage(4, 4, 0xff))},
+		{name: "webp", data: webp},
+		{name: "text", data: []byte("not an image"), wantErr: ErrUnsupportedFormat},
+		{name: "truncated png", data: encodePNG(t, noiseImage(4, 4, 0xff))[:20], wantErr: ErrUnsupportedFormat},
+		{name: "too wide", data: encodePNG(t, image.NewGray(image.Rect(0, 0, MaxSourceDimension+1, 1))), wantErr: ErrSourceTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Decode(tt.data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && img.Bounds().Empty() {
+				t.Error("Decode() returned an empty image")
+			}
+		})
+	}
+}
+
+func TestCrop(t *testing.T) {
+	img := noiseImage(400, 300, 0xff)
+
+	tests := []struct {
+		name  string
+		ratio float64
+		want  image.Point
+	}{
+		{name: "no ratio", ratio: 0, want: image.Pt(400, 300)},
+		{name: "same ratio", ratio: 4.0 / 3.0, want: image.Pt(400, 300)},
+		{name: "square", ratio: 1, want: image.Pt(300, 300)},
+		{name: "wide", ratio: 16.0 / 9.0, want: image.Pt(400, 225)},
+		{name: "tall", ratio: 9.0 / 16.0, want: image.Pt(169, 300)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Crop(img, tt.ratio).Bounds().Size(); got != tt.want {
+				t.Errorf("Crop() size = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("keeps the center", func(t *testing.T) {
+		cropped := Crop(img, 1)
+		if got, want := cropped.At(cropped.Bounds().Min.X, 0), img.At(50, 0); got != want {
+			t.Errorf("Crop() left edge = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name         string
+		size         image.Point
+		maxDimension int
+		want         image.Point
+	}{
+		{name: "no limit", size: image.Pt(5000, 2500), maxDimension: 0, want: image.Pt(5000, 2500)},
+		{name: "already fits", size: image.Pt(800, 600), maxDimension: 1000, want: image.Pt(800, 600)},
+		{name: "landscape", size: image.Pt(5000, 2500), maxDimension: 1000, want: image.Pt(1000, 500)},
+		{name: "portrait", size: image.Pt(600, 1200), maxDimension: 300, want: image.Pt(150, 300)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewGray(image.Rectangle{Max: tt.size})
+			if got := Resize(img, tt.maxDimension).Bounds().Size(); got != tt.want {
+				t.Errorf("Resize() size = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		name            string
+		img             image.Image
+		budget          Budget
+		wantContentType string
+		wantSize        image.Point
+	}{
+		{
+			name:            "opaque image without a budget",
+			img:             noiseImage(200, 100, 0xff),
+			wantContentType: "image/jpeg",
+			wantSize:        image.Pt(200, 100),
+		},
+		{
+			name:            "transparent image without a budget",
+			img:             noiseImage(200, 100, 0x80),
+			wantContentType: "image/png",
+			wantSize:        image.Pt(200, 100),
+		},
+		{
+			name:            "crops and resizes",
+			img:             noiseImage(400, 300, 0xff),
+			budget:          Budget{MaxDimension: 100, AspectRatio: 1},
+			wantContentType: "image/jpeg",
+			wantSize:        image.Pt(100, 100),
+		},
+		{
+			name:            "lowers JPEG quality to fit",
+			img:             noiseImage(256, 256, 0xff),
+			budget:          Budget{MaxBytes: 40_000},
+			wantContentType: "image/jpeg",
+			wantSize:        image.Pt(256, 256),
+		},
+		{
+			name:            "scales down a PNG to fit",
+			img:             noiseImage(256, 256, 0x80),
+			budget:          Budget{MaxBytes: 100_000},
+			wantContentType: "image/png",
+			wantSize:        image.Pt(144, 144),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Fit(tt.img, tt.budget)
+			if err != nil {
+				t.Fatalf("Fit() error = %v", err)
+			}
+			if got.ContentType != tt.wantContentType {
+				t.Errorf("Fit() content type = %s, want %s", got.ContentType, tt.wantContentType)
+			}
+			if size := image.Pt(got.Width, got.Height); size != tt.wantSize {
+				t.Errorf("Fit() size = %v, want %v", size, tt.wantSize)
+			}
+			if tt.budget.MaxBytes > 0 && len(got.Data) > tt.budget.MaxBytes {
+				t.Errorf("Fit() = %d bytes, want at most %d", len(got.Data), tt.budget.MaxBytes)
+			}
+			decoded, _, err := image.Decode(bytes.NewReader(got.Data))
+			if err != nil {
+				t.Fatalf("Fit() returned undecodable data: %v", err)
+			}
+			if size := decoded.Bounds().Size(); size != tt.wantSize {
+				t.Errorf("decoded size = %v, want %v", size, tt.wantSize)
+			}
+		})
+	}
+
+	t.Run("impossible budget", func(t *testing.T) {
+		if _, err := Fit(noiseImage(256, 256, 0xff), Budget{MaxBytes: 100}); !errors.Is(err, ErrOverBudget) {
+			t.Errorf("Fit() error = %v, want ErrOverBudget", err)
+		}
+	})
+}
+
+func TestProcess_StripsMetadata(t *testing.T) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, noiseImage(32, 32, 0xff), nil); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	// Insert an EXIF APP1 segment right after the SOI marker
+	exif := []byte("Exif\x00\x00GPS 35.6812N 139.7671E")
+	segment := append([]byte{0xff, 0xe1, 0x00, byte(len(exif) + 2)}, exif...)
+	data := append(append(append([]byte{}, buf.Bytes()[:2]...), segment...), buf.Bytes()[2:]...)
+
+	got, err := Process(data, Twitter)
+	if err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if bytes.Contains(got.Data, []byte("Exif")) || bytes.Contains(got.Data, []byte("GPS")) {
+		t.Error("Process() kept the EXIF metadata")
+	}
+}
@@ -0,0 +1,73 @@
+package imagestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/store"
+)
+
+// bucketImages is the store bucket holding generation metadata of stored images
+const bucketImages = "generated_images"
+
+// ErrRecordNotFound is returned when no generation metadata exists for an image
+var ErrRecordNotFound = errors.New("image record not found")
+
+// Record describes who stored an image and how it was generated so it can be regenerated later.
+// Images uploaded by users have no prompt but a description of their content; rendered images have neither.
+// Model, ImageSize, PersonGeneration and SafetySetting are empty when the deployment defaults were used.
+type Record struct {
+	ID               string    `json:"id"`
+	OwnerID          string    `json:"ownerId,omitempty"` // User who stored the image; empty for anonymous users
+	Prompt           string    `json:"prompt"`
+	Description      string    `json:"description,omitempty"`
+	NegativePrompt   string    `json:"negativePrompt,omitempty"`
+	Style            string    `json:"style,omitempty"`
+	AspectRatio      string    `json:"aspectRatio,omitempty"`
+	Seed             *int64    `json:"seed,omitempty"`
+	Model            string    `json:"model,omitempty"`
+	ImageSize        string    `json:"imageSize,omitempty"`
+	PersonGeneration string    `json:"personGeneration,omitempty"`
+	SafetySetting    string    `json:"safetySetting,omitempty"`
+	ParentID         string    `json:"parentId,omitempty"` // Image this one is a variation of
+	CreatedAt        time.Time `json:"createdAt"`
+}
+
+// Catalog keeps the generation metadata of stored images in the embedded store
+type Catalog struct {
+	db  *store.DB
+	now func() time.Time
+}
+
+// NewCatalog creates a catalog backed by db
+func NewCatalog(db *store.DB) *Catalog {
+	return &Catalog{db: db, now: time.Now}
+}
+
+// Save stores record under its image ID. CreatedAt is filled in when zero.
+func (c *Catalog) Save(_ context.Context, record Record) (*Record, error) {
+	if !ValidID(record.ID) {
+		return nil, ErrInvalidID
+	}
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = c.now()
+	}
+	if err := c.db.Put(bucketImages, record.ID, &record); err != nil {
+		return nil, fmt.Errorf("failed to store image record: %w", err)
+	}
+	return &record, nil
+}
+
+// Get returns the generation metadata of the image with the given ID
+func (c *Catalog) Get(_ context.Context, id string) (*Record, error) {
+	var record Record
+	if err := c.db.Get(bucketImages, id, &record); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, ErrRecordNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
@@ -0,0 +1,45 @@
+package imagestore
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Tattsum/enjo/backend/store"
+)
+
+func TestCatalog(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	catalog := NewCatalog(db)
+	seed := int64(42)
+
+	saved, err := catalog.Save(ctx, Record{ID: "img-1", Prompt: "燃える会議室", Seed: &seed, ParentID: "img-0"})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if saved.CreatedAt.IsZero() {
+		t.Error("Save() did not set CreatedAt")
+	}
+
+	got, err := catalog.Get(ctx, "img-1")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Prompt != "燃える会議室" || got.Seed == nil || *got.Seed != 42 || got.ParentID != "img-0" {
+		t.Errorf("Get() = %+v, want saved record", got)
+	}
+
+	if _, err := catalog.Get(ctx, "missing"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrRecordNotFound", err)
+	}
+	if _, err := catalog.Save(ctx, Record{ID: "../escape"}); !errors.Is(err, ErrInvalidID) {
+		t.Errorf("Save(invalid) error = %v, want ErrInvalidID", err)
+	}
+}
@@ -0,0 +1,57 @@
+package imagestore
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// Handler serves stored images at RoutePrefix+"{id}" for requests carrying a valid signature
+type Handler struct {
+	store  Store
+	signer *URLSigner
+}
+
+// NewHandler creates an HTTP handler serving images from store
+func NewHandler(store Store, signer *URLSigner) *Handler {
+	return &Handler{store: store, signer: signer}
+}
+
+// ServeHTTP serves the image named by the {id} path value (or the last path segment)
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		id = path.Base(r.URL.Path)
+	}
+	if !ValidID(id) {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	if err := h.signer.Verify(id, query.Get("exp"), query.Get("sig")); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	data, err := h.store.Get(r.Context(), id)
+	if errors.Is(err, ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "failed to load image", "image_id", id, "error", err)
+		http.Error(w, "failed to load image", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", http.DetectContentType(data))
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if _, err := w.Write(data); err != nil {
+		slog.WarnContext(r.Context(), "failed to write image", "image_id", id, "error", err)
+	}
+}
@@ -0,0 +1,105 @@
+package imagestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// dirPerm is the permission for the image directory
+	dirPerm = 0o750
+	// filePerm is the permission for stored image files
+	filePerm = 0o640
+)
+
+// LocalStore keeps images as files in a directory on the local filesystem
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates a store in dir, creating the directory if needed
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, errors.New("image directory is required")
+	}
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create image directory: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// Put writes data to a new file. The file is written under a temporary name and renamed
+// so readers never see a partially written image.
+func (s *LocalStore) Put(_ context.Context, data []byte) (string, error) {
+	id := newID()
+
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create image file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to write image: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), filePerm); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to set image permissions: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(id)); err != nil {
+		os.Remove(tmp.Name())
+		return "", fmt.Errorf("failed to store image: %w", err)
+	}
+
+	return id, nil
+}
+
+// Get reads the image stored under id
+func (s *LocalStore) Get(_ context.Context, id string) ([]byte, error) {
+	if !ValidID(id) {
+		return nil, ErrInvalidID
+	}
+
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return data, nil
+}
+
+// Delete removes the image stored under id
+func (s *LocalStore) Delete(_ context.Context, id string) error {
+	if !ValidID(id) {
+		return ErrInvalidID
+	}
+
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete image: %w", err)
+	}
+	return nil
+}
+
+// Ping checks that the directory is writable by creating and removing a temporary file
+func (s *LocalStore) Ping(_ context.Context) error {
+	f, err := os.CreateTemp(s.dir, ".ping-*")
+	if err != nil {
+		return fmt.Errorf("image directory is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// path returns the file path for id
+func (s *LocalStore) path(id string) string {
+	return filepath.Join(s.dir, id)
+}
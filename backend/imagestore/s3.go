@@ -0,0 +1,219 @@
+package imagestore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultS3Region is used when no region is configured (GCS accepts any region for HMAC keys)
+	defaultS3Region = "auto"
+	// sigV4Algorithm is the AWS Signature Version 4 algorithm identifier
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+	// amzDateFormat is the timestamp format used by Signature Version 4
+	amzDateFormat = "20060102T150405Z"
+	// amzShortDateFormat is the date format of the credential scope
+	amzShortDateFormat = "20060102"
+	// s3ContentType is the content type images are stored with
+	s3ContentType = "application/octet-stream"
+)
+
+// S3Config configures an S3-compatible object store.
+// It works with AWS S3, MinIO and Google Cloud Storage through its XML API with HMAC keys
+// (Endpoint "https://storage.googleapis.com").
+type S3Config struct {
+	Endpoint  string // Base URL, e.g. "https://storage.googleapis.com" or "http://localhost:9000"
+	Bucket    string
+	Region    string // Defaults to "auto"
+	AccessKey string
+	SecretKey string
+	Prefix    string // Optional key prefix, e.g. "images/"
+}
+
+// S3Store keeps images in an S3-compatible bucket using path-style requests signed with Signature Version 4
+type S3Store struct {
+	config     S3Config
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+// NewS3Store creates an S3-compatible store. If httpClient is nil http.DefaultClient is used.
+func NewS3Store(config S3Config, httpClient *http.Client) (*S3Store, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, errors.New("S3 endpoint and bucket are required")
+	}
+	if config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("S3 access key and secret key are required")
+	}
+	if config.Region == "" {
+		config.Region = defaultS3Region
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %w", err)
+	}
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &S3Store{config: config, endpoint: endpoint, httpClient: httpClient, now: time.Now}, nil
+}
+
+// Put uploads data as a new object
+func (s *S3Store) Put(ctx context.Context, data []byte) (string, error) {
+	id := newID()
+	resp, err := s.request(ctx, http.MethodPut, id, data)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to upload image: %w", s3Error(resp))
+	}
+	return id, nil
+}
+
+// Get downloads the object stored under id
+func (s *S3Store) Get(ctx context.Context, id string) ([]byte, error) {
+	if !ValidID(id) {
+		return nil, ErrInvalidID
+	}
+
+	resp, err := s.request(ctx, http.MethodGet, id, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image: %w", s3Error(resp))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	return data, nil
+}
+
+// Delete removes the object stored under id
+func (s *S3Store) Delete(ctx context.Context, id string) error {
+	if !ValidID(id) {
+		return ErrInvalidID
+	}
+
+	resp, err := s.request(ctx, http.MethodDelete, id, nil)
+	if err != nil {
+		return fmt.Errorf("failed to delete image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to delete image: %w", s3Error(resp))
+	}
+	return nil
+}
+
+// pingObject is looked up by Ping; it need not exist
+const pingObject = "health-check"
+
+// Ping checks that the bucket is reachable and accepts the credentials by looking up an object
+func (s *S3Store) Ping(ctx context.Context) error {
+	resp, err := s.request(ctx, http.MethodHead, pingObject, nil)
+	if err != nil {
+		return fmt.Errorf("failed to reach image bucket: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("failed to reach image bucket: %w", s3Error(resp))
+	}
+	return nil
+}
+
+// request sends a signed request for the object id
+func (s *S3Store) request(ctx context.Context, method, id string, body []byte) (*http.Response, error) {
+	objectURL := *s.endpoint
+	objectURL.Path = s.endpoint.Path + "/" + s.config.Bucket + "/" + s.config.Prefix + id
+
+	req, err := http.NewRequestWithContext(ctx, method, objectURL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", s3ContentType)
+	}
+	s.sign(req, body)
+
+	return s.httpClient.Do(req)
+}
+
+// sign adds Signature Version 4 authentication headers to req
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	now := s.now().UTC()
+	amzDate := now.Format(amzDateFormat)
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + req.URL.Host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := now.Format(amzShortDateFormat) + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{sigV4Algorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), now.Format(amzShortDateFormat))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, s.config.AccessKey, scope, signedHeaders, signature))
+}
+
+// s3Error builds an error from a failed S3 response
+func s3Error(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// sha256Hex returns the hex-encoded SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns HMAC-SHA256(key, data)
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
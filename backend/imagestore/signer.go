@@ -0,0 +1,100 @@
+package imagestore
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// RoutePrefix is the HTTP path images are served under
+	RoutePrefix = "/images/"
+	// defaultURLTTL is how long signed image URLs stay valid by default
+	defaultURLTTL = 24 * time.Hour
+	// secretSize is the size of a generated signing secret in bytes
+	secretSize = 32
+)
+
+var (
+	// ErrInvalidSignature is returned when a signed URL was not issued by this signer
+	ErrInvalidSignature = errors.New("invalid image URL signature")
+	// ErrExpired is returned when a signed URL has expired
+	ErrExpired = errors.New("image URL has expired")
+)
+
+// URLSigner issues and verifies expiring, HMAC-signed image URLs
+type URLSigner struct {
+	secret  []byte
+	ttl     time.Duration
+	baseURL string
+	now     func() time.Time
+}
+
+// NewURLSigner creates a signer. A random secret is generated when secret is empty, which
+// invalidates previously issued URLs on restart. baseURL is prepended to issued URLs and may be empty.
+func NewURLSigner(secret []byte, ttl time.Duration, baseURL string) (*URLSigner, error) {
+	if len(secret) == 0 {
+		secret = make([]byte, secretSize)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate image URL secret: %w", err)
+		}
+	}
+	if ttl <= 0 {
+		ttl = defaultURLTTL
+	}
+	return &URLSigner{secret: secret, ttl: ttl, baseURL: strings.TrimRight(baseURL, "/"), now: time.Now}, nil
+}
+
+// URL returns a signed URL for the image id
+func (s *URLSigner) URL(id string) string {
+	expiresAt := s.now().Add(s.ttl).Unix()
+	query := url.Values{
+		"exp": {strconv.FormatInt(expiresAt, 10)},
+		"sig": {s.sign(id, expiresAt)},
+	}
+	return s.baseURL + RoutePrefix + id + "?" + query.Encode()
+}
+
+// Verify checks the exp and sig query parameters of a signed URL for id
+func (s *URLSigner) Verify(id, exp, sig string) error {
+	expiresAt, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if subtle.ConstantTimeCompare([]byte(sig), []byte(s.sign(id, expiresAt))) != 1 {
+		return ErrInvalidSignature
+	}
+	if s.now().Unix() > expiresAt {
+		return ErrExpired
+	}
+	return nil
+}
+
+// sign computes the signature for id and expiry
+func (s *URLSigner) sign(id string, expiresAt int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id + "\n" + strconv.FormatInt(expiresAt, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// IDFromURL extracts the image ID from a URL issued by URLSigner.URL (absolute or relative).
+// It does not verify the signature; callers inside the server can trust their own store.
+func IDFromURL(rawURL string) (string, bool) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
+	id, found := strings.CutPrefix(parsed.Path, RoutePrefix)
+	if !found || !ValidID(id) {
+		return "", false
+	}
+	return id, true
+}
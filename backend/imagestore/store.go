@@ -0,0 +1,43 @@
+// Package imagestore stores generated and uploaded images outside of GraphQL payloads
+// and serves them through signed, expiring URLs.
+package imagestore
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrNotFound is returned when an image does not exist
+	ErrNotFound = errors.New("image not found")
+	// ErrInvalidID is returned for IDs that could not have been issued by a store
+	ErrInvalidID = errors.New("invalid image ID")
+)
+
+// idPattern restricts IDs to characters that are safe in file names, object keys and URL paths
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,128}$`)
+
+// Store persists image bytes under opaque IDs
+type Store interface {
+	// Put stores data and returns its new ID
+	Put(ctx context.Context, data []byte) (string, error)
+	// Get returns the data stored under id, or ErrNotFound
+	Get(ctx context.Context, id string) ([]byte, error)
+	// Delete removes the image; deleting a missing image is not an error
+	Delete(ctx context.Context, id string) error
+	// Ping checks that the store is reachable and writable, for health checks
+	Ping(ctx context.Context) error
+}
+
+// newID returns a fresh image ID
+func newID() string {
+	return uuid.NewString()
+}
+
+// ValidID reports whether id is well-formed
+func ValidID(id string) bool {
+	return idPattern.MatchString(id)
+}
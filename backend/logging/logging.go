@@ -0,0 +1,116 @@
+// Package logging configures structured logging with log/slog. Records logged with a request
+// context carry its request ID and GraphQL operation, and attributes holding user content or
+// credentials are masked unless debug mode is on.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formats accepted by Config.Format
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Config controls the logger built by New
+type Config struct {
+	Level  slog.Level
+	Format string // FormatJSON (default) or FormatText
+	// Debug logs user content and credentials in the clear. Never enable it in production.
+	Debug bool
+}
+
+// ParseLevel parses a level name such as "debug", "info", "warn" or "error"
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: %w", value, err)
+	}
+	return level, nil
+}
+
+// New creates a logger writing to w
+func New(w io.Writer, config Config) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: config.Level}
+	if !config.Debug {
+		options.ReplaceAttr = redact
+	}
+
+	var handler slog.Handler
+	switch strings.ToLower(config.Format) {
+	case "", FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	case FormatText:
+		handler = slog.NewTextHandler(w, options)
+	default:
+		return nil, fmt.Errorf("unsupported log format %q, want %s or %s", config.Format, FormatJSON, FormatText)
+	}
+	return slog.New(NewContextHandler(handler)), nil
+}
+
+type requestIDKey struct{}
+
+type operationKey struct{}
+
+// WithRequestID returns a context carrying the ID of the request being served
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "" outside a request
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithOperation returns a context carrying the name of the GraphQL operation being executed
+func WithOperation(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, operationKey{}, name)
+}
+
+// Operation returns the GraphQL operation ctx belongs to, or ""
+func Operation(ctx context.Context) string {
+	name, _ := ctx.Value(operationKey{}).(string)
+	return name
+}
+
+// ContextHandler adds the request ID, GraphQL operation and trace found in the context to each record
+type ContextHandler struct {
+	slog.Handler
+}
+
+// NewContextHandler wraps handler so that records logged with a context carry its request fields
+func NewContextHandler(handler slog.Handler) *ContextHandler {
+	return &ContextHandler{Handler: handler}
+}
+
+// Handle implements slog.Handler
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if operation := Operation(ctx); operation != "" {
+		record.AddAttrs(slog.String("graphql_operation", operation))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+// WithAttrs implements slog.Handler
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup implements slog.Handler
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,179 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record logs one message with attrs through a logger built from config and returns it decoded
+func record(t *testing.T, config Config, ctx context.Context, attrs ...any) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := New(&buf, config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	logger.InfoContext(ctx, "message", attrs...)
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log output %q is not JSON: %v", buf.String(), err)
+	}
+	return entry
+}
+
+func TestNew_Redaction(t *testing.T) {
+	attrs := []any{
+		"prompt", "炎上しそうな投稿",
+		"access_token", "abc123",
+		"Authorization", "Bearer xyz",
+		"variables", map[string]any{"text": "secret plan"},
+		"tweet_id", "42",
+	}
+
+	entry := record(t, Config{}, context.Background(), attrs...)
+	want := map[string]any{
+		"prompt":        "[REDACTED] (8 chars)",
+		"access_token":  Redacted,
+		"Authorization": Redacted,
+		"variables":     Redacted,
+		"tweet_id":      "42",
+	}
+	for key, value := range want {
+		if entry[key] != value {
+			t.Errorf("%s = %v, want %v", key, entry[key], value)
+		}
+	}
+
+	// Debug mode logs everything in the clear
+	entry = record(t, Config{Debug: true}, context.Background(), attrs...)
+	if entry["prompt"] != "炎上しそうな投稿" || entry["access_token"] != "abc123" {
+		t.Errorf("debug entry = %v, want unredacted values", entry)
+	}
+}
+
+func TestNew_ContextFields(t *testing.T) {
+	ctx := WithOperation(WithRequestID(context.Background(), "req-1"), "GenerateImage")
+	entry := record(t, Config{}, ctx)
+	if entry["request_id"] != "req-1" || entry["graphql_operation"] != "GenerateImage" {
+		t.Errorf("entry = %v, want request_id and graphql_operation", entry)
+	}
+
+	entry = record(t, Config{}, context.Background())
+	if _, ok := entry["request_id"]; ok {
+		t.Errorf("entry without a request = %v, want no request_id", entry)
+	}
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx = trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+	entry = record(t, Config{}, ctx)
+	if entry["trace_id"] != traceID.String() || entry["span_id"] != spanID.String() {
+		t.Errorf("entry = %v, want trace_id and span_id", entry)
+	}
+}
+
+func TestNew_InvalidFormat(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, Config{Format: "xml"}); err == nil {
+		t.Error("New() with an unknown format error = nil")
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("ParseLevel() with an unknown level error = nil")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	var seen string
+	handler := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		reuse  bool
+	}{
+		{name: "reuses a client ID", header: "client-abc", reuse: true},
+		{name: "generates an ID", header: ""},
+		{name: "replaces a malformed ID", header: "bad id\n"},
+		{name: "replaces an overlong ID", header: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			req := httptest.NewRequest(http.MethodGet, "/images/1?signature=s3cret", http.NoBody)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || id != seen {
+				t.Fatalf("response ID %q, handler saw %q", id, seen)
+			}
+			if tt.reuse != (id == tt.header) {
+				t.Errorf("request ID = %q for header %q, reuse %v", id, tt.header, tt.reuse)
+			}
+
+			var entry map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("log output %q is not JSON: %v", buf.String(), err)
+			}
+			if entry["request_id"] != id || entry["status"] != float64(http.StatusTeapot) || entry["level"] != "WARN" {
+				t.Errorf("entry = %v", entry)
+			}
+			if strings.Contains(buf.String(), "s3cret") {
+				t.Errorf("log output %q contains the query string", buf.String())
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var got string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(RequestIDHeader)
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: NewTransport("test", nil)}
+	req, err := http.NewRequestWithContext(WithRequestID(context.Background(), "req-1"), http.MethodGet, upstream.URL, http.NoBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if got != "req-1" {
+		t.Errorf("upstream saw %s %q, want req-1", RequestIDHeader, got)
+	}
+	if req.Header.Get(RequestIDHeader) != "" {
+		t.Error("Transport modified the caller's request")
+	}
+}
+
+func TestContextHandler_WithAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(NewContextHandler(slog.NewJSONHandler(&buf, nil))).With("service", "worker")
+	logger.InfoContext(WithRequestID(context.Background(), "job-1"), "message")
+	if out := buf.String(); !strings.Contains(out, `"service":"worker"`) || !strings.Contains(out, `"request_id":"job-1"`) {
+		t.Errorf("log output = %s", out)
+	}
+}
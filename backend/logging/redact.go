@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redaction policy. Attributes are masked by key, so log user content and credentials under
+// these keys rather than formatting them into the message.
+var (
+	// contentKeys hold text users typed or that was generated from it. Their length is kept.
+	contentKeys = map[string]bool{
+		"text":            true,
+		"original_text":   true,
+		"prompt":          true,
+		"negative_prompt": true,
+		"content":         true,
+		"description":     true,
+		"alt_text":        true,
+		"query":           true,
+		"variables":       true,
+		"email":           true,
+	}
+	// credentialKeys hold secrets. Keys ending in one of credentialSuffixes are masked as well.
+	credentialKeys = map[string]bool{
+		"authorization":  true,
+		"api_key":        true,
+		"encryption_key": true,
+		"private_key":    true,
+		"password":       true,
+		"secret":         true,
+		"token":          true,
+		"cookie":         true,
+		"signature":      true,
+		"verifier":       true,
+	}
+	credentialSuffixes = []string{"_token", "_secret", "_password"}
+)
+
+// Redacted replaces masked values
+const Redacted = "[REDACTED]"
+
+// redact is the slog.HandlerOptions.ReplaceAttr that applies the redaction policy
+func redact(_ []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	switch {
+	case isCredential(key):
+		return slog.String(attr.Key, Redacted)
+	case contentKeys[key]:
+		if attr.Value.Kind() == slog.KindString {
+			return slog.String(attr.Key, fmt.Sprintf("%s (%d chars)", Redacted, utf8.RuneCountInString(attr.Value.String())))
+		}
+		return slog.String(attr.Key, Redacted)
+	}
+	return attr
+}
+
+func isCredential(key string) bool {
+	if credentialKeys[key] {
+		return true
+	}
+	for _, suffix := range credentialSuffixes {
+		if strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
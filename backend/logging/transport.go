@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Transport forwards the request ID of the context to upstream APIs and logs each call
+type Transport struct {
+	// Service names the upstream API in logs, e.g. "imagen" or "twitter"
+	Service string
+	// Base performs the requests; http.DefaultTransport when nil
+	Base http.RoundTripper
+}
+
+// NewTransport returns a Transport for service on top of base
+func NewTransport(service string, base http.RoundTripper) *Transport {
+	return &Transport{Service: service, Base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if id := RequestID(ctx); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		// RoundTrippers must not modify the caller's request
+		req = req.Clone(ctx)
+		req.Header.Set(RequestIDHeader, id)
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+
+	attrs := []slog.Attr{
+		slog.String("service", t.Service),
+		slog.String("method", req.Method),
+		slog.String("host", req.URL.Host),
+		slog.String("path", req.URL.Path),
+		slog.Duration("duration", time.Since(start)),
+	}
+	switch {
+	case err != nil:
+		slog.LogAttrs(ctx, slog.LevelWarn, "upstream request failed", append(attrs, slog.Any("error", err))...)
+	case resp.StatusCode >= http.StatusBadRequest:
+		slog.LogAttrs(ctx, slog.LevelWarn, "upstream request", append(attrs, slog.Int("status", resp.StatusCode))...)
+	default:
+		slog.LogAttrs(ctx, slog.LevelDebug, "upstream request", append(attrs, slog.Int("status", resp.StatusCode))...)
+	}
+	return resp, err
+}
@@ -3,45 +3,66 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
-	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
 	"github.com/joho/godotenv"
 
+	"github.com/Tattsum/enjo/backend/accounts"
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/cache"
+	"github.com/Tattsum/enjo/backend/config"
 	"github.com/Tattsum/enjo/backend/gemini"
+	"github.com/Tattsum/enjo/backend/gqlserver"
 	"github.com/Tattsum/enjo/backend/graph"
 	"github.com/Tattsum/enjo/backend/graph/generated"
+	"github.com/Tattsum/enjo/backend/health"
 	"github.com/Tattsum/enjo/backend/image"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/logging"
+	"github.com/Tattsum/enjo/backend/metrics"
+	"github.com/Tattsum/enjo/backend/overlay"
+	"github.com/Tattsum/enjo/backend/queue"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+	"github.com/Tattsum/enjo/backend/screenshot"
+	"github.com/Tattsum/enjo/backend/secret"
+	"github.com/Tattsum/enjo/backend/simulation"
+	"github.com/Tattsum/enjo/backend/store"
+	"github.com/Tattsum/enjo/backend/tracing"
 	"github.com/Tattsum/enjo/backend/twitter"
 )
 
-// setupRouter creates and configures the HTTP router
-func setupRouter(geminiClient graph.GeminiClient, twitterClient graph.TwitterClient, imageClient graph.ImageClient) http.Handler {
+// twitterCallbackPath is the route Twitter redirects users to after they approve account linking
+const twitterCallbackPath = "/oauth/twitter/callback"
+
+// schedulerPollInterval is how often the scheduled post worker looks for due posts
+const schedulerPollInterval = 5 * time.Second
+
+// setupRouter creates and configures the HTTP router. server sets the limits applied to GraphQL requests
+// and corsOrigins the browser origins allowed to call the API.
+func setupRouter(server gqlserver.Config, corsOrigins []string, geminiClient graph.GeminiClient, twitterClient graph.TwitterClient, imageClient graph.ImageClient, options ...graph.ResolverOption) http.Handler {
 	router := chi.NewRouter()
 
-	// Request logging middleware
-	router.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			log.Printf("→ %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
-			next.ServeHTTP(w, r)
-			log.Printf("← %s %s completed in %v", r.Method, r.URL.Path, time.Since(start))
-		})
-	})
+	// Continue the frontend's trace, then assign request IDs and log requests
+	router.Use(tracing.Middleware)
+	router.Use(logging.Middleware(slog.Default()))
 
 	// CORS configuration
 	router.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:3000", "http://localhost:8080"},
+		AllowedOrigins:   corsOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   append([]string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", auth.APIKeyHeader, logging.RequestIDHeader}, tracing.Headers...),
+		ExposedHeaders:   append([]string{"Link", logging.RequestIDHeader}, ratelimit.Headers...),
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -51,112 +72,628 @@ func setupRouter(geminiClient graph.GeminiClient, twitterClient graph.TwitterCli
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		if err := json.NewEncoder(w).Encode(map[string]string{"status": "OK"}); err != nil {
-			log.Printf("Error encoding health response: %v", err)
+			slog.Error("failed to encode health response", "error", err)
 		}
 	})
 
 	// GraphQL resolver
-	resolver := graph.NewResolver(geminiClient, twitterClient, imageClient)
+	resolver := graph.NewResolver(geminiClient, twitterClient, imageClient, options...)
+
+	// Liveness and readiness probes. Readiness fails only when a critical dependency is down.
+	router.Get("/livez", health.Livez)
+	healthChecker := resolver.HealthChecker()
+	if healthChecker == nil {
+		healthChecker = health.New(nil)
+	}
+	router.Handle("/readyz", healthChecker.Readyz())
+
+	// OAuth callback for linking Twitter accounts. Twitter redirects the browser here, so it takes no credentials.
+	if callbackHandler := resolver.TwitterCallbackHandler(); callbackHandler != nil {
+		router.Get(twitterCallbackPath, callbackHandler.ServeHTTP)
+	}
+
+	// Stored images, served through signed URLs
+	if imageHandler := resolver.ImageHandler(); imageHandler != nil {
+		router.Get("/images/{id}", imageHandler.ServeHTTP)
+	}
 
 	// GraphQL server with timeout
-	srv := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{
-		Resolvers: resolver,
-	}))
+	srv := gqlserver.New(generated.NewExecutableSchema(generated.Config{
+		Resolvers:  resolver,
+		Complexity: graph.NewComplexityRoot(),
+	}), server)
 
 	// Add error handling
 	srv.SetRecoverFunc(func(ctx context.Context, err interface{}) error {
-		log.Printf("PANIC recovered in GraphQL handler: %v", err)
+		slog.ErrorContext(ctx, "panic recovered in GraphQL handler", "panic", err)
 		return fmt.Errorf("internal server error")
 	})
 
-	// GraphQL endpoints
-	router.Handle("/graphql", srv)
-	router.Handle("/", playground.Handler("GraphQL Playground", "/graphql"))
+	// GraphQL endpoints. The playground page itself is public; its queries authenticate like any other client.
+	// Resolvers may set cookies, e.g. the state that ties Twitter account linking to the browser
+	var graphqlHandler http.Handler = graph.ResponseCookies(srv)
+	if limiter := resolver.RateLimiter(); limiter != nil {
+		graphqlHandler = limiter.Middleware(graphqlHandler)
+	}
+	if authenticator := resolver.Authenticator(); authenticator != nil {
+		graphqlHandler = authenticator.Middleware(graphqlHandler)
+	}
+	router.Handle("/graphql", graphqlHandler)
+	// Prometheus metrics
+	if server.Metrics {
+		router.Handle("/metrics", metrics.Handler())
+	}
+	// The playground relies on introspection, so it is only served when introspection is enabled
+	if server.Introspection {
+		router.Handle("/", playground.Handler("GraphQL Playground", "/graphql"))
+	}
 
 	return router
 }
 
-// initializeTwitterClient creates a Twitter client if credentials are configured
-func initializeTwitterClient() graph.TwitterClient {
-	apiKey := os.Getenv("TWITTER_API_KEY")
-	apiSecret := os.Getenv("TWITTER_API_SECRET")
-	accessToken := os.Getenv("TWITTER_ACCESS_TOKEN")
-	accessTokenSecret := os.Getenv("TWITTER_ACCESS_TOKEN_SECRET")
-
-	if apiKey == "" || apiSecret == "" || accessToken == "" || accessTokenSecret == "" {
-		log.Println("Twitter API credentials not configured - Twitter posting functionality will be disabled")
+// initializeTwitterClient creates a Twitter client if the shared account's credentials are configured
+func initializeTwitterClient(cfg config.Twitter) graph.TwitterClient {
+	if !cfg.PostingEnabled() {
+		slog.Info("Twitter API credentials not configured - Twitter posting functionality will be disabled")
 		return nil
 	}
 
-	client, err := twitter.NewClient(apiKey, apiSecret, accessToken, accessTokenSecret)
+	client, err := twitter.NewClient(cfg.APIKey, cfg.APISecret, cfg.AccessToken, cfg.AccessTokenSecret)
 	if err != nil {
-		log.Printf("Warning: Failed to create Twitter client: %v", err)
-		log.Println("Twitter posting functionality will be disabled")
+		slog.Warn("failed to create Twitter client - Twitter posting functionality will be disabled", "error", err)
 		return nil
 	}
 
-	log.Println("Twitter client initialized successfully")
+	slog.Info("Twitter client initialized")
 	return client
 }
 
-func main() {
-	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Println("Warning: .env file not found, using system environment variables")
+// initializeTwitterAccounts enables per-user Twitter account linking when the app credentials and
+// token encryption key are set. It returns nil when linking is not configured. baseURL is the
+// server's public URL, used for the default OAuth callback.
+func initializeTwitterAccounts(db *store.DB, cfg config.Twitter, baseURL string) (graph.ResolverOption, error) {
+	if !cfg.LinkingEnabled() {
+		slog.Info("Twitter account linking not configured - posts use the shared Twitter account")
+		return nil, nil
 	}
 
-	// Get GCP configuration
-	projectID := os.Getenv("GCP_PROJECT_ID")
-	if projectID == "" {
-		log.Fatal("GCP_PROJECT_ID environment variable is required")
+	key, err := secret.ParseKey(cfg.TokenEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TWITTER_TOKEN_ENCRYPTION_KEY: %w", err)
+	}
+	box, err := secret.New(key)
+	if err != nil {
+		return nil, err
 	}
 
-	location := os.Getenv("GCP_LOCATION")
-	if location == "" {
-		location = "us-central1" // Default location
+	callbackURL := cfg.OAuthCallbackURL
+	if callbackURL == "" {
+		callbackURL = baseURL + twitterCallbackPath
+	}
+	authorizer, err := twitter.NewAuthorizer(cfg.APIKey, cfg.APISecret, callbackURL,
+		twitter.WithOAuthHTTPClient(&http.Client{
+			Timeout:   15 * time.Second,
+			Transport: logging.NewTransport("twitter", metrics.NewTransport(metrics.ServiceTwitter, tracing.NewTransport("twitter", nil))),
+		}))
+	if err != nil {
+		return nil, err
+	}
+	clients := func(accessToken, accessTokenSecret string) (graph.TwitterClient, error) {
+		client, err := twitter.NewClient(cfg.APIKey, cfg.APISecret, accessToken, accessTokenSecret)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
 	}
 
-	// Get port
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	slog.Info("Twitter account linking enabled", "callback", callbackURL)
+	return graph.WithTwitterAccounts(accounts.New(db, box), authorizer, clients, cfg.OAuthRedirectURL), nil
+}
+
+// initializeImageStore creates the configured image store together with the signer for image URLs,
+// which point at baseURL. It returns a nil store when images should stay inline.
+func initializeImageStore(cfg config.ImageStore, baseURL string) (imagestore.Store, *imagestore.URLSigner, error) {
+	var store imagestore.Store
+	var err error
+	switch cfg.Kind {
+	case config.ImageStoreLocal:
+		store, err = imagestore.NewLocalStore(cfg.Dir)
+	case config.ImageStoreS3:
+		store, err = imagestore.NewS3Store(imagestore.S3Config{
+			Endpoint:  cfg.S3.Endpoint,
+			Bucket:    cfg.S3.Bucket,
+			Region:    cfg.S3.Region,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			Prefix:    cfg.S3.Prefix,
+		}, nil)
+	default:
+		slog.Info("image store disabled - generated images will be returned as data URLs")
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	signer, err := imagestore.NewURLSigner([]byte(cfg.URLSecret), cfg.URLTTL, baseURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return store, signer, nil
+}
+
+// initializeGenerationCache creates the configured cache of Gemini and Imagen results.
+// It returns nil when caching is disabled.
+func initializeGenerationCache(cfg config.Cache) (cache.Store, error) {
+	switch cfg.Kind {
+	case config.CacheMemory:
+		return cache.NewLRU(cfg.Size), nil
+	case config.CacheDisk:
+		return cache.NewDisk(cfg.Dir)
+	default:
+		return nil, nil
+	}
+}
+
+// initializeAuthenticator builds the GraphQL authenticator. It returns nil when neither API keys
+// nor a JWKS are configured.
+func initializeAuthenticator(cfg config.Auth) (*auth.Authenticator, error) {
+	var options []auth.Option
+
+	if cfg.APIKeys != "" {
+		keys, err := auth.ParseAPIKeys(cfg.APIKeys)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_API_KEYS: %w", err)
+		}
+		options = append(options, auth.WithAPIKeys(keys))
+	}
+
+	jwksURL, jwksFile := cfg.JWKSURL, cfg.JWKSFile
+	var keySet auth.KeySet
+	switch {
+	case jwksURL != "":
+		remote, err := auth.NewRemoteKeySet(jwksURL, &http.Client{Timeout: 10 * time.Second})
+		if err != nil {
+			return nil, err
+		}
+		keySet = remote
+	case jwksFile != "":
+		data, err := os.ReadFile(jwksFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read AUTH_JWKS_FILE: %w", err)
+		}
+		static, err := auth.NewStaticKeySet(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AUTH_JWKS_FILE: %w", err)
+		}
+		keySet = static
+	}
+	if keySet != nil {
+		verifier, err := auth.NewVerifier(keySet, cfg.Issuer, cfg.Audience)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, auth.WithJWT(verifier))
 	}
 
+	if len(options) == 0 {
+		return nil, nil
+	}
+
+	if !cfg.Required {
+		options = append(options, auth.WithOptional())
+	}
+	return auth.New(options...)
+}
+
+// initializeGraphQLServer builds the GraphQL limits. A persisted query manifest, when configured,
+// restricts execution to its queries. metricsEnabled records GraphQL metrics and serves /metrics.
+func initializeGraphQLServer(cfg config.GraphQL, metricsEnabled bool) (gqlserver.Config, error) {
+	server := gqlserver.Config{
+		MaxComplexity: cfg.MaxComplexity,
+		MaxDepth:      cfg.MaxDepth,
+		Introspection: cfg.Introspection,
+		Metrics:       metricsEnabled,
+	}
+	if !server.Introspection {
+		slog.Info("GraphQL introspection and playground disabled")
+	}
+	if !server.Metrics {
+		slog.Info("metrics disabled")
+	}
+
+	if path := cfg.PersistedQueries; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return server, fmt.Errorf("failed to read GRAPHQL_PERSISTED_QUERIES: %w", err)
+		}
+		allowlist, err := gqlserver.ParseAllowlist(data)
+		if err != nil {
+			return server, fmt.Errorf("invalid GRAPHQL_PERSISTED_QUERIES: %w", err)
+		}
+		server.Allowlist = allowlist
+		slog.Info("GraphQL persisted query allowlist enabled", "queries", allowlist.Len())
+	}
+	return server, nil
+}
+
+// initializeRateLimiter builds the per-client rate limits and daily cost quota. It returns nil when
+// rate limiting is disabled.
+func initializeRateLimiter(enabled bool, cfg config.RateLimit) *ratelimit.Limiter {
+	if !enabled {
+		return nil
+	}
+
+	slog.Info("rate limiting enabled",
+		"text", formatRate(cfg.Rates[ratelimit.OpText]), "image", formatRate(cfg.Rates[ratelimit.OpImage]),
+		"post", formatRate(cfg.Rates[ratelimit.OpPost]), "daily_budget_usd", cfg.DailyBudget)
+	return ratelimit.New(cfg.Config)
+}
+
+// formatRate describes a rate for the startup log
+func formatRate(rate ratelimit.Rate) string {
+	if rate.Limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d/%s", rate.Limit, rate.Period)
+}
+
+// loadRenderFont reads the font used for overlays and screenshots.
+// It returns nil when fontPath is empty so that the renderers use their embedded font.
+func loadRenderFont(fontPath string) ([]byte, error) {
+	if fontPath == "" {
+		return nil, nil
+	}
+
+	fontData, err := os.ReadFile(fontPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read RENDER_FONT_PATH: %w", err)
+	}
+	return fontData, nil
+}
+
+// imageDefaults builds the deployment-wide Imagen options. An empty negative prompt disables the
+// default one.
+func imageDefaults(cfg config.Imagen) []image.Option {
+	var defaults []image.Option
+	if cfg.Model != "" {
+		defaults = append(defaults, image.WithModel(cfg.Model))
+	}
+	if cfg.ImageSize != "" {
+		defaults = append(defaults, image.WithImageSize(cfg.ImageSize))
+	}
+	if cfg.NegativePrompt != nil {
+		if *cfg.NegativePrompt == "" {
+			defaults = append(defaults, image.WithoutNegativePrompt())
+		} else {
+			defaults = append(defaults, image.WithNegativePrompt(*cfg.NegativePrompt))
+		}
+	}
+	if cfg.PersonGeneration != "" {
+		defaults = append(defaults, image.WithPersonGeneration(cfg.PersonGeneration))
+	}
+	if cfg.SafetySetting != "" {
+		defaults = append(defaults, image.WithSafetySetting(cfg.SafetySetting))
+	}
+	return defaults
+}
+
+// loadImageModerator returns a Gemini image moderator when enabled, or nil
+func loadImageModerator(enabled bool, client graph.VisionClient) graph.ImageModerator {
+	if !enabled || client == nil {
+		return nil
+	}
+	return graph.NewGeminiImageModerator(client)
+}
+
+// loadPostingPolicy builds the posting policy, falling back to graph.DefaultPostingPolicy for
+// levels not set. moderation checks posts with geminiClient before publishing.
+func loadPostingPolicy(cfg config.Posting, moderation bool, geminiClient graph.GeminiClient) graph.PostingPolicy {
+	policy := graph.DefaultPostingPolicy()
+
+	levels := []struct {
+		value  int
+		target *int
+	}{
+		{cfg.ForceDisclaimerLevel, &policy.ForceDisclaimerFromLevel},
+		{cfg.ForceHashtagLevel, &policy.ForceHashtagFromLevel},
+		{cfg.ConfirmationLevel, &policy.ConfirmationFromLevel},
+		{cfg.DefaultLevel, &policy.DefaultLevel},
+	}
+	for _, l := range levels {
+		if l.value != 0 {
+			*l.target = l.value
+		}
+	}
+
+	if cfg.ConfirmationSecret != "" {
+		policy.ConfirmationSecret = []byte(cfg.ConfirmationSecret)
+	}
+	policy.BlockOnModerationError = cfg.BlockOnModerationError
+	if moderation && geminiClient != nil {
+		policy.Moderator = graph.NewGeminiModerator(geminiClient)
+	}
+
+	return policy
+}
+
+// initializeLogger builds the logger. Debug logs at debug level and turns off redaction of prompts,
+// generated text and credentials, so it must not be enabled in production.
+func initializeLogger(cfg config.Logging) (*slog.Logger, error) {
+	loggingConfig := logging.Config{Format: cfg.Format, Debug: cfg.Debug}
+	if cfg.Debug {
+		loggingConfig.Level = slog.LevelDebug
+	}
+	if cfg.Level != "" {
+		level, err := logging.ParseLevel(cfg.Level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL: %w", err)
+		}
+		loggingConfig.Level = level
+	}
+	return logging.New(os.Stderr, loggingConfig)
+}
+
+// initializeTracing sets up OpenTelemetry tracing with exporter: "otlp" (configured by the standard
+// OTEL_EXPORTER_OTLP_* variables), "console" or "stdout" for local use, or "none".
+func initializeTracing(ctx context.Context, exporter string) (func(context.Context) error, error) {
+	shutdown, err := tracing.Setup(ctx, tracing.Config{Exporter: exporter})
+	if err != nil {
+		return nil, err
+	}
+	if exporter != "" && exporter != tracing.ExporterNone {
+		slog.Info("tracing enabled", "exporter", exporter)
+	}
+	return shutdown, nil
+}
+
+// twitterHealthTTL is how long a Twitter probe result is reused. The endpoint it calls allows only
+// a few requests per window, so it is probed far less often than the other dependencies.
+const twitterHealthTTL = time.Hour
+
+// initializeHealthChecker creates the checker behind /readyz and the health queries. Gemini and the
+// database are critical; Imagen, Twitter and image storage only degrade the service when down.
+// Probe results are reused for ttl.
+func initializeHealthChecker(ttl time.Duration, geminiClient *gemini.Client, imageClient *image.Client, twitterClient graph.TwitterClient, db *store.DB, imageStore imagestore.Store) *health.Checker {
+	dependencies := []health.Dependency{
+		{Name: "gemini", Probe: geminiClient.Ping, Critical: true},
+		{Name: "imagen", Probe: imageClient.Ping},
+		{Name: "twitter", TTL: twitterHealthTTL},
+		{Name: "database", Probe: func(context.Context) error { return db.Ping() }, Critical: true},
+		{Name: "storage"},
+	}
+	if pinger, ok := twitterClient.(interface{ Ping(context.Context) error }); ok {
+		dependencies[2].Probe = pinger.Ping
+	}
+	if imageStore != nil {
+		dependencies[4].Probe = imageStore.Ping
+	}
+	return health.New(dependencies, health.WithTTL(ttl))
+}
+
+// fatal logs msg at error level and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// newApp builds the service from cfg. The clients it opens are released when the app shuts down,
+// or immediately if startup fails.
+func newApp(ctx context.Context, cfg *config.Config) (_ *App, err error) {
+	app := NewApp(&http.Server{
+		Addr:         ":" + strconv.Itoa(cfg.Server.Port),
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+	}, cfg.Server.ShutdownTimeout)
+	// Release whatever was opened if startup fails part way
+	defer func() {
+		if err != nil {
+			if closeErr := app.Close(context.Background()); closeErr != nil {
+				slog.Warn("failed to release resources after startup failure", "error", closeErr)
+			}
+		}
+	}()
+
+	// Trace requests across resolvers and upstream calls. Closed last, so spans from the
+	// shutdown itself are flushed.
+	shutdownTracing, err := initializeTracing(ctx, cfg.Tracing.Exporter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tracing configuration: %w", err)
+	}
+	app.onClose("tracing", shutdownTracing)
+
 	// Initialize Vertex AI client with ADC
-	ctx := context.Background()
-	geminiClient, err := gemini.NewClient(ctx, projectID, location)
+	geminiClient, err := gemini.NewClient(ctx, cfg.GCP.ProjectID, cfg.GCP.Location, gemini.WithModel(cfg.Gemini.Model))
 	if err != nil {
-		log.Fatalf("Failed to create Vertex AI client: %v", err)
+		return nil, fmt.Errorf("failed to create Vertex AI client: %w", err)
 	}
+	app.onClose("gemini", func(context.Context) error { return geminiClient.Close() })
 
 	// Initialize Image client
-	imgClient, err := image.NewClient(ctx, projectID, location)
+	imgClient, err := image.NewClient(ctx, cfg.GCP.ProjectID, cfg.GCP.Location, imageDefaults(cfg.Imagen)...)
 	if err != nil {
-		log.Fatalf("Failed to create Image client: %v", err)
+		return nil, fmt.Errorf("failed to create Image client: %w", err)
 	}
+	app.onClose("imagen", func(context.Context) error { return imgClient.Close() })
 	imageClient := image.NewAdapter(imgClient)
 
 	// Initialize Twitter client (optional)
-	twitterClient := initializeTwitterClient()
+	twitterClient := initializeTwitterClient(cfg.Twitter)
+
+	// Open the embedded store used for durable state such as scheduled posts
+	db, err := store.Open(cfg.Database.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	app.onClose("database", func(context.Context) error { return db.Close() })
+
+	// Configure the posting policy applied before anything is published, optionally classifying
+	// generated and posted images with a multimodal model
+	postingPolicy := loadPostingPolicy(cfg.Posting, cfg.Features.PostingModeration, geminiClient)
+	imageModerator := loadImageModerator(cfg.Features.ImageModeration, geminiClient)
+	postingPolicy.ImageModerator = imageModerator
+	policyEngine, err := graph.NewPolicyEngine(postingPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create posting policy: %w", err)
+	}
+
+	// Simulations and the tweets posted from them
+	simulations := simulation.New(db)
+
+	// Image store for generated images
+	imageStore, imageURLs, err := initializeImageStore(cfg.ImageStore, cfg.Server.BaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image store: %w", err)
+	}
+
+	// Renderers for meme overlays and simulation screenshots
+	renderFont, err := loadRenderFont(cfg.Render.FontPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load render font: %w", err)
+	}
+	overlayRenderer, err := overlay.New(overlay.WithFont(renderFont))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create overlay renderer: %w", err)
+	}
+	screenshotRenderer, err := screenshot.New(screenshot.WithFont(renderFont))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create screenshot renderer: %w", err)
+	}
+
+	// Optionally answer identical Gemini and Imagen requests from a cache
+	generationCache, err := initializeGenerationCache(cfg.Cache)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create generation cache: %w", err)
+	}
+	var textClient graph.GeminiClient = geminiClient
+	var imagesClient graph.ImageClient = imageClient
+	if generationCache != nil {
+		textClient = graph.NewCachedGeminiClient(geminiClient, generationCache, cfg.Cache.TTL)
+		imagesClient = graph.NewCachedImageClient(imageClient, generationCache, cfg.Cache.TTL)
+	}
+
+	// Authenticate GraphQL requests when API keys or a JWKS are configured
+	authenticator, err := initializeAuthenticator(cfg.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authentication configuration: %w", err)
+	}
+	if authenticator == nil {
+		slog.Warn("authentication is not configured; the GraphQL API is open to anyone")
+	}
+
+	// Limits for GraphQL queries
+	graphqlServer, err := initializeGraphQLServer(cfg.GraphQL, cfg.Features.Metrics)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GraphQL server configuration: %w", err)
+	}
+
+	// Limit generation and posting per client
+	rateLimiter := initializeRateLimiter(cfg.Features.RateLimit, cfg.RateLimit)
+
+	// Let users link their own Twitter account
+	twitterAccounts, err := initializeTwitterAccounts(db, cfg.Twitter, cfg.Server.BaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("invalid Twitter account linking configuration: %w", err)
+	}
+
+	// Probe dependencies for readiness and the health queries
+	healthChecker := initializeHealthChecker(cfg.Health.CheckTTL, geminiClient, imgClient, twitterClient, db, imageStore)
+
+	// Options shared by the GraphQL resolver and the scheduled post worker
+	resolverOptions := []graph.ResolverOption{
+		graph.WithSimulationStore(simulations),
+		graph.WithOverlayRenderer(overlayRenderer),
+		graph.WithScreenshotRenderer(screenshotRenderer),
+		graph.WithVisionClient(geminiClient),
+	}
+	if imageModerator != nil {
+		resolverOptions = append(resolverOptions, graph.WithImageModerator(imageModerator))
+	}
+	if twitterAccounts != nil {
+		resolverOptions = append(resolverOptions, twitterAccounts)
+	}
+	if imageStore != nil {
+		resolverOptions = append(resolverOptions,
+			graph.WithImageStore(imageStore, imageURLs),
+			graph.WithImageCatalog(imagestore.NewCatalog(db)),
+		)
+	}
+
+	// Publish scheduled posts and compare replies in the background
+	postQueue := queue.New(db)
+	worker := queue.NewWorker(postQueue, graph.NewJobHandler(geminiClient, twitterClient, resolverOptions...), schedulerPollInterval)
+	app.addWorker("background jobs", worker.Run)
 
 	// Setup router
-	router := setupRouter(geminiClient, twitterClient, imageClient)
+	resolverOptions = append(resolverOptions,
+		graph.WithPostQueue(postQueue),
+		graph.WithPolicyEngine(policyEngine),
+	)
+	if authenticator != nil {
+		resolverOptions = append(resolverOptions, graph.WithAuthenticator(authenticator))
+	}
+	if rateLimiter != nil {
+		resolverOptions = append(resolverOptions, graph.WithRateLimiter(rateLimiter))
+	}
+	resolverOptions = append(resolverOptions, graph.WithHealthChecker(healthChecker))
+
+	app.server.Handler = setupRouter(graphqlServer, cfg.Server.CORSOrigins, textClient, twitterClient, imagesClient, resolverOptions...)
+
+	if graphqlServer.Introspection {
+		slog.Info("GraphQL Playground enabled", "url", cfg.Server.BaseURL()+"/")
+	}
+	return app, nil
+}
+
+func main() {
+	// Load environment variables. Variables already set take precedence over .env.
+	envErr := godotenv.Load()
+
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file; environment variables and .env take precedence (default $CONFIG_FILE)")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	flag.Parse()
 
-	// Start server
-	log.Printf("Server is running on http://localhost:%s", port)
-	log.Printf("GraphQL Playground: http://localhost:%s/graphql", port)
+	cfg, err := config.Load(*configPath, os.LookupEnv)
+	if *printConfig {
+		if printErr := cfg.Print(os.Stdout); printErr != nil {
+			fmt.Fprintf(os.Stderr, "Failed to print configuration: %v\n", printErr)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	if *printConfig {
+		return
+	}
 
-	server := &http.Server{
-		Addr:         ":" + port,
-		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	// Structured logging, configured before anything else logs
+	logger, err := initializeLogger(cfg.Logging)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid logging configuration: %v\n", err)
+		os.Exit(1)
 	}
+	slog.SetDefault(logger)
+	if envErr != nil {
+		slog.Warn(".env file not found, using system environment variables")
+	}
+	if *configPath != "" {
+		slog.Info("configuration loaded", "file", *configPath)
+	}
+
+	// Shut down gracefully on Ctrl-C or SIGTERM. A second signal exits immediately.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
 
-	// Run server
-	if err := server.ListenAndServe(); err != nil {
-		imgClient.Close()
-		log.Fatalf("Server failed: %v", err)
+	app, err := newApp(ctx, cfg)
+	if err != nil {
+		fatal("Failed to start", "error", err)
+	}
+	if err := app.Run(ctx); err != nil {
+		fatal("Server stopped with an error", "error", err)
 	}
+	slog.Info("server stopped")
 }
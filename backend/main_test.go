@@ -1,17 +1,35 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/http/cookiejar"
 	"net/http/httptest"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Tattsum/enjo/backend/accounts"
+	"github.com/Tattsum/enjo/backend/auth"
+	"github.com/Tattsum/enjo/backend/config"
+	"github.com/Tattsum/enjo/backend/gqlserver"
 	"github.com/Tattsum/enjo/backend/graph"
+	"github.com/Tattsum/enjo/backend/health"
+	"github.com/Tattsum/enjo/backend/image"
+	"github.com/Tattsum/enjo/backend/imagestore"
+	"github.com/Tattsum/enjo/backend/ratelimit"
+	"github.com/Tattsum/enjo/backend/secret"
+	"github.com/Tattsum/enjo/backend/store"
 	"github.com/Tattsum/enjo/backend/twitter"
 )
 
+// testCORSOrigins are the default allowed origins
+var testCORSOrigins = config.Default().Server.CORSOrigins
+
 // MockGeminiClient for testing
 type MockGeminiClient struct{}
 
@@ -48,16 +66,35 @@ func (*MockTwitterClient) PostTweetWithImage(_ context.Context, _ string, _ []by
 	}, nil
 }
 
+func (*MockTwitterClient) PostTweetWithImages(_ context.Context, _ string, _ []twitter.Image, _ ...twitter.TweetOption) (*twitter.TweetResult, error) {
+	return &twitter.TweetResult{
+		ID:  "mock-tweet-id-with-images",
+		URL: "https://twitter.com/user/status/mock-tweet-id-with-images",
+	}, nil
+}
+
+func (*MockTwitterClient) DeleteTweet(_ context.Context, _ string) error {
+	return nil
+}
+
+func (*MockTwitterClient) GetTweetMetrics(_ context.Context, _ string) (*twitter.TweetMetrics, error) {
+	return &twitter.TweetMetrics{}, nil
+}
+
+func (*MockTwitterClient) SearchReplies(_ context.Context, _ string) ([]twitter.Reply, error) {
+	return nil, nil
+}
+
 // MockImageClient for testing
 type MockImageClient struct{}
 
-func (*MockImageClient) GenerateImage(_ context.Context, _ string) ([]byte, error) {
-	return []byte("mock-image-data"), nil
+func (*MockImageClient) GenerateImages(_ context.Context, _ string, _ ...image.Option) ([][]byte, error) {
+	return [][]byte{[]byte("mock-image-data")}, nil
 }
 
 func TestHealthEndpoint(t *testing.T) {
 	// Arrange
-	handler := setupRouter(&MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{})
+	handler := setupRouter(gqlserver.DefaultConfig(), testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{})
 	req := httptest.NewRequest(http.MethodGet, "/health", http.NoBody)
 	w := httptest.NewRecorder()
 
@@ -76,9 +113,41 @@ func TestHealthEndpoint(t *testing.T) {
 	}
 }
 
+func TestProbeEndpoints(t *testing.T) {
+	down := health.New([]health.Dependency{
+		{Name: "database", Probe: func(context.Context) error { return errors.New("database is closed") }, Critical: true},
+	})
+	tests := []struct {
+		name       string
+		path       string
+		options    []graph.ResolverOption
+		wantStatus int
+	}{
+		{name: "liveness", path: "/livez", wantStatus: http.StatusOK},
+		{name: "liveness ignores dependencies", path: "/livez", options: []graph.ResolverOption{graph.WithHealthChecker(down)}, wantStatus: http.StatusOK},
+		{name: "readiness without checks", path: "/readyz", wantStatus: http.StatusOK},
+		{name: "readiness with a critical dependency down", path: "/readyz", options: []graph.ResolverOption{graph.WithHealthChecker(down)}, wantStatus: http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			handler := setupRouter(gqlserver.DefaultConfig(), testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{}, tt.options...)
+
+			// Act
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, http.NoBody))
+
+			// Assert
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
 func TestGraphQLEndpoint(t *testing.T) {
 	// Arrange
-	handler := setupRouter(&MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{})
+	handler := setupRouter(gqlserver.DefaultConfig(), testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{})
 
 	// GraphQL health query
 	query := `{"query": "query { health }"}`
@@ -116,7 +185,7 @@ func TestGraphQLEndpoint(t *testing.T) {
 
 func TestCORSHeaders(t *testing.T) {
 	// Arrange
-	handler := setupRouter(&MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{})
+	handler := setupRouter(gqlserver.DefaultConfig(), testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{})
 	req := httptest.NewRequest(http.MethodOptions, "/graphql", http.NoBody)
 	req.Header.Set("Origin", "http://localhost:3000")
 	req.Header.Set("Access-Control-Request-Method", "POST")
@@ -137,6 +206,38 @@ func TestCORSHeaders(t *testing.T) {
 	}
 }
 
+func TestCORSOrigins(t *testing.T) {
+	tests := []struct {
+		name      string
+		origins   []string
+		origin    string
+		wantAllow string
+	}{
+		{"configured origin", []string{"https://enjo.example.com"}, "https://enjo.example.com", "https://enjo.example.com"},
+		{"default origin no longer allowed", []string{"https://enjo.example.com"}, "http://localhost:3000", ""},
+		{"wildcard", []string{"*"}, "https://anywhere.example.com", "*"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			handler := setupRouter(gqlserver.DefaultConfig(), tt.origins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{})
+			req := httptest.NewRequest(http.MethodOptions, "/graphql", http.NoBody)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			w := httptest.NewRecorder()
+
+			// Act
+			handler.ServeHTTP(w, req)
+
+			// Assert
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllow {
+				t.Errorf("Expected Access-Control-Allow-Origin %q, got %q", tt.wantAllow, got)
+			}
+		})
+	}
+}
+
 func TestSetupRouter(t *testing.T) {
 	// Arrange
 	geminiClient := &MockGeminiClient{}
@@ -144,7 +245,7 @@ func TestSetupRouter(t *testing.T) {
 	imageClient := &MockImageClient{}
 
 	// Act
-	handler := setupRouter(geminiClient, twitterClient, imageClient)
+	handler := setupRouter(gqlserver.DefaultConfig(), testCORSOrigins, geminiClient, twitterClient, imageClient)
 
 	// Assert
 	if handler == nil {
@@ -166,3 +267,274 @@ func TestNewResolver(t *testing.T) {
 		t.Fatal("Expected NewResolver to return non-nil resolver")
 	}
 }
+
+func TestImageRoute(t *testing.T) {
+	// Arrange
+	images, err := imagestore.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create image store: %v", err)
+	}
+	signer, err := imagestore.NewURLSigner([]byte("secret"), time.Hour, "")
+	if err != nil {
+		t.Fatalf("Failed to create URL signer: %v", err)
+	}
+	id, err := images.Put(context.Background(), []byte("\x89PNG\r\n\x1a\nimage"))
+	if err != nil {
+		t.Fatalf("Failed to store image: %v", err)
+	}
+	handler := setupRouter(gqlserver.DefaultConfig(), testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{}, graph.WithImageStore(images, signer))
+
+	// Act
+	signed := httptest.NewRecorder()
+	handler.ServeHTTP(signed, httptest.NewRequest(http.MethodGet, signer.URL(id), http.NoBody))
+	unsigned := httptest.NewRecorder()
+	handler.ServeHTTP(unsigned, httptest.NewRequest(http.MethodGet, "/images/"+id, http.NoBody))
+
+	// Assert
+	if signed.Code != http.StatusOK {
+		t.Errorf("Expected status code %d for signed URL, got %d", http.StatusOK, signed.Code)
+	}
+	if unsigned.Code != http.StatusForbidden {
+		t.Errorf("Expected status code %d for unsigned URL, got %d", http.StatusForbidden, unsigned.Code)
+	}
+}
+
+func TestGraphQLAuthentication(t *testing.T) {
+	// Arrange
+	keys, err := auth.ParseAPIKeys("alice:alice-api-key-0123456789")
+	if err != nil {
+		t.Fatalf("Failed to parse API keys: %v", err)
+	}
+	authenticator, err := auth.New(auth.WithAPIKeys(keys))
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	handler := setupRouter(gqlserver.DefaultConfig(), testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{}, graph.WithAuthenticator(authenticator))
+
+	query := func(apiKey string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "query { me { id authMethod } }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if apiKey != "" {
+			req.Header.Set(auth.APIKeyHeader, apiKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Act
+	anonymous := query("")
+	authenticated := query("alice-api-key-0123456789")
+	health := httptest.NewRecorder()
+	handler.ServeHTTP(health, httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+
+	// Assert
+	if anonymous.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status code %d without credentials, got %d", http.StatusUnauthorized, anonymous.Code)
+	}
+	if health.Code != http.StatusOK {
+		t.Errorf("Expected /health to stay public, got %d", health.Code)
+	}
+	if authenticated.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d with an API key, got %d", http.StatusOK, authenticated.Code)
+	}
+	expected := `{"data":{"me":{"id":"alice","authMethod":"API_KEY"}}}`
+	if actual := strings.TrimSpace(authenticated.Body.String()); actual != expected {
+		t.Errorf("Expected body %q, got %q", expected, actual)
+	}
+}
+
+// mockTwitterAuthorizer issues a fixed request token and accepts the verifier "approved"
+type mockTwitterAuthorizer struct{}
+
+func (mockTwitterAuthorizer) RequestToken(context.Context) (*twitter.RequestToken, error) {
+	return &twitter.RequestToken{Token: "request-token", Secret: "request-secret", AuthorizeURL: "https://twitter.example.com/authorize"}, nil
+}
+
+func (mockTwitterAuthorizer) AccessToken(_ context.Context, token, secret, verifier string) (*twitter.Credentials, error) {
+	if token != "request-token" || secret != "request-secret" || verifier != "approved" {
+		return nil, errors.New("invalid verifier")
+	}
+	return &twitter.Credentials{AccessToken: "alice-token", AccessTokenSecret: "alice-secret", UserID: "42", ScreenName: "alice_enjo"}, nil
+}
+
+func TestTwitterAccountLinkingFlow(t *testing.T) {
+	// Arrange
+	keys, err := auth.ParseAPIKeys("alice:alice-api-key-0123456789")
+	if err != nil {
+		t.Fatalf("Failed to parse API keys: %v", err)
+	}
+	authenticator, err := auth.New(auth.WithAPIKeys(keys))
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	db, err := store.Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	box, err := secret.New(bytes.Repeat([]byte{1}, secret.KeySize))
+	if err != nil {
+		t.Fatalf("Failed to create secret box: %v", err)
+	}
+	clients := func(string, string) (graph.TwitterClient, error) { return &MockTwitterClient{}, nil }
+	server := httptest.NewServer(setupRouter(gqlserver.DefaultConfig(), testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{},
+		graph.WithAuthenticator(authenticator),
+		graph.WithTwitterAccounts(accounts.New(db, box), mockTwitterAuthorizer{}, clients, "https://enjo.example.com/settings")))
+	t.Cleanup(server.Close)
+
+	// newBrowser returns a client that keeps cookies like the browser the frontend runs in and
+	// does not follow redirects out of the app
+	newBrowser := func() *http.Client {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			t.Fatalf("Failed to create cookie jar: %v", err)
+		}
+		return &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	}
+	graphql := func(browser *http.Client, query string) *http.Response {
+		t.Helper()
+		body, _ := json.Marshal(map[string]string{"query": query})
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/graphql", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Origin", testCORSOrigins[0])
+		req.Header.Set(auth.APIKeyHeader, "alice-api-key-0123456789")
+		resp, err := browser.Do(req)
+		if err != nil {
+			t.Fatalf("GraphQL request failed: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	callback := func(browser *http.Client) string {
+		t.Helper()
+		resp, err := browser.Get(server.URL + twitterCallbackPath + "?oauth_token=request-token&oauth_verifier=approved")
+		if err != nil {
+			t.Fatalf("Callback request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.Header.Get("Location")
+	}
+
+	// Act
+	browser := newBrowser()
+	connect := graphql(browser, "mutation { connectTwitterAccount { authorizeUrl } }")
+	otherBrowser := newBrowser()
+	otherLocation := callback(otherBrowser)
+	location := callback(browser)
+	var account struct {
+		Data struct {
+			TwitterAccount *struct{ ScreenName string } `json:"twitterAccount"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(graphql(browser, "query { twitterAccount { screenName } }").Body).Decode(&account); err != nil {
+		t.Fatalf("Failed to decode twitterAccount: %v", err)
+	}
+
+	// Assert
+	if connect.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d from connectTwitterAccount, got %d", http.StatusOK, connect.StatusCode)
+	}
+	if got := connect.Header.Get("Access-Control-Allow-Credentials"); got != "true" {
+		t.Errorf("Expected the frontend to be allowed to send credentials, got Access-Control-Allow-Credentials %q", got)
+	}
+	if otherLocation != "https://enjo.example.com/settings?twitter=failed" {
+		t.Errorf("Expected a callback from another browser to fail, got redirect to %q", otherLocation)
+	}
+	if location != "https://enjo.example.com/settings?twitter=linked" {
+		t.Errorf("Expected the callback to link the account, got redirect to %q", location)
+	}
+	if account.Data.TwitterAccount == nil || account.Data.TwitterAccount.ScreenName != "alice_enjo" {
+		t.Errorf("Expected alice_enjo to be linked, got %+v", account.Data.TwitterAccount)
+	}
+}
+
+func TestGraphQLRateLimit(t *testing.T) {
+	// Arrange
+	// Room for one conversion, which is two Gemini calls
+	limiter := ratelimit.New(ratelimit.Config{
+		Rates: map[ratelimit.Operation]ratelimit.Rate{ratelimit.OpText: {Limit: 2, Period: time.Minute}},
+	})
+	handler := setupRouter(gqlserver.DefaultConfig(), testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{}, graph.WithRateLimiter(limiter))
+
+	generate := func() *httptest.ResponseRecorder {
+		body := `{"query": "mutation { generateInflammatoryText(input: {originalText: \"test\", level: 1}) { inflammatoryText } }"}`
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	// Act
+	allowed := generate()
+	limited := generate()
+
+	// Assert
+	if allowed.Header().Get(ratelimit.HeaderRemaining) != "0" || allowed.Header().Get(ratelimit.HeaderPolicy) != "2;w=60" {
+		t.Errorf("Expected rate limit headers on the allowed request, got %v", allowed.Header())
+	}
+	if strings.Contains(allowed.Body.String(), "errors") {
+		t.Errorf("Expected the first request to succeed, got %s", allowed.Body.String())
+	}
+	if limited.Header().Get(ratelimit.HeaderRetryAfter) == "" {
+		t.Error("Expected Retry-After on the limited request")
+	}
+	if !strings.Contains(limited.Body.String(), `"code":"RATE_LIMITED"`) {
+		t.Errorf("Expected a RATE_LIMITED error, got %s", limited.Body.String())
+	}
+}
+
+func TestPlaygroundDisabled(t *testing.T) {
+	// Arrange
+	server := gqlserver.DefaultConfig()
+	server.Introspection = false
+	handler := setupRouter(server, testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{})
+
+	// Act
+	playground := httptest.NewRecorder()
+	handler.ServeHTTP(playground, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	introspection := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query": "{ __schema { queryType { name } } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	handler.ServeHTTP(introspection, req)
+
+	// Assert
+	if playground.Code != http.StatusNotFound {
+		t.Errorf("Expected the playground to be disabled, got status %d", playground.Code)
+	}
+	if !strings.Contains(introspection.Body.String(), "errors") {
+		t.Errorf("Expected introspection to be rejected, got %s", introspection.Body.String())
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		wantStatus int
+	}{
+		{name: "enabled", enabled: true, wantStatus: http.StatusOK},
+		{name: "disabled", enabled: false, wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			server := gqlserver.DefaultConfig()
+			server.Metrics = tt.enabled
+			handler := setupRouter(server, testCORSOrigins, &MockGeminiClient{}, &MockTwitterClient{}, &MockImageClient{})
+
+			// Act
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+
+			// Assert
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if tt.enabled && !strings.Contains(w.Body.String(), "enjo_") {
+				t.Errorf("Expected application metrics, got %s", w.Body.String())
+			}
+		})
+	}
+}
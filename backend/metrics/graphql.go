@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// Extension is a gqlgen extension that records the duration of every operation and of every
+// field backed by a resolver. Fields that only read a struct member are not timed.
+type Extension struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = Extension{}
+
+// ExtensionName implements graphql.HandlerExtension
+func (Extension) ExtensionName() string {
+	return "Metrics"
+}
+
+// Validate implements graphql.HandlerExtension
+func (Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptResponse implements graphql.ResponseInterceptor
+func (Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	if resp == nil || !graphql.HasOperationContext(ctx) {
+		return resp
+	}
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil {
+		return resp
+	}
+
+	outcome := OutcomeOK
+	if len(resp.Errors) > 0 {
+		outcome = OutcomeError
+	}
+	OperationDuration.WithLabelValues(rootFields(opCtx.Operation), string(opCtx.Operation.Operation), outcome).
+		Observe(time.Since(opCtx.Stats.OperationStart).Seconds())
+	return resp
+}
+
+// InterceptField implements graphql.FieldInterceptor
+func (Extension) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+	start := time.Now()
+	res, err := next(ctx)
+	FieldDuration.WithLabelValues(fc.Object, fc.Field.Name, Outcome(err)).Observe(time.Since(start).Seconds())
+	return res, err
+}
+
+// rootFields names an operation by its root fields, e.g. "generateImage" or "quota,health".
+// Operation names are chosen by clients, so they would make the label unbounded.
+func rootFields(operation *ast.OperationDefinition) string {
+	var fields []string
+	seen := make(map[string]bool)
+	for _, selection := range operation.SelectionSet {
+		field, ok := selection.(*ast.Field)
+		if !ok || seen[field.Name] {
+			continue
+		}
+		seen[field.Name] = true
+		fields = append(fields, field.Name)
+	}
+	return strings.Join(fields, ",")
+}
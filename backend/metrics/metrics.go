@@ -0,0 +1,135 @@
+// Package metrics exposes Prometheus metrics for GraphQL operations and the upstream AI and
+// Twitter APIs. Collectors are registered on Registry, which Handler serves.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "enjo"
+
+// Upstream services, used as the service label
+const (
+	ServiceGemini  = "gemini"
+	ServiceImagen  = "imagen"
+	ServiceTwitter = "twitter"
+)
+
+// Outcomes of operations and upstream calls, used as the outcome label
+const (
+	OutcomeOK      = "ok"
+	OutcomeError   = "error"
+	OutcomeBlocked = "blocked" // Refused by the upstream safety filters
+)
+
+// Registry holds every collector of the package together with the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+// durationBuckets span fast GraphQL fields to slow image generations
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 60}
+
+var (
+	// OperationDuration observes GraphQL operations by their root fields, type and outcome
+	OperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "graphql",
+		Name:      "operation_duration_seconds",
+		Help:      "Duration of GraphQL operations by root fields, operation type and outcome.",
+		Buckets:   durationBuckets,
+	}, []string{"operation", "type", "outcome"})
+
+	// FieldDuration observes GraphQL fields backed by a resolver
+	FieldDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "graphql",
+		Name:      "field_duration_seconds",
+		Help:      "Duration of GraphQL field resolvers by object, field and outcome.",
+		Buckets:   durationBuckets,
+	}, []string{"object", "field", "outcome"})
+
+	// UpstreamDuration observes calls to Gemini, Imagen and Twitter. The task is the Gemini
+	// task type, the Imagen model or the Twitter endpoint.
+	UpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "upstream",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of upstream API calls by service, task and outcome.",
+		Buckets:   durationBuckets,
+	}, []string{"service", "task", "outcome"})
+
+	// SafetyBlocks counts generations refused or filtered by the upstream safety filters
+	SafetyBlocks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "upstream",
+		Name:      "safety_blocks_total",
+		Help:      "Generations blocked or filtered by upstream safety filters by service and task.",
+	}, []string{"service", "task"})
+
+	// FinishReasons counts Gemini candidates by the reason generation stopped
+	FinishReasons = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gemini",
+		Name:      "finish_reasons_total",
+		Help:      "Gemini candidates by task and finish reason.",
+	}, []string{"task", "reason"})
+
+	// Tokens counts Gemini tokens reported in the usage metadata of responses
+	Tokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "gemini",
+		Name:      "tokens_total",
+		Help:      "Gemini tokens used by task and kind (prompt, candidates or thoughts).",
+	}, []string{"task", "kind"})
+
+	// Retries counts failed attempts that are retried later
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retries_total",
+		Help:      "Failed attempts scheduled for a retry by operation.",
+	}, []string{"operation"})
+
+	// EstimatedCost sums the estimated cost charged to clients by the rate limiter
+	EstimatedCost = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "estimated_cost_usd_total",
+		Help:      "Estimated cost in USD of allowed operations by operation class.",
+	}, []string{"operation"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		OperationDuration,
+		FieldDuration,
+		UpstreamDuration,
+		SafetyBlocks,
+		FinishReasons,
+		Tokens,
+		Retries,
+		EstimatedCost,
+	)
+}
+
+// Handler serves the metrics in Registry in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveUpstream records an upstream call that started at start
+func ObserveUpstream(service, task, outcome string, start time.Time) {
+	UpstreamDuration.WithLabelValues(service, task, outcome).Observe(time.Since(start).Seconds())
+}
+
+// Outcome returns OutcomeError when err is set and OutcomeOK otherwise
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeOK
+}
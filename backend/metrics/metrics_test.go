@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestEndpoint(t *testing.T) {
+	tests := map[string]string{
+		"/2/tweets":                  "/2/tweets",
+		"/2/tweets/1234567890":       "/2/tweets/:id",
+		"/2/tweets/search/recent":    "/2/tweets/search/recent",
+		"/1.1/media/upload.json":     "/1.1/media/upload.json",
+		"/1.1/statuses/destroy/42.x": "/1.1/statuses/destroy/42.x",
+	}
+	for path, want := range tests {
+		if got := endpoint(path); got != want {
+			t.Errorf("endpoint(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/2/tweets/1" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: NewTransport("test", nil)}
+	for _, path := range []string{"/2/tweets", "/2/tweets/1"} {
+		resp, err := client.Get(upstream.URL + path)
+		if err != nil {
+			t.Fatalf("Get(%s) error = %v", path, err)
+		}
+		resp.Body.Close()
+	}
+
+	output := scrape(t)
+	for _, want := range []string{
+		`enjo_upstream_request_duration_seconds_count{outcome="ok",service="test",task="GET /2/tweets"} 1`,
+		`enjo_upstream_request_duration_seconds_count{outcome="error",service="test",task="GET /2/tweets/:id"} 1`,
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("metrics output is missing %s", want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	EstimatedCost.WithLabelValues("IMAGE").Add(0.02)
+	if got := testutil.ToFloat64(EstimatedCost.WithLabelValues("IMAGE")); got != 0.02 {
+		t.Errorf("estimated cost = %v, want 0.02", got)
+	}
+
+	output := scrape(t)
+	for _, want := range []string{`enjo_estimated_cost_usd_total{operation="IMAGE"} 0.02`, "go_goroutines"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("metrics output is missing %s", want)
+		}
+	}
+}
+
+// scrape returns the metrics served by Handler
+func scrape(t *testing.T) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", http.NoBody))
+	body, err := io.ReadAll(w.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+	return string(body)
+}
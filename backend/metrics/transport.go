@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Transport records the duration and outcome of each request to an upstream API, using the
+// method and the request path with IDs replaced by ":id" as the task
+type Transport struct {
+	// Service names the upstream API, e.g. ServiceTwitter
+	Service string
+	// Base performs the requests; http.DefaultTransport when nil
+	Base http.RoundTripper
+}
+
+// NewTransport returns a Transport for service on top of base
+func NewTransport(service string, base http.RoundTripper) *Transport {
+	return &Transport{Service: service, Base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+
+	outcome := Outcome(err)
+	if err == nil && resp.StatusCode >= http.StatusBadRequest {
+		outcome = OutcomeError
+	}
+	ObserveUpstream(t.Service, req.Method+" "+endpoint(req.URL.Path), outcome, start)
+	return resp, err
+}
+
+// endpoint replaces numeric path segments, such as tweet IDs, to keep the task label bounded.
+// The first segment is kept since it holds the API version, e.g. "/2/tweets".
+func endpoint(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if i > 1 && segment != "" && strings.Trim(segment, "0123456789") == "" {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,330 @@
+// Package overlay composites meme-style captions, a fake engagement bar and the
+// simulator watermark onto generated images using only image/draw.
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // Register the JPEG decoder for source images
+	"image/png"
+	"strings"
+
+	"github.com/Tattsum/enjo/backend/typeset"
+)
+
+// Watermark is the text stamped onto images when Spec.Watermark is set
+const Watermark = "炎上シミュレーター"
+
+// Layout selects where the caption text is placed
+type Layout string
+
+const (
+	// LayoutTop draws outlined caption text over the top of the image
+	LayoutTop Layout = "TOP"
+	// LayoutBottom draws outlined caption text over the bottom of the image
+	LayoutBottom Layout = "BOTTOM"
+	// LayoutTopBottom splits the caption between the top and bottom edges, classic meme style
+	LayoutTopBottom Layout = "TOP_BOTTOM"
+	// LayoutCaptionBar puts the caption in a white bar above the image
+	LayoutCaptionBar Layout = "CAPTION_BAR"
+)
+
+var (
+	// ErrEmptySpec is returned when a spec has nothing to draw
+	ErrEmptySpec = errors.New("overlay needs text, an engagement bar or the watermark")
+	// ErrUnknownLayout is returned for layouts not defined by this package
+	ErrUnknownLayout = errors.New("unknown overlay layout")
+)
+
+// Engagement holds the counts shown in the fake engagement bar
+type Engagement struct {
+	Replies int
+	Reposts int
+	Likes   int
+}
+
+// Spec describes what to draw onto an image
+type Spec struct {
+	Layout     Layout
+	Text       string
+	Engagement *Engagement // Adds an engagement bar below the image when non-nil
+	Watermark  bool
+}
+
+// Validate reports whether the spec can be rendered
+func (s Spec) Validate() error {
+	switch s.Layout {
+	case LayoutTop, LayoutBottom, LayoutTopBottom, LayoutCaptionBar:
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownLayout, s.Layout)
+	}
+	if strings.TrimSpace(s.Text) == "" && s.Engagement == nil && !s.Watermark {
+		return ErrEmptySpec
+	}
+	return nil
+}
+
+var (
+	captionBarColor    = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	captionTextColor   = color.RGBA{R: 0x0f, G: 0x14, B: 0x19, A: 0xff}
+	memeTextColor      = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	memeStrokeColor    = color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0xff}
+	engagementBarColor = color.RGBA{R: 0x15, G: 0x20, B: 0x2b, A: 0xff}
+	engagementColor    = color.RGBA{R: 0x8b, G: 0x98, B: 0xa5, A: 0xff}
+	watermarkColor     = color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xc0}
+	watermarkStroke    = color.NRGBA{R: 0x00, G: 0x00, B: 0x00, A: 0x80}
+)
+
+const (
+	// captionMaxLines is the number of lines a caption bar may use
+	captionMaxLines = 4
+	// memeMaxLines is the number of lines each block of outlined meme text may use
+	memeMaxLines = 3
+)
+
+// Renderer draws overlays with a single font
+type Renderer struct {
+	font *typeset.Font
+}
+
+// Option is a functional option for the renderer
+type Option func(*rendererOptions)
+
+type rendererOptions struct {
+	font []byte
+}
+
+// WithFont renders text with the given TrueType/OpenType font instead of the embedded one
+func WithFont(data []byte) Option {
+	return func(opts *rendererOptions) {
+		opts.font = data
+	}
+}
+
+// New creates a renderer. Without WithFont the embedded font is used.
+func New(options ...Option) (*Renderer, error) {
+	opts := &rendererOptions{}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	f, err := typeset.Parse(opts.font)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{font: f}, nil
+}
+
+// Render decodes a PNG or JPEG image, draws spec onto it and returns the result as PNG
+func (r *Renderer) Render(src []byte, spec Spec) ([]byte, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	canvas, err := r.compose(img, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// compose lays out the canvas: an optional caption bar, the image and an optional engagement bar
+func (r *Renderer) compose(img image.Image, spec Spec) (*image.RGBA, error) {
+	width := img.Bounds().Dx()
+	height := img.Bounds().Dy()
+	text := strings.TrimSpace(spec.Text)
+
+	var caption *typeset.Block
+	captionHeight := 0
+	if spec.Layout == LayoutCaptionBar && text != "" {
+		block, err := r.font.Fit(text, width-2*padding(width), captionMaxLines, float64(width)/16)
+		if err != nil {
+			return nil, err
+		}
+		caption = block
+		captionHeight = block.Height() + 2*padding(width)
+	}
+	engagementHeight := 0
+	if spec.Engagement != nil {
+		engagementHeight = max(width/10, 2*typeset.MinSize)
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, captionHeight+height+engagementHeight))
+	imageArea := image.Rect(0, captionHeight, width, captionHeight+height)
+	draw.Draw(canvas, imageArea, img, img.Bounds().Min, draw.Src)
+
+	if caption != nil {
+		defer caption.Close()
+		draw.Draw(canvas, image.Rect(0, 0, width, captionHeight), image.NewUniform(captionBarColor), image.Point{}, draw.Src)
+		caption.Draw(canvas, padding(width), padding(width), typeset.AlignLeft, captionTextColor, nil)
+	}
+	if text != "" && spec.Layout != LayoutCaptionBar {
+		if err := r.drawMemeText(canvas, imageArea, text, spec.Layout); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Watermark {
+		if err := r.drawWatermark(canvas, imageArea); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Engagement != nil {
+		bar := image.Rect(0, imageArea.Max.Y, width, imageArea.Max.Y+engagementHeight)
+		if err := r.drawEngagementBar(canvas, bar, *spec.Engagement); err != nil {
+			return nil, err
+		}
+	}
+	return canvas, nil
+}
+
+// drawMemeText draws outlined caption text inside area according to layout
+func (r *Renderer) drawMemeText(dst draw.Image, area image.Rectangle, text string, layout Layout) error {
+	width := area.Dx()
+	maxWidth := width - 2*padding(width)
+	size := float64(width) / 9
+
+	top, bottom := text, ""
+	switch layout {
+	case LayoutBottom:
+		top, bottom = "", text
+	case LayoutTopBottom:
+		top, bottom = splitTopBottom(text)
+	}
+
+	if top != "" {
+		block, err := r.font.Fit(top, maxWidth, memeMaxLines, size)
+		if err != nil {
+			return err
+		}
+		defer block.Close()
+		block.Draw(dst, area.Min.X+padding(width), area.Min.Y+padding(width), typeset.AlignCenter, memeTextColor, memeStroke(block.Size))
+	}
+	if bottom != "" {
+		block, err := r.font.Fit(bottom, maxWidth, memeMaxLines, size)
+		if err != nil {
+			return err
+		}
+		defer block.Close()
+		y := area.Max.Y - padding(width) - block.Height()
+		block.Draw(dst, area.Min.X+padding(width), y, typeset.AlignCenter, memeTextColor, memeStroke(block.Size))
+	}
+	return nil
+}
+
+// drawWatermark stamps the watermark into the bottom-right corner of area
+func (r *Renderer) drawWatermark(dst draw.Image, area image.Rectangle) error {
+	face, err := r.font.Face(float64(area.Dx()) / 28)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+
+	block := &typeset.Block{Face: face, Lines: []string{Watermark}}
+	x := area.Max.X - padding(area.Dx()) - block.Width()
+	y := area.Max.Y - padding(area.Dx())/2 - block.Height()
+	block.Draw(dst, x, y, typeset.AlignLeft, watermarkColor, &typeset.Stroke{Color: watermarkStroke, Width: 1})
+	return nil
+}
+
+// drawEngagementBar fills bar and writes the engagement counts into it
+func (r *Renderer) drawEngagementBar(dst draw.Image, bar image.Rectangle, e Engagement) error {
+	draw.Draw(dst, bar, image.NewUniform(engagementBarColor), image.Point{}, draw.Src)
+
+	face, err := r.font.Face(float64(bar.Dy()) * 0.4)
+	if err != nil {
+		return err
+	}
+	defer face.Close()
+
+	line := fmt.Sprintf("返信 %s    リポスト %s    いいね %s", FormatCount(e.Replies), FormatCount(e.Reposts), FormatCount(e.Likes))
+	block := &typeset.Block{Face: face, Lines: []string{line}}
+	y := bar.Min.Y + (bar.Dy()-block.Height())/2
+	block.Draw(dst, bar.Min.X+padding(bar.Dx()), y, typeset.AlignLeft, engagementColor, nil)
+	return nil
+}
+
+// FormatCount formats a count the way Japanese SNS clients do: 3,456 / 1.2万 / 3.4億
+func FormatCount(n int) string {
+	switch {
+	case n < 0:
+		return "0"
+	case n < 10_000:
+		return groupThousands(n)
+	case n < 100_000_000:
+		return shortUnit(n, 10_000, "万")
+	default:
+		return shortUnit(n, 100_000_000, "億")
+	}
+}
+
+// groupThousands inserts thousands separators into n
+func groupThousands(n int) string {
+	s := fmt.Sprint(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// shortUnit formats n in the given unit, with one decimal below 100 units
+func shortUnit(n, unit int, suffix string) string {
+	tenths := n * 10 / unit
+	if tenths%10 == 0 || tenths >= 1000 {
+		return groupThousands(tenths/10) + suffix
+	}
+	return fmt.Sprintf("%d.%d%s", tenths/10, tenths%10, suffix)
+}
+
+// padding is the margin used around overlay elements for an image of the given width
+func padding(width int) int {
+	return max(width/40, 4)
+}
+
+// memeStroke is the outline used for meme text of the given size
+func memeStroke(size float64) *typeset.Stroke {
+	return &typeset.Stroke{Color: memeStrokeColor, Width: max(int(size/16), 1)}
+}
+
+// splitTopBottom splits meme text into a top and a bottom half: at the first line break
+// if there is one, otherwise at the punctuation or space closest to the middle
+func splitTopBottom(text string) (top, bottom string) {
+	if before, after, ok := strings.Cut(text, "\n"); ok {
+		return strings.TrimSpace(before), strings.TrimSpace(after)
+	}
+
+	runes := []rune(text)
+	if len(runes) < 2 {
+		return text, ""
+	}
+	middle := len(runes) / 2
+	split := middle
+	for offset := 0; offset < middle; offset++ {
+		if i := middle + offset; i < len(runes)-1 && isBreak(runes[i]) {
+			split = i + 1
+			break
+		}
+		if i := middle - offset - 1; i > 0 && isBreak(runes[i]) {
+			split = i + 1
+			break
+		}
+	}
+	return strings.TrimSpace(string(runes[:split])), strings.TrimSpace(string(runes[split:]))
+}
+
+// isBreak reports whether r is a natural place to split a sentence
+func isBreak(r rune) bool {
+	return strings.ContainsRune(" 、。，．！？!?,.", r)
+}
@@ -0,0 +1,195 @@
+package overlay
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// testImage returns a solid grey PNG of the given size
+func testImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			img.Set(x, y, color.RGBA{R: 0x80, G: 0x80, B: 0x80, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// changedRows reports whether any row in [from, to) differs from the grey test background
+func changedRows(img image.Image, from, to int) bool {
+	for y := from; y < to; y++ {
+		for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			if r>>8 != 0x80 || g>>8 != 0x80 || b>>8 != 0x80 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestRenderer_Render(t *testing.T) {
+	renderer, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	src := testImage(t, 400, 300)
+
+	tests := []struct {
+		name       string
+		spec       Spec
+		wantHeight int
+		wantTop    bool // Whether the top quarter of the image area is drawn on
+		wantBottom bool // Whether the bottom quarter of the image area is drawn on
+	}{
+		{
+			name:       "top layout draws only over the top",
+			spec:       Spec{Layout: LayoutTop, Text: "BURNING TAKE"},
+			wantHeight: 300,
+			wantTop:    true,
+		},
+		{
+			name:       "bottom layout draws only over the bottom",
+			spec:       Spec{Layout: LayoutBottom, Text: "BURNING TAKE"},
+			wantHeight: 300,
+			wantBottom: true,
+		},
+		{
+			name:       "top and bottom layout splits the text",
+			spec:       Spec{Layout: LayoutTopBottom, Text: "ONE DOES NOT SIMPLY\nPOST THIS"},
+			wantHeight: 300,
+			wantTop:    true,
+			wantBottom: true,
+		},
+		{
+			name:       "watermark alone marks the bottom corner",
+			spec:       Spec{Layout: LayoutTop, Watermark: true},
+			wantHeight: 300,
+			wantBottom: true,
+		},
+		{
+			name:       "engagement bar extends the canvas",
+			spec:       Spec{Layout: LayoutTop, Engagement: &Engagement{Replies: 789, Reposts: 3456, Likes: 12345}},
+			wantHeight: 340,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := renderer.Render(src, tt.spec)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			img, err := png.Decode(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("Render() returned an invalid PNG: %v", err)
+			}
+
+			if img.Bounds().Dx() != 400 || img.Bounds().Dy() != tt.wantHeight {
+				t.Errorf("size = %v, want 400x%d", img.Bounds().Size(), tt.wantHeight)
+			}
+			if got := changedRows(img, 0, 75); got != tt.wantTop {
+				t.Errorf("top drawn = %v, want %v", got, tt.wantTop)
+			}
+			if got := changedRows(img, 225, 300); got != tt.wantBottom {
+				t.Errorf("bottom drawn = %v, want %v", got, tt.wantBottom)
+			}
+		})
+	}
+}
+
+func TestRenderer_CaptionBar(t *testing.T) {
+	renderer, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	out, err := renderer.Render(testImage(t, 400, 300), Spec{Layout: LayoutCaptionBar, Text: "When the take is too hot to post"})
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Render() returned an invalid PNG: %v", err)
+	}
+
+	captionHeight := img.Bounds().Dy() - 300
+	if captionHeight <= 0 {
+		t.Fatalf("height = %d, want a caption bar above the image", img.Bounds().Dy())
+	}
+	if changedRows(img, captionHeight, img.Bounds().Dy()) {
+		t.Error("caption bar layout drew over the image")
+	}
+}
+
+func TestRenderer_Errors(t *testing.T) {
+	renderer, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := renderer.Render(testImage(t, 10, 10), Spec{Layout: LayoutTop}); !errors.Is(err, ErrEmptySpec) {
+		t.Errorf("Render(empty) error = %v, want ErrEmptySpec", err)
+	}
+	if _, err := renderer.Render(testImage(t, 10, 10), Spec{Layout: "SIDEWAYS", Text: "x"}); !errors.Is(err, ErrUnknownLayout) {
+		t.Errorf("Render(unknown layout) error = %v, want ErrUnknownLayout", err)
+	}
+	if _, err := renderer.Render([]byte("not an image"), Spec{Layout: LayoutTop, Text: "x"}); err == nil {
+		t.Error("Render(invalid image) succeeded, want error")
+	}
+	if _, err := New(WithFont([]byte("not a font"))); err == nil {
+		t.Error("New(invalid font) succeeded, want error")
+	}
+}
+
+func TestSplitTopBottom(t *testing.T) {
+	tests := []struct {
+		text       string
+		wantTop    string
+		wantBottom string
+	}{
+		{text: "上の行\n下の行", wantTop: "上の行", wantBottom: "下の行"},
+		{text: "会議が長すぎる、帰りたい", wantTop: "会議が長すぎる、", wantBottom: "帰りたい"},
+		{text: "abcd", wantTop: "ab", wantBottom: "cd"},
+		{text: "a", wantTop: "a"},
+	}
+
+	for _, tt := range tests {
+		top, bottom := splitTopBottom(tt.text)
+		if top != tt.wantTop || bottom != tt.wantBottom {
+			t.Errorf("splitTopBottom(%q) = %q, %q, want %q, %q", tt.text, top, bottom, tt.wantTop, tt.wantBottom)
+		}
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := map[int]string{
+		-1:          "0",
+		0:           "0",
+		789:         "789",
+		3456:        "3,456",
+		10000:       "1万",
+		12345:       "1.2万",
+		1234567:     "123万",
+		12345678:    "1,234万",
+		340000000:   "3.4億",
+		12345678901: "123億",
+	}
+
+	for n, want := range tests {
+		if got := FormatCount(n); got != want {
+			t.Errorf("FormatCount(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,408 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Tattsum/enjo/backend/store"
+)
+
+const (
+	// bucketJobs is the store bucket holding scheduled post jobs
+	bucketJobs = "scheduled_posts"
+	// defaultMaxAttempts is the number of publish attempts before a job is marked as failed
+	defaultMaxAttempts = 3
+	// defaultRetryBackoff is the delay before the first retry; it doubles on every attempt
+	defaultRetryBackoff = 30 * time.Second
+)
+
+// Kind is what a job does
+type Kind string
+
+const (
+	// KindPost publishes a scheduled post
+	KindPost Kind = "POST"
+	// KindCompareReplies fetches the real replies of a posted tweet and compares them with its
+	// simulation. It has no side effects outside the store, so it is retried after interruptions.
+	KindCompareReplies Kind = "COMPARE_REPLIES"
+)
+
+// Status is the lifecycle state of a scheduled post job
+type Status string
+
+const (
+	// StatusPending means the job is waiting for its scheduled time
+	StatusPending Status = "PENDING"
+	// StatusRunning means a worker is currently publishing the job
+	StatusRunning Status = "RUNNING"
+	// StatusSucceeded means the post was published
+	StatusSucceeded Status = "SUCCEEDED"
+	// StatusFailed means the job exhausted its retries or was interrupted mid-publish
+	StatusFailed Status = "FAILED"
+	// StatusCancelled means the job was cancelled before it was published
+	StatusCancelled Status = "CANCELLED"
+)
+
+var (
+	// ErrNotFound is returned when a job does not exist
+	ErrNotFound = errors.New("scheduled post not found")
+	// ErrInvalidState is returned when an operation is not allowed in the job's current status
+	ErrInvalidState = errors.New("scheduled post cannot be modified in its current status")
+)
+
+// Transition records a status change of a job
+type Transition struct {
+	Status  Status    `json:"status"`
+	At      time.Time `json:"at"`
+	Message string    `json:"message,omitempty"`
+}
+
+// Image is an image attached to a scheduled post
+type Image struct {
+	URL     string `json:"url"`
+	AltText string `json:"altText,omitempty"`
+}
+
+// Job is a post scheduled for publication at a future time, or another kind of background work
+type Job struct {
+	ID            string       `json:"id"`
+	Kind          Kind         `json:"kind,omitempty"`
+	OwnerID       string       `json:"ownerId,omitempty"`
+	Text          string       `json:"text"`
+	Images        []Image      `json:"images,omitempty"`
+	Level         int          `json:"level,omitempty"`
+	SimulationID  string       `json:"simulationId,omitempty"`
+	AddHashtag    bool         `json:"addHashtag"`
+	AddDisclaimer bool         `json:"addDisclaimer"`
+	ScheduledAt   time.Time    `json:"scheduledAt"`
+	Status        Status       `json:"status"`
+	Attempts      int          `json:"attempts"`
+	MaxAttempts   int          `json:"maxAttempts"`
+	LastError     string       `json:"lastError,omitempty"`
+	TweetID       string       `json:"tweetId,omitempty"` // The published tweet, or the tweet whose replies are compared
+	TweetURL      string       `json:"tweetUrl,omitempty"`
+	CreatedAt     time.Time    `json:"createdAt"`
+	UpdatedAt     time.Time    `json:"updatedAt"`
+	History       []Transition `json:"history"`
+}
+
+// normalize fills in the kind of jobs stored before jobs had kinds
+func (j *Job) normalize() {
+	if j.Kind == "" {
+		j.Kind = KindPost
+	}
+}
+
+// transition moves the job to status and appends it to the history
+func (j *Job) transition(status Status, at time.Time, message string) {
+	j.Status = status
+	j.UpdatedAt = at
+	j.History = append(j.History, Transition{Status: status, At: at, Message: message})
+}
+
+// Queue is a durable queue of scheduled posts persisted in the embedded store
+type Queue struct {
+	db           *store.DB
+	now          func() time.Time
+	maxAttempts  int
+	retryBackoff time.Duration
+}
+
+// Option is a functional option for the queue
+type Option func(*Queue)
+
+// WithMaxAttempts sets how many times a job is attempted before it is marked as failed
+func WithMaxAttempts(n int) Option {
+	return func(q *Queue) {
+		if n > 0 {
+			q.maxAttempts = n
+		}
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry of a failed attempt
+func WithRetryBackoff(d time.Duration) Option {
+	return func(q *Queue) {
+		q.retryBackoff = d
+	}
+}
+
+// WithClock overrides the clock used for scheduling (useful for testing)
+func WithClock(now func() time.Time) Option {
+	return func(q *Queue) {
+		q.now = now
+	}
+}
+
+// New creates a queue backed by db
+func New(db *store.DB, options ...Option) *Queue {
+	q := &Queue{
+		db:           db,
+		now:          time.Now,
+		maxAttempts:  defaultMaxAttempts,
+		retryBackoff: defaultRetryBackoff,
+	}
+	for _, opt := range options {
+		opt(q)
+	}
+	return q
+}
+
+// Enqueue stores a new pending job, a post unless job.Kind says otherwise. ID, status and
+// bookkeeping fields are filled in by the queue.
+func (q *Queue) Enqueue(_ context.Context, job Job) (*Job, error) {
+	job.normalize()
+	switch job.Kind {
+	case KindPost:
+		if job.Text == "" {
+			return nil, errors.New("text is required")
+		}
+	case KindCompareReplies:
+		if job.TweetID == "" {
+			return nil, errors.New("tweet ID is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+	if job.ScheduledAt.IsZero() {
+		return nil, errors.New("scheduled time is required")
+	}
+
+	now := q.now()
+	job.ID = uuid.NewString()
+	job.Attempts = 0
+	job.MaxAttempts = q.maxAttempts
+	job.LastError = ""
+	job.CreatedAt = now
+	job.History = nil
+	job.transition(StatusPending, now, "scheduled")
+
+	if err := q.db.Put(bucketJobs, job.ID, &job); err != nil {
+		return nil, fmt.Errorf("failed to store scheduled post: %w", err)
+	}
+	return &job, nil
+}
+
+// Get returns the job with the given ID
+func (q *Queue) Get(_ context.Context, id string) (*Job, error) {
+	var job Job
+	if err := q.db.Get(bucketJobs, id, &job); err != nil {
+		return nil, wrapNotFound(err)
+	}
+	job.normalize()
+	return &job, nil
+}
+
+// List returns jobs of kind ordered by scheduled time. If status is non-nil only jobs in that status
+// are returned.
+func (q *Queue) List(_ context.Context, kind Kind, status *Status) ([]*Job, error) {
+	return q.list(func(job *Job) bool {
+		return job.Kind == kind && (status == nil || job.Status == *status)
+	})
+}
+
+// list returns the jobs matching keep ordered by scheduled time
+func (q *Queue) list(keep func(*Job) bool) ([]*Job, error) {
+	var jobs []*Job
+	err := q.db.ForEach(bucketJobs, func(_ string, raw []byte) error {
+		var job Job
+		if err := json.Unmarshal(raw, &job); err != nil {
+			return fmt.Errorf("failed to decode scheduled post: %w", err)
+		}
+		job.normalize()
+		if keep(&job) {
+			jobs = append(jobs, &job)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].ScheduledAt.Before(jobs[j].ScheduledAt)
+	})
+	return jobs, nil
+}
+
+// withStatus keeps jobs of any kind in status
+func withStatus(status Status) func(*Job) bool {
+	return func(job *Job) bool { return job.Status == status }
+}
+
+// Cancel cancels a pending job
+func (q *Queue) Cancel(_ context.Context, id string) (*Job, error) {
+	var job Job
+	err := q.db.Update(bucketJobs, id, &job, func() error {
+		if job.Status != StatusPending {
+			return ErrInvalidState
+		}
+		job.transition(StatusCancelled, q.now(), "cancelled by user")
+		return nil
+	})
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return &job, nil
+}
+
+// Reschedule moves a pending or failed job to a new time. Failed jobs get a fresh set of attempts.
+func (q *Queue) Reschedule(_ context.Context, id string, at time.Time) (*Job, error) {
+	if at.IsZero() {
+		return nil, errors.New("scheduled time is required")
+	}
+
+	var job Job
+	err := q.db.Update(bucketJobs, id, &job, func() error {
+		if job.Status != StatusPending && job.Status != StatusFailed {
+			return ErrInvalidState
+		}
+		job.ScheduledAt = at
+		job.Attempts = 0
+		job.LastError = ""
+		job.transition(StatusPending, q.now(), "rescheduled to "+at.Format(time.RFC3339))
+		return nil
+	})
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return &job, nil
+}
+
+// claimNext marks the earliest due pending job as running and returns it.
+// It returns nil when no job is due.
+func (q *Queue) claimNext(_ context.Context) (*Job, error) {
+	jobs, err := q.list(withStatus(StatusPending))
+	if err != nil {
+		return nil, err
+	}
+
+	now := q.now()
+	for _, candidate := range jobs {
+		if candidate.ScheduledAt.After(now) {
+			break
+		}
+
+		var job Job
+		err := q.db.Update(bucketJobs, candidate.ID, &job, func() error {
+			// Another worker or a cancel may have raced us
+			if job.Status != StatusPending {
+				return ErrInvalidState
+			}
+			job.Attempts++
+			job.transition(StatusRunning, now, fmt.Sprintf("attempt %d/%d", job.Attempts, job.MaxAttempts))
+			return nil
+		})
+		if errors.Is(err, ErrInvalidState) || errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		job.normalize()
+		return &job, nil
+	}
+	return nil, nil
+}
+
+// complete marks a running job as succeeded
+func (q *Queue) complete(id, tweetID, tweetURL string) (*Job, error) {
+	var job Job
+	err := q.db.Update(bucketJobs, id, &job, func() error {
+		job.normalize()
+		job.LastError = ""
+		if job.Kind != KindPost {
+			job.transition(StatusSucceeded, q.now(), "completed")
+			return nil
+		}
+		job.TweetID = tweetID
+		job.TweetURL = tweetURL
+		job.transition(StatusSucceeded, q.now(), "published as tweet "+tweetID)
+		return nil
+	})
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return &job, nil
+}
+
+// unconfirmedMessage explains why a post was failed instead of retried
+const unconfirmedMessage = "the tweet may already have been posted; check the account and reschedule to retry"
+
+// fail records a failed attempt. The job is retried with exponential backoff
+// until MaxAttempts is reached, after which it is marked as failed. A post is only
+// retried when cause is marked with SafeToRetry: after any other failure, such as a
+// timeout, the tweet may have been published, and retrying could publish it twice.
+func (q *Queue) fail(id string, cause error) (*Job, error) {
+	var job Job
+	err := q.db.Update(bucketJobs, id, &job, func() error {
+		now := q.now()
+		job.normalize()
+		job.LastError = cause.Error()
+		if job.Attempts >= job.MaxAttempts {
+			job.transition(StatusFailed, now, cause.Error())
+			return nil
+		}
+		if job.Kind == KindPost && !isSafeToRetry(cause) {
+			job.transition(StatusFailed, now, fmt.Sprintf("%v; %s", cause, unconfirmedMessage))
+			return nil
+		}
+
+		backoff := q.retryBackoff << (job.Attempts - 1)
+		job.ScheduledAt = now.Add(backoff)
+		job.transition(StatusPending, now, fmt.Sprintf("retrying in %v: %v", backoff, cause))
+		return nil
+	})
+	if err != nil {
+		return nil, wrapNotFound(err)
+	}
+	return &job, nil
+}
+
+// interruptedMessage explains why a job left running was failed instead of retried
+const interruptedMessage = "interrupted while publishing; the tweet may already have been posted, check the account and reschedule to retry"
+
+// recoverRunning settles jobs left running by a crashed or killed worker. The tweet of a post may
+// have been accepted before the worker died, so retrying automatically could publish it twice; the
+// post fails and waits for an explicit reschedule instead. Other kinds are safe to run again.
+func (q *Queue) recoverRunning(_ context.Context) error {
+	jobs, err := q.list(withStatus(StatusRunning))
+	if err != nil {
+		return err
+	}
+
+	for _, stale := range jobs {
+		var job Job
+		err := q.db.Update(bucketJobs, stale.ID, &job, func() error {
+			if job.Status != StatusRunning {
+				return ErrInvalidState
+			}
+			job.normalize()
+			if job.Kind != KindPost {
+				job.transition(StatusPending, q.now(), "recovered after interrupted attempt")
+				return nil
+			}
+			job.LastError = interruptedMessage
+			job.transition(StatusFailed, q.now(), interruptedMessage)
+			return nil
+		})
+		if err != nil && !errors.Is(err, ErrInvalidState) {
+			return wrapNotFound(err)
+		}
+	}
+	return nil
+}
+
+// wrapNotFound translates the store's not-found error into the queue's
+func wrapNotFound(err error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
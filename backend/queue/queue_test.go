@@ -0,0 +1,537 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/store"
+)
+
+// fakeClock is a controllable clock for testing
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestQueue(t *testing.T, options ...Option) (*Queue, *fakeClock) {
+	t.Helper()
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "queue.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	options = append([]Option{WithClock(clock.Now)}, options...)
+	return New(db, options...), clock
+}
+
+func assertStatus(t *testing.T, job *Job, want Status) {
+	t.Helper()
+
+	if job.Status != want {
+		t.Errorf("Status = %s, want %s", job.Status, want)
+	}
+	if len(job.History) == 0 || job.History[len(job.History)-1].Status != want {
+		t.Errorf("last history entry does not record status %s: %+v", want, job.History)
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	ctx := context.Background()
+	q, clock := newTestQueue(t)
+
+	t.Run("stores a pending job", func(t *testing.T) {
+		job, err := q.Enqueue(ctx, Job{Text: "予約投稿", ScheduledAt: clock.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		if job.ID == "" {
+			t.Error("expected job ID to be set")
+		}
+		if job.MaxAttempts != defaultMaxAttempts {
+			t.Errorf("MaxAttempts = %d, want %d", job.MaxAttempts, defaultMaxAttempts)
+		}
+		assertStatus(t, job, StatusPending)
+
+		got, err := q.Get(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got.Text != "予約投稿" {
+			t.Errorf("Text = %q, want %q", got.Text, "予約投稿")
+		}
+	})
+
+	t.Run("error when text is empty", func(t *testing.T) {
+		if _, err := q.Enqueue(ctx, Job{ScheduledAt: clock.Now()}); err == nil {
+			t.Fatal("expected error when text is empty")
+		}
+	})
+
+	t.Run("error when scheduled time is missing", func(t *testing.T) {
+		if _, err := q.Enqueue(ctx, Job{Text: "text"}); err == nil {
+			t.Fatal("expected error when scheduled time is missing")
+		}
+	})
+
+	t.Run("stores a reply comparison without text", func(t *testing.T) {
+		job, err := q.Enqueue(ctx, Job{Kind: KindCompareReplies, TweetID: "tweet-1", ScheduledAt: clock.Now()})
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+		if job.Kind != KindCompareReplies {
+			t.Errorf("Kind = %s, want %s", job.Kind, KindCompareReplies)
+		}
+	})
+
+	t.Run("error when reply comparison has no tweet", func(t *testing.T) {
+		if _, err := q.Enqueue(ctx, Job{Kind: KindCompareReplies, ScheduledAt: clock.Now()}); err == nil {
+			t.Fatal("expected error when tweet ID is empty")
+		}
+	})
+
+	t.Run("error when kind is unknown", func(t *testing.T) {
+		if _, err := q.Enqueue(ctx, Job{Kind: "DELETE", Text: "text", ScheduledAt: clock.Now()}); err == nil {
+			t.Fatal("expected error when kind is unknown")
+		}
+	})
+}
+
+func TestGet_NotFound(t *testing.T) {
+	q, _ := newTestQueue(t)
+
+	if _, err := q.Get(context.Background(), "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	q, clock := newTestQueue(t)
+
+	later, err := q.Enqueue(ctx, Job{Text: "later", ScheduledAt: clock.Now().Add(2 * time.Hour)})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	sooner, err := q.Enqueue(ctx, Job{Text: "sooner", ScheduledAt: clock.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if _, err := q.Cancel(ctx, later.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	comparison, err := q.Enqueue(ctx, Job{Kind: KindCompareReplies, TweetID: "tweet-1", ScheduledAt: clock.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	all, err := q.List(ctx, KindPost, nil)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(all) != 2 || all[0].ID != sooner.ID || all[1].ID != later.ID {
+		t.Errorf("List() did not return jobs ordered by scheduled time")
+	}
+
+	pending := StatusPending
+	filtered, err := q.List(ctx, KindPost, &pending)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(filtered) != 1 || filtered[0].ID != sooner.ID {
+		t.Errorf("List(PENDING) returned %d jobs, want only the pending one", len(filtered))
+	}
+
+	comparisons, err := q.List(ctx, KindCompareReplies, nil)
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	if len(comparisons) != 1 || comparisons[0].ID != comparison.ID {
+		t.Errorf("List(COMPARE_REPLIES) returned %d jobs, want only the comparison", len(comparisons))
+	}
+}
+
+func TestCancel(t *testing.T) {
+	ctx := context.Background()
+	q, clock := newTestQueue(t)
+
+	job, err := q.Enqueue(ctx, Job{Text: "text", ScheduledAt: clock.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	cancelled, err := q.Cancel(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	assertStatus(t, cancelled, StatusCancelled)
+
+	if _, err := q.Cancel(ctx, job.ID); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("second Cancel() error = %v, want ErrInvalidState", err)
+	}
+	if _, err := q.Cancel(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Cancel() of missing job error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestReschedule(t *testing.T) {
+	ctx := context.Background()
+	q, clock := newTestQueue(t, WithMaxAttempts(1))
+
+	job, err := q.Enqueue(ctx, Job{Text: "text", ScheduledAt: clock.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	// Exhaust the only attempt so the job fails
+	claimed, err := q.claimNext(ctx)
+	if err != nil || claimed == nil {
+		t.Fatalf("claimNext() = %v, %v", claimed, err)
+	}
+	failed, err := q.fail(job.ID, errors.New("boom"))
+	if err != nil {
+		t.Fatalf("fail() error = %v", err)
+	}
+	assertStatus(t, failed, StatusFailed)
+
+	newTime := clock.Now().Add(time.Hour)
+	rescheduled, err := q.Reschedule(ctx, job.ID, newTime)
+	if err != nil {
+		t.Fatalf("Reschedule() error = %v", err)
+	}
+	assertStatus(t, rescheduled, StatusPending)
+	if !rescheduled.ScheduledAt.Equal(newTime) {
+		t.Errorf("ScheduledAt = %v, want %v", rescheduled.ScheduledAt, newTime)
+	}
+	if rescheduled.Attempts != 0 || rescheduled.LastError != "" {
+		t.Errorf("expected attempts and last error to be reset, got %d %q", rescheduled.Attempts, rescheduled.LastError)
+	}
+
+	if _, err := q.Cancel(ctx, job.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if _, err := q.Reschedule(ctx, job.ID, newTime); !errors.Is(err, ErrInvalidState) {
+		t.Errorf("Reschedule() of cancelled job error = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestClaimNext(t *testing.T) {
+	ctx := context.Background()
+	q, clock := newTestQueue(t)
+
+	job, err := q.Enqueue(ctx, Job{Text: "text", ScheduledAt: clock.Now().Add(time.Minute)})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	claimed, err := q.claimNext(ctx)
+	if err != nil {
+		t.Fatalf("claimNext() error = %v", err)
+	}
+	if claimed != nil {
+		t.Fatal("claimNext() returned a job that is not due yet")
+	}
+
+	clock.Advance(time.Minute)
+	claimed, err = q.claimNext(ctx)
+	if err != nil {
+		t.Fatalf("claimNext() error = %v", err)
+	}
+	if claimed == nil || claimed.ID != job.ID {
+		t.Fatalf("claimNext() = %v, want job %s", claimed, job.ID)
+	}
+	assertStatus(t, claimed, StatusRunning)
+	if claimed.Attempts != 1 {
+		t.Errorf("Attempts = %d, want 1", claimed.Attempts)
+	}
+
+	again, err := q.claimNext(ctx)
+	if err != nil {
+		t.Fatalf("claimNext() error = %v", err)
+	}
+	if again != nil {
+		t.Error("claimNext() returned an already running job")
+	}
+}
+
+func TestFail_RetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	q, clock := newTestQueue(t, WithMaxAttempts(2), WithRetryBackoff(time.Minute))
+
+	job, err := q.Enqueue(ctx, Job{Text: "text", ScheduledAt: clock.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+
+	if _, err := q.claimNext(ctx); err != nil {
+		t.Fatalf("claimNext() error = %v", err)
+	}
+	retried, err := q.fail(job.ID, SafeToRetry(errors.New("temporary")))
+	if err != nil {
+		t.Fatalf("fail() error = %v", err)
+	}
+	assertStatus(t, retried, StatusPending)
+	if want := clock.Now().Add(time.Minute); !retried.ScheduledAt.Equal(want) {
+		t.Errorf("ScheduledAt = %v, want %v", retried.ScheduledAt, want)
+	}
+	if retried.LastError != "temporary" {
+		t.Errorf("LastError = %q, want %q", retried.LastError, "temporary")
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := q.claimNext(ctx); err != nil {
+		t.Fatalf("claimNext() error = %v", err)
+	}
+	failed, err := q.fail(job.ID, errors.New("permanent"))
+	if err != nil {
+		t.Fatalf("fail() error = %v", err)
+	}
+	assertStatus(t, failed, StatusFailed)
+}
+
+func TestFail_PostsOnlyRetriedWhenSafe(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		kind       Kind
+		cause      error
+		wantStatus Status
+	}{
+		{"post that may have been published", KindPost, errors.New("timeout awaiting response"), StatusFailed},
+		{"post that was not published", KindPost, SafeToRetry(errors.New("rate limited")), StatusPending},
+		{"comparison", KindCompareReplies, errors.New("timeout awaiting response"), StatusPending},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, clock := newTestQueue(t, WithMaxAttempts(3))
+			job, err := q.Enqueue(ctx, Job{Kind: tt.kind, Text: "text", TweetID: "tweet-1", ScheduledAt: clock.Now()})
+			if err != nil {
+				t.Fatalf("Enqueue() error = %v", err)
+			}
+			if _, err := q.claimNext(ctx); err != nil {
+				t.Fatalf("claimNext() error = %v", err)
+			}
+
+			got, err := q.fail(job.ID, tt.cause)
+			if err != nil {
+				t.Fatalf("fail() error = %v", err)
+			}
+			assertStatus(t, got, tt.wantStatus)
+			if got.LastError != tt.cause.Error() {
+				t.Errorf("LastError = %q, want %q", got.LastError, tt.cause.Error())
+			}
+		})
+	}
+}
+
+func TestRecoverRunning(t *testing.T) {
+	ctx := context.Background()
+	q, clock := newTestQueue(t)
+
+	job, err := q.Enqueue(ctx, Job{Text: "text", ScheduledAt: clock.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if _, err := q.claimNext(ctx); err != nil {
+		t.Fatalf("claimNext() error = %v", err)
+	}
+
+	if err := q.recoverRunning(ctx); err != nil {
+		t.Fatalf("recoverRunning() error = %v", err)
+	}
+
+	// The interrupted attempt may have been published, so the job is not retried automatically
+	got, err := q.Get(ctx, job.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	assertStatus(t, got, StatusFailed)
+	if got.LastError != interruptedMessage {
+		t.Errorf("LastError = %q, want %q", got.LastError, interruptedMessage)
+	}
+	if last := got.History[len(got.History)-1]; last.Status != StatusFailed || last.Message != interruptedMessage {
+		t.Errorf("last transition = %+v, want FAILED with the interruption message", last)
+	}
+	claimed, err := q.claimNext(ctx)
+	if err != nil {
+		t.Fatalf("claimNext() error = %v", err)
+	}
+	if claimed != nil {
+		t.Errorf("claimNext() = %+v, want the interrupted job to stay failed", claimed)
+	}
+
+	// An explicit reschedule makes it eligible again
+	rescheduled, err := q.Reschedule(ctx, job.ID, clock.Now())
+	if err != nil {
+		t.Fatalf("Reschedule() error = %v", err)
+	}
+	assertStatus(t, rescheduled, StatusPending)
+}
+
+func TestRecoverRunning_ComparisonIsRetried(t *testing.T) {
+	ctx := context.Background()
+	q, clock := newTestQueue(t)
+
+	job, err := q.Enqueue(ctx, Job{Kind: KindCompareReplies, TweetID: "tweet-1", ScheduledAt: clock.Now()})
+	if err != nil {
+		t.Fatalf("Enqueue() error = %v", err)
+	}
+	if _, err := q.claimNext(ctx); err != nil {
+		t.Fatalf("claimNext() error = %v", err)
+	}
+
+	if err := q.recoverRunning(ctx); err != nil {
+		t.Fatalf("recoverRunning() error = %v", err)
+	}
+
+	// Comparing replies only reads from Twitter, so running it again is harmless
+	claimed, err := q.claimNext(ctx)
+	if err != nil {
+		t.Fatalf("claimNext() error = %v", err)
+	}
+	if claimed == nil || claimed.ID != job.ID {
+		t.Fatalf("claimNext() = %+v, want the interrupted comparison", claimed)
+	}
+	if claimed.Attempts != 2 {
+		t.Errorf("Attempts = %d, want 2", claimed.Attempts)
+	}
+}
+
+func TestWorker(t *testing.T) {
+	t.Run("publishes due jobs", func(t *testing.T) {
+		ctx := context.Background()
+		q, clock := newTestQueue(t)
+
+		job, err := q.Enqueue(ctx, Job{Text: "text", ScheduledAt: clock.Now()})
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+
+		var published []string
+		worker := NewWorker(q, func(_ context.Context, j *Job) (*Result, error) {
+			published = append(published, j.Text)
+			return &Result{TweetID: "tweet-1", TweetURL: "https://twitter.com/user/status/tweet-1"}, nil
+		}, time.Second)
+		worker.drain(ctx)
+
+		if len(published) != 1 {
+			t.Fatalf("handler called %d times, want 1", len(published))
+		}
+
+		got, err := q.Get(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		assertStatus(t, got, StatusSucceeded)
+		if got.TweetID != "tweet-1" {
+			t.Errorf("TweetID = %q, want %q", got.TweetID, "tweet-1")
+		}
+	})
+
+	t.Run("keeps the compared tweet of a reply comparison", func(t *testing.T) {
+		ctx := context.Background()
+		q, clock := newTestQueue(t)
+
+		job, err := q.Enqueue(ctx, Job{Kind: KindCompareReplies, TweetID: "tweet-1", ScheduledAt: clock.Now()})
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+
+		worker := NewWorker(q, func(context.Context, *Job) (*Result, error) {
+			return &Result{}, nil
+		}, time.Second)
+		worker.drain(ctx)
+
+		got, err := q.Get(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		assertStatus(t, got, StatusSucceeded)
+		if got.TweetID != "tweet-1" {
+			t.Errorf("TweetID = %q, want %q", got.TweetID, "tweet-1")
+		}
+	})
+
+	t.Run("records handler failures", func(t *testing.T) {
+		ctx := context.Background()
+		q, clock := newTestQueue(t, WithMaxAttempts(1))
+
+		job, err := q.Enqueue(ctx, Job{Text: "text", ScheduledAt: clock.Now()})
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+
+		worker := NewWorker(q, func(context.Context, *Job) (*Result, error) {
+			return nil, errors.New("twitter is down")
+		}, time.Second)
+		worker.drain(ctx)
+
+		got, err := q.Get(ctx, job.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		assertStatus(t, got, StatusFailed)
+		if got.LastError != "twitter is down" {
+			t.Errorf("LastError = %q, want %q", got.LastError, "twitter is down")
+		}
+	})
+
+	t.Run("stops when context is cancelled", func(t *testing.T) {
+		q, _ := newTestQueue(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		worker := NewWorker(q, func(context.Context, *Job) (*Result, error) {
+			return &Result{}, nil
+		}, time.Millisecond)
+		if err := worker.Run(ctx); err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	})
+
+	t.Run("finishes a claimed job when cancelled", func(t *testing.T) {
+		q, clock := newTestQueue(t)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		job, err := q.Enqueue(ctx, Job{Text: "text", ScheduledAt: clock.Now()})
+		if err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+
+		worker := NewWorker(q, func(jobCtx context.Context, _ *Job) (*Result, error) {
+			// Shutdown starts while the post is being published
+			cancel()
+			if err := jobCtx.Err(); err != nil {
+				return nil, err
+			}
+			return &Result{TweetID: "tweet-1"}, nil
+		}, time.Second)
+		worker.drain(ctx)
+
+		got, err := q.Get(context.Background(), job.ID)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		assertStatus(t, got, StatusSucceeded)
+	})
+}
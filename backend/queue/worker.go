@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/logging"
+	"github.com/Tattsum/enjo/backend/metrics"
+)
+
+// defaultPollInterval is how often the worker checks for due jobs
+const defaultPollInterval = 5 * time.Second
+
+// Result is the outcome of publishing a job. Jobs other than posts return an empty result.
+type Result struct {
+	TweetID  string
+	TweetURL string
+}
+
+// Handler runs a single job. A post whose handler fails is only retried when the error is marked
+// with SafeToRetry.
+type Handler func(ctx context.Context, job *Job) (*Result, error)
+
+// safeToRetryError marks a handler error as leaving nothing published
+type safeToRetryError struct {
+	err error
+}
+
+func (e *safeToRetryError) Error() string {
+	return e.err.Error()
+}
+
+func (e *safeToRetryError) Unwrap() error {
+	return e.err
+}
+
+// SafeToRetry marks err, returned by a handler for a post, as certain to have left no tweet
+// behind, so that the post can be attempted again without publishing it twice
+func SafeToRetry(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &safeToRetryError{err: err}
+}
+
+// isSafeToRetry reports whether err was marked with SafeToRetry
+func isSafeToRetry(err error) bool {
+	var safe *safeToRetryError
+	return errors.As(err, &safe)
+}
+
+// Worker polls the queue and runs due jobs through a Handler
+type Worker struct {
+	queue        *Queue
+	handler      Handler
+	pollInterval time.Duration
+}
+
+// NewWorker creates a worker that publishes jobs from q with handler
+func NewWorker(q *Queue, handler Handler, pollInterval time.Duration) *Worker {
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	return &Worker{
+		queue:        q,
+		handler:      handler,
+		pollInterval: pollInterval,
+	}
+}
+
+// Run processes due jobs until ctx is cancelled. It returns once the job in progress, if any, is done.
+func (w *Worker) Run(ctx context.Context) error {
+	if err := w.queue.recoverRunning(ctx); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain publishes every job that is currently due
+func (w *Worker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := w.queue.claimNext(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "scheduled post worker failed to claim job", "error", err)
+			return
+		}
+		if job == nil {
+			return
+		}
+		// A claimed job is finished even if ctx is cancelled meanwhile, so shutting down does
+		// not abandon a post half published
+		w.process(context.WithoutCancel(ctx), job)
+	}
+}
+
+// metricLabel names the kind of job in metrics and logs
+func (k Kind) metricLabel() string {
+	if k == KindCompareReplies {
+		return "reply_comparison"
+	}
+	return "scheduled_post"
+}
+
+// process runs a claimed job and records the outcome
+func (w *Worker) process(ctx context.Context, job *Job) {
+	// Jobs run outside any request, so the job ID stands in for the request ID in logs and upstream calls
+	ctx = logging.WithRequestID(ctx, "job-"+job.ID)
+	result, err := w.handler(ctx, job)
+	if err == nil && result == nil {
+		err = errors.New("handler returned no result")
+	}
+
+	if err != nil {
+		updated, failErr := w.queue.fail(job.ID, err)
+		if failErr != nil {
+			slog.ErrorContext(ctx, "scheduled post worker failed to record job failure", "job_id", job.ID, "error", failErr)
+			return
+		}
+		if updated.Status == StatusPending {
+			metrics.Retries.WithLabelValues(job.Kind.metricLabel()).Inc()
+		}
+		slog.WarnContext(ctx, "background job failed", "job_id", job.ID, "kind", job.Kind,
+			"attempt", job.Attempts, "max_attempts", job.MaxAttempts, "status", updated.Status, "error", err)
+		return
+	}
+
+	if _, err := w.queue.complete(job.ID, result.TweetID, result.TweetURL); err != nil {
+		slog.ErrorContext(ctx, "scheduled post worker failed to record job success", "job_id", job.ID, "error", err)
+		return
+	}
+	if job.Kind != KindPost {
+		slog.InfoContext(ctx, "background job completed", "job_id", job.ID, "kind", job.Kind)
+		return
+	}
+	slog.InfoContext(ctx, "scheduled post published", "job_id", job.ID, "tweet_id", result.TweetID)
+}
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/auth"
+)
+
+// Standard rate limit headers, as described by the IETF RateLimit header fields draft
+const (
+	HeaderLimit      = "RateLimit-Limit"
+	HeaderRemaining  = "RateLimit-Remaining"
+	HeaderReset      = "RateLimit-Reset"
+	HeaderPolicy     = "RateLimit-Policy"
+	HeaderRetryAfter = "Retry-After"
+)
+
+// Headers lists the response headers set by the middleware, for CORS configuration
+var Headers = []string{HeaderLimit, HeaderRemaining, HeaderReset, HeaderPolicy, HeaderRetryAfter}
+
+type clientKey struct{}
+
+type decisionsKey struct{}
+
+// decisions keeps the most restrictive decision made while serving one request
+type decisions struct {
+	mu    sync.Mutex
+	worst *Decision
+}
+
+// WithClient returns a context that charges operations to client
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFrom returns the client operations in ctx are charged to
+func ClientFrom(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+// ClientKey identifies the client making r: the authenticated user if any, otherwise the remote IP
+func ClientKey(r *http.Request) string {
+	if user, ok := auth.UserFrom(r.Context()); ok {
+		return "user:" + user.OwnerID()
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Record notes a decision so that the middleware reports it in the response headers.
+// When a request performs several operations, the most restrictive decision is reported.
+func Record(ctx context.Context, decision Decision) {
+	d, ok := ctx.Value(decisionsKey{}).(*decisions)
+	if !ok || decision.Limit == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.worst == nil || moreRestrictive(decision, *d.worst) {
+		d.worst = &decision
+	}
+}
+
+// moreRestrictive reports whether a leaves the client less room than b
+func moreRestrictive(a, b Decision) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	return float64(a.Remaining)/float64(a.Limit) < float64(b.Remaining)/float64(b.Limit)
+}
+
+// Middleware identifies the client of each request and reports the rate limits its operations
+// were checked against in RateLimit headers. Limits themselves are enforced per operation by
+// Allow, since a single GraphQL request may run any mix of operations. It must run after
+// authentication so that authenticated users are limited per user rather than per address.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithClient(r.Context(), ClientKey(r))
+		d := &decisions{}
+		ctx = context.WithValue(ctx, decisionsKey{}, d)
+		next.ServeHTTP(&headerWriter{ResponseWriter: w, decisions: d}, r.WithContext(ctx))
+	})
+}
+
+// headerWriter adds the rate limit headers before the response is written
+type headerWriter struct {
+	http.ResponseWriter
+	decisions *decisions
+	written   bool
+}
+
+func (w *headerWriter) WriteHeader(status int) {
+	w.writeRateLimitHeaders()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerWriter) Write(b []byte) (int, error) {
+	w.writeRateLimitHeaders()
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (w *headerWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *headerWriter) writeRateLimitHeaders() {
+	if w.written {
+		return
+	}
+	w.written = true
+
+	w.decisions.mu.Lock()
+	decision := w.decisions.worst
+	w.decisions.mu.Unlock()
+	if decision == nil {
+		return
+	}
+
+	h := w.Header()
+	h.Set(HeaderLimit, strconv.Itoa(decision.Limit))
+	h.Set(HeaderRemaining, strconv.Itoa(decision.Remaining))
+	h.Set(HeaderReset, seconds(decision.Reset))
+	h.Set(HeaderPolicy, fmt.Sprintf("%d;w=%s", decision.Limit, seconds(decision.Period)))
+	if !decision.Allowed {
+		h.Set(HeaderRetryAfter, seconds(decision.RetryAfter))
+	}
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(ceilSecond(d)/time.Second), 10)
+}
@@ -0,0 +1,331 @@
+// Package ratelimit throttles expensive operations per client with token buckets and caps the
+// estimated daily spend of each client.
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Operation is a class of operations that share a rate limit and a cost estimate
+type Operation string
+
+const (
+	// OpText covers Gemini text generation
+	OpText Operation = "TEXT"
+	// OpImage covers Imagen image generation, charged per generated image
+	OpImage Operation = "IMAGE"
+	// OpPost covers publishing to Twitter
+	OpPost Operation = "POST"
+)
+
+// Operations lists every operation class in display order
+var Operations = []Operation{OpText, OpImage, OpPost}
+
+// maxClients bounds the number of tracked clients before idle ones are pruned
+const maxClients = 10000
+
+var (
+	// ErrRateLimited is returned when a client has used up the burst for an operation
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrQuotaExceeded is returned when an operation would take a client over its daily budget
+	ErrQuotaExceeded = errors.New("daily cost quota exceeded")
+	// ErrTooManyUnits is returned for requests that need more units than the rate limit allows at
+	// once, which no amount of waiting would let through
+	ErrTooManyUnits = errors.New("request exceeds the rate limit")
+)
+
+// Rate allows Limit operations per Period, refilled continuously. A zero Limit means unlimited.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// ParseRate parses a rate written as "limit/period", e.g. "10/1m". "off" and "0" mean unlimited.
+func ParseRate(value string) (Rate, error) {
+	value = strings.TrimSpace(value)
+	if value == "off" || value == "0" {
+		return Rate{}, nil
+	}
+	limitPart, periodPart, ok := strings.Cut(value, "/")
+	if !ok {
+		return Rate{}, fmt.Errorf("rate %q must be limit/period, e.g. 10/1m", value)
+	}
+	limit, err := strconv.Atoi(limitPart)
+	if err != nil || limit < 1 {
+		return Rate{}, fmt.Errorf("rate limit %q must be a positive integer", limitPart)
+	}
+	period, err := time.ParseDuration(periodPart)
+	if err != nil || period <= 0 {
+		return Rate{}, fmt.Errorf("rate period %q must be a positive duration", periodPart)
+	}
+	return Rate{Limit: limit, Period: period}, nil
+}
+
+// Config sets the limits applied to every client
+type Config struct {
+	// Rates are the token bucket limits per operation; operations without one are unlimited
+	Rates map[Operation]Rate
+	// DailyBudget caps the estimated spend per client per UTC day in USD; zero disables the quota
+	DailyBudget float64
+	// Costs are the estimated costs per unit of each operation in USD
+	Costs map[Operation]float64
+}
+
+// DefaultConfig returns limits suited to a small deployment: a few images per minute
+// and one US dollar of estimated spend per client per day
+func DefaultConfig() Config {
+	return Config{
+		Rates: map[Operation]Rate{
+			OpText:  {Limit: 30, Period: time.Minute},
+			OpImage: {Limit: 5, Period: time.Minute},
+			OpPost:  {Limit: 5, Period: time.Minute},
+		},
+		DailyBudget: 1.0,
+		Costs: map[Operation]float64{
+			OpText:  0.001,
+			OpImage: 0.02,
+			OpPost:  0,
+		},
+	}
+}
+
+// Decision is the outcome of a request to perform an operation
+type Decision struct {
+	Operation Operation
+	Allowed   bool
+	// Limit, Period, Remaining and Reset describe the operation's token bucket after the
+	// request; Limit is zero when the operation is not rate limited
+	Limit     int
+	Period    time.Duration
+	Remaining int
+	Reset     time.Duration // Until the bucket is full again
+	// RetryAfter is how long to wait before retrying a denied request
+	RetryAfter time.Duration
+	// Cost is the estimated cost charged for the request
+	Cost float64
+}
+
+// Usage is a client's standing against the rate limit of one operation
+type Usage struct {
+	Operation Operation
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+	Period    time.Duration
+}
+
+// Quota is a client's standing against its limits
+type Quota struct {
+	DailyBudget float64 // Zero when no quota is enforced
+	Spent       float64
+	ResetsAt    time.Time
+	Limits      []Usage
+}
+
+// Remaining returns the estimated budget left for the day
+func (q Quota) Remaining() float64 {
+	return math.Max(0, q.DailyBudget-q.Spent)
+}
+
+// bucket is a token bucket
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// spend is the estimated spend of a client on one UTC day
+type spend struct {
+	day    time.Time
+	amount float64
+}
+
+// Limiter enforces a Config for every client
+type Limiter struct {
+	config Config
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket // Keyed by client and operation
+	spent   map[string]*spend
+}
+
+// Option is a functional option for the limiter
+type Option func(*Limiter)
+
+// WithClock overrides the clock used for refills and daily resets (useful for testing)
+func WithClock(now func() time.Time) Option {
+	return func(l *Limiter) {
+		l.now = now
+	}
+}
+
+// New creates a limiter. Limits are kept in memory, so they restart with the process.
+func New(config Config, options ...Option) *Limiter {
+	l := &Limiter{
+		config:  config,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+		spent:   make(map[string]*spend),
+	}
+	for _, opt := range options {
+		opt(l)
+	}
+	return l
+}
+
+// Allow lets client perform op if both the operation's rate limit and the client's daily budget
+// allow it. Each request takes units tokens from the bucket and is charged units times the
+// operation's cost, e.g. per image requested. Denied requests are not charged.
+// The returned error is ErrRateLimited, ErrQuotaExceeded or ErrTooManyUnits when the request is denied.
+func (l *Limiter) Allow(client string, op Operation, units int) (Decision, error) {
+	if units < 1 {
+		units = 1
+	}
+	now := l.now()
+	rate := l.config.Rates[op]
+	cost := l.config.Costs[op] * float64(units)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+
+	decision := Decision{Operation: op, Limit: rate.Limit, Period: rate.Period, Cost: cost}
+
+	var b *bucket
+	if rate.Limit > 0 {
+		b = l.bucket(client, op, rate, now)
+		decision.Remaining = int(b.tokens)
+		decision.Reset = untilFull(b, rate)
+		if units > rate.Limit {
+			return decision, ErrTooManyUnits
+		}
+		if b.tokens < float64(units) {
+			decision.RetryAfter = ceilSecond(time.Duration((float64(units) - b.tokens) / float64(rate.Limit) * float64(rate.Period)))
+			return decision, ErrRateLimited
+		}
+	}
+
+	s := l.spend(client, now)
+	if l.config.DailyBudget > 0 && cost > 0 && s.amount+cost > l.config.DailyBudget+1e-9 {
+		decision.RetryAfter = ceilSecond(s.day.AddDate(0, 0, 1).Sub(now))
+		return decision, ErrQuotaExceeded
+	}
+
+	if b != nil {
+		b.tokens -= float64(units)
+		decision.Remaining = int(b.tokens)
+		decision.Reset = untilFull(b, rate)
+	}
+	s.amount += cost
+	decision.Allowed = true
+	return decision, nil
+}
+
+// Status returns client's standing without charging anything
+func (l *Limiter) Status(client string) Quota {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	quota := Quota{DailyBudget: l.config.DailyBudget, ResetsAt: startOfDay(now).AddDate(0, 0, 1)}
+	if s, ok := l.spent[client]; ok && s.day.Equal(startOfDay(now)) {
+		quota.Spent = s.amount
+	}
+	for _, op := range Operations {
+		rate := l.config.Rates[op]
+		usage := Usage{Operation: op, Limit: rate.Limit, Period: rate.Period}
+		if rate.Limit > 0 {
+			usage.Remaining = rate.Limit
+			if b, ok := l.buckets[bucketKey(client, op)]; ok {
+				refill(b, rate, now)
+				usage.Remaining = int(b.tokens)
+				usage.Reset = untilFull(b, rate)
+			}
+		}
+		quota.Limits = append(quota.Limits, usage)
+	}
+	return quota
+}
+
+// bucket returns the client's refilled bucket for op, creating a full one if needed
+func (l *Limiter) bucket(client string, op Operation, rate Rate, now time.Time) *bucket {
+	key := bucketKey(client, op)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Limit), updated: now}
+		l.buckets[key] = b
+		return b
+	}
+	refill(b, rate, now)
+	return b
+}
+
+// spend returns the client's spend for the current day, starting a new day if needed
+func (l *Limiter) spend(client string, now time.Time) *spend {
+	day := startOfDay(now)
+	s, ok := l.spent[client]
+	if !ok || !s.day.Equal(day) {
+		s = &spend{day: day}
+		l.spent[client] = s
+	}
+	return s
+}
+
+// prune forgets buckets that have refilled and spending from previous days once many clients
+// are tracked, since both are indistinguishable from a client that was never seen
+func (l *Limiter) prune(now time.Time) {
+	if len(l.buckets)+len(l.spent) < maxClients {
+		return
+	}
+	for key, b := range l.buckets {
+		op := Operation(key[strings.LastIndexByte(key, '|')+1:])
+		rate := l.config.Rates[op]
+		refill(b, rate, now)
+		if b.tokens >= float64(rate.Limit) {
+			delete(l.buckets, key)
+		}
+	}
+	today := startOfDay(now)
+	for client, s := range l.spent {
+		if !s.day.Equal(today) {
+			delete(l.spent, client)
+		}
+	}
+}
+
+// refill adds the tokens accrued since the bucket was last updated
+func refill(b *bucket, rate Rate, now time.Time) {
+	if elapsed := now.Sub(b.updated); elapsed > 0 {
+		b.tokens = math.Min(float64(rate.Limit), b.tokens+elapsed.Seconds()/rate.Period.Seconds()*float64(rate.Limit))
+		b.updated = now
+	}
+}
+
+// untilFull returns how long the bucket takes to refill completely
+func untilFull(b *bucket, rate Rate) time.Duration {
+	missing := float64(rate.Limit) - b.tokens
+	return ceilSecond(time.Duration(missing / float64(rate.Limit) * float64(rate.Period)))
+}
+
+// ceilSecond rounds d up to whole seconds, as reported in headers
+func ceilSecond(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	return (d + time.Second - 1).Truncate(time.Second)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+func bucketKey(client string, op Operation) string {
+	return client + "|" + string(op)
+}
@@ -0,0 +1,215 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/auth"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Rate
+		wantErr bool
+	}{
+		{value: "10/1m", want: Rate{Limit: 10, Period: time.Minute}},
+		{value: " 3/24h ", want: Rate{Limit: 3, Period: 24 * time.Hour}},
+		{value: "off", want: Rate{}},
+		{value: "0", want: Rate{}},
+		{value: "10", wantErr: true},
+		{value: "-1/1m", wantErr: true},
+		{value: "10/soon", wantErr: true},
+		{value: "10/0s", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseRate(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(Config{
+		Rates: map[Operation]Rate{OpImage: {Limit: 2, Period: time.Minute}},
+	}, WithClock(func() time.Time { return now }))
+
+	for i := range 2 {
+		if _, err := l.Allow("alice", OpImage, 1); err != nil {
+			t.Fatalf("Allow() #%d error = %v", i+1, err)
+		}
+	}
+	decision, err := l.Allow("alice", OpImage, 1)
+	if !errors.Is(err, ErrRateLimited) || decision.Allowed {
+		t.Fatalf("Allow() over the limit = %+v, %v, want ErrRateLimited", decision, err)
+	}
+	if decision.RetryAfter != 30*time.Second || decision.Reset != time.Minute {
+		t.Errorf("RetryAfter = %v, Reset = %v, want 30s and 1m", decision.RetryAfter, decision.Reset)
+	}
+
+	// Clients and operations have separate buckets; operations without a rate are unlimited
+	if _, err := l.Allow("bob", OpImage, 1); err != nil {
+		t.Errorf("Allow() for another client error = %v", err)
+	}
+	for range 10 {
+		if _, err := l.Allow("alice", OpText, 1); err != nil {
+			t.Fatalf("Allow() for an unlimited operation error = %v", err)
+		}
+	}
+
+	// Tokens refill continuously
+	now = now.Add(30 * time.Second)
+	decision, err = l.Allow("alice", OpImage, 1)
+	if err != nil {
+		t.Fatalf("Allow() after refill error = %v", err)
+	}
+	if decision.Remaining != 0 {
+		t.Errorf("Remaining = %d, want 0", decision.Remaining)
+	}
+}
+
+func TestLimiter_AllowUnits(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(Config{
+		Rates: map[Operation]Rate{OpImage: {Limit: 5, Period: time.Minute}},
+	}, WithClock(func() time.Time { return now }))
+
+	// Four images take four of the five tokens, so the next four must wait for three more
+	decision, err := l.Allow("alice", OpImage, 4)
+	if err != nil || decision.Remaining != 1 {
+		t.Fatalf("Allow(4) = %+v, %v, want 1 token left", decision, err)
+	}
+	decision, err = l.Allow("alice", OpImage, 4)
+	if !errors.Is(err, ErrRateLimited) || decision.Allowed {
+		t.Fatalf("Allow(4) with 1 token left = %+v, %v, want ErrRateLimited", decision, err)
+	}
+	if decision.RetryAfter != 36*time.Second {
+		t.Errorf("RetryAfter = %v, want 36s for the 3 missing tokens", decision.RetryAfter)
+	}
+	// The denied request took nothing
+	if decision, err := l.Allow("alice", OpImage, 1); err != nil || decision.Remaining != 0 {
+		t.Errorf("Allow(1) = %+v, %v, want the last token", decision, err)
+	}
+
+	// More units than the limit can never be allowed
+	if _, err := l.Allow("bob", OpImage, 6); !errors.Is(err, ErrTooManyUnits) {
+		t.Errorf("Allow(6) error = %v, want ErrTooManyUnits", err)
+	}
+	if decision, err := l.Allow("bob", OpImage, 5); err != nil || decision.Remaining != 0 {
+		t.Errorf("Allow(5) after the rejected request = %+v, %v, want a full bucket", decision, err)
+	}
+}
+
+func TestLimiter_DailyQuota(t *testing.T) {
+	now := time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC)
+	l := New(Config{
+		DailyBudget: 0.06,
+		Costs:       map[Operation]float64{OpImage: 0.02, OpPost: 0},
+	}, WithClock(func() time.Time { return now }))
+
+	if _, err := l.Allow("alice", OpImage, 2); err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	decision, err := l.Allow("alice", OpImage, 2)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Allow() over budget error = %v, want ErrQuotaExceeded", err)
+	}
+	if decision.RetryAfter != time.Hour {
+		t.Errorf("RetryAfter = %v, want the time until midnight UTC", decision.RetryAfter)
+	}
+	// The denied request is not charged, so a smaller one still fits
+	if _, err := l.Allow("alice", OpImage, 1); err != nil {
+		t.Errorf("Allow() within the remaining budget error = %v", err)
+	}
+	if _, err := l.Allow("alice", OpPost, 1); err != nil {
+		t.Errorf("Allow() for a free operation error = %v", err)
+	}
+
+	quota := l.Status("alice")
+	if quota.Spent < 0.059 || quota.Remaining() > 1e-9 {
+		t.Errorf("Status() spent %v with %v remaining, want 0.06 and 0", quota.Spent, quota.Remaining())
+	}
+	if want := time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC); !quota.ResetsAt.Equal(want) {
+		t.Errorf("ResetsAt = %v, want %v", quota.ResetsAt, want)
+	}
+
+	now = now.Add(time.Hour)
+	if quota := l.Status("alice"); quota.Spent != 0 {
+		t.Errorf("Status() on the next day spent = %v, want 0", quota.Spent)
+	}
+	if _, err := l.Allow("alice", OpImage, 2); err != nil {
+		t.Errorf("Allow() on the next day error = %v", err)
+	}
+}
+
+func TestLimiter_Status(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := New(DefaultConfig(), WithClock(func() time.Time { return now }))
+	if _, err := l.Allow("alice", OpText, 1); err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+
+	quota := l.Status("alice")
+	if len(quota.Limits) != len(Operations) {
+		t.Fatalf("Status() has %d limits, want %d", len(quota.Limits), len(Operations))
+	}
+	for _, usage := range quota.Limits {
+		want := usage.Limit
+		if usage.Operation == OpText {
+			want--
+		}
+		if usage.Remaining != want {
+			t.Errorf("%s remaining = %d, want %d", usage.Operation, usage.Remaining, want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	l := New(Config{Rates: map[Operation]Rate{OpPost: {Limit: 1, Period: time.Minute}}})
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, _ := l.Allow(ClientFrom(r.Context()), OpPost, 1)
+		Record(r.Context(), decision)
+		w.Write([]byte(`{"data":{}}`))
+	}))
+
+	serve := func(ctx context.Context) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", http.NoBody).WithContext(ctx)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(context.Background())
+	if got := w.Header().Get(HeaderRemaining); got != "0" {
+		t.Errorf("%s = %q, want 0", HeaderRemaining, got)
+	}
+	if got := w.Header().Get(HeaderPolicy); got != "1;w=60" {
+		t.Errorf("%s = %q, want 1;w=60", HeaderPolicy, got)
+	}
+	if got := w.Header().Get(HeaderRetryAfter); got != "" {
+		t.Errorf("%s = %q on an allowed request", HeaderRetryAfter, got)
+	}
+
+	w = serve(context.Background())
+	if got := w.Header().Get(HeaderRetryAfter); got != "60" {
+		t.Errorf("%s = %q, want 60", HeaderRetryAfter, got)
+	}
+
+	// Authenticated users are limited separately from their address
+	w = serve(auth.WithUser(context.Background(), &auth.User{ID: "alice"}))
+	if got := w.Header().Get(HeaderRetryAfter); got != "" {
+		t.Errorf("%s = %q for an authenticated user", HeaderRetryAfter, got)
+	}
+}
@@ -0,0 +1,440 @@
+// Package screenshot renders mock-ups of a simulated social post and its predicted
+// replies as a PNG, for use in slides and training material.
+package screenshot
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" // Register the JPEG decoder for attached images
+	"image/png"
+	"strings"
+	"time"
+
+	xdraw "golang.org/x/image/draw"
+
+	"github.com/Tattsum/enjo/backend/typeset"
+)
+
+// Disclaimer is printed in the banner at the bottom of every screenshot
+const Disclaimer = "炎上シミュレーターによる架空の投稿です"
+
+// Theme selects the color scheme of the mock-up
+type Theme string
+
+const (
+	// ThemeLight mimics the light SNS theme
+	ThemeLight Theme = "LIGHT"
+	// ThemeDark mimics the dark SNS theme
+	ThemeDark Theme = "DARK"
+)
+
+var (
+	// ErrEmptyPost is returned when the post has no text
+	ErrEmptyPost = errors.New("post text is required")
+	// ErrUnknownTheme is returned for themes not defined by this package
+	ErrUnknownTheme = errors.New("unknown screenshot theme")
+)
+
+// Account is the fake author of a post or reply
+type Account struct {
+	DisplayName string
+	Handle      string // Without the leading @
+}
+
+// Reply is a simulated reply shown under the post
+type Reply struct {
+	Author Account
+	Text   string
+}
+
+// Post is the content of a mock-up
+type Post struct {
+	Author   Account
+	Text     string
+	Image    []byte // Optional PNG or JPEG attached to the post
+	PostedAt time.Time
+	Replies  []Reply
+}
+
+// palette holds the colors of a theme
+type palette struct {
+	background color.Color
+	text       color.Color
+	secondary  color.Color
+	divider    color.Color
+}
+
+var palettes = map[Theme]palette{
+	ThemeLight: {
+		background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+		text:       color.RGBA{R: 0x0f, G: 0x14, B: 0x19, A: 0xff},
+		secondary:  color.RGBA{R: 0x53, G: 0x64, B: 0x71, A: 0xff},
+		divider:    color.RGBA{R: 0xef, G: 0xf3, B: 0xf4, A: 0xff},
+	},
+	ThemeDark: {
+		background: color.RGBA{R: 0x00, G: 0x00, B: 0x00, A: 0xff},
+		text:       color.RGBA{R: 0xe7, G: 0xe9, B: 0xea, A: 0xff},
+		secondary:  color.RGBA{R: 0x71, G: 0x76, B: 0x7b, A: 0xff},
+		divider:    color.RGBA{R: 0x2f, G: 0x33, B: 0x36, A: 0xff},
+	},
+}
+
+var (
+	bannerColor     = color.RGBA{R: 0xf4, G: 0x21, B: 0x2e, A: 0xff}
+	bannerTextColor = color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff}
+	// avatarColors are picked from by handle so every account keeps its color
+	avatarColors = []color.RGBA{
+		{R: 0x1d, G: 0x9b, B: 0xf0, A: 0xff},
+		{R: 0xf9, G: 0x18, B: 0x80, A: 0xff},
+		{R: 0x7a, G: 0x5a, B: 0xf8, A: 0xff},
+		{R: 0xff, G: 0x7a, B: 0x00, A: 0xff},
+		{R: 0x00, G: 0xba, B: 0x7c, A: 0xff},
+		{R: 0xff, G: 0xd4, B: 0x00, A: 0xff},
+	}
+)
+
+const (
+	// width is the width of every screenshot in pixels
+	width = 600
+	// padding is the margin around and between elements
+	padding = 16
+	// avatarSize is the diameter of the post author's avatar
+	avatarSize = 48
+	// replyAvatarSize is the diameter of reply authors' avatars
+	replyAvatarSize = 40
+	// maxImageHeight bounds the height of the attached image
+	maxImageHeight = 400
+	// bannerHeight is the height of the disclaimer banner
+	bannerHeight = 40
+)
+
+// Renderer draws mock-ups with a single font
+type Renderer struct {
+	font     *typeset.Font
+	location *time.Location
+}
+
+// Option is a functional option for the renderer
+type Option func(*rendererOptions)
+
+type rendererOptions struct {
+	font     []byte
+	location *time.Location
+}
+
+// WithFont renders text with the given TrueType/OpenType font instead of the embedded one
+func WithFont(data []byte) Option {
+	return func(opts *rendererOptions) {
+		opts.font = data
+	}
+}
+
+// WithLocation sets the time zone timestamps are shown in (JST by default)
+func WithLocation(location *time.Location) Option {
+	return func(opts *rendererOptions) {
+		opts.location = location
+	}
+}
+
+// New creates a renderer. Without WithFont the embedded font is used.
+func New(options ...Option) (*Renderer, error) {
+	opts := &rendererOptions{location: time.FixedZone("JST", 9*60*60)}
+	for _, opt := range options {
+		opt(opts)
+	}
+
+	f, err := typeset.Parse(opts.font)
+	if err != nil {
+		return nil, err
+	}
+	return &Renderer{font: f, location: opts.location}, nil
+}
+
+// section is a horizontal strip of the screenshot, measured before it is drawn
+type section struct {
+	height int
+	draw   func(dst draw.Image, y int)
+	blocks []*typeset.Block // Released once the screenshot is drawn
+}
+
+// Render draws post in the given theme and returns it as PNG
+func (r *Renderer) Render(post Post, theme Theme) ([]byte, error) {
+	colors, ok := palettes[theme]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownTheme, theme)
+	}
+	if strings.TrimSpace(post.Text) == "" {
+		return nil, ErrEmptyPost
+	}
+
+	sections, err := r.layout(post, colors)
+	defer func() {
+		for _, s := range sections {
+			for _, b := range s.blocks {
+				b.Close()
+			}
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	height := 0
+	for _, s := range sections {
+		height += s.height
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(colors.background), image.Point{}, draw.Src)
+
+	y := 0
+	for _, s := range sections {
+		s.draw(canvas, y)
+		y += s.height
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, canvas); err != nil {
+		return nil, fmt.Errorf("failed to encode screenshot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// layout measures every section of the screenshot from top to bottom.
+// Sections built before an error are returned so their blocks can be released.
+func (r *Renderer) layout(post Post, colors palette) ([]*section, error) {
+	var sections []*section
+	add := func(s *section, err error) error {
+		if s != nil {
+			sections = append(sections, s)
+		}
+		return err
+	}
+
+	if err := add(r.header(post.Author, colors)); err != nil {
+		return sections, err
+	}
+	if err := add(r.body(post.Text, 22, colors.text)); err != nil {
+		return sections, err
+	}
+	if len(post.Image) > 0 {
+		if err := add(attachedImage(post.Image)); err != nil {
+			return sections, err
+		}
+	}
+	if err := add(r.timestamp(post.PostedAt, colors)); err != nil {
+		return sections, err
+	}
+	for _, reply := range post.Replies {
+		if err := add(r.reply(reply, post.Author, colors)); err != nil {
+			return sections, err
+		}
+	}
+	err := add(r.banner())
+	return sections, err
+}
+
+// header draws the author's avatar, display name and handle
+func (r *Renderer) header(author Account, colors palette) (*section, error) {
+	name, err := r.font.Fit(author.DisplayName, width-avatarSize-3*padding, 1, 17)
+	if err != nil {
+		return nil, err
+	}
+	handle, err := r.font.Fit("@"+author.Handle, width-avatarSize-3*padding, 1, 15)
+	if err != nil {
+		return &section{blocks: []*typeset.Block{name}}, err
+	}
+
+	return &section{
+		height: avatarSize + 2*padding,
+		blocks: []*typeset.Block{name, handle},
+		draw: func(dst draw.Image, y int) {
+			r.drawAvatar(dst, image.Pt(padding, y+padding), avatarSize, author)
+			textX := padding + avatarSize + padding*3/4
+			textY := y + padding + (avatarSize-name.Height()-handle.Height())/2
+			name.Draw(dst, textX, textY, typeset.AlignLeft, colors.text, nil)
+			handle.Draw(dst, textX, textY+name.Height(), typeset.AlignLeft, colors.secondary, nil)
+		},
+	}, nil
+}
+
+// body draws wrapped post text across the full content width
+func (r *Renderer) body(text string, size float64, fill color.Color) (*section, error) {
+	block, err := r.font.Fit(text, width-2*padding, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	return &section{
+		height: block.Height() + padding/2,
+		blocks: []*typeset.Block{block},
+		draw: func(dst draw.Image, y int) {
+			block.Draw(dst, padding, y, typeset.AlignLeft, fill, nil)
+		},
+	}, nil
+}
+
+// attachedImage scales the attached image to the content width
+func attachedImage(data []byte) (*section, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	maxWidth := width - 2*padding
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w == 0 || h == 0 {
+		return nil, errors.New("image has no pixels")
+	}
+	scaledW, scaledH := maxWidth, h*maxWidth/w
+	if scaledH > maxImageHeight {
+		scaledW, scaledH = w*maxImageHeight/h, maxImageHeight
+	}
+
+	return &section{
+		height: scaledH + padding,
+		draw: func(dst draw.Image, y int) {
+			x := padding + (maxWidth-scaledW)/2
+			rect := image.Rect(x, y+padding/2, x+scaledW, y+padding/2+scaledH)
+			xdraw.CatmullRom.Scale(dst, rect, img, img.Bounds(), xdraw.Over, nil)
+		},
+	}, nil
+}
+
+// timestamp draws the posting time followed by a divider
+func (r *Renderer) timestamp(postedAt time.Time, colors palette) (*section, error) {
+	if postedAt.IsZero() {
+		postedAt = time.Now()
+	}
+	block, err := r.font.Fit(FormatTimestamp(postedAt.In(r.location)), width-2*padding, 1, 15)
+	if err != nil {
+		return nil, err
+	}
+	return &section{
+		height: block.Height() + 2*padding,
+		blocks: []*typeset.Block{block},
+		draw: func(dst draw.Image, y int) {
+			block.Draw(dst, padding, y+padding/2, typeset.AlignLeft, colors.secondary, nil)
+			divider(dst, y+block.Height()+2*padding-1, colors.divider)
+		},
+	}, nil
+}
+
+// reply draws a simulated reply with its own avatar, followed by a divider
+func (r *Renderer) reply(reply Reply, to Account, colors palette) (*section, error) {
+	contentX := padding + replyAvatarSize + padding*3/4
+	contentWidth := width - contentX - padding
+
+	var blocks []*typeset.Block
+	for _, line := range []struct {
+		text string
+		size float64
+		max  int
+	}{
+		{reply.Author.DisplayName + "  @" + reply.Author.Handle, 15, 1},
+		{"返信先: @" + to.Handle, 13, 1},
+		{reply.Text, 17, 0},
+	} {
+		block, err := r.font.Fit(line.text, contentWidth, line.max, line.size)
+		if err != nil {
+			return &section{blocks: blocks}, err
+		}
+		blocks = append(blocks, block)
+	}
+	header, replyingTo, text := blocks[0], blocks[1], blocks[2]
+
+	contentHeight := header.Height() + replyingTo.Height() + text.Height()
+	height := max(contentHeight, replyAvatarSize) + 2*padding
+	return &section{
+		height: height,
+		blocks: blocks,
+		draw: func(dst draw.Image, y int) {
+			r.drawAvatar(dst, image.Pt(padding, y+padding), replyAvatarSize, reply.Author)
+			textY := y + padding
+			header.Draw(dst, contentX, textY, typeset.AlignLeft, colors.text, nil)
+			textY += header.Height()
+			replyingTo.Draw(dst, contentX, textY, typeset.AlignLeft, colors.secondary, nil)
+			textY += replyingTo.Height()
+			text.Draw(dst, contentX, textY, typeset.AlignLeft, colors.text, nil)
+			divider(dst, y+height-1, colors.divider)
+		},
+	}, nil
+}
+
+// banner draws the disclaimer marking the screenshot as simulated content
+func (r *Renderer) banner() (*section, error) {
+	block, err := r.font.Fit(Disclaimer, width-2*padding, 1, 15)
+	if err != nil {
+		return nil, err
+	}
+	block.BoxWidth = width - 2*padding
+	return &section{
+		height: bannerHeight,
+		blocks: []*typeset.Block{block},
+		draw: func(dst draw.Image, y int) {
+			draw.Draw(dst, image.Rect(0, y, width, y+bannerHeight), image.NewUniform(bannerColor), image.Point{}, draw.Src)
+			block.Draw(dst, padding, y+(bannerHeight-block.Height())/2, typeset.AlignCenter, bannerTextColor, nil)
+		},
+	}, nil
+}
+
+// drawAvatar draws a colored circle with the first letter of the account's name
+func (r *Renderer) drawAvatar(dst draw.Image, at image.Point, size int, account Account) {
+	rect := image.Rect(at.X, at.Y, at.X+size, at.Y+size)
+	draw.DrawMask(dst, rect, image.NewUniform(avatarColor(account.Handle)), image.Point{}, &circle{size: size}, image.Point{}, draw.Over)
+
+	initial := []rune(strings.TrimSpace(account.DisplayName))
+	if len(initial) == 0 {
+		return
+	}
+	face, err := r.font.Face(float64(size) / 2)
+	if err != nil {
+		return
+	}
+	defer face.Close()
+	block := &typeset.Block{Face: face, Lines: []string{string(initial[0])}, BoxWidth: size}
+	block.Draw(dst, at.X, at.Y+(size-block.Height())/2, typeset.AlignCenter, color.White, nil)
+}
+
+// avatarColor picks a stable avatar color for a handle
+func avatarColor(handle string) color.RGBA {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(handle))
+	return avatarColors[h.Sum32()%uint32(len(avatarColors))]
+}
+
+// divider draws a one pixel horizontal rule at y
+func divider(dst draw.Image, y int, c color.Color) {
+	draw.Draw(dst, image.Rect(0, y, width, y+1), image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// circle is an alpha mask of a filled circle with the given diameter
+type circle struct {
+	size int
+}
+
+func (c *circle) ColorModel() color.Model { return color.AlphaModel }
+
+func (c *circle) Bounds() image.Rectangle { return image.Rect(0, 0, c.size, c.size) }
+
+func (c *circle) At(x, y int) color.Color {
+	r := float64(c.size) / 2
+	dx, dy := float64(x)+0.5-r, float64(y)+0.5-r
+	if dx*dx+dy*dy <= r*r {
+		return color.Alpha{A: 0xff}
+	}
+	return color.Alpha{}
+}
+
+// FormatTimestamp formats t the way Japanese SNS clients show a post's time, e.g. "午後3:04 · 2026年10月18日"
+func FormatTimestamp(t time.Time) string {
+	period, hour := "午前", t.Hour()
+	if hour >= 12 {
+		period = "午後"
+		hour -= 12
+	}
+	return fmt.Sprintf("%s%d:%02d · %d年%d月%d日", period, hour, t.Minute(), t.Year(), int(t.Month()), t.Day())
+}
@@ -0,0 +1,147 @@
+package screenshot
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"time"
+)
+
+func testPost(t *testing.T, withImage bool) Post {
+	t.Helper()
+
+	post := Post{
+		Author:   Account{DisplayName: "Enjo User", Handle: "enjo_user"},
+		Text:     "Hot take: meetings should be banned on Mondays and anyone who disagrees is wrong",
+		PostedAt: time.Date(2026, 10, 18, 15, 4, 0, 0, time.UTC),
+		Replies: []Reply{
+			{Author: Account{DisplayName: "Logic", Handle: "logic"}, Text: "That is not how companies work."},
+			{Author: Account{DisplayName: "Nitpick", Handle: "nitpick"}, Text: "You mean Mondays in which time zone?"},
+		},
+	}
+	if withImage {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1200, 600))); err != nil {
+			t.Fatalf("failed to encode image: %v", err)
+		}
+		post.Image = buf.Bytes()
+	}
+	return post
+}
+
+func TestRenderer_Render(t *testing.T) {
+	renderer, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	decode := func(t *testing.T, data []byte) image.Image {
+		t.Helper()
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Render() returned an invalid PNG: %v", err)
+		}
+		return img
+	}
+
+	light, err := renderer.Render(testPost(t, false), ThemeLight)
+	if err != nil {
+		t.Fatalf("Render(light) error = %v", err)
+	}
+	dark, err := renderer.Render(testPost(t, false), ThemeDark)
+	if err != nil {
+		t.Fatalf("Render(dark) error = %v", err)
+	}
+	withImage, err := renderer.Render(testPost(t, true), ThemeLight)
+	if err != nil {
+		t.Fatalf("Render(with image) error = %v", err)
+	}
+
+	lightImg, darkImg, imageImg := decode(t, light), decode(t, dark), decode(t, withImage)
+	if lightImg.Bounds().Dx() != width {
+		t.Errorf("width = %d, want %d", lightImg.Bounds().Dx(), width)
+	}
+	if lightImg.Bounds().Dy() != darkImg.Bounds().Dy() {
+		t.Errorf("themes have different heights: %d and %d", lightImg.Bounds().Dy(), darkImg.Bounds().Dy())
+	}
+	// The 1200x600 image is scaled to the content width, keeping its aspect ratio
+	if got, want := imageImg.Bounds().Dy()-lightImg.Bounds().Dy(), (width-2*padding)/2+padding; got != want {
+		t.Errorf("image added %dpx, want %dpx", got, want)
+	}
+
+	if got := color.RGBAModel.Convert(lightImg.At(width-1, 1)).(color.RGBA); got != palettes[ThemeLight].background {
+		t.Errorf("light background = %v, want %v", got, palettes[ThemeLight].background)
+	}
+	if got := color.RGBAModel.Convert(darkImg.At(width-1, 1)).(color.RGBA); got != palettes[ThemeDark].background {
+		t.Errorf("dark background = %v, want %v", got, palettes[ThemeDark].background)
+	}
+	// Every screenshot ends with the disclaimer banner
+	bottom := lightImg.Bounds().Dy() - 1
+	if got := color.RGBAModel.Convert(lightImg.At(0, bottom)).(color.RGBA); got != bannerColor {
+		t.Errorf("bottom row = %v, want the banner color %v", got, bannerColor)
+	}
+}
+
+func TestRenderer_RepliesGrowTheScreenshot(t *testing.T) {
+	renderer, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	post := testPost(t, false)
+	withReplies, err := renderer.Render(post, ThemeLight)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+	post.Replies = nil
+	withoutReplies, err := renderer.Render(post, ThemeLight)
+	if err != nil {
+		t.Fatalf("Render() error = %v", err)
+	}
+
+	a, _ := png.DecodeConfig(bytes.NewReader(withReplies))
+	b, _ := png.DecodeConfig(bytes.NewReader(withoutReplies))
+	if a.Height-b.Height < 2*(replyAvatarSize+2*padding) {
+		t.Errorf("two replies added %dpx, want at least %dpx", a.Height-b.Height, 2*(replyAvatarSize+2*padding))
+	}
+}
+
+func TestRenderer_Errors(t *testing.T) {
+	renderer, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := renderer.Render(Post{}, ThemeLight); !errors.Is(err, ErrEmptyPost) {
+		t.Errorf("Render(empty) error = %v, want ErrEmptyPost", err)
+	}
+	if _, err := renderer.Render(testPost(t, false), "SEPIA"); !errors.Is(err, ErrUnknownTheme) {
+		t.Errorf("Render(unknown theme) error = %v, want ErrUnknownTheme", err)
+	}
+	post := testPost(t, false)
+	post.Image = []byte("not an image")
+	if _, err := renderer.Render(post, ThemeLight); err == nil {
+		t.Error("Render(invalid image) succeeded, want error")
+	}
+}
+
+func TestFormatTimestamp(t *testing.T) {
+	tests := []struct {
+		time time.Time
+		want string
+	}{
+		{time.Date(2026, 10, 18, 15, 4, 0, 0, time.UTC), "午後3:04 · 2026年10月18日"},
+		{time.Date(2026, 1, 2, 9, 30, 0, 0, time.UTC), "午前9:30 · 2026年1月2日"},
+		{time.Date(2026, 1, 2, 0, 5, 0, 0, time.UTC), "午前0:05 · 2026年1月2日"},
+		{time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC), "午後0:00 · 2026年1月2日"},
+	}
+
+	for _, tt := range tests {
+		if got := FormatTimestamp(tt.time); got != tt.want {
+			t.Errorf("FormatTimestamp(%v) = %q, want %q", tt.time, got, tt.want)
+		}
+	}
+}
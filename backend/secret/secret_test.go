@@ -0,0 +1,72 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func newTestBox(t *testing.T, fill byte) *Box {
+	t.Helper()
+	box, err := New(bytes.Repeat([]byte{fill}, KeySize))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return box
+}
+
+func TestBox(t *testing.T) {
+	box := newTestBox(t, 1)
+	plaintext := []byte("access-token")
+
+	sealed, err := box.Seal(plaintext, []byte("alice"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Error("Seal() output contains the plaintext")
+	}
+	again, _ := box.Seal(plaintext, []byte("alice"))
+	if bytes.Equal(sealed, again) {
+		t.Error("Seal() is deterministic, want a fresh nonce per call")
+	}
+
+	opened, err := box.Open(sealed, []byte("alice"))
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Fatalf("Open() = %q, %v, want %q", opened, err, plaintext)
+	}
+
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1] ^= 1
+	tests := []struct {
+		name   string
+		box    *Box
+		sealed []byte
+		ad     string
+	}{
+		{name: "different associated data", box: box, sealed: sealed, ad: "bob"},
+		{name: "different key", box: newTestBox(t, 2), sealed: sealed, ad: "alice"},
+		{name: "tampered", box: box, sealed: tampered, ad: "alice"},
+		{name: "truncated", box: box, sealed: sealed[:8], ad: "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.box.Open(tt.sealed, []byte(tt.ad)); !errors.Is(err, ErrDecrypt) {
+				t.Errorf("Open() error = %v, want ErrDecrypt", err)
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	valid := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, KeySize))
+	if key, err := ParseKey(valid); err != nil || len(key) != KeySize {
+		t.Errorf("ParseKey() = %d bytes, %v", len(key), err)
+	}
+	for _, invalid := range []string{"not base64!", base64.StdEncoding.EncodeToString([]byte("short"))} {
+		if _, err := ParseKey(invalid); err == nil {
+			t.Errorf("ParseKey(%q) error = nil, want error", invalid)
+		}
+	}
+}
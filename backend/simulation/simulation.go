@@ -0,0 +1,320 @@
+package simulation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Tattsum/enjo/backend/store"
+)
+
+const (
+	// bucketSimulations is the store bucket holding simulations
+	bucketSimulations = "simulations"
+	// bucketTweets is the store bucket holding tweets posted from the simulator
+	bucketTweets = "posted_tweets"
+	// bucketComparisons is the store bucket holding the latest reply comparison per tweet
+	bucketComparisons = "reply_comparisons"
+)
+
+var (
+	// ErrNotFound is returned when a simulation does not exist
+	ErrNotFound = errors.New("simulation not found")
+	// ErrTweetNotFound is returned when a posted tweet is not known to the simulator
+	ErrTweetNotFound = errors.New("posted tweet not found")
+	// ErrComparisonNotFound is returned when no reply comparison has been run for a tweet
+	ErrComparisonNotFound = errors.New("reply comparison not found")
+)
+
+// Reply is a simulated reply generated for a simulation
+type Reply struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Content string `json:"content"`
+}
+
+// Simulation is a single run of the simulator: the original text, its inflammatory rewrite and the predicted replies
+type Simulation struct {
+	ID               string    `json:"id"`
+	OwnerID          string    `json:"ownerId,omitempty"` // User that created the simulation; empty without authentication
+	OriginalText     string    `json:"originalText"`
+	InflammatoryText string    `json:"inflammatoryText"`
+	Explanation      string    `json:"explanation,omitempty"`
+	Level            int       `json:"level"`
+	Replies          []Reply   `json:"replies,omitempty"`
+	ImageID          string    `json:"imageId,omitempty"`          // Stored image attached by the user
+	ImageDescription string    `json:"imageDescription,omitempty"` // Description of the attached image factored into generation
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// TweetStatus is the last known state of a posted tweet
+type TweetStatus string
+
+const (
+	// TweetStatusLive means the tweet was visible the last time it was checked
+	TweetStatusLive TweetStatus = "LIVE"
+	// TweetStatusDeleted means the tweet was deleted through the simulator
+	TweetStatusDeleted TweetStatus = "DELETED"
+	// TweetStatusUnavailable means the tweet could no longer be found on Twitter
+	TweetStatusUnavailable TweetStatus = "UNAVAILABLE"
+)
+
+// Metrics is a snapshot of the public engagement of a tweet
+type Metrics struct {
+	LikeCount       int       `json:"likeCount"`
+	RetweetCount    int       `json:"retweetCount"`
+	ReplyCount      int       `json:"replyCount"`
+	QuoteCount      int       `json:"quoteCount"`
+	ImpressionCount int       `json:"impressionCount"`
+	FetchedAt       time.Time `json:"fetchedAt"`
+}
+
+// Tweet records a tweet published by the simulator and the simulation it came from
+type Tweet struct {
+	TweetID         string      `json:"tweetId"`
+	TweetURL        string      `json:"tweetUrl"`
+	OwnerID         string      `json:"ownerId,omitempty"`
+	SimulationID    string      `json:"simulationId,omitempty"`
+	ScheduledPostID string      `json:"scheduledPostId,omitempty"`
+	Text            string      `json:"text"`
+	Level           int         `json:"level,omitempty"`
+	Status          TweetStatus `json:"status"`
+	Metrics         *Metrics    `json:"metrics,omitempty"`
+	PostedAt        time.Time   `json:"postedAt"`
+	DeletedAt       *time.Time  `json:"deletedAt,omitempty"`
+}
+
+// ObservedReply is a real reply to a posted tweet, classified into a reply persona
+type ObservedReply struct {
+	ID       string    `json:"id"`
+	Text     string    `json:"text"`
+	AuthorID string    `json:"authorId,omitempty"`
+	PostedAt time.Time `json:"postedAt"`
+	// Persona is the reply type the reply was classified as, or empty if it fits none
+	Persona string `json:"persona,omitempty"`
+	// ClosestReply is the simulated reply most similar to this one
+	ClosestReply *Reply `json:"closestReply,omitempty"`
+	// Similarity is the text similarity to ClosestReply in [0, 1]
+	Similarity float64 `json:"similarity"`
+}
+
+// PersonaCount compares how often a reply persona was predicted and observed
+type PersonaCount struct {
+	Type      string `json:"type"`
+	Predicted int    `json:"predicted"`
+	Observed  int    `json:"observed"`
+}
+
+// Comparison is a report comparing the real replies to a tweet against the simulated ones
+type Comparison struct {
+	TweetID      string          `json:"tweetId"`
+	SimulationID string          `json:"simulationId"`
+	Distribution []PersonaCount  `json:"distribution"`
+	Unclassified int             `json:"unclassified"`
+	Replies      []ObservedReply `json:"replies"`
+	GeneratedAt  time.Time       `json:"generatedAt"`
+}
+
+// Store persists simulations and the tweets posted from them in the embedded store
+type Store struct {
+	db  *store.DB
+	now func() time.Time
+}
+
+// Option is a functional option for the store
+type Option func(*Store)
+
+// WithClock overrides the clock used for timestamps (useful for testing)
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) {
+		s.now = now
+	}
+}
+
+// New creates a simulation store backed by db
+func New(db *store.DB, options ...Option) *Store {
+	s := &Store{db: db, now: time.Now}
+	for _, opt := range options {
+		opt(s)
+	}
+	return s
+}
+
+// Create stores a new simulation. ID and timestamps are filled in by the store.
+func (s *Store) Create(_ context.Context, sim Simulation) (*Simulation, error) {
+	now := s.now()
+	sim.ID = uuid.NewString()
+	sim.CreatedAt = now
+	sim.UpdatedAt = now
+
+	if err := s.db.Put(bucketSimulations, sim.ID, &sim); err != nil {
+		return nil, fmt.Errorf("failed to store simulation: %w", err)
+	}
+	return &sim, nil
+}
+
+// Get returns the simulation with the given ID
+func (s *Store) Get(_ context.Context, id string) (*Simulation, error) {
+	var sim Simulation
+	if err := s.db.Get(bucketSimulations, id, &sim); err != nil {
+		return nil, translateNotFound(err, ErrNotFound)
+	}
+	return &sim, nil
+}
+
+// SetReplies replaces the simulated replies of a simulation
+func (s *Store) SetReplies(_ context.Context, id string, replies []Reply) (*Simulation, error) {
+	var sim Simulation
+	err := s.db.Update(bucketSimulations, id, &sim, func() error {
+		sim.Replies = replies
+		sim.UpdatedAt = s.now()
+		return nil
+	})
+	if err != nil {
+		return nil, translateNotFound(err, ErrNotFound)
+	}
+	return &sim, nil
+}
+
+// SetImage attaches a stored image and its description to a simulation
+func (s *Store) SetImage(_ context.Context, id, imageID, description string) (*Simulation, error) {
+	var sim Simulation
+	err := s.db.Update(bucketSimulations, id, &sim, func() error {
+		sim.ImageID = imageID
+		sim.ImageDescription = description
+		sim.UpdatedAt = s.now()
+		return nil
+	})
+	if err != nil {
+		return nil, translateNotFound(err, ErrNotFound)
+	}
+	return &sim, nil
+}
+
+// RecordTweet stores a tweet published by the simulator
+func (s *Store) RecordTweet(_ context.Context, tweet Tweet) (*Tweet, error) {
+	if tweet.TweetID == "" {
+		return nil, errors.New("tweet ID is required")
+	}
+	if tweet.PostedAt.IsZero() {
+		tweet.PostedAt = s.now()
+	}
+	tweet.Status = TweetStatusLive
+
+	if err := s.db.Put(bucketTweets, tweet.TweetID, &tweet); err != nil {
+		return nil, fmt.Errorf("failed to store posted tweet: %w", err)
+	}
+	return &tweet, nil
+}
+
+// GetTweet returns the posted tweet with the given tweet ID
+func (s *Store) GetTweet(_ context.Context, tweetID string) (*Tweet, error) {
+	var tweet Tweet
+	if err := s.db.Get(bucketTweets, tweetID, &tweet); err != nil {
+		return nil, translateNotFound(err, ErrTweetNotFound)
+	}
+	return &tweet, nil
+}
+
+// ListTweets returns posted tweets, newest first. If simulationID is non-empty only its tweets are returned.
+func (s *Store) ListTweets(_ context.Context, simulationID string) ([]*Tweet, error) {
+	var tweets []*Tweet
+	err := s.db.ForEach(bucketTweets, func(_ string, raw []byte) error {
+		var tweet Tweet
+		if err := json.Unmarshal(raw, &tweet); err != nil {
+			return fmt.Errorf("failed to decode posted tweet: %w", err)
+		}
+		if simulationID == "" || tweet.SimulationID == simulationID {
+			tweets = append(tweets, &tweet)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tweets, func(i, j int) bool {
+		return tweets[i].PostedAt.After(tweets[j].PostedAt)
+	})
+	return tweets, nil
+}
+
+// MarkDeleted records that a tweet was deleted through the simulator
+func (s *Store) MarkDeleted(_ context.Context, tweetID string) (*Tweet, error) {
+	return s.updateTweet(tweetID, func(tweet *Tweet) {
+		now := s.now()
+		tweet.Status = TweetStatusDeleted
+		tweet.DeletedAt = &now
+	})
+}
+
+// MarkUnavailable records that a tweet can no longer be found on Twitter
+func (s *Store) MarkUnavailable(_ context.Context, tweetID string) (*Tweet, error) {
+	return s.updateTweet(tweetID, func(tweet *Tweet) {
+		if tweet.Status == TweetStatusLive {
+			tweet.Status = TweetStatusUnavailable
+		}
+	})
+}
+
+// UpdateMetrics stores a fresh metrics snapshot for a tweet
+func (s *Store) UpdateMetrics(_ context.Context, tweetID string, metrics Metrics) (*Tweet, error) {
+	return s.updateTweet(tweetID, func(tweet *Tweet) {
+		if metrics.FetchedAt.IsZero() {
+			metrics.FetchedAt = s.now()
+		}
+		tweet.Metrics = &metrics
+		tweet.Status = TweetStatusLive
+	})
+}
+
+// SaveComparison stores the latest reply comparison for a tweet, replacing any previous one
+func (s *Store) SaveComparison(_ context.Context, comparison Comparison) (*Comparison, error) {
+	if comparison.TweetID == "" {
+		return nil, errors.New("tweet ID is required")
+	}
+	if comparison.GeneratedAt.IsZero() {
+		comparison.GeneratedAt = s.now()
+	}
+
+	if err := s.db.Put(bucketComparisons, comparison.TweetID, &comparison); err != nil {
+		return nil, fmt.Errorf("failed to store reply comparison: %w", err)
+	}
+	return &comparison, nil
+}
+
+// GetComparison returns the latest reply comparison for a tweet
+func (s *Store) GetComparison(_ context.Context, tweetID string) (*Comparison, error) {
+	var comparison Comparison
+	if err := s.db.Get(bucketComparisons, tweetID, &comparison); err != nil {
+		return nil, translateNotFound(err, ErrComparisonNotFound)
+	}
+	return &comparison, nil
+}
+
+// updateTweet applies mutate to a stored tweet atomically
+func (s *Store) updateTweet(tweetID string, mutate func(*Tweet)) (*Tweet, error) {
+	var tweet Tweet
+	err := s.db.Update(bucketTweets, tweetID, &tweet, func() error {
+		mutate(&tweet)
+		return nil
+	})
+	if err != nil {
+		return nil, translateNotFound(err, ErrTweetNotFound)
+	}
+	return &tweet, nil
+}
+
+// translateNotFound translates the store's not-found error into target
+func translateNotFound(err, target error) error {
+	if errors.Is(err, store.ErrNotFound) {
+		return target
+	}
+	return err
+}
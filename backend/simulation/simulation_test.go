@@ -0,0 +1,162 @@
+package simulation
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/store"
+)
+
+func newTestStore(t *testing.T, now time.Time) *Store {
+	t.Helper()
+
+	db, err := store.Open(filepath.Join(t.TempDir(), "simulation.db"))
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return New(db, WithClock(func() time.Time { return now }))
+}
+
+func TestStore_Simulation(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	created, err := s.Create(ctx, Simulation{OriginalText: "元の文章", InflammatoryText: "炎上文章", Level: 3})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if created.ID == "" {
+		t.Fatal("Create() returned empty ID")
+	}
+
+	replies := []Reply{{ID: "1", Type: "NITPICKING", Content: "揚げ足"}}
+	if _, err := s.SetReplies(ctx, created.ID, replies); err != nil {
+		t.Fatalf("SetReplies() error = %v", err)
+	}
+	if _, err := s.SetImage(ctx, created.ID, "img-1", "燃えるスマホ"); err != nil {
+		t.Fatalf("SetImage() error = %v", err)
+	}
+
+	got, err := s.Get(ctx, created.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.InflammatoryText != "炎上文章" || len(got.Replies) != 1 || got.Replies[0].Content != "揚げ足" {
+		t.Errorf("Get() = %+v, want stored simulation with replies", got)
+	}
+	if got.ImageID != "img-1" || got.ImageDescription != "燃えるスマホ" {
+		t.Errorf("Get() = %+v, want the attached image", got)
+	}
+
+	if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.SetReplies(ctx, "missing", replies); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetReplies(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := s.SetImage(ctx, "missing", "img-1", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetImage(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStore_Tweets(t *testing.T) {
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := newTestStore(t, base)
+
+	records := []Tweet{
+		{TweetID: "1", SimulationID: "sim-a", Text: "first", PostedAt: base},
+		{TweetID: "2", SimulationID: "sim-b", Text: "second", PostedAt: base.Add(time.Minute)},
+		{TweetID: "3", SimulationID: "sim-a", Text: "third", PostedAt: base.Add(2 * time.Minute)},
+	}
+	for _, r := range records {
+		if _, err := s.RecordTweet(ctx, r); err != nil {
+			t.Fatalf("RecordTweet() error = %v", err)
+		}
+	}
+
+	all, err := s.ListTweets(ctx, "")
+	if err != nil {
+		t.Fatalf("ListTweets() error = %v", err)
+	}
+	if len(all) != 3 || all[0].TweetID != "3" {
+		t.Errorf("ListTweets() = %d tweets starting with %q, want 3 newest first", len(all), all[0].TweetID)
+	}
+
+	forSim, err := s.ListTweets(ctx, "sim-a")
+	if err != nil {
+		t.Fatalf("ListTweets(sim-a) error = %v", err)
+	}
+	if len(forSim) != 2 {
+		t.Errorf("ListTweets(sim-a) returned %d tweets, want 2", len(forSim))
+	}
+
+	updated, err := s.UpdateMetrics(ctx, "1", Metrics{LikeCount: 7})
+	if err != nil {
+		t.Fatalf("UpdateMetrics() error = %v", err)
+	}
+	if updated.Metrics.LikeCount != 7 || updated.Metrics.FetchedAt.IsZero() {
+		t.Errorf("UpdateMetrics() = %+v, want like count and fetch time", updated.Metrics)
+	}
+
+	unavailable, err := s.MarkUnavailable(ctx, "2")
+	if err != nil {
+		t.Fatalf("MarkUnavailable() error = %v", err)
+	}
+	if unavailable.Status != TweetStatusUnavailable {
+		t.Errorf("Status = %v, want UNAVAILABLE", unavailable.Status)
+	}
+
+	deleted, err := s.MarkDeleted(ctx, "1")
+	if err != nil {
+		t.Fatalf("MarkDeleted() error = %v", err)
+	}
+	if deleted.Status != TweetStatusDeleted || deleted.DeletedAt == nil {
+		t.Errorf("MarkDeleted() = %+v, want deleted tweet", deleted)
+	}
+
+	// A deleted tweet stays deleted even if it is later reported missing
+	stillDeleted, _ := s.MarkUnavailable(ctx, "1")
+	if stillDeleted.Status != TweetStatusDeleted {
+		t.Errorf("Status = %v, want DELETED", stillDeleted.Status)
+	}
+
+	if _, err := s.GetTweet(ctx, "missing"); !errors.Is(err, ErrTweetNotFound) {
+		t.Errorf("GetTweet(missing) error = %v, want ErrTweetNotFound", err)
+	}
+}
+
+func TestStore_Comparison(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t, time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	if _, err := s.GetComparison(ctx, "1"); !errors.Is(err, ErrComparisonNotFound) {
+		t.Errorf("GetComparison(missing) error = %v, want ErrComparisonNotFound", err)
+	}
+
+	saved, err := s.SaveComparison(ctx, Comparison{
+		TweetID:      "1",
+		SimulationID: "sim",
+		Distribution: []PersonaCount{{Type: "NITPICKING", Predicted: 1, Observed: 2}},
+		Replies:      []ObservedReply{{ID: "r1", Text: "揚げ足", Persona: "NITPICKING"}},
+	})
+	if err != nil {
+		t.Fatalf("SaveComparison() error = %v", err)
+	}
+	if saved.GeneratedAt.IsZero() {
+		t.Error("SaveComparison() did not set GeneratedAt")
+	}
+
+	got, err := s.GetComparison(ctx, "1")
+	if err != nil {
+		t.Fatalf("GetComparison() error = %v", err)
+	}
+	if len(got.Distribution) != 1 || got.Distribution[0].Observed != 2 || len(got.Replies) != 1 {
+		t.Errorf("GetComparison() = %+v, want stored comparison", got)
+	}
+}
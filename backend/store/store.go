@@ -0,0 +1,202 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// openTimeout bounds how long Open waits for the file lock held by another process
+	openTimeout = 5 * time.Second
+	// dirPerm is the permission used when creating the parent directory of the database file
+	dirPerm = 0o750
+	// filePerm is the permission used when creating the database file
+	filePerm = 0o600
+)
+
+// ErrNotFound is returned when a key does not exist in a bucket
+var ErrNotFound = errors.New("not found")
+
+// DB is an embedded, durable key/value store backed by bbolt.
+// Values are stored as JSON documents grouped into named buckets.
+type DB struct {
+	bolt *bolt.DB
+}
+
+// Open opens (or creates) the database file at path
+func Open(path string) (*DB, error) {
+	if path == "" {
+		return nil, errors.New("database path is required")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	db, err := bolt.Open(path, filePerm, &bolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	return &DB{bolt: db}, nil
+}
+
+// Close closes the database file
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+// Ping checks that the database is open and readable
+func (db *DB) Ping() error {
+	return db.bolt.View(func(*bolt.Tx) error { return nil })
+}
+
+// Put stores v as JSON under key in bucket, creating the bucket if needed
+func (db *DB) Put(bucket, key string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return fmt.Errorf("failed to create bucket %s: %w", bucket, err)
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+// Get loads the JSON value stored under key in bucket into v.
+// It returns ErrNotFound if the bucket or key does not exist.
+func (db *DB) Get(bucket, key string, v any) error {
+	return db.bolt.View(func(tx *bolt.Tx) error {
+		data := lookup(tx, bucket, key)
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, v)
+	})
+}
+
+// Update atomically loads the value under key into v, calls mutate and stores v back.
+// If mutate returns an error nothing is written and the error is returned as-is.
+func (db *DB) Update(bucket, key string, v any, mutate func() error) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		data := lookup(tx, bucket, key)
+		if data == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to unmarshal value: %w", err)
+		}
+
+		if err := mutate(); err != nil {
+			return err
+		}
+
+		updated, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to marshal value: %w", err)
+		}
+		return tx.Bucket([]byte(bucket)).Put([]byte(key), updated)
+	})
+}
+
+// Delete removes key from bucket. Deleting a missing key is not an error.
+func (db *DB) Delete(bucket, key string) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// Take atomically loads the value under key into v and removes it, so that concurrent callers
+// cannot both take the same value. If check is non-nil and returns an error nothing is removed
+// and the error is returned as-is.
+func (db *DB) Take(bucket, key string, v any, check func() error) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		data := lookup(tx, bucket, key)
+		if data == nil {
+			return ErrNotFound
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return fmt.Errorf("failed to unmarshal value: %w", err)
+		}
+
+		if check != nil {
+			if err := check(); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket([]byte(bucket)).Delete([]byte(key))
+	})
+}
+
+// DeleteMatching removes every key in bucket for which match returns true, in a single transaction,
+// and returns how many were removed. An error from match aborts without removing anything.
+func (db *DB) DeleteMatching(bucket string, match func(key string, raw []byte) (bool, error)) (int, error) {
+	removed := 0
+	err := db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+
+		// Deleting while iterating makes the cursor skip keys, so collect them first
+		var keys [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			ok, err := match(string(k), v)
+			if ok {
+				keys = append(keys, k)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(keys)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// ForEach calls fn for every key in bucket in byte-sorted key order.
+// The raw JSON passed to fn is only valid for the duration of the call.
+func (db *DB) ForEach(bucket string, fn func(key string, raw []byte) error) error {
+	return db.bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}
+
+// lookup returns the raw value for key in bucket, or nil if it does not exist
+func lookup(tx *bolt.Tx, bucket, key string) []byte {
+	b := tx.Bucket([]byte(bucket))
+	if b == nil {
+		return nil
+	}
+	return b.Get([]byte(key))
+}
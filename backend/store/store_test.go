@@ -0,0 +1,225 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type testDoc struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+
+	db, err := Open(filepath.Join(t.TempDir(), "nested", "test.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("error when path is empty", func(t *testing.T) {
+		if _, err := Open(""); err == nil {
+			t.Fatal("expected error when path is empty")
+		}
+	})
+}
+
+func TestPing(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Ping(); err != nil {
+		t.Fatalf("Ping() error = %v", err)
+	}
+
+	db.Close()
+	if err := db.Ping(); err == nil {
+		t.Error("expected error after Close")
+	}
+}
+
+func TestPutGetDelete(t *testing.T) {
+	db := openTestDB(t)
+
+	var missing testDoc
+	if err := db.Get("docs", "a", &missing); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() on empty bucket error = %v, want ErrNotFound", err)
+	}
+
+	if err := db.Put("docs", "a", testDoc{Name: "first", Count: 1}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	var got testDoc
+	if err := db.Get("docs", "a", &got); err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Name != "first" || got.Count != 1 {
+		t.Errorf("Get() = %+v, want {first 1}", got)
+	}
+
+	if err := db.Delete("docs", "a"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := db.Get("docs", "a", &got); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.Put("docs", "a", testDoc{Name: "doc", Count: 1}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	t.Run("mutation is persisted", func(t *testing.T) {
+		var doc testDoc
+		err := db.Update("docs", "a", &doc, func() error {
+			doc.Count++
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		var got testDoc
+		if err := db.Get("docs", "a", &got); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got.Count != 2 {
+			t.Errorf("Count = %d, want 2", got.Count)
+		}
+	})
+
+	t.Run("mutation error aborts the write", func(t *testing.T) {
+		errAbort := errors.New("abort")
+		var doc testDoc
+		err := db.Update("docs", "a", &doc, func() error {
+			doc.Count = 100
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("Update() error = %v, want %v", err, errAbort)
+		}
+
+		var got testDoc
+		if err := db.Get("docs", "a", &got); err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if got.Count != 2 {
+			t.Errorf("Count = %d, want 2", got.Count)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		var doc testDoc
+		err := db.Update("docs", "missing", &doc, func() error { return nil })
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Update() error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestForEach(t *testing.T) {
+	db := openTestDB(t)
+
+	for _, key := range []string{"b", "a", "c"} {
+		if err := db.Put("docs", key, testDoc{Name: key}); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	var keys []string
+	err := db.ForEach("docs", func(key string, _ []byte) error {
+		keys = append(keys, key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ForEach() error = %v", err)
+	}
+
+	if len(keys) != 3 || keys[0] != "a" || keys[1] != "b" || keys[2] != "c" {
+		t.Errorf("ForEach() keys = %v, want [a b c]", keys)
+	}
+
+	if err := db.ForEach("missing", func(string, []byte) error { return nil }); err != nil {
+		t.Errorf("ForEach() on missing bucket error = %v", err)
+	}
+}
+
+func TestTake(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.Put("docs", "a", testDoc{Name: "doc", Count: 1}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	t.Run("check error keeps the value", func(t *testing.T) {
+		errAbort := errors.New("abort")
+		var doc testDoc
+		if err := db.Take("docs", "a", &doc, func() error { return errAbort }); !errors.Is(err, errAbort) {
+			t.Fatalf("Take() error = %v, want %v", err, errAbort)
+		}
+		if err := db.Get("docs", "a", &doc); err != nil {
+			t.Errorf("Get() after aborted Take() error = %v", err)
+		}
+	})
+
+	t.Run("value is taken once", func(t *testing.T) {
+		var doc testDoc
+		if err := db.Take("docs", "a", &doc, nil); err != nil {
+			t.Fatalf("Take() error = %v", err)
+		}
+		if doc.Name != "doc" {
+			t.Errorf("Take() loaded %+v, want the stored doc", doc)
+		}
+		if err := db.Take("docs", "a", &doc, nil); !errors.Is(err, ErrNotFound) {
+			t.Errorf("second Take() error = %v, want ErrNotFound", err)
+		}
+	})
+}
+
+func TestDeleteMatching(t *testing.T) {
+	db := openTestDB(t)
+
+	for i, key := range []string{"a", "b", "c", "d"} {
+		if err := db.Put("docs", key, testDoc{Name: key, Count: i}); err != nil {
+			t.Fatalf("Put() error = %v", err)
+		}
+	}
+
+	removed, err := db.DeleteMatching("docs", func(_ string, raw []byte) (bool, error) {
+		var doc testDoc
+		if err := json.Unmarshal(raw, &doc); err != nil {
+			return false, err
+		}
+		return doc.Count%2 == 0, nil
+	})
+	if err != nil {
+		t.Fatalf("DeleteMatching() error = %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("DeleteMatching() removed %d, want 2", removed)
+	}
+
+	var keys []string
+	if err := db.ForEach("docs", func(key string, _ []byte) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		t.Fatalf("ForEach() error = %v", err)
+	}
+	if len(keys) != 2 || keys[0] != "b" || keys[1] != "d" {
+		t.Errorf("remaining keys = %v, want [b d]", keys)
+	}
+
+	if removed, err := db.DeleteMatching("missing", func(string, []byte) (bool, error) { return true, nil }); err != nil || removed != 0 {
+		t.Errorf("DeleteMatching() on missing bucket = %d, %v, want 0, nil", removed, err)
+	}
+}
@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/ast"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Extension is a gqlgen extension that traces each GraphQL operation and each field backed by
+// a resolver. Upstream calls made by resolvers become children of their field's span.
+type Extension struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationInterceptor
+	graphql.FieldInterceptor
+} = Extension{}
+
+// ExtensionName implements graphql.HandlerExtension
+func (Extension) ExtensionName() string {
+	return "Tracing"
+}
+
+// Validate implements graphql.HandlerExtension
+func (Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// InterceptOperation implements graphql.OperationInterceptor
+func (Extension) InterceptOperation(ctx context.Context, next graphql.OperationHandler) graphql.ResponseHandler {
+	opCtx := graphql.GetOperationContext(ctx)
+	if opCtx.Operation == nil {
+		return next(ctx)
+	}
+
+	operationType := string(opCtx.Operation.Operation)
+	name := opCtx.OperationName
+	if name == "" {
+		name = opCtx.Operation.Name
+	}
+	spanName := operationType
+	if name != "" {
+		spanName += " " + name
+	}
+	ctx, span := Start(ctx, spanName,
+		attribute.String("graphql.operation.type", operationType),
+		attribute.String("graphql.operation.name", name),
+		attribute.String("graphql.operation.fields", rootFields(opCtx.Operation)),
+	)
+	handler := next(ctx)
+
+	return func(ctx context.Context) *graphql.Response {
+		resp := handler(ctx)
+		// Subscriptions respond until the handler returns nil; other operations respond once
+		if resp == nil || opCtx.Operation.Operation != ast.Subscription {
+			if resp != nil && len(resp.Errors) > 0 {
+				span.SetAttributes(attribute.Int("graphql.errors", len(resp.Errors)))
+				span.SetStatus(codes.Error, resp.Errors.Error())
+			}
+			span.End()
+		}
+		return resp
+	}
+}
+
+// InterceptField implements graphql.FieldInterceptor
+func (Extension) InterceptField(ctx context.Context, next graphql.Resolver) (any, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver || !trace.SpanFromContext(ctx).IsRecording() {
+		return next(ctx)
+	}
+	ctx, span := Start(ctx, fc.Object+"."+fc.Field.Name,
+		attribute.String("graphql.field.path", fc.Path().String()),
+	)
+	res, err := next(ctx)
+	End(span, err)
+	return res, err
+}
+
+// rootFields lists the root fields an operation selects, e.g. "generateImage,quota"
+func rootFields(operation *ast.OperationDefinition) string {
+	fields := make([]string, 0, len(operation.SelectionSet))
+	for _, selection := range operation.SelectionSet {
+		if field, ok := selection.(*ast.Field); ok {
+			fields = append(fields, field.Name)
+		}
+	}
+	return strings.Join(fields, ",")
+}
@@ -0,0 +1,116 @@
+// Package tracing sets up OpenTelemetry tracing: a span per GraphQL operation and per call to
+// Gemini, Imagen and Twitter, continuing the trace started by the frontend's traceparent header.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters accepted by Config.Exporter, named as in OTEL_TRACES_EXPORTER
+const (
+	ExporterNone    = "none"
+	ExporterOTLP    = "otlp"
+	ExporterConsole = "console"
+	// ExporterStdout is an alias of ExporterConsole
+	ExporterStdout = "stdout"
+)
+
+// instrumentationName names the tracer of the application's own spans
+const instrumentationName = "github.com/Tattsum/enjo/backend"
+
+// defaultServiceName is reported unless OTEL_SERVICE_NAME is set
+const defaultServiceName = "enjo-backend"
+
+// Config selects where spans are exported
+type Config struct {
+	// Exporter is ExporterOTLP, ExporterConsole or ExporterNone (default). The OTLP exporter is
+	// configured by the standard OTEL_EXPORTER_OTLP_* environment variables and sampling by
+	// OTEL_TRACES_SAMPLER.
+	Exporter string
+	// Writer receives spans from the console exporter; os.Stdout when nil
+	Writer io.Writer
+}
+
+// Setup installs the global tracer provider and the W3C trace context propagator. The returned
+// function flushes pending spans and must be called before the process exits. With ExporterNone
+// only the propagator is installed, so request traces still reach upstream logs but nothing is
+// recorded.
+func Setup(ctx context.Context, config Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch config.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case ExporterConsole, ExporterStdout:
+		writer := config.Writer
+		if writer == nil {
+			writer = os.Stdout
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(writer))
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter %q, want %s, %s or %s", config.Exporter, ExporterOTLP, ExporterConsole, ExporterNone)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", config.Exporter, err)
+	}
+
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(defaultServiceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Middleware continues the trace described by the traceparent and tracestate headers of each
+// request, so that spans started while serving it join the caller's trace
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Headers lists the request headers the propagator reads, for CORS configuration
+var Headers = []string{"traceparent", "tracestate", "baggage"}
@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Transport traces each request to an upstream API. The trace context is not
+// forwarded, since third-party APIs have no use for it.
+type Transport struct {
+	// Service names the upstream API in span names, e.g. "twitter"
+	Service string
+	// Base performs the requests; http.DefaultTransport when nil
+	Base http.RoundTripper
+}
+
+// NewTransport returns a Transport for service on top of base
+func NewTransport(service string, base http.RoundTripper) *Transport {
+	return &Transport{Service: service, Base: base}
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	ctx, span := Start(req.Context(), t.Service+" "+req.Method,
+		attribute.String("http.request.method", req.Method),
+		attribute.String("server.address", req.URL.Host),
+		attribute.String("url.path", req.URL.Path),
+	)
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	if err == nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= http.StatusBadRequest {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	End(span, err)
+	return resp, err
+}
@@ -1,19 +1,22 @@
 package twitter
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"log/slog"
+	"net"
 	"net/http"
-	"net/url"
 	"strconv"
+	"syscall"
 
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/dghubble/oauth1"
+
+	"github.com/Tattsum/enjo/backend/imageproc"
+	"github.com/Tattsum/enjo/backend/logging"
+	"github.com/Tattsum/enjo/backend/metrics"
+	"github.com/Tattsum/enjo/backend/tracing"
 )
 
 const (
@@ -21,6 +24,12 @@ const (
 	MaxTweetLength = 280
 	// MediaUploadURL is the Twitter Media Upload API endpoint
 	MediaUploadURL = "https://upload.twitter.com/1.1/media/upload.json"
+	// MediaMetadataURL is the Twitter Media Metadata API endpoint used for alt text
+	MediaMetadataURL = "https://upload.twitter.com/1.1/media/metadata/create.json"
+	// MaxImagesPerTweet is the maximum number of images that can be attached to a tweet
+	MaxImagesPerTweet = 4
+	// MaxAltTextLength is the maximum length of image alt text in characters
+	MaxAltTextLength = 1000
 )
 
 // Client represents a Twitter API client
@@ -30,8 +39,29 @@ type Client struct {
 	accessToken       string
 	accessTokenSecret string
 	twitterClient     *twitter.Client
-	httpClient        *http.Client // OAuth1-authenticated HTTP client
-	mockMode          bool         // If true, use mock responses for testing
+	httpClient        *http.Client     // OAuth1-authenticated HTTP client
+	mockMode          bool             // If true, use mock responses for testing
+	uploadURL         string           // Media upload endpoint (defaults to MediaUploadURL)
+	metadataURL       string           // Media metadata endpoint (defaults to MediaMetadataURL)
+	tweetsURL         string           // v2 tweets endpoint (defaults to TweetsURL)
+	imageBudget       imageproc.Budget // Limits still images are fitted to before upload (defaults to imageproc.Twitter)
+}
+
+// ClientOption is a functional option for the Twitter client
+type ClientOption func(*Client)
+
+// WithImageBudget sets the limits still images are fitted to before upload.
+// Setting an aspect ratio center-crops every attached image to it.
+func WithImageBudget(budget imageproc.Budget) ClientOption {
+	return func(c *Client) {
+		c.imageBudget = budget
+	}
+}
+
+// Image is an image attached to a tweet
+type Image struct {
+	Data    []byte // Raw image bytes (PNG, JPEG, WebP, GIF) or MP4 video
+	AltText string // Accessibility description, up to MaxAltTextLength characters
 }
 
 // TweetResult represents the result of posting a tweet
@@ -40,16 +70,47 @@ type TweetResult struct {
 	URL string
 }
 
-// mediaUploadResponse represents the response from Twitter Media Upload API
-type mediaUploadResponse struct {
-	MediaID       int64  `json:"media_id"`
-	MediaIDString string `json:"media_id_string"`
-	Size          int    `json:"size"`
-	ExpiresAfter  int    `json:"expires_after_secs"`
+// ErrNotPosted is wrapped by posting errors after which the tweet certainly does not exist: Twitter
+// rate limited the request, the request never reached Twitter, or uploading the media before it
+// failed. After any other failure, such as a timeout, the tweet may have been posted.
+var ErrNotPosted = errors.New("tweet was not posted")
+
+// notPostedError marks an error as leaving no tweet behind without changing its message
+type notPostedError struct {
+	err error
+}
+
+func (e *notPostedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notPostedError) Unwrap() error {
+	return e.err
+}
+
+func (*notPostedError) Is(target error) bool {
+	return target == ErrNotPosted
+}
+
+// updateError wraps an error of the status update request, marking it with ErrNotPosted when the
+// tweet cannot have been created
+func updateError(message string, resp *http.Response, err error) error {
+	err = fmt.Errorf("%s: %w", message, err)
+	if (resp != nil && resp.StatusCode == http.StatusTooManyRequests) || requestNotSent(err) {
+		return &notPostedError{err: err}
+	}
+	return err
+}
+
+// requestNotSent reports whether err happened before the request reached Twitter, because the
+// host could not be resolved or refused the connection
+func requestNotSent(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED)
 }
 
 // NewClient creates a new Twitter API client
-func NewClient(apiKey, apiSecret, accessToken, accessTokenSecret string) (*Client, error) {
+func NewClient(apiKey, apiSecret, accessToken, accessTokenSecret string, options ...ClientOption) (*Client, error) {
 	if apiKey == "" || apiSecret == "" || accessToken == "" || accessTokenSecret == "" {
 		return nil, errors.New("all Twitter API credentials are required")
 	}
@@ -63,13 +124,16 @@ func NewClient(apiKey, apiSecret, accessToken, accessTokenSecret string) (*Clien
 		// Create OAuth1 config
 		config := oauth1.NewConfig(apiKey, apiSecret)
 		token := oauth1.NewToken(accessToken, accessTokenSecret)
-		httpClient = config.Client(oauth1.NoContext, token)
+		// Upstream calls forward the request ID of their context and are logged, measured and traced
+		base := &http.Client{Transport: logging.NewTransport("twitter",
+			metrics.NewTransport(metrics.ServiceTwitter, tracing.NewTransport("twitter", nil)))}
+		httpClient = config.Client(context.WithValue(oauth1.NoContext, oauth1.HTTPClient, base), token)
 
 		// Create Twitter client
 		twitterClient = twitter.NewClient(httpClient)
 	}
 
-	return &Client{
+	c := &Client{
 		apiKey:            apiKey,
 		apiSecret:         apiSecret,
 		accessToken:       accessToken,
@@ -77,11 +141,39 @@ func NewClient(apiKey, apiSecret, accessToken, accessTokenSecret string) (*Clien
 		twitterClient:     twitterClient,
 		httpClient:        httpClient,
 		mockMode:          mockMode,
-	}, nil
+	}
+	for _, opt := range options {
+		opt(c)
+	}
+	return c, nil
+}
+
+// Ping checks that Twitter accepts the client's credentials by looking up the authenticated
+// user. A rate limited response still proves the credentials valid. The endpoint is heavily
+// rate limited on lower API tiers, so call Ping sparingly.
+func (c *Client) Ping(ctx context.Context) error {
+	if c.mockMode {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, UsersMeURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Twitter: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusTooManyRequests {
+		return fmt.Errorf("twitter rejected the credentials with status %d", resp.StatusCode)
+	}
+	return nil
 }
 
 // PostTweet posts a tweet to Twitter
-func (c *Client) PostTweet(_ context.Context, text string, options ...TweetOption) (*TweetResult, error) {
+func (c *Client) PostTweet(ctx context.Context, text string, options ...TweetOption) (*TweetResult, error) {
 	// Validate input
 	if text == "" {
 		return nil, errors.New("tweet text cannot be empty")
@@ -114,11 +206,13 @@ func (c *Client) PostTweet(_ context.Context, text string, options ...TweetOptio
 		}, nil
 	}
 
-	// Post tweet using Twitter API
-	tweet, _, err := c.twitterClient.Statuses.Update(finalText, nil)
+	// Post tweet using Twitter API. The v1.1 client takes no context, so log the result here
+	// to tie it to the request.
+	tweet, resp, err := c.twitterClient.Statuses.Update(finalText, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to post tweet: %w", err)
+		return nil, updateError("failed to post tweet", resp, err)
 	}
+	slog.InfoContext(ctx, "tweet posted", "tweet_id", tweet.IDStr)
 
 	return &TweetResult{
 		ID:  tweet.IDStr,
@@ -163,70 +257,31 @@ func WithDisclaimer() TweetOption {
 	}
 }
 
-// uploadMedia uploads image data to Twitter and returns a media ID
-func (c *Client) uploadMedia(ctx context.Context, imageData []byte) (string, error) {
-	// Validate input
-	if len(imageData) == 0 {
-		return "", errors.New("image data cannot be empty")
-	}
-
-	// If in mock mode, return mock media ID
-	if c.mockMode {
-		return "mock-media-id-123456789", nil
-	}
-
-	// Base64 encode the image data
-	encodedData := base64.StdEncoding.EncodeToString(imageData)
-
-	// Prepare form data
-	formData := url.Values{}
-	formData.Set("media_data", encodedData)
-	formData.Set("media_category", "tweet_image")
-
-	// Create HTTP request with context
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, MediaUploadURL, bytes.NewBufferString(formData.Encode()))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	// Send request using OAuth1-authenticated HTTP client
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to upload media: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %w", err)
-	}
-
-	// Check HTTP status
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("media upload failed with status %d: %s", resp.StatusCode, string(body))
-	}
-
-	// Parse JSON response
-	var uploadResp mediaUploadResponse
-	if err := json.Unmarshal(body, &uploadResp); err != nil {
-		return "", fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return uploadResp.MediaIDString, nil
+// postTweetWithMediaID posts a tweet with an attached media ID
+func (c *Client) postTweetWithMediaID(ctx context.Context, text string, mediaID string, options ...TweetOption) (*TweetResult, error) {
+	return c.postTweetWithMediaIDs(ctx, text, []string{mediaID}, options...)
 }
 
-// postTweetWithMediaID posts a tweet with an attached media ID
-func (c *Client) postTweetWithMediaID(_ context.Context, text string, mediaID string, options ...TweetOption) (*TweetResult, error) {
+// postTweetWithMediaIDs posts a tweet with up to MaxImagesPerTweet attached media IDs
+func (c *Client) postTweetWithMediaIDs(ctx context.Context, text string, mediaIDs []string, options ...TweetOption) (*TweetResult, error) {
 	// Validate input
 	if text == "" {
 		return nil, errors.New("tweet text cannot be empty")
 	}
-	if mediaID == "" {
+	if len(mediaIDs) == 0 {
 		return nil, errors.New("media ID cannot be empty")
 	}
+	if len(mediaIDs) > MaxImagesPerTweet {
+		return nil, fmt.Errorf("a tweet can have at most %d images, got %d", MaxImagesPerTweet, len(mediaIDs))
+	}
+
+	ids := make([]int64, 0, len(mediaIDs))
+	for _, mediaID := range mediaIDs {
+		if mediaID == "" {
+			return nil, errors.New("media ID cannot be empty")
+		}
+		ids = append(ids, mustParseMediaID(mediaID))
+	}
 
 	// Apply options
 	opts := &tweetOptions{}
@@ -252,12 +307,13 @@ func (c *Client) postTweetWithMediaID(_ context.Context, text string, mediaID st
 
 	// Post tweet with media using Twitter API
 	params := &twitter.StatusUpdateParams{
-		MediaIds: []int64{mustParseMediaID(mediaID)},
+		MediaIds: ids,
 	}
-	tweet, _, err := c.twitterClient.Statuses.Update(finalText, params)
+	tweet, resp, err := c.twitterClient.Statuses.Update(finalText, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to post tweet with media: %w", err)
+		return nil, updateError("failed to post tweet with media", resp, err)
 	}
+	slog.InfoContext(ctx, "tweet posted", "tweet_id", tweet.IDStr, "media", len(ids))
 
 	return &TweetResult{
 		ID:  tweet.IDStr,
@@ -275,14 +331,51 @@ func (c *Client) PostTweetWithImage(ctx context.Context, text string, imageData
 		return nil, errors.New("image data cannot be empty")
 	}
 
-	// 1. Upload media
-	mediaID, err := c.uploadMedia(ctx, imageData)
-	if err != nil {
-		return nil, fmt.Errorf("failed to upload media: %w", err)
+	return c.PostTweetWithImages(ctx, text, []Image{{Data: imageData}}, options...)
+}
+
+// PostTweetWithImages posts a tweet with up to MaxImagesPerTweet images, each with optional alt text
+func (c *Client) PostTweetWithImages(ctx context.Context, text string, images []Image, options ...TweetOption) (*TweetResult, error) {
+	// Validate input
+	if text == "" {
+		return nil, errors.New("tweet text cannot be empty")
+	}
+	if len(images) == 0 {
+		return nil, errors.New("at least one image is required")
+	}
+	if len(images) > MaxImagesPerTweet {
+		return nil, fmt.Errorf("a tweet can have at most %d images, got %d", MaxImagesPerTweet, len(images))
+	}
+	for i, img := range images {
+		if len(img.Data) == 0 {
+			return nil, fmt.Errorf("image %d: image data cannot be empty", i+1)
+		}
+		if len([]rune(img.AltText)) > MaxAltTextLength {
+			return nil, fmt.Errorf("image %d: alt text exceeds %d characters", i+1, MaxAltTextLength)
+		}
+		// Twitter only allows a single GIF or video per tweet
+		if category := mediaCategoryFor(img.Data); category != categoryImage && len(images) > 1 {
+			return nil, fmt.Errorf("image %d: %s cannot be combined with other media", i+1, category)
+		}
+	}
+
+	// 1. Upload media and attach alt text
+	mediaIDs := make([]string, 0, len(images))
+	for i, img := range images {
+		mediaID, err := c.uploadMedia(ctx, img.Data)
+		if err != nil {
+			return nil, &notPostedError{err: fmt.Errorf("failed to upload media: %w", err)}
+		}
+		if img.AltText != "" {
+			if err := c.createMediaMetadata(ctx, mediaID, img.AltText); err != nil {
+				return nil, &notPostedError{err: fmt.Errorf("failed to set alt text for image %d: %w", i+1, err)}
+			}
+		}
+		mediaIDs = append(mediaIDs, mediaID)
 	}
 
-	// 2. Post tweet with media ID
-	result, err := c.postTweetWithMediaID(ctx, text, mediaID, options...)
+	// 2. Post tweet with media IDs
+	result, err := c.postTweetWithMediaIDs(ctx, text, mediaIDs, options...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to post tweet: %w", err)
 	}
@@ -2,7 +2,14 @@ package twitter
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
 )
 
 func TestNewClient(t *testing.T) {
@@ -179,3 +186,56 @@ func TestPostTweet_WithOptions(t *testing.T) {
 		})
 	}
 }
+
+// rewriteTransport sends every request to target instead of the Twitter API
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestPostTweet_NotPosted(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int  // Status of the update response
+		unreachable   bool // The server refuses connections instead
+		wantNotPosted bool
+	}{
+		{name: "rate limited", status: http.StatusTooManyRequests, wantNotPosted: true},
+		{name: "connection refused", unreachable: true, wantNotPosted: true},
+		{name: "server error", status: http.StatusServiceUnavailable, wantNotPosted: false},
+		{name: "duplicate", status: http.StatusForbidden, wantNotPosted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.status)
+				fmt.Fprintf(w, `{"errors":[{"code":%d,"message":"rejected"}]}`, tt.status)
+			}))
+			defer server.Close()
+			target, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatalf("failed to parse server URL: %v", err)
+			}
+			if tt.unreachable {
+				server.Close()
+			}
+
+			client := &Client{twitterClient: twitter.NewClient(&http.Client{Transport: rewriteTransport{target: target}})}
+			_, err = client.PostTweet(context.Background(), "炎上投稿")
+			if err == nil {
+				t.Fatal("PostTweet() error = nil, want an error")
+			}
+			if got := errors.Is(err, ErrNotPosted); got != tt.wantNotPosted {
+				t.Errorf("errors.Is(%v, ErrNotPosted) = %v, want %v", err, got, tt.wantNotPosted)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package twitter
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Tattsum/enjo/backend/imageproc"
+)
+
+// Media categories understood by the Twitter Media Upload API
+const (
+	categoryImage = "tweet_image"
+	categoryGIF   = "tweet_gif"
+	categoryVideo = "tweet_video"
+)
+
+const (
+	// maxImageBytes is the upload limit for still images
+	maxImageBytes = 5 * 1024 * 1024
+	// maxGIFBytes is the upload limit for animated GIFs
+	maxGIFBytes = 15 * 1024 * 1024
+	// maxVideoBytes is the upload limit for videos
+	maxVideoBytes = 512 * 1024 * 1024
+	// chunkSize is the size of each APPEND segment in a chunked upload
+	chunkSize = 1024 * 1024
+	// maxStatusChecks bounds how often a processing upload is polled
+	maxStatusChecks = 30
+)
+
+// mediaUploadResponse represents the response from Twitter Media Upload API
+type mediaUploadResponse struct {
+	MediaID        int64           `json:"media_id"`
+	MediaIDString  string          `json:"media_id_string"`
+	Size           int             `json:"size"`
+	ExpiresAfter   int             `json:"expires_after_secs"`
+	ProcessingInfo *processingInfo `json:"processing_info,omitempty"`
+}
+
+// processingInfo describes the asynchronous processing state of an uploaded GIF or video
+type processingInfo struct {
+	State          string `json:"state"`
+	CheckAfterSecs int    `json:"check_after_secs"`
+	Error          *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// mediaMetadataRequest is the body of a media metadata (alt text) request
+type mediaMetadataRequest struct {
+	MediaID string `json:"media_id"`
+	AltText struct {
+		Text string `json:"text"`
+	} `json:"alt_text"`
+}
+
+// mediaCategoryFor returns the upload category for the given media bytes
+func mediaCategoryFor(data []byte) string {
+	contentType := http.DetectContentType(data)
+	switch {
+	case contentType == "image/gif":
+		return categoryGIF
+	case strings.HasPrefix(contentType, "video/"):
+		return categoryVideo
+	default:
+		return categoryImage
+	}
+}
+
+// maxBytesFor returns the upload size limit for a media category
+func maxBytesFor(category string) int {
+	switch category {
+	case categoryGIF:
+		return maxGIFBytes
+	case categoryVideo:
+		return maxVideoBytes
+	default:
+		return maxImageBytes
+	}
+}
+
+// uploadMedia uploads image, GIF or video data to Twitter and returns a media ID.
+// Still images use a simple upload; GIFs and videos use the chunked INIT/APPEND/FINALIZE flow.
+func (c *Client) uploadMedia(ctx context.Context, imageData []byte) (string, error) {
+	// Validate input
+	if len(imageData) == 0 {
+		return "", errors.New("image data cannot be empty")
+	}
+
+	category := mediaCategoryFor(imageData)
+	if category == categoryImage {
+		prepared, err := c.prepareImage(imageData)
+		if err != nil {
+			return "", err
+		}
+		imageData = prepared
+	}
+	if limit := maxBytesFor(category); len(imageData) > limit {
+		return "", fmt.Errorf("%s exceeds the %d byte upload limit", category, limit)
+	}
+
+	// If in mock mode, return mock media ID
+	if c.mockMode {
+		return "mock-media-id-123456789", nil
+	}
+
+	if category == categoryImage {
+		return c.uploadSimple(ctx, imageData, category)
+	}
+	return c.uploadChunked(ctx, imageData, category)
+}
+
+// prepareImage fits a still image to the client's image budget, which also strips its metadata.
+// Formats imageproc does not handle are returned unchanged and left to the API to validate.
+func (c *Client) prepareImage(data []byte) ([]byte, error) {
+	budget := c.imageBudget
+	if budget == (imageproc.Budget{}) {
+		budget = imageproc.Twitter
+	}
+
+	result, err := imageproc.Process(data, budget)
+	if errors.Is(err, imageproc.ErrUnsupportedFormat) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare image: %w", err)
+	}
+	return result.Data, nil
+}
+
+// uploadSimple uploads media in a single request
+func (c *Client) uploadSimple(ctx context.Context, data []byte, category string) (string, error) {
+	formData := url.Values{}
+	formData.Set("media_data", base64.StdEncoding.EncodeToString(data))
+	formData.Set("media_category", category)
+
+	uploadResp, err := c.mediaRequest(ctx, http.MethodPost, formData)
+	if err != nil {
+		return "", err
+	}
+	return uploadResp.MediaIDString, nil
+}
+
+// uploadChunked uploads media using the INIT/APPEND/FINALIZE flow and waits for processing to finish
+func (c *Client) uploadChunked(ctx context.Context, data []byte, category string) (string, error) {
+	// INIT
+	initForm := url.Values{}
+	initForm.Set("command", "INIT")
+	initForm.Set("total_bytes", strconv.Itoa(len(data)))
+	initForm.Set("media_type", http.DetectContentType(data))
+	initForm.Set("media_category", category)
+
+	initResp, err := c.mediaRequest(ctx, http.MethodPost, initForm)
+	if err != nil {
+		return "", fmt.Errorf("INIT failed: %w", err)
+	}
+	mediaID := initResp.MediaIDString
+
+	// APPEND
+	for segment, offset := 0, 0; offset < len(data); segment, offset = segment+1, offset+chunkSize {
+		end := min(offset+chunkSize, len(data))
+
+		appendForm := url.Values{}
+		appendForm.Set("command", "APPEND")
+		appendForm.Set("media_id", mediaID)
+		appendForm.Set("segment_index", strconv.Itoa(segment))
+		appendForm.Set("media_data", base64.StdEncoding.EncodeToString(data[offset:end]))
+
+		if _, err := c.mediaRequest(ctx, http.MethodPost, appendForm); err != nil {
+			return "", fmt.Errorf("APPEND segment %d failed: %w", segment, err)
+		}
+	}
+
+	// FINALIZE
+	finalizeForm := url.Values{}
+	finalizeForm.Set("command", "FINALIZE")
+	finalizeForm.Set("media_id", mediaID)
+
+	finalizeResp, err := c.mediaRequest(ctx, http.MethodPost, finalizeForm)
+	if err != nil {
+		return "", fmt.Errorf("FINALIZE failed: %w", err)
+	}
+
+	if err := c.waitForProcessing(ctx, mediaID, finalizeResp.ProcessingInfo); err != nil {
+		return "", err
+	}
+	return mediaID, nil
+}
+
+// waitForProcessing polls the STATUS command until asynchronous processing succeeds or fails
+func (c *Client) waitForProcessing(ctx context.Context, mediaID string, info *processingInfo) error {
+	for range maxStatusChecks {
+		if info == nil || info.State == "succeeded" {
+			return nil
+		}
+		if info.State == "failed" {
+			if info.Error != nil {
+				return fmt.Errorf("media processing failed: %s", info.Error.Message)
+			}
+			return errors.New("media processing failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(info.CheckAfterSecs) * time.Second):
+		}
+
+		statusQuery := url.Values{}
+		statusQuery.Set("command", "STATUS")
+		statusQuery.Set("media_id", mediaID)
+
+		statusResp, err := c.mediaRequest(ctx, http.MethodGet, statusQuery)
+		if err != nil {
+			return fmt.Errorf("STATUS failed: %w", err)
+		}
+		info = statusResp.ProcessingInfo
+	}
+	return errors.New("media processing did not finish in time")
+}
+
+// mediaRequest sends a form-encoded request to the media upload endpoint
+func (c *Client) mediaRequest(ctx context.Context, method string, form url.Values) (*mediaUploadResponse, error) {
+	endpoint := c.uploadURL
+	if endpoint == "" {
+		endpoint = MediaUploadURL
+	}
+
+	var body io.Reader = http.NoBody
+	if method == http.MethodGet {
+		endpoint += "?" + form.Encode()
+	} else {
+		body = strings.NewReader(form.Encode())
+	}
+
+	// Create HTTP request with context
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if method != http.MethodGet {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	respBody, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// APPEND returns an empty body on success
+	uploadResp := &mediaUploadResponse{}
+	if len(respBody) == 0 {
+		return uploadResp, nil
+	}
+	if err := json.Unmarshal(respBody, uploadResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return uploadResp, nil
+}
+
+// createMediaMetadata attaches alt text to an uploaded media item
+func (c *Client) createMediaMetadata(ctx context.Context, mediaID, altText string) error {
+	if mediaID == "" {
+		return errors.New("media ID cannot be empty")
+	}
+	if len([]rune(altText)) > MaxAltTextLength {
+		return fmt.Errorf("alt text exceeds %d characters", MaxAltTextLength)
+	}
+
+	// If in mock mode, pretend the metadata was stored
+	if c.mockMode {
+		return nil
+	}
+
+	payload := mediaMetadataRequest{MediaID: mediaID}
+	payload.AltText.Text = altText
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	endpoint := c.metadataURL
+	if endpoint == "" {
+		endpoint = MediaMetadataURL
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	_, err = c.do(req)
+	return err
+}
+
+// do sends an authenticated request and returns the body of a 2xx response
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	// Send request using OAuth1-authenticated HTTP client
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload media: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Check HTTP status
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("media request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
@@ -1,8 +1,20 @@
 package twitter
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/Tattsum/enjo/backend/imageproc"
 )
 
 func TestUploadMedia(t *testing.T) {
@@ -241,3 +253,251 @@ func TestPostTweetWithImage(t *testing.T) {
 		})
 	}
 }
+
+func TestMediaCategoryFor(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "png image",
+			data: []byte("\x89PNG\r\n\x1a\n0000"),
+			want: categoryImage,
+		},
+		{
+			name: "gif image",
+			data: []byte("GIF89a000000"),
+			want: categoryGIF,
+		},
+		{
+			name: "mp4 video",
+			data: []byte("\x00\x00\x00\x18ftypmp42\x00\x00\x00\x00isommp42"),
+			want: categoryVideo,
+		},
+		{
+			name: "unknown data falls back to image",
+			data: []byte("fake-image-data"),
+			want: categoryImage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mediaCategoryFor(tt.data); got != tt.want {
+				t.Errorf("mediaCategoryFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostTweetWithImages(t *testing.T) {
+	gif := []byte("GIF89a000000")
+	tests := []struct {
+		name    string
+		text    string
+		images  []Image
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "successful tweet with four images and alt text",
+			text: "Test tweet",
+			images: []Image{
+				{Data: []byte("image-1"), AltText: "炎上した投稿のイラスト"},
+				{Data: []byte("image-2")},
+				{Data: []byte("image-3"), AltText: "alt"},
+				{Data: []byte("image-4")},
+			},
+		},
+		{
+			name:    "no images",
+			text:    "Test tweet",
+			wantErr: true,
+			errMsg:  "at least one image is required",
+		},
+		{
+			name: "more than four images",
+			text: "Test tweet",
+			images: []Image{
+				{Data: []byte("1")}, {Data: []byte("2")}, {Data: []byte("3")}, {Data: []byte("4")}, {Data: []byte("5")},
+			},
+			wantErr: true,
+			errMsg:  "a tweet can have at most 4 images, got 5",
+		},
+		{
+			name:    "empty image data",
+			text:    "Test tweet",
+			images:  []Image{{Data: []byte("1")}, {Data: nil}},
+			wantErr: true,
+			errMsg:  "image 2: image data cannot be empty",
+		},
+		{
+			name:    "alt text too long",
+			text:    "Test tweet",
+			images:  []Image{{Data: []byte("1"), AltText: strings.Repeat("あ", MaxAltTextLength+1)}},
+			wantErr: true,
+			errMsg:  "image 1: alt text exceeds 1000 characters",
+		},
+		{
+			name:    "gif combined with other images",
+			text:    "Test tweet",
+			images:  []Image{{Data: []byte("1")}, {Data: gif}},
+			wantErr: true,
+			errMsg:  "image 2: tweet_gif cannot be combined with other media",
+		},
+		{
+			name:   "single gif",
+			text:   "Test tweet",
+			images: []Image{{Data: gif, AltText: "animated"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{apiKey: "test-api-key", mockMode: true}
+
+			result, err := client.PostTweetWithImages(context.Background(), tt.text, tt.images)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("PostTweetWithImages() error = nil, want error")
+				}
+				if err.Error() != tt.errMsg {
+					t.Errorf("PostTweetWithImages() error = %v, want %v", err, tt.errMsg)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("PostTweetWithImages() unexpected error = %v", err)
+			}
+			if result == nil || result.ID == "" {
+				t.Error("PostTweetWithImages() returned empty result")
+			}
+		})
+	}
+}
+
+func TestUploadMedia_Chunked(t *testing.T) {
+	gif := append([]byte("GIF89a"), bytes.Repeat([]byte{0}, chunkSize+10)...)
+
+	var commands []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+		command := r.Form.Get("command")
+		commands = append(commands, command)
+
+		switch command {
+		case "INIT":
+			if got := r.Form.Get("media_category"); got != categoryGIF {
+				t.Errorf("media_category = %q, want %q", got, categoryGIF)
+			}
+			fmt.Fprint(w, `{"media_id_string":"42"}`)
+		case "APPEND":
+			w.WriteHeader(http.StatusNoContent)
+		case "FINALIZE":
+			fmt.Fprint(w, `{"media_id_string":"42","processing_info":{"state":"pending","check_after_secs":0}}`)
+		case "STATUS":
+			fmt.Fprint(w, `{"media_id_string":"42","processing_info":{"state":"succeeded"}}`)
+		default:
+			t.Errorf("unexpected command %q", command)
+		}
+	}))
+	defer server.Close()
+
+	client := &Client{httpClient: server.Client(), uploadURL: server.URL}
+
+	mediaID, err := client.uploadMedia(context.Background(), gif)
+	if err != nil {
+		t.Fatalf("uploadMedia() error = %v", err)
+	}
+	if mediaID != "42" {
+		t.Errorf("uploadMedia() = %q, want %q", mediaID, "42")
+	}
+
+	want := []string{"INIT", "APPEND", "APPEND", "FINALIZE", "STATUS"}
+	if strings.Join(commands, ",") != strings.Join(want, ",") {
+		t.Errorf("commands = %v, want %v", commands, want)
+	}
+}
+
+func TestUploadMedia_TooLarge(t *testing.T) {
+	client := &Client{mockMode: true}
+	data := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, maxImageBytes)...)
+
+	if _, err := client.uploadMedia(context.Background(), data); err == nil {
+		t.Fatal("expected error for image larger than the upload limit")
+	}
+}
+
+func TestCreateMediaMetadata(t *testing.T) {
+	var received mediaMetadataRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("failed to decode body: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := &Client{httpClient: server.Client(), metadataURL: server.URL}
+
+	if err := client.createMediaMetadata(context.Background(), "42", "炎上のイラスト"); err != nil {
+		t.Fatalf("createMediaMetadata() error = %v", err)
+	}
+	if received.MediaID != "42" || received.AltText.Text != "炎上のイラスト" {
+		t.Errorf("received = %+v, want media 42 with alt text", received)
+	}
+}
+
+func TestUploadMedia_FitsImageBudget(t *testing.T) {
+	// Random pixels keep the PNG large and force the image through the budget
+	rng := rand.New(rand.NewPCG(1, 2))
+	src := image.NewNRGBA(image.Rect(0, 0, 600, 300))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(rng.IntN(256))
+	}
+	for i := 3; i < len(src.Pix); i += 4 {
+		src.Pix[i] = 0xff
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+
+	var uploaded []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form: %v", err)
+		}
+		data, err := base64.StdEncoding.DecodeString(r.Form.Get("media_data"))
+		if err != nil {
+			t.Errorf("failed to decode media_data: %v", err)
+		}
+		uploaded = data
+		fmt.Fprint(w, `{"media_id_string":"7"}`)
+	}))
+	defer server.Close()
+
+	budget := imageproc.Budget{MaxBytes: 60_000, MaxDimension: 400, AspectRatio: 1}
+	client := &Client{httpClient: server.Client(), uploadURL: server.URL, imageBudget: budget}
+
+	if _, err := client.uploadMedia(context.Background(), buf.Bytes()); err != nil {
+		t.Fatalf("uploadMedia() error = %v", err)
+	}
+	if len(uploaded) > budget.MaxBytes {
+		t.Errorf("uploaded %d bytes, want at most %d", len(uploaded), budget.MaxBytes)
+	}
+	if got := http.DetectContentType(uploaded); got != "image/jpeg" {
+		t.Errorf("uploaded content type = %s, want image/jpeg", got)
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(uploaded))
+	if err != nil {
+		t.Fatalf("failed to decode uploaded image: %v", err)
+	}
+	if config.Width != 300 || config.Height != 300 {
+		t.Errorf("uploaded image is %dx%d, want 300x300", config.Width, config.Height)
+	}
+}
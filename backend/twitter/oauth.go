@@ -0,0 +1,157 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dghubble/oauth1"
+	oauthtwitter "github.com/dghubble/oauth1/twitter"
+)
+
+// UsersMeURL is the Twitter API v2 endpoint describing the authenticated user
+const UsersMeURL = "https://api.twitter.com/2/users/me"
+
+// RequestToken is the temporary credential of an authorization in progress
+type RequestToken struct {
+	Token  string
+	Secret string
+	// AuthorizeURL is the page the user is sent to in order to approve the app
+	AuthorizeURL string
+}
+
+// Credentials are the access token of a linked account together with the account it belongs to
+type Credentials struct {
+	AccessToken       string
+	AccessTokenSecret string
+	UserID            string
+	ScreenName        string
+}
+
+// Authorizer runs the three-legged OAuth 1.0a flow that lets users link their own Twitter account.
+// OAuth 1.0a is used rather than OAuth 2.0 because media uploads require it.
+type Authorizer struct {
+	config     *oauth1.Config
+	httpClient *http.Client
+	usersMeURL string
+}
+
+// AuthorizerOption is a functional option for the authorizer
+type AuthorizerOption func(*Authorizer)
+
+// WithOAuthEndpoint replaces the Twitter OAuth endpoints, e.g. to point them at a test server
+func WithOAuthEndpoint(endpoint oauth1.Endpoint, usersMeURL string) AuthorizerOption {
+	return func(a *Authorizer) {
+		a.config.Endpoint = endpoint
+		a.usersMeURL = usersMeURL
+	}
+}
+
+// WithOAuthHTTPClient sets the HTTP client used for token requests
+func WithOAuthHTTPClient(client *http.Client) AuthorizerOption {
+	return func(a *Authorizer) {
+		a.httpClient = client
+	}
+}
+
+// NewAuthorizer creates an authorizer for the app identified by apiKey and apiSecret.
+// Twitter redirects users to callbackURL once they have approved or denied the app.
+func NewAuthorizer(apiKey, apiSecret, callbackURL string, options ...AuthorizerOption) (*Authorizer, error) {
+	if apiKey == "" || apiSecret == "" {
+		return nil, errors.New("twitter API key and secret are required")
+	}
+	if callbackURL == "" {
+		return nil, errors.New("OAuth callback URL is required")
+	}
+
+	a := &Authorizer{
+		config: &oauth1.Config{
+			ConsumerKey:    apiKey,
+			ConsumerSecret: apiSecret,
+			CallbackURL:    callbackURL,
+			Endpoint:       oauthtwitter.AuthenticateEndpoint,
+		},
+		httpClient: http.DefaultClient,
+		usersMeURL: UsersMeURL,
+	}
+	for _, opt := range options {
+		opt(a)
+	}
+	a.config.HTTPClient = a.httpClient
+	return a, nil
+}
+
+// RequestToken starts an authorization and returns the page the user approves it on
+func (a *Authorizer) RequestToken(_ context.Context) (*RequestToken, error) {
+	token, secret, err := a.config.RequestToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain request token: %w", err)
+	}
+	authorizeURL, err := a.config.AuthorizationURL(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build authorization URL: %w", err)
+	}
+	return &RequestToken{Token: token, Secret: secret, AuthorizeURL: authorizeURL.String()}, nil
+}
+
+// AccessToken exchanges an approved request token for the user's access token
+// and looks up the account it belongs to
+func (a *Authorizer) AccessToken(ctx context.Context, requestToken, requestSecret, verifier string) (*Credentials, error) {
+	accessToken, accessSecret, err := a.config.AccessToken(requestToken, requestSecret, verifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain access token: %w", err)
+	}
+
+	credentials := &Credentials{AccessToken: accessToken, AccessTokenSecret: accessSecret}
+	if err := a.lookupUser(ctx, credentials); err != nil {
+		return nil, err
+	}
+	return credentials, nil
+}
+
+// usersMeResponse is the response of GET /2/users/me
+type usersMeResponse struct {
+	Data *struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"data"`
+	Errors []apiError `json:"errors"`
+}
+
+// lookupUser fills in the account the credentials belong to
+func (a *Authorizer) lookupUser(ctx context.Context, credentials *Credentials) error {
+	token := oauth1.NewToken(credentials.AccessToken, credentials.AccessTokenSecret)
+	client := a.config.Client(context.WithValue(ctx, oauth1.HTTPClient, a.httpClient), token)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.usersMeURL, http.NoBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to look up linked account: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	var me usersMeResponse
+	if err := json.Unmarshal(body, &me); err != nil {
+		return fmt.Errorf("failed to look up linked account: status %d", resp.StatusCode)
+	}
+	if me.Data == nil || me.Data.ID == "" {
+		if len(me.Errors) > 0 {
+			return fmt.Errorf("failed to look up linked account: %s: %s", me.Errors[0].Title, me.Errors[0].Detail)
+		}
+		return fmt.Errorf("failed to look up linked account: status %d", resp.StatusCode)
+	}
+
+	credentials.UserID = me.Data.ID
+	credentials.ScreenName = me.Data.Username
+	return nil
+}
@@ -0,0 +1,81 @@
+package twitter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dghubble/oauth1"
+)
+
+func TestAuthorizer(t *testing.T) {
+	var meAuthorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		switch r.URL.Path {
+		case "/oauth/request_token":
+			if !strings.Contains(auth, `oauth_callback="https%3A%2F%2Fenjo.example.com%2Fcallback"`) {
+				t.Errorf("request token Authorization = %q, want the callback", auth)
+			}
+			_, _ = w.Write([]byte("oauth_token=request-token&oauth_token_secret=request-secret&oauth_callback_confirmed=true"))
+		case "/oauth/access_token":
+			if !strings.Contains(auth, `oauth_token="request-token"`) || !strings.Contains(auth, `oauth_verifier="verifier"`) {
+				t.Errorf("access token Authorization = %q, want the request token and verifier", auth)
+			}
+			_, _ = w.Write([]byte("oauth_token=access-token&oauth_token_secret=access-secret&user_id=42&screen_name=enjo"))
+		case "/2/users/me":
+			meAuthorization = auth
+			_, _ = w.Write([]byte(`{"data":{"id":"42","name":"炎上","username":"enjo"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	authorizer, err := NewAuthorizer("api-key", "api-secret", "https://enjo.example.com/callback",
+		WithOAuthHTTPClient(server.Client()),
+		WithOAuthEndpoint(oauth1.Endpoint{
+			RequestTokenURL: server.URL + "/oauth/request_token",
+			AuthorizeURL:    server.URL + "/oauth/authenticate",
+			AccessTokenURL:  server.URL + "/oauth/access_token",
+		}, server.URL+"/2/users/me"),
+	)
+	if err != nil {
+		t.Fatalf("NewAuthorizer() error = %v", err)
+	}
+	ctx := context.Background()
+
+	requestToken, err := authorizer.RequestToken(ctx)
+	if err != nil {
+		t.Fatalf("RequestToken() error = %v", err)
+	}
+	if requestToken.Token != "request-token" || requestToken.Secret != "request-secret" {
+		t.Errorf("RequestToken() = %+v", requestToken)
+	}
+	if want := server.URL + "/oauth/authenticate?oauth_token=request-token"; requestToken.AuthorizeURL != want {
+		t.Errorf("AuthorizeURL = %q, want %q", requestToken.AuthorizeURL, want)
+	}
+
+	credentials, err := authorizer.AccessToken(ctx, requestToken.Token, requestToken.Secret, "verifier")
+	if err != nil {
+		t.Fatalf("AccessToken() error = %v", err)
+	}
+	want := Credentials{AccessToken: "access-token", AccessTokenSecret: "access-secret", UserID: "42", ScreenName: "enjo"}
+	if *credentials != want {
+		t.Errorf("AccessToken() = %+v, want %+v", *credentials, want)
+	}
+	if !strings.Contains(meAuthorization, `oauth_token="access-token"`) {
+		t.Errorf("users/me Authorization = %q, want it signed with the access token", meAuthorization)
+	}
+}
+
+func TestNewAuthorizer_RequiresConfiguration(t *testing.T) {
+	if _, err := NewAuthorizer("", "secret", "https://enjo.example.com/callback"); err == nil {
+		t.Error("NewAuthorizer() without an API key error = nil, want error")
+	}
+	if _, err := NewAuthorizer("key", "secret", ""); err == nil {
+		t.Error("NewAuthorizer() without a callback error = nil, want error")
+	}
+}
@@ -0,0 +1,231 @@
+package twitter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// TweetsURL is the Twitter API v2 tweets endpoint
+const TweetsURL = "https://api.twitter.com/2/tweets"
+
+const (
+	// searchPageSize is the number of replies requested per search page (the API maximum)
+	searchPageSize = 100
+	// maxSearchPages caps how many pages of replies are fetched for a single tweet
+	maxSearchPages = 5
+)
+
+// ErrTweetNotFound is returned when a tweet does not exist or is no longer visible
+var ErrTweetNotFound = errors.New("tweet not found")
+
+// TweetMetrics holds the public engagement counters of a tweet
+type TweetMetrics struct {
+	LikeCount       int
+	RetweetCount    int
+	ReplyCount      int
+	QuoteCount      int
+	ImpressionCount int
+}
+
+// Reply is a reply posted to a tweet
+type Reply struct {
+	ID        string
+	Text      string
+	AuthorID  string
+	CreatedAt time.Time
+}
+
+// apiError is an entry of the errors array returned by the v2 API
+type apiError struct {
+	Title  string `json:"title"`
+	Type   string `json:"type"`
+	Detail string `json:"detail"`
+}
+
+// tweetLookupResponse is the response of GET /2/tweets/:id
+type tweetLookupResponse struct {
+	Data *struct {
+		ID            string `json:"id"`
+		PublicMetrics struct {
+			LikeCount       int `json:"like_count"`
+			RetweetCount    int `json:"retweet_count"`
+			ReplyCount      int `json:"reply_count"`
+			QuoteCount      int `json:"quote_count"`
+			ImpressionCount int `json:"impression_count"`
+		} `json:"public_metrics"`
+	} `json:"data"`
+	Errors []apiError `json:"errors"`
+}
+
+// tweetDeleteResponse is the response of DELETE /2/tweets/:id
+type tweetDeleteResponse struct {
+	Data struct {
+		Deleted bool `json:"deleted"`
+	} `json:"data"`
+	Errors []apiError `json:"errors"`
+}
+
+// searchResponse is the response of GET /2/tweets/search/recent
+type searchResponse struct {
+	Data []struct {
+		ID        string    `json:"id"`
+		Text      string    `json:"text"`
+		AuthorID  string    `json:"author_id"`
+		CreatedAt time.Time `json:"created_at"`
+	} `json:"data"`
+	Meta struct {
+		NextToken string `json:"next_token"`
+	} `json:"meta"`
+	Errors []apiError `json:"errors"`
+}
+
+// DeleteTweet deletes a tweet posted by the authenticated user
+func (c *Client) DeleteTweet(ctx context.Context, tweetID string) error {
+	if tweetID == "" {
+		return errors.New("tweet ID cannot be empty")
+	}
+
+	// If in mock mode, pretend the tweet was deleted
+	if c.mockMode {
+		return nil
+	}
+
+	var resp tweetDeleteResponse
+	if err := c.tweetsRequest(ctx, http.MethodDelete, url.PathEscape(tweetID), nil, &resp); err != nil {
+		return fmt.Errorf("failed to delete tweet: %w", err)
+	}
+	if !resp.Data.Deleted {
+		return fmt.Errorf("failed to delete tweet: %w", notFoundOrAPIError(resp.Errors))
+	}
+	return nil
+}
+
+// GetTweetMetrics fetches the current public metrics of a tweet
+func (c *Client) GetTweetMetrics(ctx context.Context, tweetID string) (*TweetMetrics, error) {
+	if tweetID == "" {
+		return nil, errors.New("tweet ID cannot be empty")
+	}
+
+	// If in mock mode, return empty metrics
+	if c.mockMode {
+		return &TweetMetrics{}, nil
+	}
+
+	query := url.Values{"tweet.fields": {"public_metrics"}}
+	var resp tweetLookupResponse
+	if err := c.tweetsRequest(ctx, http.MethodGet, url.PathEscape(tweetID), query, &resp); err != nil {
+		return nil, fmt.Errorf("failed to fetch tweet metrics: %w", err)
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("failed to fetch tweet metrics: %w", notFoundOrAPIError(resp.Errors))
+	}
+
+	metrics := resp.Data.PublicMetrics
+	return &TweetMetrics{
+		LikeCount:       metrics.LikeCount,
+		RetweetCount:    metrics.RetweetCount,
+		ReplyCount:      metrics.ReplyCount,
+		QuoteCount:      metrics.QuoteCount,
+		ImpressionCount: metrics.ImpressionCount,
+	}, nil
+}
+
+// SearchReplies returns the replies to a tweet found by the recent search endpoint.
+// Recent search only covers the last seven days and at most maxSearchPages pages are fetched.
+func (c *Client) SearchReplies(ctx context.Context, tweetID string) ([]Reply, error) {
+	if tweetID == "" {
+		return nil, errors.New("tweet ID cannot be empty")
+	}
+
+	// If in mock mode, return no replies
+	if c.mockMode {
+		return nil, nil
+	}
+
+	query := url.Values{
+		"query":        {"conversation_id:" + tweetID + " is:reply"},
+		"tweet.fields": {"author_id,created_at"},
+		"max_results":  {strconv.Itoa(searchPageSize)},
+	}
+
+	var replies []Reply
+	for page := 0; page < maxSearchPages; page++ {
+		var resp searchResponse
+		if err := c.tweetsRequest(ctx, http.MethodGet, "search/recent", query, &resp); err != nil {
+			return nil, fmt.Errorf("failed to search replies: %w", err)
+		}
+		if len(resp.Errors) > 0 && len(resp.Data) == 0 {
+			return nil, fmt.Errorf("failed to search replies: %w", notFoundOrAPIError(resp.Errors))
+		}
+
+		for _, tweet := range resp.Data {
+			replies = append(replies, Reply{ID: tweet.ID, Text: tweet.Text, AuthorID: tweet.AuthorID, CreatedAt: tweet.CreatedAt})
+		}
+
+		if resp.Meta.NextToken == "" {
+			break
+		}
+		query.Set("next_token", resp.Meta.NextToken)
+	}
+	return replies, nil
+}
+
+// tweetsRequest sends a request to the v2 tweets endpoint and decodes the JSON response into out
+func (c *Client) tweetsRequest(ctx context.Context, method, path string, query url.Values, out any) error {
+	endpoint := c.tweetsURL
+	if endpoint == "" {
+		endpoint = TweetsURL
+	}
+	endpoint += "/" + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrTweetNotFound
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to parse response: %w", err)
+	}
+	return nil
+}
+
+// notFoundOrAPIError converts the v2 errors array into an error, mapping missing tweets to ErrTweetNotFound
+func notFoundOrAPIError(apiErrors []apiError) error {
+	if len(apiErrors) == 0 {
+		return errors.New("unexpected empty response")
+	}
+
+	first := apiErrors[0]
+	if first.Title == "Not Found Error" || first.Title == "Authorization Error" {
+		return ErrTweetNotFound
+	}
+	return fmt.Errorf("%s: %s", first.Title, first.Detail)
+}
@@ -0,0 +1,146 @@
+package twitter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetTweetMetrics(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		want     *TweetMetrics
+		wantErr  error
+		anyError bool
+	}{
+		{
+			name:   "returns public metrics",
+			status: http.StatusOK,
+			body:   `{"data":{"id":"1","public_metrics":{"like_count":10,"retweet_count":2,"reply_count":5,"quote_count":1,"impression_count":300}}}`,
+			want:   &TweetMetrics{LikeCount: 10, RetweetCount: 2, ReplyCount: 5, QuoteCount: 1, ImpressionCount: 300},
+		},
+		{
+			name:    "maps missing tweet to ErrTweetNotFound",
+			status:  http.StatusOK,
+			body:    `{"errors":[{"title":"Not Found Error","detail":"Could not find tweet with id: [1]."}]}`,
+			wantErr: ErrTweetNotFound,
+		},
+		{
+			name:     "returns error on server failure",
+			status:   http.StatusInternalServerError,
+			body:     `{}`,
+			anyError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/1" || r.URL.Query().Get("tweet.fields") != "public_metrics" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			client := &Client{httpClient: server.Client(), tweetsURL: server.URL}
+
+			got, err := client.GetTweetMetrics(context.Background(), "1")
+			if tt.wantErr != nil || tt.anyError {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+					t.Errorf("GetTweetMetrics() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetTweetMetrics() error = %v", err)
+			}
+			if *got != *tt.want {
+				t.Errorf("GetTweetMetrics() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteTweet(t *testing.T) {
+	t.Run("deletes the tweet", func(t *testing.T) {
+		var method, path string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method, path = r.Method, r.URL.Path
+			fmt.Fprint(w, `{"data":{"deleted":true}}`)
+		}))
+		defer server.Close()
+
+		client := &Client{httpClient: server.Client(), tweetsURL: server.URL}
+		if err := client.DeleteTweet(context.Background(), "42"); err != nil {
+			t.Fatalf("DeleteTweet() error = %v", err)
+		}
+		if method != http.MethodDelete || path != "/42" {
+			t.Errorf("request = %s %s, want DELETE /42", method, path)
+		}
+	})
+
+	t.Run("maps 404 to ErrTweetNotFound", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+
+		client := &Client{httpClient: server.Client(), tweetsURL: server.URL}
+		if err := client.DeleteTweet(context.Background(), "42"); !errors.Is(err, ErrTweetNotFound) {
+			t.Errorf("DeleteTweet() error = %v, want ErrTweetNotFound", err)
+		}
+	})
+
+	t.Run("rejects empty ID", func(t *testing.T) {
+		client := &Client{mockMode: true}
+		if err := client.DeleteTweet(context.Background(), ""); err == nil {
+			t.Error("expected error for empty tweet ID")
+		}
+	})
+}
+
+func TestSearchReplies(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/search/recent" {
+			t.Errorf("path = %q, want /search/recent", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("query"); got != "conversation_id:1 is:reply" {
+			t.Errorf("query = %q", got)
+		}
+
+		if r.URL.Query().Get("next_token") == "" {
+			fmt.Fprint(w, `{"data":[{"id":"2","text":"正論です","author_id":"a","created_at":"2025-01-01T00:00:00.000Z"}],"meta":{"next_token":"p2"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":[{"id":"3","text":"擁護します","author_id":"b","created_at":"2025-01-01T00:01:00.000Z"}],"meta":{}}`)
+	}))
+	defer server.Close()
+
+	client := &Client{httpClient: server.Client(), tweetsURL: server.URL}
+
+	replies, err := client.SearchReplies(context.Background(), "1")
+	if err != nil {
+		t.Fatalf("SearchReplies() error = %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2 (one per page)", requests)
+	}
+	if len(replies) != 2 || replies[0].Text != "正論です" || replies[1].AuthorID != "b" {
+		t.Errorf("SearchReplies() = %+v, want both pages of replies", replies)
+	}
+	if replies[0].CreatedAt.IsZero() {
+		t.Error("SearchReplies() did not parse created_at")
+	}
+}
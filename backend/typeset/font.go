@@ -0,0 +1,77 @@
+// Package typeset loads the font bundled for server-side rendering and lays out
+// wrapped, optionally outlined text onto images.
+package typeset
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+)
+
+// embeddedFonts holds the fonts bundled at build time (see fonts/README.md)
+//
+//go:embed fonts
+var embeddedFonts embed.FS
+
+// MinSize is the smallest font size text is shrunk to before it is truncated
+const MinSize = 12
+
+// Font is a parsed TrueType/OpenType font
+type Font struct {
+	font *opentype.Font
+}
+
+// Parse parses TrueType/OpenType font data. Empty data selects DefaultFont.
+func Parse(data []byte) (*Font, error) {
+	if len(data) == 0 {
+		data = DefaultFont()
+	}
+	f, err := opentype.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+	return &Font{font: f}, nil
+}
+
+// DefaultFont returns the first embedded TrueType/OpenType font, or Go Bold when none is bundled
+func DefaultFont() []byte {
+	var names []string
+	_ = fs.WalkDir(embeddedFonts, "fonts", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		switch strings.ToLower(path.Ext(name)) {
+		case ".ttf", ".otf":
+			names = append(names, name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+
+	for _, name := range names {
+		if data, err := embeddedFonts.ReadFile(name); err == nil {
+			return data
+		}
+	}
+	return gobold.TTF
+}
+
+// Face returns a face of the font at the given pixel size, never smaller than MinSize
+func (f *Font) Face(size float64) (font.Face, error) {
+	face, err := opentype.NewFace(f.font, &opentype.FaceOptions{
+		Size:    max(size, MinSize),
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create font face: %w", err)
+	}
+	return face, nil
+}
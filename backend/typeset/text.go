@@ -0,0 +1,174 @@
+package typeset
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	// shrinkFactor is how much the font size is reduced per attempt when text does not fit
+	shrinkFactor = 0.85
+	// ellipsis is appended to text truncated at the minimum font size
+	ellipsis = "…"
+)
+
+// Align is the horizontal alignment of the lines in a block
+type Align int
+
+const (
+	// AlignLeft starts every line at the block's left edge
+	AlignLeft Align = iota
+	// AlignCenter centers every line in the block's width
+	AlignCenter
+)
+
+// Stroke outlines text for legibility on busy backgrounds
+type Stroke struct {
+	Color color.Color
+	Width int
+}
+
+// Block is text wrapped into lines for a face
+type Block struct {
+	Face     font.Face
+	Size     float64
+	Lines    []string
+	BoxWidth int // Width lines are centered in
+}
+
+// Fit wraps text into at most maxLines lines of maxWidth pixels, shrinking the font
+// from size down to MinSize and truncating with an ellipsis if it still does not fit.
+// A maxLines of zero or less allows any number of lines at the given size.
+func (f *Font) Fit(text string, maxWidth, maxLines int, size float64) (*Block, error) {
+	for {
+		face, err := f.Face(size)
+		if err != nil {
+			return nil, err
+		}
+
+		lines := Wrap(face, text, maxWidth)
+		if maxLines <= 0 || len(lines) <= maxLines || size <= MinSize {
+			if maxLines > 0 && len(lines) > maxLines {
+				lines = lines[:maxLines]
+				lines[maxLines-1] = truncate(face, lines[maxLines-1], maxWidth)
+			}
+			return &Block{Face: face, Size: max(size, MinSize), Lines: lines, BoxWidth: maxWidth}, nil
+		}
+
+		face.Close()
+		size = max(size*shrinkFactor, MinSize)
+	}
+}
+
+// Wrap breaks text into lines no wider than maxWidth. Lines are broken between any two
+// characters, since Japanese has no spaces, but a break inside a Latin word moves to the last space.
+func Wrap(face font.Face, text string, maxWidth int) []string {
+	limit := fixed.I(maxWidth)
+	var lines []string
+
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := []rune{}
+		for _, r := range strings.TrimSpace(paragraph) {
+			if len(line) > 0 && font.MeasureString(face, string(line)+string(r)) > limit {
+				rest := []rune{}
+				if !unicode.IsSpace(r) && r < unicode.MaxLatin1 {
+					if i := lastSpace(line); i > 0 {
+						rest = append(rest, line[i+1:]...)
+						line = line[:i]
+					}
+				}
+				lines = append(lines, strings.TrimSpace(string(line)))
+				line = rest
+			}
+			if len(line) == 0 && unicode.IsSpace(r) {
+				continue
+			}
+			line = append(line, r)
+		}
+		if len(line) > 0 {
+			lines = append(lines, strings.TrimSpace(string(line)))
+		}
+	}
+	return lines
+}
+
+// lastSpace returns the index of the last space in line, or -1
+func lastSpace(line []rune) int {
+	for i := len(line) - 1; i >= 0; i-- {
+		if line[i] == ' ' {
+			return i
+		}
+	}
+	return -1
+}
+
+// truncate shortens line until it fits maxWidth with a trailing ellipsis
+func truncate(face font.Face, line string, maxWidth int) string {
+	runes := []rune(line)
+	for len(runes) > 0 && font.MeasureString(face, string(runes)+ellipsis) > fixed.I(maxWidth) {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + ellipsis
+}
+
+// Height is the pixel height of the block
+func (b *Block) Height() int {
+	return len(b.Lines) * b.Face.Metrics().Height.Ceil()
+}
+
+// Width is the pixel width of the widest line
+func (b *Block) Width() int {
+	widest := 0
+	for _, line := range b.Lines {
+		widest = max(widest, font.MeasureString(b.Face, line).Ceil())
+	}
+	return widest
+}
+
+// Draw renders the block with its top-left corner at (x, y)
+func (b *Block) Draw(dst draw.Image, x, y int, align Align, fill color.Color, outline *Stroke) {
+	metrics := b.Face.Metrics()
+	lineHeight := metrics.Height.Ceil()
+	ascent := metrics.Ascent.Ceil()
+
+	for i, line := range b.Lines {
+		lineX := x
+		if align == AlignCenter {
+			lineX += (b.BoxWidth - font.MeasureString(b.Face, line).Ceil()) / 2
+		}
+		baseline := y + i*lineHeight + ascent
+
+		if outline != nil {
+			for dy := -outline.Width; dy <= outline.Width; dy++ {
+				for dx := -outline.Width; dx <= outline.Width; dx++ {
+					if (dx != 0 || dy != 0) && dx*dx+dy*dy <= outline.Width*outline.Width {
+						DrawString(dst, b.Face, line, lineX+dx, baseline+dy, outline.Color)
+					}
+				}
+			}
+		}
+		DrawString(dst, b.Face, line, lineX, baseline, fill)
+	}
+}
+
+// Close releases the block's font face
+func (b *Block) Close() {
+	b.Face.Close()
+}
+
+// DrawString draws s with its baseline starting at (x, y)
+func DrawString(dst draw.Image, face font.Face, s string, x, y int, c color.Color) {
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(s)
+}
@@ -0,0 +1,99 @@
+package typeset
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+func TestWrap(t *testing.T) {
+	f, err := Parse(nil)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	face, err := f.Face(20)
+	if err != nil {
+		t.Fatalf("Face() error = %v", err)
+	}
+	defer face.Close()
+
+	lines := Wrap(face, "the quick brown fox jumps over the lazy dog", 120)
+	if len(lines) < 2 {
+		t.Fatalf("Wrap() = %q, want several lines", lines)
+	}
+	for _, line := range lines {
+		if font.MeasureString(face, line) > fixed.I(120) {
+			t.Errorf("line %q is wider than 120px", line)
+		}
+		if line[0] == ' ' || line[len(line)-1] == ' ' {
+			t.Errorf("line %q has surrounding spaces", line)
+		}
+	}
+
+	if got := Wrap(face, "first\nsecond", 1000); len(got) != 2 {
+		t.Errorf("Wrap() = %q, want one line per paragraph", got)
+	}
+}
+
+func TestFit(t *testing.T) {
+	f, err := Parse(nil)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	truncated, err := f.Fit("the quick brown fox jumps over the lazy dog", 60, 1, 40)
+	if err != nil {
+		t.Fatalf("Fit() error = %v", err)
+	}
+	defer truncated.Close()
+	if len(truncated.Lines) != 1 || truncated.Size != MinSize {
+		t.Errorf("Fit() = %q at %v, want one truncated line at the minimum size", truncated.Lines, truncated.Size)
+	}
+
+	unlimited, err := f.Fit("the quick brown fox jumps over the lazy dog", 60, 0, 40)
+	if err != nil {
+		t.Fatalf("Fit() error = %v", err)
+	}
+	defer unlimited.Close()
+	if len(unlimited.Lines) < 2 || unlimited.Size != 40 {
+		t.Errorf("Fit() = %q at %v, want several lines at the requested size", unlimited.Lines, unlimited.Size)
+	}
+}
+
+func TestBlock_Draw(t *testing.T) {
+	f, err := Parse(nil)
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	block, err := f.Fit("Hello", 100, 1, 20)
+	if err != nil {
+		t.Fatalf("Fit() error = %v", err)
+	}
+	defer block.Close()
+
+	dst := image.NewRGBA(image.Rect(0, 0, 100, 40))
+	block.Draw(dst, 0, 0, AlignCenter, color.White, &Stroke{Color: color.Black, Width: 1})
+
+	drawn := false
+	for i := 3; i < len(dst.Pix); i += 4 {
+		if dst.Pix[i] != 0 {
+			drawn = true
+			break
+		}
+	}
+	if !drawn {
+		t.Error("Draw() left the image empty")
+	}
+	if block.Width() <= 0 || block.Width() > 100 || block.Height() <= 0 {
+		t.Errorf("block size = %dx%d, want within 100px wide", block.Width(), block.Height())
+	}
+}
+
+func TestParse_InvalidFont(t *testing.T) {
+	if _, err := Parse([]byte("not a font")); err == nil {
+		t.Error("Parse(invalid) succeeded, want error")
+	}
+}
@@ -42,4 +42,11 @@ describe('Apollo Client', () => {
 
     process.env.NEXT_PUBLIC_GRAPHQL_ENDPOINT = originalEnv
   })
+
+  it('should send cookies to the API', () => {
+    const client = createApolloClient()
+    // @ts-expect-error - accessing private property for testing
+    const credentials = client.link?.options?.credentials
+    expect(credentials).toBe('include')
+  })
 })
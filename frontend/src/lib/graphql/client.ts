@@ -7,6 +7,8 @@ import { ApolloClient, InMemoryCache, HttpLink } from '@apollo/client'
 export function createApolloClient(): ApolloClient<unknown> {
   const httpLink = new HttpLink({
     uri: process.env.NEXT_PUBLIC_GRAPHQL_ENDPOINT || 'http://localhost:8080/graphql',
+    // API が別オリジンでも Cookie を送受信する（Twitter 連携の state Cookie に必要）
+    credentials: 'include',
     fetchOptions: {
       timeout: 60000, // 60秒タイムアウト（Gemini APIのレスポンス待ち用）
     },